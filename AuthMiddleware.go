@@ -2,7 +2,6 @@ package main
 
 import (
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +12,12 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		authHeader := c.GetHeader("Authorization")
+
+		// Browsers can't set headers on a WebSocket handshake or an EventSource
+		if authHeader == "" && (isWebSocketUpgrade(c) || isEventStream(c)) && c.Query("access_token") != "" {
+			authHeader = "Bearer " + c.Query("access_token")
+		}
+
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
 			c.Abort()
@@ -27,22 +32,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			secret = "defaultsecret"
-		}
-
-		// Parse token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signing method"})
-				c.Abort()
-				return nil, nil
-			}
-			return []byte(secret), nil
-		})
 
+		// Parse token; the kid header selects the key from the keyset
+		token, err := jwt.Parse(tokenString, jwtKeyFunc)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
 			c.Abort()
@@ -59,8 +51,24 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		userID := uint(claims["user_id"].(float64))
 
+		// Tokens issued before sessions existed carry no sid
+		if sid, ok := claims["sid"].(float64); ok {
+			if err := TouchSession(uint(sid), userID); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+				c.Abort()
+				return
+			}
+			c.Set("session_id", uint(sid))
+		}
+
 		// Attach user ID to context
 		c.Set("user_id", userID)
+		if _, ok := claims["sid"]; !ok {
+			useDeprecated(c, DeprecationSessionlessToken)
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("token_expires_at", exp.Time)
+		}
 
 		c.Next()
 	}
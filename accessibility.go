@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Events and venues (organization resources of kind "room") describe what
+// they offer people with access needs. Each feature is yes, no, or not
+// stated (null): "no wheelchair access" is worth knowing and isn't the same
+// as nobody having said. Booking a room fills in what the event hasn't
+// stated from the room. Listings filter with ?accessibility=, a comma
+// separated list of features the event must offer.
+
+const maxAccessibilityNotes = 1000
+
+// Accessibility is stored inline on its owner, in a11y_ columns.
+type Accessibility struct {
+	WheelchairAccess  *bool  `json:"wheelchair_access"`
+	SignLanguage      *bool  `json:"sign_language"`      // interpretation provided
+	AccessibleParking *bool  `json:"accessible_parking"` // reserved spaces near the entrance
+	DietaryOptions    *bool  `json:"dietary_options"`    // dietary requirements catered for
+	Notes             string `json:"notes,omitempty"`    // e.g. "step-free entrance on Elm St"
+}
+
+// accessibilityFeatures maps the feature names ?accessibility= accepts to
+// their columns.
+var accessibilityFeatures = map[string]string{
+	"wheelchair_access":  "a11y_wheelchair_access",
+	"sign_language":      "a11y_sign_language",
+	"accessible_parking": "a11y_accessible_parking",
+	"dietary_options":    "a11y_dietary_options",
+}
+
+// accessibilityFeatureNames lists the features in a fixed order.
+var accessibilityFeatureNames = []string{"wheelchair_access", "sign_language", "accessible_parking", "dietary_options"}
+
+func (a *Accessibility) validate() error {
+	a.Notes = strings.TrimSpace(a.Notes)
+	if utf8.RuneCountInString(a.Notes) > maxAccessibilityNotes {
+		return errors.New("accessibility notes must be at most 1000 characters")
+	}
+	return nil
+}
+
+// stated is true once anything is known.
+func (a Accessibility) stated() bool {
+	return a.WheelchairAccess != nil || a.SignLanguage != nil || a.AccessibleParking != nil ||
+		a.DietaryOptions != nil || a.Notes != ""
+}
+
+// filledFrom fills the features a leaves unstated from venue's.
+func (a Accessibility) filledFrom(venue Accessibility) Accessibility {
+	if a.WheelchairAccess == nil {
+		a.WheelchairAccess = venue.WheelchairAccess
+	}
+	if a.SignLanguage == nil {
+		a.SignLanguage = venue.SignLanguage
+	}
+	if a.AccessibleParking == nil {
+		a.AccessibleParking = venue.AccessibleParking
+	}
+	if a.DietaryOptions == nil {
+		a.DietaryOptions = venue.DietaryOptions
+	}
+	if a.Notes == "" {
+		a.Notes = venue.Notes
+	}
+	return a
+}
+
+// columns are the updates that store a on its owner.
+func (a Accessibility) columns() map[string]interface{} {
+	return map[string]interface{}{
+		"a11y_wheelchair_access":  a.WheelchairAccess,
+		"a11y_sign_language":      a.SignLanguage,
+		"a11y_accessible_parking": a.AccessibleParking,
+		"a11y_dietary_options":    a.DietaryOptions,
+		"a11y_notes":              a.Notes,
+	}
+}
+
+// withAccessibility narrows a query on table to rows offering every
+// feature in raw, a comma separated list.
+func withAccessibility(q *gorm.DB, table, raw string) (*gorm.DB, error) {
+	for _, f := range strings.Split(raw, ",") {
+		col, ok := accessibilityFeatures[strings.TrimSpace(f)]
+		if !ok {
+			return nil, errors.New("accessibility must list features from: " + strings.Join(accessibilityFeatureNames, ", "))
+		}
+		q = q.Where(table + "." + col + " = TRUE")
+	}
+	return q, nil
+}
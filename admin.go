@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromoteConfiguredAdmins flags every user listed in ADMIN_EMAILS as admin.
+func PromoteConfiguredAdmins() {
+	if len(AppConfig.AdminEmails) == 0 {
+		return
+	}
+	emails := make([]string, 0, len(AppConfig.AdminEmails))
+	for _, e := range AppConfig.AdminEmails {
+		emails = append(emails, strings.ToLower(e))
+	}
+	if err := DB.Model(&User{}).Where("LOWER(email) IN ?", emails).Update("is_admin", true).Error; err != nil {
+		log.Printf("⚠️ could not promote admins: %v", err)
+	}
+}
+
+// RequireAdmin must run after AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			jsonError(c, http.StatusUnauthorized, "unauthorized")
+			c.Abort()
+			return
+		}
+
+		var user User
+		if err := DB.Select("id", "is_admin").First(&user, userID).Error; err != nil || !user.IsAdmin {
+			jsonError(c, http.StatusForbidden, "admin access required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Audit appends an entry to the audit log. details is stored as JSON.
+func Audit(actorID uint, action, targetType string, targetID uint, details gin.H) {
+	entry := AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+	if details != nil {
+		if raw, err := json.Marshal(details); err == nil {
+			entry.Details = string(raw)
+		}
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		log.Printf("⚠️ could not write audit log (%s): %v", action, err)
+	}
+}
+
+func GetAuditLog(c *gin.Context) {
+	query := DB.Model(&AuditLog{})
+	if t := c.Query("target_type"); t != "" {
+		query = query.Where("target_type = ?", t)
+	}
+	if id := c.Query("target_id"); id != "" {
+		targetID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid target_id")
+			return
+		}
+		query = query.Where("target_id = ?", targetID)
+	}
+	if a := c.Query("action"); a != "" {
+		query = query.Where("action = ?", a)
+	}
+
+	var entries []AuditLog
+	if err := query.Order("created_at desc").Limit(200).Find(&entries).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	AudienceAll      = "all"
+	AudienceGoing    = "going"
+	AudiencePending  = "pending" // answered Maybe or not at all
+	AudienceWaitlist = "waitlist"
+)
+
+// audiences are the values CreateAnnouncementRequest.Audience accepts.
+var audiences = []string{AudienceAll, AudienceGoing, AudiencePending, AudienceWaitlist}
+
+// audienceStatuses are the attendee statuses each targeted audience covers.
+var audienceStatuses = map[string][]string{
+	AudienceGoing:    {"Going"},
+	AudiencePending:  {"Maybe", ""},
+	AudienceWaitlist: {StatusWaitlisted},
+}
+
+type CreateAnnouncementRequest struct {
+	Title    string `json:"title" binding:"required"`
+	Body     string `json:"body"`
+	Audience string `json:"audience"` // "all" (default), "going", "pending", "waitlist" or "label:<label>"
+}
+
+// visibleAudiences lists the audiences a non-organizer with the given
+// attendee status belongs to; everyone gets AudienceAll.
+func visibleAudiences(status string, isAttendee bool) []string {
+	out := []string{AudienceAll}
+	if !isAttendee {
+		return out
+	}
+	for audience, statuses := range audienceStatuses {
+		for _, s := range statuses {
+			if s == status {
+				out = append(out, audience)
+			}
+		}
+	}
+	return out
+}
+
+// viewerAudiences is visibleAudiences for userID's current status on the
+// event, plus the label audiences they belong to.
+func viewerAudiences(eventID, userID uint) []string {
+	var att EventAttendee
+	if err := DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&att).Error; err != nil {
+		return visibleAudiences("", false)
+	}
+	out := visibleAudiences(att.Status, true)
+	for _, l := range attendeeLabels(att) {
+		out = append(out, AudienceLabelPrefix+l)
+	}
+	return out
+}
+
+func CreateAnnouncement(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, eventID64).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only organizers can post announcements")
+		return
+	}
+
+	var body CreateAnnouncementRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	if body.Audience == "" {
+		body.Audience = AudienceAll
+	}
+	if label, ok := strings.CutPrefix(body.Audience, AudienceLabelPrefix); ok {
+		l, err := normalizeLabel(label)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		body.Audience = AudienceLabelPrefix + l
+	} else if !slices.Contains(audiences, body.Audience) {
+		jsonError(c, http.StatusBadRequest, "audience must be one of: "+strings.Join(audiences, ", ")+" or label:<label>")
+		return
+	}
+
+	a := Announcement{
+		EventID:  ev.ID,
+		AuthorID: userID,
+		Title:    strings.TrimSpace(body.Title),
+		Body:     body.Body,
+		Audience: body.Audience,
+	}
+	if err := publishAnnouncement(ev, &a); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create announcement: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcementView(a))
+}
+
+// publishAnnouncement saves the announcement and sends it to its audience.
+func publishAnnouncement(ev Event, a *Announcement) error {
+	if err := DB.Create(a).Error; err != nil {
+		return err
+	}
+
+	queueLinkPreviews(a.Body)
+	recordChange(DB, EntityAnnouncement, a.ID, ev.ID, ChangeUpsert)
+
+	q := DB.Model(&EventAttendee{}).Where("event_id = ? AND user_id <> ?", ev.ID, a.AuthorID)
+	if statuses, ok := audienceStatuses[a.Audience]; ok {
+		q = q.Where("status IN ?", statuses)
+	} else if label, ok := strings.CutPrefix(a.Audience, AudienceLabelPrefix); ok {
+		q = withLabel(q, label)
+	}
+	var recipients []uint
+	q.Pluck("user_id", &recipients)
+	for _, uid := range recipients {
+		NotifyTemplate(uid, "announcement", map[string]string{"EventTitle": ev.Title, "Title": a.Title, "Body": a.Body},
+			gin.H{"event_id": ev.ID, "announcement_id": a.ID})
+	}
+	msg := WSMessage{Type: "announcement", EventID: ev.ID, Data: announcementView(*a)}
+	if a.Audience == AudienceAll {
+		RealtimeHub.BroadcastToEvent(ev.ID, msg)
+	} else {
+		RealtimeHub.BroadcastToEventUsers(ev.ID, append(recipients, eventOrganizerIDs(ev)...), msg)
+	}
+	return nil
+}
+
+func GetAnnouncements(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, eventID64).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+	if !canViewEvent(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can view announcements")
+		return
+	}
+
+	// targeted announcements only show to their audience
+	q := DB.Where("event_id = ?", ev.ID)
+	if !isEventOrganizer(ev, userID) {
+		q = q.Where("audience IN ?", viewerAudiences(ev.ID, userID))
+	}
+	var list []Announcement
+	if err := q.Order("created_at desc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	out := make([]AnnouncementView, 0, len(list))
+	for _, a := range list {
+		out = append(out, announcementView(a))
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A periodic job watches upcoming events for signals an organizer would
+// want to hear about before it is too late to act: a wave of declines, an
+// RSVP rate far below what the organizer's past events had at the same
+// point, or check-ins lagging once the doors are open. Each alert is
+// recorded once per event and period, so reruns and several instances
+// don't repeat it.
+
+const (
+	AlertDeclineWave = "decline_wave"
+	AlertLowRSVPRate = "low_rsvp_rate"
+	AlertLowCheckIns = "low_check_ins"
+
+	// declines within declineWaveWindow that count as a wave: at least
+	// declineWaveMin and declineWaveShare of the invitees
+	declineWaveWindow = time.Hour
+	declineWaveMin    = 5
+	declineWaveShare  = 0.2
+
+	// RSVP rates are compared with past events over the last two weeks
+	// before the start, once enough people are invited to mean something
+	rsvpLookahead     = 14 * 24 * time.Hour
+	rsvpMinInvited    = 10
+	rsvpMinPastEvents = 3
+	rsvpLowRatio      = 0.5 // alert below half the usual rate
+
+	// check-ins are judged from checkInGrace after the start for checkInWatch
+	checkInGrace      = 30 * time.Minute
+	checkInWatch      = 6 * time.Hour
+	checkInMinGoing   = 10
+	checkInLowRatio   = 0.5
+	checkInFallback   = 0.6 // expected rate when there is no history
+	checkInPastEvents = 10
+)
+
+// EventAlert is an anomaly raised on an event. Period tells repeats of the
+// same kind apart (the hour of a decline wave, the day of check-ins).
+type EventAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"uniqueIndex:idx_event_alert;not null"`
+	Kind      string    `json:"kind" gorm:"type:varchar(32);uniqueIndex:idx_event_alert;not null"`
+	Period    string    `json:"period" gorm:"type:varchar(32);uniqueIndex:idx_event_alert;not null;default:''"`
+	Summary   string    `json:"summary" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// raiseAlert records the alert and tells the organizer, unless this
+// period's alert was already raised.
+func raiseAlert(ev Event, kind, period, summary string) {
+	alert := EventAlert{EventID: ev.ID, Kind: kind, Period: period, Summary: summary}
+	res := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+	if res.Error != nil {
+		log.Printf("⚠️ could not record %s alert for event %d: %v", kind, ev.ID, res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	NotifyTemplate(ev.OrganizerID, "event_anomaly",
+		map[string]string{"EventTitle": ev.Title, "Summary": summary},
+		gin.H{"event_id": ev.ID, "alert_id": alert.ID, "kind": kind})
+}
+
+// invitees are the attendee rows an RSVP rate is measured over.
+func invitees(tx *gorm.DB, eventID uint) *gorm.DB {
+	return tx.Model(&EventAttendee{}).Where("event_id = ? AND role <> ?", eventID, "organizer")
+}
+
+// checkDeclineWave alerts when many invitees declined within the window.
+func checkDeclineWave(ev Event, now time.Time) {
+	var invited, declined int64
+	invitees(DB, ev.ID).Count(&invited)
+	invitees(DB, ev.ID).Where("status = ? AND updated_at >= ?", "Not Going", now.Add(-declineWaveWindow)).Count(&declined)
+	if declined < declineWaveMin || float64(declined) < declineWaveShare*float64(invited) {
+		return
+	}
+	period := now.Truncate(declineWaveWindow).UTC().Format(time.RFC3339)
+	raiseAlert(ev, AlertDeclineWave, period,
+		fmt.Sprintf("%d of %d invitees declined in the last hour", declined, invited))
+}
+
+// responseRate is the share of an event's invitees who had answered by
+// the given time.
+func responseRate(eventID uint, by time.Time) (float64, int64) {
+	var invited, responded int64
+	invitees(DB, eventID).Where("created_at <= ?", by).Count(&invited)
+	if invited == 0 {
+		return 0, 0
+	}
+	invitees(DB, eventID).Where("created_at <= ? AND status <> '' AND COALESCE(responded_at, updated_at) <= ?", by, by).
+		Count(&responded)
+	return float64(responded) / float64(invited), invited
+}
+
+// checkRSVPRate compares the event's RSVP rate with the organizer's past
+// events at the same time before their start.
+func checkRSVPRate(ev Event, now time.Time) {
+	lead := ev.Date.Sub(now)
+	rate, invited := responseRate(ev.ID, now)
+	if invited < rsvpMinInvited {
+		return
+	}
+
+	var past []Event
+	DB.Where("organizer_id = ? AND id <> ? AND date < ? AND cancelled_at IS NULL", ev.OrganizerID, ev.ID, now).
+		Order("date desc").Limit(10).Find(&past)
+	var sum float64
+	var n int
+	for _, p := range past {
+		r, pastInvited := responseRate(p.ID, p.Date.Add(-lead))
+		if pastInvited < rsvpMinInvited {
+			continue
+		}
+		sum += r
+		n++
+	}
+	if n < rsvpMinPastEvents {
+		return
+	}
+	usual := sum / float64(n)
+	if rate >= rsvpLowRatio*usual {
+		return
+	}
+	raiseAlert(ev, AlertLowRSVPRate, "",
+		fmt.Sprintf("%.0f%% of invitees have answered; your past events had %.0f%% at this point", 100*rate, 100*usual))
+}
+
+// checkInRate is the share of going attendees who checked in.
+func checkInRate(eventID uint) (float64, int64) {
+	var going, checkedIn int64
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND status = ?", eventID, "Going").Count(&going)
+	if going == 0 {
+		return 0, 0
+	}
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND status = ? AND checked_in_at IS NOT NULL", eventID, "Going").Count(&checkedIn)
+	return float64(checkedIn) / float64(going), going
+}
+
+// checkCheckIns alerts when, some time after the start, far fewer people
+// have checked in than at the organizer's past events that used check-in.
+func checkCheckIns(ev Event, now time.Time) {
+	rate, going := checkInRate(ev.ID)
+	if going < checkInMinGoing {
+		return
+	}
+
+	var past []uint
+	DB.Model(&EventAttendee{}).Distinct("event_attendees.event_id").
+		Joins("JOIN events past ON past.id = event_attendees.event_id AND past.deleted_at IS NULL").
+		Where("past.organizer_id = ? AND past.id <> ? AND past.date < ? AND event_attendees.checked_in_at IS NOT NULL", ev.OrganizerID, ev.ID, ev.Date).
+		Limit(checkInPastEvents).Pluck("event_attendees.event_id", &past)
+	expected := checkInFallback
+	if len(past) > 0 {
+		var sum float64
+		for _, id := range past {
+			r, _ := checkInRate(id)
+			sum += r
+		}
+		expected = sum / float64(len(past))
+	}
+	if rate >= checkInLowRatio*expected {
+		return
+	}
+	raiseAlert(ev, AlertLowCheckIns, ev.Date.UTC().Format(time.DateOnly),
+		fmt.Sprintf("%.0f%% of attendees have checked in %d minutes after the start; %.0f%% is usual",
+			100*rate, int(now.Sub(ev.Date).Minutes()), 100*expected))
+}
+
+// DetectEventAnomalies looks for unusual signals on events that are coming
+// up or under way.
+func DetectEventAnomalies(ctx context.Context) {
+	now := time.Now()
+	var events []Event
+	if err := DB.WithContext(ctx).
+		Where("cancelled_at IS NULL AND archived_at IS NULL AND date > ? AND date <= ?", now.Add(-checkInWatch), now.Add(rsvpLookahead)).
+		Find(&events).Error; err != nil {
+		log.Printf("⚠️ anomaly detection failed: %v", err)
+		return
+	}
+
+	for _, ev := range events {
+		if ctx.Err() != nil {
+			return
+		}
+		if ev.Date.After(now) {
+			checkDeclineWave(ev, now)
+			checkRSVPRate(ev, now)
+		} else if now.Sub(ev.Date) >= checkInGrace {
+			checkCheckIns(ev, now)
+		}
+	}
+}
+
+// GetEventAlerts lists the anomalies raised on an event, newest first.
+func GetEventAlerts(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	alerts := []EventAlert{}
+	if err := DB.Where("event_id = ?", ev.ID).Order("created_at desc").Find(&alerts).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "alerts": alerts})
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Participants share files on an event, or on one of its tasks: floor
+// plans, contracts, the run sheet. Only types we can recognise from their
+// content are accepted, and downloads go through short-lived signed URLs
+// handed out to participants only.
+
+// attachmentTypes maps sniffed content types to the extension used when
+// the upload's name has none.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+// officeTypes are the zip-based document formats, told apart from plain
+// zips by their extension.
+var officeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+}
+
+// attachmentType checks the upload is a type we accept and returns the
+// content type to serve it with and the extension it should have.
+func attachmentType(data []byte, ext string) (string, string, bool) {
+	sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	defaultExt, ok := attachmentTypes[sniffed]
+	if !ok {
+		return "", "", false
+	}
+	if ext == "" || len(ext) > 16 {
+		ext = defaultExt
+	}
+	switch sniffed {
+	case "application/zip":
+		if office, ok := officeTypes[ext]; ok {
+			return office, ext, true
+		}
+	case "text/plain":
+		return "text/plain; charset=utf-8", ext, true
+	}
+	return sniffed, ext, true
+}
+
+// cleanFileName keeps the base name of an upload without control
+// characters, falling back to "file".
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '/' {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." {
+		return "file"
+	}
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+type AttachmentView struct {
+	Attachment
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// attachmentViews signs a download URL for each attachment.
+func attachmentViews(list []Attachment) []AttachmentView {
+	expires := signedURLExpiry()
+	out := make([]AttachmentView, 0, len(list))
+	for _, a := range list {
+		v := AttachmentView{Attachment: a, URLExpiresAt: expires}
+		v.URL, _ = Storage.URL(a.StorageKey, Download{
+			FileName:    a.FileName,
+			ContentType: a.ContentType,
+			Inline:      strings.HasPrefix(a.ContentType, "image/") || a.ContentType == "application/pdf",
+		}, expires)
+		out = append(out, v)
+	}
+	return out
+}
+
+// removeAttachment deletes the attachment's file and releases its storage,
+// once its row is gone.
+func removeAttachment(a Attachment) {
+	removeStoredFile(a.StorageKey)
+	reserveStorage(a.UploadedByID, -a.Size)
+}
+
+// loadParticipantEvent resolves :id for participants only: files aren't
+// shown to people who could merely find the event.
+func loadParticipantEvent(c *gin.Context, userID uint) (Event, bool) {
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return ev, false
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can see attachments")
+		return ev, false
+	}
+	return ev, true
+}
+
+// readAttachmentUpload reads the multipart "file" of an attachment-like
+// upload, checking its size and type, and returns its cleaned name,
+// content, content type and extension.
+func readAttachmentUpload(c *gin.Context) (string, []byte, string, string, bool) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "file is required")
+		return "", nil, "", "", false
+	}
+	if fh.Size > AppConfig.AttachmentMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":     "attachment too large",
+			"max_bytes": AppConfig.AttachmentMaxBytes,
+		})
+		return "", nil, "", "", false
+	}
+	f, err := fh.Open()
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return "", nil, "", "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return "", nil, "", "", false
+	}
+
+	name := cleanFileName(fh.Filename)
+	contentType, ext, allowed := attachmentType(data, strings.ToLower(filepath.Ext(name)))
+	if !allowed {
+		jsonError(c, http.StatusUnsupportedMediaType, "attachments must be images, PDFs, text, zip or office documents")
+		return "", nil, "", "", false
+	}
+	return name, data, contentType, ext, true
+}
+
+// UploadAttachment stores a multipart "file" on the event, or on the task
+// given as "task_id". Organizers can attach to any task, other
+// participants only to tasks assigned to them.
+func UploadAttachment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadParticipantEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var taskID *uint
+	if raw := c.PostForm("task_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid task_id")
+			return
+		}
+		var task Task
+		if err := DB.Where("id = ? AND event_id = ?", id, ev.ID).First(&task).Error; err != nil {
+			jsonError(c, http.StatusNotFound, "task not found")
+			return
+		}
+		if !isEventOrganizer(ev, userID) && (task.AssigneeID == nil || *task.AssigneeID != userID) {
+			jsonError(c, http.StatusForbidden, "only organizers and the assignee can attach files to this task")
+			return
+		}
+		taskID = &task.ID
+	}
+
+	name, data, contentType, ext, ok := readAttachmentUpload(c)
+	if !ok {
+		return
+	}
+
+	size := int64(len(data))
+	if err := reserveStorage(userID, size); err != nil {
+		quotaError(c, "storage", effectiveLimits(userID).StorageQuotaBytes)
+		return
+	}
+	key, err := newStorageKey(fmt.Sprintf("attachments/%d", ev.ID), ext)
+	if err == nil {
+		err = Storage.Put(c.Request.Context(), key, data, contentType)
+	}
+	if err != nil {
+		reserveStorage(userID, -size)
+		jsonError(c, http.StatusInternalServerError, "could not store attachment: "+err.Error())
+		return
+	}
+
+	a := Attachment{
+		EventID:      ev.ID,
+		TaskID:       taskID,
+		UploadedByID: userID,
+		FileName:     name,
+		ContentType:  contentType,
+		Size:         size,
+		StorageKey:   key,
+	}
+	if err := DB.Create(&a).Error; err != nil {
+		removeAttachment(a)
+		jsonError(c, http.StatusInternalServerError, "could not save attachment: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachmentViews([]Attachment{a})[0])
+}
+
+// GetEventAttachments lists the event's attachments, newest first, with
+// download URLs. ?task_id= narrows it to one task's.
+func GetEventAttachments(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadParticipantEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	q := DB.Model(&Attachment{}).Where("event_id = ?", ev.ID)
+	if raw := c.Query("task_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid task_id")
+			return
+		}
+		q = q.Where("task_id = ?", id)
+	}
+	query, page, ok := paginate(c, q)
+	if !ok {
+		return
+	}
+	var list []Attachment
+	if err := query.Order("created_at desc, id desc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, attachmentViews(list), len(list))
+}
+
+func loadEventAttachment(c *gin.Context, ev Event) (Attachment, bool) {
+	var a Attachment
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("attachmentId"), ev.ID).First(&a).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "attachment not found")
+		return a, false
+	}
+	return a, true
+}
+
+// GetEventAttachment returns one attachment with a fresh download URL.
+func GetEventAttachment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadParticipantEvent(c, userID)
+	if !ok {
+		return
+	}
+	a, ok := loadEventAttachment(c, ev)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, attachmentViews([]Attachment{a})[0])
+}
+
+// DeleteEventAttachment removes an attachment; its uploader or an
+// organizer may.
+func DeleteEventAttachment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadParticipantEvent(c, userID)
+	if !ok {
+		return
+	}
+	a, ok := loadEventAttachment(c, ev)
+	if !ok {
+		return
+	}
+	if a.UploadedByID != userID && !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only the uploader or an organizer can delete this attachment")
+		return
+	}
+
+	if err := DB.Delete(&a).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete attachment: "+err.Error())
+		return
+	}
+	removeAttachment(a)
+	if a.UploadedByID != userID {
+		Audit(userID, "attachment.delete", "attachment", a.ID, gin.H{"event_id": ev.ID, "file_name": a.FileName})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "attachment deleted"})
+}
+
+// eventFiles are the stored files of deleted rows, removed once the
+// deleting transaction has committed.
+type eventFiles struct {
+	receipts    []Expense
+	attachments []Attachment
+	covers      []Event
+	contracts   []ContractVersion
+}
+
+func (f *eventFiles) add(other eventFiles) {
+	f.receipts = append(f.receipts, other.receipts...)
+	f.attachments = append(f.attachments, other.attachments...)
+	f.covers = append(f.covers, other.covers...)
+	f.contracts = append(f.contracts, other.contracts...)
+}
+
+func (f eventFiles) remove() {
+	for _, e := range f.receipts {
+		removeReceipt(e)
+	}
+	for _, a := range f.attachments {
+		removeAttachment(a)
+	}
+	for _, ev := range f.covers {
+		removeCover(ev.OrganizerID, ev.CoverKey, ev.CoverSize)
+	}
+	for _, v := range f.contracts {
+		removeContractVersion(v)
+	}
+}
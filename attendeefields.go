@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	fieldTypes = []string{"text", "number", "email", "boolean", "select"}
+	reFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+)
+
+func (f EventField) options() []string {
+	out := []string{}
+	for _, o := range strings.Split(f.Options, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// normalizeAnswer validates a raw JSON value against the field type and
+// returns its stored string form.
+func normalizeAnswer(f EventField, v interface{}) (string, error) {
+	switch f.Type {
+	case "number":
+		switch n := v.(type) {
+		case float64:
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		case string:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+				return strings.TrimSpace(n), nil
+			}
+		}
+		return "", fmt.Errorf("%s must be a number", f.Key)
+	case "boolean":
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("%s must be true or false", f.Key)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", f.Key)
+	}
+	s = strings.TrimSpace(s)
+	if len(s) > 500 {
+		return "", fmt.Errorf("%s is too long", f.Key)
+	}
+
+	switch f.Type {
+	case "email":
+		if s != "" {
+			if _, err := mail.ParseAddress(s); err != nil {
+				return "", fmt.Errorf("%s must be an email address", f.Key)
+			}
+		}
+	case "select":
+		if s != "" {
+			valid := false
+			for _, o := range f.options() {
+				if o == s {
+					valid = true
+				}
+			}
+			if !valid {
+				return "", fmt.Errorf("%s must be one of: %s", f.Key, strings.Join(f.options(), ", "))
+			}
+		}
+	}
+	return s, nil
+}
+
+// validateAnswers checks submitted answers and, when requireAll is set,
+// that every required field ends up answered (submitted or stored earlier).
+func validateAnswers(eventID, attendeeID uint, submitted map[string]interface{}, requireAll bool) (map[uint]string, error) {
+	var fields []EventField
+	DB.Where("event_id = ?", eventID).Find(&fields)
+
+	stored := map[uint]string{}
+	if attendeeID != 0 {
+		var existing []AttendeeAnswer
+		DB.Where("attendee_id = ?", attendeeID).Find(&existing)
+		for _, a := range existing {
+			stored[a.FieldID] = a.Value
+		}
+	}
+
+	known := map[string]bool{}
+	out := map[uint]string{}
+	for _, f := range fields {
+		known[f.Key] = true
+		if raw, ok := submitted[f.Key]; ok && raw != nil {
+			v, err := normalizeAnswer(f, raw)
+			if err != nil {
+				return nil, err
+			}
+			out[f.ID] = v
+		}
+		if requireAll && f.Required {
+			v, ok := out[f.ID]
+			if !ok {
+				v = stored[f.ID]
+			}
+			if v == "" {
+				return nil, fmt.Errorf("%s is required", f.Key)
+			}
+		}
+	}
+	for k := range submitted {
+		if !known[k] {
+			return nil, fmt.Errorf("unknown field %s", k)
+		}
+	}
+	return out, nil
+}
+
+func saveAnswers(tx *gorm.DB, attendeeID uint, answers map[uint]string) error {
+	for fieldID, v := range answers {
+		a := AttendeeAnswer{AttendeeID: attendeeID, FieldID: fieldID, Value: v}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "attendee_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&a).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOrganizedEvent loads :id and checks the caller organizes it,
+// writing the error response itself when it returns false.
+func loadOrganizedEvent(c *gin.Context, userID uint) (Event, bool) {
+	var ev Event
+	eventID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return ev, false
+	}
+	if err := DB.First(&ev, eventID64).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			eventNotFound(c, uint(eventID64))
+			return ev, false
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return ev, false
+	}
+	if !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only organizers can do this")
+		return ev, false
+	}
+	return ev, true
+}
+
+// ========================
+// FIELD DEFINITIONS
+// ========================
+
+type EventFieldRequest struct {
+	Key      string `json:"key" binding:"required"`
+	Label    string `json:"label" binding:"required"`
+	Type     string `json:"type" binding:"required"`
+	Options  string `json:"options"`
+	Required bool   `json:"required"`
+	Position int    `json:"position"`
+}
+
+func (r EventFieldRequest) validate() error {
+	if !reFieldKey.MatchString(r.Key) {
+		return fmt.Errorf("key must be lowercase letters, digits or underscores")
+	}
+	if !slices.Contains(fieldTypes, r.Type) {
+		return fmt.Errorf("type must be one of: %s", strings.Join(fieldTypes, ", "))
+	}
+	if r.Type == "select" && strings.TrimSpace(r.Options) == "" {
+		return fmt.Errorf("select fields need options")
+	}
+	return nil
+}
+
+func GetEventFields(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, c.Param("id")).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+	if !canViewEvent(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can view fields")
+		return
+	}
+
+	var fields []EventField
+	if err := DB.Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&fields).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+func CreateEventField(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body EventFieldRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f := EventField{
+		EventID:  ev.ID,
+		Key:      body.Key,
+		Label:    strings.TrimSpace(body.Label),
+		Type:     body.Type,
+		Options:  body.Options,
+		Required: body.Required,
+		Position: body.Position,
+	}
+	if err := DB.Create(&f).Error; err != nil {
+		jsonError(c, http.StatusConflict, "a field with this key already exists")
+		return
+	}
+
+	c.JSON(http.StatusCreated, f)
+}
+
+func UpdateEventField(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var f EventField
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("fieldId"), ev.ID).First(&f).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "field not found")
+		return
+	}
+
+	var body EventFieldRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.Key = body.Key
+	f.Label = strings.TrimSpace(body.Label)
+	f.Type = body.Type
+	f.Options = body.Options
+	f.Required = body.Required
+	f.Position = body.Position
+	if err := DB.Save(&f).Error; err != nil {
+		jsonError(c, http.StatusConflict, "could not update field: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+func DeleteEventField(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var f EventField
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("fieldId"), ev.ID).First(&f).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "field not found")
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", f.ID).Delete(&AttendeeAnswer{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&f).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "field deleted"})
+}
+
+// ========================
+// CSV EXPORT
+// ========================
+
+// ExportAttendeesCSV writes one row per attendee with a column per custom field.
+func ExportAttendeesCSV(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	label, ok := labelParam(c)
+	if !ok {
+		return
+	}
+	q := DB.Where("event_id = ?", ev.ID)
+	if label != "" {
+		q = withLabel(q, label)
+	}
+
+	var attendees []EventAttendee
+	if err := q.Order("id asc").Find(&attendees).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	var fields []EventField
+	DB.Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&fields)
+
+	attendeeIDs := make([]uint, 0, len(attendees))
+	userIDs := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		attendeeIDs = append(attendeeIDs, a.ID)
+		userIDs = append(userIDs, a.UserID)
+	}
+
+	emails := map[uint]string{}
+	var users []User
+	DB.Select("id", "email").Where("id IN ?", userIDs).Find(&users)
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+
+	answers := map[uint]map[uint]string{}
+	var rows []AttendeeAnswer
+	DB.Where("attendee_id IN ?", attendeeIDs).Find(&rows)
+	for _, a := range rows {
+		if answers[a.AttendeeID] == nil {
+			answers[a.AttendeeID] = map[uint]string{}
+		}
+		answers[a.AttendeeID][a.FieldID] = a.Value
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-attendees.csv"`, ev.ID))
+
+	w := csv.NewWriter(c.Writer)
+	header := []string{"user_id", "email", "role", "status", "labels"}
+	for _, f := range fields {
+		header = append(header, csvCell(f.Label))
+	}
+	w.Write(header)
+
+	for _, a := range attendees {
+		row := []string{strconv.FormatUint(uint64(a.UserID), 10), csvCell(emails[a.UserID]), a.Role, a.Status, csvCell(strings.Join(attendeeLabels(a), ", "))}
+		for _, f := range fields {
+			row = append(row, csvCell(answers[a.ID][f.ID]))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+}
+
+// csvCell defuses text that spreadsheets would run as a formula, such as
+// an answer of "=HYPERLINK(...)", by prefixing it with a quote.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
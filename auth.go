@@ -1,27 +1,55 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"time"
-	"os"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(userID uint) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "defaultsecret"
+// Access tokens are short-lived; clients renew them with their refresh
+// token (see refresh.go), which keeps the session going.
+const tokenTTL = 15 * time.Minute
+
+const minPasswordLength = 8
+
+// validatePassword checks a new password, at signup or reset.
+func validatePassword(pw string) error {
+	if utf8.RuneCountInString(pw) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// hashToken is used to store bearer-style secrets without keeping them in clear
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes, hex encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(b), nil
+}
 
+func GenerateToken(userID, sessionID uint) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"sid":     sessionID,
+		"exp":     time.Now().Add(tokenTTL).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return signToken(claims)
 }
 
 // ========================
@@ -29,24 +57,35 @@ func GenerateToken(userID uint) (string, error) {
 // ========================
 
 func Signup(c *gin.Context) {
-	var user User
+	var req LoginRequest
+
+	// bind only credentials so clients can't set admin/suspension fields
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+
+	if cfg, ok := ssoConfigForEmail(req.Email); ok {
+		ssoRequiredError(c, cfg)
+		return
+	}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := validatePassword(req.Password); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// passwords are stored as given; they aren't hashed yet
+	user := User{Email: req.Email, Password: req.Password}
+
 	if err := DB.Create(&user).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User already exists"})
 		return
 	}
 
-	// Remove password from response
-	user.Password = ""
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Signup successful",
-		"user":    user,
+		"user":    profileView(user),
 	})
 }
 
@@ -58,8 +97,14 @@ func Login(c *gin.Context) {
 	var req LoginRequest
 	var user User
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+
+	// org-managed domains sign in through their identity provider
+	if cfg, ok := ssoConfigForEmail(req.Email); ok {
+		ssoRequiredError(c, cfg)
 		return
 	}
 
@@ -74,11 +119,16 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := GenerateToken(user.ID)
+	if s := activeSuspension(user.ID); s != nil && s.Mode == SuspensionBanned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account banned", "reason": s.Reason, "expires_at": s.ExpiresAt})
+		return
+	}
+
+	tokens, err := startSessionTokens(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// Printable name badges for an event's confirmed attendees, laid out on
+// sticker sheets. The QR code carries the attendee ID the check-in kiosk
+// takes (see kiosk.go).
+
+const maxBadges = 2000
+
+// badgeLayout is a sheet of labels; lengths are in millimetres.
+type badgeLayout struct {
+	PageW, PageH   float64
+	LabelW, LabelH float64
+	Cols, Rows     int
+	Left, Top      float64
+	GapX, GapY     float64
+}
+
+func (l badgeLayout) perPage() int { return l.Cols * l.Rows }
+
+var badgeLayouts = map[string]badgeLayout{
+	// US Letter, 8 name badges of 3⅜ × 2⅓ in
+	"avery-5395": {PageW: 215.9, PageH: 279.4, LabelW: 85.725, LabelH: 59.267, Cols: 2, Rows: 4, Left: 17.463, Top: 15.088, GapX: 12.7},
+	// US Letter, 6 badges of 4 × 3 in
+	"avery-74459": {PageW: 215.9, PageH: 279.4, LabelW: 101.6, LabelH: 76.2, Cols: 2, Rows: 3, Left: 6.35, Top: 25.4},
+	// A4, 8 badges of 90 × 60 mm
+	"a4-8": {PageW: 210, PageH: 297, LabelW: 90, LabelH: 60, Cols: 2, Rows: 4, Left: 12, Top: 24.5, GapX: 6, GapY: 2},
+}
+
+const defaultBadgeLayout = "avery-5395"
+
+func badgeLayoutNames() []string {
+	names := make([]string, 0, len(badgeLayouts))
+	for n := range badgeLayouts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type badge struct {
+	AttendeeID uint
+	Name       string
+	Org        string
+}
+
+// badgeNames are the printable names of the given users: their display
+// name, else one made from their email.
+func badgeNames(ids []uint) map[uint]string {
+	out := map[uint]string{}
+	if len(ids) == 0 {
+		return out
+	}
+	var users []User
+	DB.Select("id", "email", "name").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		out[u.ID] = strings.TrimSpace(u.Name)
+		if out[u.ID] == "" {
+			out[u.ID] = badgeName(u.Email)
+		}
+	}
+	return out
+}
+
+// badgeName makes a printable name from an email for users who haven't
+// set one: "jane.doe+events@x.com" becomes "Jane Doe".
+func badgeName(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	local, _, _ = strings.Cut(local, "+")
+	parts := strings.FieldsFunc(local, func(r rune) bool { return r == '.' || r == '_' || r == '-' })
+	for i, p := range parts {
+		r := []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+		parts[i] = string(r)
+	}
+	if len(parts) == 0 {
+		return email
+	}
+	return strings.Join(parts, " ")
+}
+
+// badgeOrgs names each user's organization, preferring the event's own.
+func badgeOrgs(ev Event, userIDs []uint) map[uint]string {
+	var rows []struct {
+		UserID         uint
+		OrganizationID uint
+		Name           string
+	}
+	DB.Table("organization_members").
+		Select("organization_members.user_id, organization_members.organization_id, organizations.name").
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id").
+		Where("organization_members.user_id IN ?", userIDs).
+		Order("organization_members.id asc").
+		Scan(&rows)
+
+	out := map[uint]string{}
+	for _, r := range rows {
+		if _, seen := out[r.UserID]; !seen || (ev.OrganizationID != nil && *ev.OrganizationID == r.OrganizationID) {
+			out[r.UserID] = r.Name
+		}
+	}
+	return out
+}
+
+// renderBadges writes the badges onto sheets of layout, leaving the first
+// skip labels of the first sheet empty for partly used sheets.
+func renderBadges(ev Event, badges []badge, layout badgeLayout, skip int) ([]byte, error) {
+	pdf := fpdf.NewCustom(&fpdf.InitType{UnitStr: "mm", Size: fpdf.SizeType{Wd: layout.PageW, Ht: layout.PageH}})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle(ev.Title+" badges", true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+
+	const pad = 4.0
+	for i, b := range badges {
+		slot := (i + skip) % layout.perPage()
+		if i == 0 || slot == 0 {
+			pdf.AddPage()
+		}
+		x := layout.Left + float64(slot%layout.Cols)*(layout.LabelW+layout.GapX)
+		y := layout.Top + float64(slot/layout.Cols)*(layout.LabelH+layout.GapY)
+		w, h := layout.LabelW, layout.LabelH
+
+		// QR code in the bottom right corner
+		qr, err := qrcode.New(strconv.FormatUint(uint64(b.AttendeeID), 10), qrcode.Medium)
+		if err != nil {
+			return nil, err
+		}
+		qr.DisableBorder = true
+		bitmap := qr.Bitmap()
+		size := min(h*0.5, w*0.35)
+		module := size / float64(len(bitmap))
+		qx, qy := x+w-pad-size, y+h-pad-size
+		pdf.SetFillColor(0, 0, 0)
+		for r, row := range bitmap {
+			for c, dark := range row {
+				if dark {
+					pdf.Rect(qx+float64(c)*module, qy+float64(r)*module, module, module, "F")
+				}
+			}
+		}
+
+		// event title along the top
+		pdf.SetTextColor(110, 110, 110)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetXY(x+pad, y+pad)
+		pdf.CellFormat(w-2*pad, 4, fitText(pdf, tr(ev.Title), w-2*pad), "", 0, "L", false, 0, "")
+
+		// name, shrunk until it fits beside the code
+		textW := w - 3*pad - size
+		name := tr(b.Name)
+		fontSize := 22.0
+		pdf.SetFont("Helvetica", "B", fontSize)
+		for fontSize > 10 && pdf.GetStringWidth(name) > textW {
+			fontSize--
+			pdf.SetFont("Helvetica", "B", fontSize)
+		}
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetXY(x+pad, y+h*0.35)
+		pdf.CellFormat(textW, fontSize*0.45, fitText(pdf, name, textW), "", 0, "L", false, 0, "")
+
+		if b.Org != "" {
+			pdf.SetFont("Helvetica", "", 11)
+			pdf.SetTextColor(60, 60, 60)
+			pdf.SetXY(x+pad, y+h*0.35+fontSize*0.45+2)
+			pdf.CellFormat(textW, 5, fitText(pdf, tr(b.Org), textW), "", 0, "L", false, 0, "")
+		}
+	}
+	if len(badges) == 0 {
+		pdf.AddPage()
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitText cuts s, already translated to the single-byte cp1252, with an
+// ellipsis to fit width in the current font.
+func fitText(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	for len(s) > 0 && pdf.GetStringWidth(s+"...") > width {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
+
+// GetEventBadges renders name badges for the attendees going to the event.
+//
+//	layout  one of badgeLayouts, default avery-5395
+//	skip    labels already used on the first sheet
+//	label   only attendees carrying this label, e.g. speakers
+func GetEventBadges(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	layout, ok := badgeLayouts[c.DefaultQuery("layout", defaultBadgeLayout)]
+	if !ok {
+		jsonError(c, http.StatusBadRequest, "layout must be one of: "+strings.Join(badgeLayoutNames(), ", "))
+		return
+	}
+	skip := 0
+	if raw := c.Query("skip"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n >= layout.perPage() {
+			jsonError(c, http.StatusBadRequest, fmt.Sprintf("skip must be between 0 and %d", layout.perPage()-1))
+			return
+		}
+		skip = n
+	}
+	label, ok := labelParam(c)
+	if !ok {
+		return
+	}
+
+	q := DB.Where("event_id = ? AND status = ?", ev.ID, "Going")
+	if label != "" {
+		q = withLabel(q, label)
+	}
+	var attendees []EventAttendee
+	if err := q.Order("id asc").Limit(maxBadges + 1).Find(&attendees).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if len(attendees) > maxBadges {
+		jsonError(c, http.StatusBadRequest, fmt.Sprintf("more than %d badges; print them by label", maxBadges))
+		return
+	}
+
+	ids := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		ids = append(ids, a.UserID)
+	}
+	names := badgeNames(ids)
+	orgs := badgeOrgs(ev, ids)
+	badges := make([]badge, 0, len(attendees))
+	for _, a := range attendees {
+		badges = append(badges, badge{AttendeeID: a.ID, Name: names[a.UserID], Org: orgs[a.UserID]})
+	}
+	sort.SliceStable(badges, func(i, j int) bool { return strings.ToLower(badges[i].Name) < strings.ToLower(badges[j].Name) })
+
+	out, err := renderBadges(ev, badges, layout, skip)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not render badges: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-badges.pdf"`, ev.ID))
+	c.Data(http.StatusOK, "application/pdf", out)
+}
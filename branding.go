@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var accentColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Branding is an organization's look, applied to its events' public
+// pages, emails and calendar exports.
+type Branding struct {
+	Name        string `json:"name"`
+	LogoURL     string `json:"logo_url,omitempty"`
+	AccentColor string `json:"accent_color,omitempty"`
+	ReplyTo     string `json:"reply_to,omitempty"`
+}
+
+func orgBranding(org Organization) *Branding {
+	if org.BrandLogoURL == "" && org.BrandAccentColor == "" && org.BrandReplyTo == "" {
+		return nil
+	}
+	return &Branding{
+		Name:        org.Name,
+		LogoURL:     org.BrandLogoURL,
+		AccentColor: org.BrandAccentColor,
+		ReplyTo:     org.BrandReplyTo,
+	}
+}
+
+// eventBranding is the branding of the organization the event belongs to,
+// nil when it has none.
+func eventBranding(ev Event) *Branding {
+	if ev.OrganizationID == nil {
+		return nil
+	}
+	var org Organization
+	if DB.First(&org, *ev.OrganizationID).Error != nil {
+		return nil
+	}
+	return orgBranding(org)
+}
+
+// eventBrandings loads the branding for many events in one query, keyed by
+// organization ID.
+func eventBrandings(events []Event) map[uint]*Branding {
+	out := map[uint]*Branding{}
+	ids := []uint{}
+	for _, ev := range events {
+		if ev.OrganizationID != nil {
+			ids = append(ids, *ev.OrganizationID)
+		}
+	}
+	if len(ids) == 0 {
+		return out
+	}
+	var orgs []Organization
+	DB.Where("id IN ?", ids).Find(&orgs)
+	for _, org := range orgs {
+		if b := orgBranding(org); b != nil {
+			out[org.ID] = b
+		}
+	}
+	return out
+}
+
+func GetOrgBranding(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var org Organization
+	if err := DB.First(&org, orgID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "organization not found")
+		return
+	}
+	c.JSON(http.StatusOK, Branding{Name: org.Name, LogoURL: org.BrandLogoURL, AccentColor: org.BrandAccentColor, ReplyTo: org.BrandReplyTo})
+}
+
+type BrandingRequest struct {
+	LogoURL     string `json:"logo_url" binding:"max=500"`
+	AccentColor string `json:"accent_color"`
+	ReplyTo     string `json:"reply_to" binding:"max=255"`
+}
+
+// PutOrgBranding replaces the organization's branding; empty fields fall
+// back to the app's defaults.
+func PutOrgBranding(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return
+	}
+	if !isOrgAdmin(orgID, userID) {
+		jsonError(c, http.StatusForbidden, "only organization admins can change branding")
+		return
+	}
+
+	var body BrandingRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	body.LogoURL = strings.TrimSpace(body.LogoURL)
+	body.AccentColor = strings.ToLower(strings.TrimSpace(body.AccentColor))
+	body.ReplyTo = strings.TrimSpace(body.ReplyTo)
+
+	if body.LogoURL != "" {
+		// pages embedding the logo are served over https
+		if u, err := url.Parse(body.LogoURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			jsonError(c, http.StatusBadRequest, "logo_url must be an https URL")
+			return
+		}
+	}
+	if body.AccentColor != "" && !accentColorPattern.MatchString(body.AccentColor) {
+		jsonError(c, http.StatusBadRequest, "accent_color must look like #1a2b3c")
+		return
+	}
+	if body.ReplyTo != "" {
+		addr, err := mail.ParseAddress(body.ReplyTo)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "reply_to must be an email address")
+			return
+		}
+		body.ReplyTo = addr.Address
+	}
+
+	if err := DB.Model(&Organization{}).Where("id = ?", orgID).Updates(map[string]interface{}{
+		"brand_logo_url":     body.LogoURL,
+		"brand_accent_color": body.AccentColor,
+		"brand_reply_to":     body.ReplyTo,
+	}).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update branding: "+err.Error())
+		return
+	}
+	Audit(userID, "org.branding", "organization", orgID, gin.H{"logo_url": body.LogoURL, "accent_color": body.AccentColor, "reply_to": body.ReplyTo})
+
+	var org Organization
+	DB.First(&org, orgID)
+	c.JSON(http.StatusOK, Branding{Name: org.Name, LogoURL: org.BrandLogoURL, AccentColor: org.BrandAccentColor, ReplyTo: org.BrandReplyTo})
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const uncategorized = "uncategorized"
+
+var vendorPaymentStatuses = []string{"unpaid", "deposit_paid", "paid"}
+
+func normalizeCategory(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return uncategorized
+	}
+	return s
+}
+
+func eventHasVendor(eventID, vendorID uint) bool {
+	var n int64
+	DB.Model(&Vendor{}).Where("id = ? AND event_id = ?", vendorID, eventID).Count(&n)
+	return n > 0
+}
+
+// ========================
+// VENDORS
+// ========================
+
+type VendorRequest struct {
+	Name          string `json:"name" binding:"required"`
+	Category      string `json:"category"`
+	ContactName   string `json:"contact_name"`
+	ContactEmail  string `json:"contact_email"`
+	ContactPhone  string `json:"contact_phone"`
+	QuoteCents    int64  `json:"quote_cents"`
+	PaymentStatus string `json:"payment_status"`
+	Notes         string `json:"notes"`
+}
+
+func (r *VendorRequest) apply(v *Vendor) error {
+	if r.QuoteCents < 0 {
+		return errors.New("quote_cents must not be negative")
+	}
+	status := r.PaymentStatus
+	if status == "" {
+		status = "unpaid"
+	}
+	if !slices.Contains(vendorPaymentStatuses, status) {
+		return errors.New("payment_status must be one of: " + strings.Join(vendorPaymentStatuses, ", "))
+	}
+	v.Name = strings.TrimSpace(r.Name)
+	v.Category = normalizeCategory(r.Category)
+	v.ContactName = r.ContactName
+	v.ContactEmail = strings.TrimSpace(r.ContactEmail)
+	v.ContactPhone = r.ContactPhone
+	v.QuoteCents = r.QuoteCents
+	v.PaymentStatus = status
+	v.Notes = r.Notes
+	return nil
+}
+
+func GetVendors(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var vendors []Vendor
+	if err := DB.Where("event_id = ?", ev.ID).Order("category asc, name asc").Find(&vendors).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, vendors)
+}
+
+func CreateVendor(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body VendorRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	v := Vendor{EventID: ev.ID}
+	if err := body.apply(&v); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := DB.Create(&v).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create vendor: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, v)
+}
+
+func UpdateVendor(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var v Vendor
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("vendorId"), ev.ID).First(&v).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "vendor not found")
+		return
+	}
+
+	var body VendorRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.apply(&v); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := DB.Save(&v).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update vendor: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// DeleteVendor keeps the vendor's expenses and tasks, just unlinked, and
+// drops its quotes.
+func DeleteVendor(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var v Vendor
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("vendorId"), ev.ID).First(&v).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "vendor not found")
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Expense{}).Where("vendor_id = ?", v.ID).Update("vendor_id", nil).Error; err != nil {
+			return err
+		}
+		var taskIDs []uint
+		tx.Model(&Task{}).Where("vendor_id = ?", v.ID).Pluck("id", &taskIDs)
+		if err := tx.Model(&Task{}).Where("vendor_id = ?", v.ID).Update("vendor_id", nil).Error; err != nil {
+			return err
+		}
+		for _, id := range taskIDs {
+			recordChange(tx, EntityTask, id, ev.ID, ChangeUpsert)
+		}
+		if err := tx.Where("vendor_id = ?", v.ID).Delete(&VendorQuote{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&v).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "vendor deleted"})
+}
+
+// ========================
+// EXPENSES
+// ========================
+
+type ExpenseRequest struct {
+	Category    string `json:"category"` // defaults to the vendor's category
+	Description string `json:"description"`
+	AmountCents int64  `json:"amount_cents" binding:"required"`
+	IncurredOn  string `json:"incurred_on"` // YYYY-MM-DD, defaults to today
+	VendorID    *uint  `json:"vendor_id"`
+	TaskID      *uint  `json:"task_id"`
+}
+
+func GetExpenses(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	q := DB.Where("event_id = ?", ev.ID)
+	if cat := c.Query("category"); cat != "" {
+		q = q.Where("category = ?", normalizeCategory(cat))
+	}
+	var expenses []Expense
+	if err := q.Order("incurred_on desc, id desc").Find(&expenses).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, expenses)
+}
+
+func CreateExpense(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body ExpenseRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.AmountCents <= 0 {
+		jsonError(c, http.StatusBadRequest, "amount_cents must be positive")
+		return
+	}
+
+	incurred := time.Now()
+	if body.IncurredOn != "" {
+		d, err := time.Parse("2006-01-02", body.IncurredOn)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "incurred_on must be YYYY-MM-DD")
+			return
+		}
+		incurred = d
+	}
+
+	category := body.Category
+	if body.VendorID != nil {
+		var v Vendor
+		if err := DB.Where("id = ? AND event_id = ?", *body.VendorID, ev.ID).First(&v).Error; err != nil {
+			jsonError(c, http.StatusBadRequest, "vendor not found for this event")
+			return
+		}
+		if category == "" {
+			category = v.Category
+		}
+	}
+	if body.TaskID != nil {
+		var n int64
+		DB.Model(&Task{}).Where("id = ? AND event_id = ?", *body.TaskID, ev.ID).Count(&n)
+		if n == 0 {
+			jsonError(c, http.StatusBadRequest, "task not found for this event")
+			return
+		}
+	}
+
+	e := Expense{
+		EventID:     ev.ID,
+		VendorID:    body.VendorID,
+		TaskID:      body.TaskID,
+		Category:    normalizeCategory(category),
+		Description: body.Description,
+		AmountCents: body.AmountCents,
+		IncurredOn:  incurred,
+		CreatedByID: userID,
+	}
+	if err := DB.Create(&e).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create expense: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, e)
+}
+
+func DeleteExpense(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e, ok := loadEventExpense(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&VendorQuote{}).Where("expense_id = ?", e.ID).Update("expense_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&e).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+	removeReceipt(e)
+	c.JSON(http.StatusOK, gin.H{"message": "expense deleted"})
+}
+
+// ========================
+// BUDGET
+// ========================
+
+type BudgetCategoryRequest struct {
+	BudgetCents int64 `json:"budget_cents"`
+}
+
+func SetBudgetCategory(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body BudgetCategoryRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.BudgetCents < 0 {
+		jsonError(c, http.StatusBadRequest, "budget_cents must not be negative")
+		return
+	}
+
+	bc := BudgetCategory{EventID: ev.ID, Name: normalizeCategory(c.Param("category"))}
+	if err := DB.Where("event_id = ? AND name = ?", bc.EventID, bc.Name).
+		Assign(BudgetCategory{BudgetCents: body.BudgetCents}).
+		FirstOrCreate(&bc).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save budget: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, bc)
+}
+
+type budgetLine struct {
+	Category       string `json:"category"`
+	BudgetCents    int64  `json:"budget_cents"`
+	QuotedCents    int64  `json:"quoted_cents"`
+	SpentCents     int64  `json:"spent_cents"`
+	RemainingCents int64  `json:"remaining_cents"`
+	OverBudget     bool   `json:"over_budget"`
+	Vendors        int    `json:"vendors"`
+}
+
+// GetBudgetBreakdown compares planned budget, vendor quotes and actual
+// spending per category.
+func GetBudgetBreakdown(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	lines := map[string]*budgetLine{}
+	line := func(cat string) *budgetLine {
+		if l, ok := lines[cat]; ok {
+			return l
+		}
+		l := &budgetLine{Category: cat}
+		lines[cat] = l
+		return l
+	}
+
+	var categories []BudgetCategory
+	DB.Where("event_id = ?", ev.ID).Find(&categories)
+	for _, bc := range categories {
+		line(bc.Name).BudgetCents = bc.BudgetCents
+	}
+
+	var vendors []Vendor
+	DB.Where("event_id = ?", ev.ID).Find(&vendors)
+	for _, v := range vendors {
+		l := line(normalizeCategory(v.Category))
+		l.QuotedCents += v.QuoteCents
+		l.Vendors++
+	}
+
+	var spent []struct {
+		Category string
+		Total    int64
+	}
+	DB.Model(&Expense{}).Select("category, SUM(amount_cents) AS total").
+		Where("event_id = ?", ev.ID).Group("category").Scan(&spent)
+	for _, s := range spent {
+		line(normalizeCategory(s.Category)).SpentCents += s.Total
+	}
+
+	out := make([]budgetLine, 0, len(lines))
+	var total budgetLine
+	total.Category = "total"
+	for _, l := range lines {
+		l.RemainingCents = l.BudgetCents - l.SpentCents
+		l.OverBudget = l.BudgetCents > 0 && l.SpentCents > l.BudgetCents
+		out = append(out, *l)
+
+		total.BudgetCents += l.BudgetCents
+		total.QuotedCents += l.QuotedCents
+		total.SpentCents += l.SpentCents
+		total.Vendors += l.Vendors
+	}
+	total.RemainingCents = total.BudgetCents - total.SpentCents
+	total.OverBudget = total.BudgetCents > 0 && total.SpentCents > total.BudgetCents
+	sort.Slice(out, func(i, j int) bool { return out[i].Category < out[j].Category })
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "categories": out, "total": total})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxBulkEvents = 100
+
+type BulkEventsRequest struct {
+	EventIDs []uint `json:"event_ids" binding:"required,min=1"`
+}
+
+type bulkEventResult struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"` // "deleted", "archived", "unarchived", "not_found", "forbidden" or "failed"
+	Error  string `json:"error,omitempty"`
+}
+
+var (
+	errBulkNotFound  = errors.New("event not found")
+	errBulkForbidden = errors.New("only the organizer can do this")
+)
+
+// runBulkEvents applies fn to each event the caller owns inside one
+// transaction, each in its own savepoint, so a failure on one ID is
+// reported without undoing the others. Duplicate IDs are handled once.
+func runBulkEvents(c *gin.Context, done string, fn func(tx *gorm.DB, ev Event) error) ([]bulkEventResult, bool) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return nil, false
+	}
+
+	var body BulkEventsRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return nil, false
+	}
+	if len(body.EventIDs) > maxBulkEvents {
+		jsonError(c, http.StatusBadRequest, "at most 100 events per request")
+		return nil, false
+	}
+
+	results := []bulkEventResult{}
+	seen := map[uint]bool{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range body.EventIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				var ev Event
+				if err := sp.First(&ev, id).Error; err != nil {
+					if err == gorm.ErrRecordNotFound {
+						return errBulkNotFound
+					}
+					return err
+				}
+				if ev.OrganizerID != userID {
+					return errBulkForbidden
+				}
+				return fn(sp, ev)
+			})
+
+			res := bulkEventResult{ID: id, Status: done}
+			switch {
+			case err == nil:
+			case errors.Is(err, errBulkNotFound):
+				res.Status = "not_found"
+			case errors.Is(err, errBulkForbidden):
+				res.Status = "forbidden"
+			default:
+				res.Status, res.Error = "failed", err.Error()
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "bulk update failed: "+err.Error())
+		return nil, false
+	}
+	return results, true
+}
+
+func succeededIDs(results []bulkEventResult, status string) []uint {
+	ids := []uint{}
+	for _, r := range results {
+		if r.Status == status {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+// BulkDeleteEvents deletes many of the caller's events at once, e.g. after
+// an import went wrong. Same rules as DeleteEvent, per event.
+func BulkDeleteEvents(c *gin.Context) {
+	var files eventFiles
+	results, ok := runBulkEvents(c, "deleted", func(tx *gorm.DB, ev Event) error {
+		f, err := deleteEventTx(tx, ev)
+		if err == nil {
+			files.add(f)
+		}
+		return err
+	})
+	if !ok {
+		return
+	}
+	files.remove()
+
+	userID, _ := getUserIDFromContext(c)
+	if ids := succeededIDs(results, "deleted"); len(ids) > 0 {
+		Audit(userID, "event.bulk_delete", "event", 0, gin.H{"event_ids": ids})
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func setArchived(archived bool) func(tx *gorm.DB, ev Event) error {
+	return func(tx *gorm.DB, ev Event) error {
+		var at *time.Time
+		if archived {
+			now := time.Now()
+			at = &now
+		}
+		if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Update("archived_at", at).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	}
+}
+
+// BulkArchiveEvents hides events from the listings without deleting them.
+func BulkArchiveEvents(c *gin.Context) {
+	results, ok := runBulkEvents(c, "archived", setArchived(true))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func BulkUnarchiveEvents(c *gin.Context) {
+	results, ok := runBulkEvents(c, "unarchived", setArchived(false))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
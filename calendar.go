@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type heatmapDay struct {
+	Date      string   `json:"date"`
+	Events    int      `json:"events"`
+	OpenTasks int      `json:"open_tasks"`
+	Holiday   *Holiday `json:"holiday,omitempty"`
+}
+
+// monthRange parses ?month=YYYY-MM (default: current month) in loc and
+// returns its first instant and the first instant of the next month.
+func monthRange(c *gin.Context, loc *time.Location) (time.Time, time.Time, bool) {
+	month := c.Query("month")
+	if month == "" {
+		month = time.Now().In(loc).Format("2006-01")
+	}
+	start, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "month must be YYYY-MM")
+		return start, start, false
+	}
+	return start, start.AddDate(0, 1, 0), true
+}
+
+// queryLocation reads ?tz= (IANA name), defaulting to UTC.
+func queryLocation(c *gin.Context) (*time.Location, bool) {
+	tz := c.Query("tz")
+	if tz == "" {
+		return time.UTC, true
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "unknown tz")
+		return nil, false
+	}
+	return loc, true
+}
+
+// GetCalendarHeatmap returns per-day counts of the caller's events and of
+// open tasks on events they organize, for shading a month view. Public
+// holidays in the caller's country are marked on their day.
+func GetCalendarHeatmap(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	loc, ok := queryLocation(c)
+	if !ok {
+		return
+	}
+	start, end, ok := monthRange(c, loc)
+	if !ok {
+		return
+	}
+
+	country := localeCountry(c, userID)
+	days := []heatmapDay{}
+	index := map[string]int{}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		index[key] = len(days)
+		day := heatmapDay{Date: key}
+		if h, ok := holidayOn(c.Request.Context(), country, d); ok {
+			day.Holiday = &h
+		}
+		days = append(days, day)
+	}
+
+	if ids := participatingEventIDs(userID); len(ids) > 0 {
+		var dates []time.Time
+		if err := ReadDB.Model(&Event{}).
+			Where("id IN ? AND date >= ? AND date < ? AND cancelled_at IS NULL", ids, start, end).
+			Pluck("date", &dates).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+		for _, d := range dates {
+			if i, ok := index[d.In(loc).Format("2006-01-02")]; ok {
+				days[i].Events++
+			}
+		}
+	}
+
+	var taskDates []time.Time
+	if err := ReadDB.Model(&Task{}).
+		Joins("JOIN events ON events.id = tasks.event_id").
+		Where("events.organizer_id = ? AND events.date >= ? AND events.date < ? AND tasks.status <> ?", userID, start, end, TaskDone).
+		Pluck("events.date", &taskDates).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	for _, d := range taskDates {
+		if i, ok := index[d.In(loc).Format("2006-01-02")]; ok {
+			days[i].OpenTasks++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":    start.Format("2006-01"),
+		"timezone": loc.String(),
+		"country":  country,
+		"days":     days,
+	})
+}
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Capacity is limited at three levels: the event (MaxAttendees, which
+// waitlists), each ticket tier and each session (which refuse with 409).
+// Every reservation goes through lockCapacity so the row behind each limit
+// is locked before its seats are counted.
+
+const (
+	ScopeEvent      = "event"
+	ScopeTicketTier = "ticket_tier"
+	ScopeSession    = "session"
+)
+
+// lock order; always event, then tier, then session so two reservations
+// can't wait on each other
+var scopeRank = map[string]int{ScopeEvent: 0, ScopeTicketTier: 1, ScopeSession: 2}
+
+type capacityScope struct {
+	Kind string
+	ID   uint
+}
+
+// capacityLimit is one limit and how much of it is taken.
+type capacityLimit struct {
+	Scope     string `json:"scope"`
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Capacity  *int   `json:"capacity"`
+	Taken     int64  `json:"taken"`
+	Remaining *int64 `json:"remaining"` // nil when unlimited
+}
+
+func newLimit(scope string, id uint, name string, capacity *int, taken int64) capacityLimit {
+	l := capacityLimit{Scope: scope, ID: id, Name: name, Capacity: capacity, Taken: taken}
+	if capacity != nil {
+		left := max(int64(*capacity)-taken, 0)
+		l.Remaining = &left
+	}
+	return l
+}
+
+func (l capacityLimit) full() bool {
+	return l.Remaining != nil && *l.Remaining == 0
+}
+
+// CapacityError reports the first full limit a reservation ran into, with
+// everything that was checked alongside it.
+type CapacityError struct {
+	Full   capacityLimit
+	Limits []capacityLimit
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("%s %q is full", strings.ReplaceAll(e.Full.Scope, "_", " "), e.Full.Name)
+}
+
+func capacityConflict(c *gin.Context, err *CapacityError) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":     err.Error(),
+		"scope":     err.Full.Scope,
+		"id":        err.Full.ID,
+		"remaining": 0,
+		"limits":    err.Limits,
+	})
+}
+
+func tierTaken(tx *gorm.DB, tierID uint) int64 {
+	var n int64
+	tx.Model(&EventAttendee{}).Where("ticket_tier_id = ? AND status = ?", tierID, "Going").Count(&n)
+	return n
+}
+
+func sessionTaken(tx *gorm.DB, sessionID uint) int64 {
+	var n int64
+	tx.Model(&SessionRegistration{}).Where("session_id = ?", sessionID).Count(&n)
+	return n
+}
+
+// lockCapacity locks the rows behind scopes and counts their seats. It must
+// run inside a transaction; the locks hold until it ends.
+func lockCapacity(tx *gorm.DB, scopes ...capacityScope) ([]capacityLimit, error) {
+	sort.SliceStable(scopes, func(i, j int) bool { return scopeRank[scopes[i].Kind] < scopeRank[scopes[j].Kind] })
+	locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+
+	limits := make([]capacityLimit, 0, len(scopes))
+	for _, s := range scopes {
+		switch s.Kind {
+		case ScopeEvent:
+			var ev Event
+			if err := locked.First(&ev, s.ID).Error; err != nil {
+				return nil, err
+			}
+			limits = append(limits, newLimit(s.Kind, ev.ID, ev.Title, ev.MaxAttendees, goingCount(tx, ev.ID)))
+		case ScopeTicketTier:
+			var tier TicketTier
+			if err := locked.First(&tier, s.ID).Error; err != nil {
+				return nil, err
+			}
+			limits = append(limits, newLimit(s.Kind, tier.ID, tier.Name, tier.Capacity, tierTaken(tx, tier.ID)))
+		case ScopeSession:
+			var sess EventSession
+			if err := locked.First(&sess, s.ID).Error; err != nil {
+				return nil, err
+			}
+			limits = append(limits, newLimit(s.Kind, sess.ID, sess.Title, sess.Capacity, sessionTaken(tx, sess.ID)))
+		default:
+			return nil, fmt.Errorf("unknown capacity scope %q", s.Kind)
+		}
+	}
+	return limits, nil
+}
+
+// firstFull returns the first full limit among kinds (any kind if none
+// are given).
+func firstFull(limits []capacityLimit, kinds ...string) *capacityLimit {
+	for i, l := range limits {
+		if l.full() && (len(kinds) == 0 || slices.Contains(kinds, l.Scope)) {
+			return &limits[i]
+		}
+	}
+	return nil
+}
+
+// reserveCapacity takes one seat in each of scopes, or returns a
+// *CapacityError when any is full. The caller writes the seat itself in the
+// same transaction.
+func reserveCapacity(tx *gorm.DB, scopes ...capacityScope) error {
+	limits, err := lockCapacity(tx, scopes...)
+	if err != nil {
+		return err
+	}
+	if full := firstFull(limits); full != nil {
+		return &CapacityError{Full: *full, Limits: limits}
+	}
+	return nil
+}
+
+// leaveSessions gives up a user's session seats once they stop going.
+func leaveSessions(tx *gorm.DB, eventID, userID uint) error {
+	return tx.Where("event_id = ? AND user_id = ?", eventID, userID).Delete(&SessionRegistration{}).Error
+}
+
+// eventTier loads a tier of eventID; the error is meant for the client.
+func eventTier(eventID, tierID uint) (TicketTier, error) {
+	var tier TicketTier
+	if err := DB.Where("id = ? AND event_id = ?", tierID, eventID).First(&tier).Error; err != nil {
+		return tier, fmt.Errorf("unknown ticket tier")
+	}
+	return tier, nil
+}
+
+func hasTicketTiers(eventID uint) bool {
+	var n int64
+	DB.Model(&TicketTier{}).Where("event_id = ?", eventID).Count(&n)
+	return n > 0
+}
+
+// ========================
+// OVERVIEW
+// ========================
+
+// GetEventCapacity shows every limit on the event and what's left of it.
+func GetEventCapacity(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var tiers []TicketTier
+	DB.Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&tiers)
+	var sessions []EventSession
+	DB.Where("event_id = ?", ev.ID).Order("starts_at asc, id asc").Find(&sessions)
+
+	tierLimits := make([]capacityLimit, 0, len(tiers))
+	for _, t := range tiers {
+		tierLimits = append(tierLimits, newLimit(ScopeTicketTier, t.ID, t.Name, t.Capacity, tierTaken(DB, t.ID)))
+	}
+	sessionLimits := make([]capacityLimit, 0, len(sessions))
+	for _, s := range sessions {
+		sessionLimits = append(sessionLimits, newLimit(ScopeSession, s.ID, s.Title, s.Capacity, sessionTaken(DB, s.ID)))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event":        newLimit(ScopeEvent, ev.ID, ev.Title, ev.MaxAttendees, goingCount(DB, ev.ID)),
+		"ticket_tiers": tierLimits,
+		"sessions":     sessionLimits,
+	})
+}
+
+// ========================
+// TICKET TIERS
+// ========================
+
+type TicketTierRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Capacity    *int   `json:"capacity"` // nil for unlimited
+	Position    int    `json:"position"`
+
+	PriceCents int64  `json:"price_cents"` // net, in the currency's minor unit
+	Currency   string `json:"currency"`    // ISO 4217, required with a price
+	TaxRateBps int    `json:"tax_rate_bps"`
+}
+
+func (r *TicketTierRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 100 {
+		return fmt.Errorf("name must be 1-100 characters")
+	}
+	if len(r.Description) > 1000 {
+		return fmt.Errorf("description is too long")
+	}
+	if r.Capacity != nil && *r.Capacity < 0 {
+		return fmt.Errorf("capacity must not be negative")
+	}
+	if r.PriceCents < 0 {
+		return fmt.Errorf("price_cents must not be negative")
+	}
+	r.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+	if r.PriceCents > 0 && r.Currency == "" {
+		return fmt.Errorf("a priced tier needs a currency")
+	}
+	if r.Currency != "" && !currencyPattern.MatchString(r.Currency) {
+		return fmt.Errorf("currency must be a three-letter ISO 4217 code")
+	}
+	if r.TaxRateBps < 0 || r.TaxRateBps > 10000 {
+		return fmt.Errorf("tax_rate_bps must be between 0 and 10000")
+	}
+	return nil
+}
+
+type ticketTierView struct {
+	TicketTier
+	Taken     int64  `json:"taken"`
+	Remaining *int64 `json:"remaining"` // nil when unlimited
+}
+
+func tierViews(tiers []TicketTier) []ticketTierView {
+	out := make([]ticketTierView, 0, len(tiers))
+	for _, t := range tiers {
+		l := newLimit(ScopeTicketTier, t.ID, t.Name, t.Capacity, tierTaken(DB, t.ID))
+		out = append(out, ticketTierView{TicketTier: t, Taken: l.Taken, Remaining: l.Remaining})
+	}
+	return out
+}
+
+// GetTicketTiers lists the event's tiers with what's left of each, for
+// anyone who can RSVP.
+func GetTicketTiers(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var tiers []TicketTier
+	if err := DB.Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&tiers).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, tierViews(tiers))
+}
+
+func CreateTicketTier(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body TicketTierRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tier := TicketTier{
+		EventID: ev.ID, Name: body.Name, Description: body.Description, Capacity: body.Capacity, Position: body.Position,
+		PriceCents: body.PriceCents, Currency: body.Currency, TaxRateBps: body.TaxRateBps,
+	}
+	if err := DB.Create(&tier).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create ticket tier: "+err.Error())
+		return
+	}
+	Audit(userID, "ticket_tier.create", "event", ev.ID, gin.H{"tier_id": tier.ID, "name": tier.Name})
+
+	c.JSON(http.StatusCreated, tierViews([]TicketTier{tier})[0])
+}
+
+// UpdateTicketTier replaces a tier. Lowering the capacity below what's
+// already taken keeps those attendees; the tier just stays full.
+func UpdateTicketTier(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var tier TicketTier
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("tierId"), ev.ID).First(&tier).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "ticket tier not found")
+		return
+	}
+
+	var body TicketTierRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tier.Name = body.Name
+	tier.Description = body.Description
+	tier.Capacity = body.Capacity
+	tier.Position = body.Position
+	tier.PriceCents = body.PriceCents
+	tier.Currency = body.Currency
+	tier.TaxRateBps = body.TaxRateBps
+	if err := DB.Save(&tier).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update ticket tier: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tierViews([]TicketTier{tier})[0])
+}
+
+// DeleteTicketTier removes a tier; attendees holding it keep their RSVP
+// without a tier.
+func DeleteTicketTier(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var tier TicketTier
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("tierId"), ev.ID).First(&tier).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "ticket tier not found")
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		var holders []uint
+		tx.Model(&EventAttendee{}).Where("ticket_tier_id = ?", tier.ID).Pluck("id", &holders)
+		if err := tx.Model(&EventAttendee{}).Where("ticket_tier_id = ?", tier.ID).Update("ticket_tier_id", nil).Error; err != nil {
+			return err
+		}
+		for _, id := range holders {
+			recordChange(tx, EntityAttendee, id, ev.ID, ChangeUpsert)
+		}
+		return tx.Delete(&tier).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete ticket tier: "+err.Error())
+		return
+	}
+	Audit(userID, "ticket_tier.delete", "event", ev.ID, gin.H{"tier_id": tier.ID, "name": tier.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": "ticket tier deleted"})
+}
+
+// ========================
+// SESSIONS
+// ========================
+
+type EventSessionRequest struct {
+	Title    string     `json:"title" binding:"required"`
+	StartsAt time.Time  `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Location string     `json:"location"`
+	Capacity *int       `json:"capacity"` // nil for unlimited
+}
+
+func (r *EventSessionRequest) validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" || len(r.Title) > 200 {
+		return fmt.Errorf("title must be 1-200 characters")
+	}
+	if r.EndsAt != nil && !r.EndsAt.After(r.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if len(r.Location) > 200 {
+		return fmt.Errorf("location is too long")
+	}
+	if r.Capacity != nil && *r.Capacity < 0 {
+		return fmt.Errorf("capacity must not be negative")
+	}
+	return nil
+}
+
+type eventSessionView struct {
+	EventSession
+	Taken      int64  `json:"taken"`
+	Remaining  *int64 `json:"remaining"` // nil when unlimited
+	Registered bool   `json:"registered"`
+}
+
+func sessionViews(sessions []EventSession, viewerID uint) []eventSessionView {
+	ids := make([]uint, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	var mine []uint
+	if len(ids) > 0 {
+		DB.Model(&SessionRegistration{}).Where("session_id IN ? AND user_id = ?", ids, viewerID).Pluck("session_id", &mine)
+	}
+
+	out := make([]eventSessionView, 0, len(sessions))
+	for _, s := range sessions {
+		l := newLimit(ScopeSession, s.ID, s.Title, s.Capacity, sessionTaken(DB, s.ID))
+		out = append(out, eventSessionView{EventSession: s, Taken: l.Taken, Remaining: l.Remaining, Registered: slices.Contains(mine, s.ID)})
+	}
+	return out
+}
+
+func loadEventSession(c *gin.Context, ev Event) (EventSession, bool) {
+	var sess EventSession
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("sessionId"), ev.ID).First(&sess).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "session not found")
+		return sess, false
+	}
+	return sess, true
+}
+
+func GetEventSessions(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var sessions []EventSession
+	if err := DB.Where("event_id = ?", ev.ID).Order("starts_at asc, id asc").Find(&sessions).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, sessionViews(sessions, userID))
+}
+
+func CreateEventSession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body EventSessionRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sess := EventSession{EventID: ev.ID, Title: body.Title, StartsAt: body.StartsAt, EndsAt: body.EndsAt, Location: body.Location, Capacity: body.Capacity}
+	if err := DB.Create(&sess).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create session: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, sessionViews([]EventSession{sess}, userID)[0])
+}
+
+// UpdateEventSession replaces a session. As with tiers, a lower capacity
+// never drops anyone already registered.
+func UpdateEventSession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	sess, ok := loadEventSession(c, ev)
+	if !ok {
+		return
+	}
+
+	var body EventSessionRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sess.Title = body.Title
+	sess.StartsAt = body.StartsAt
+	sess.EndsAt = body.EndsAt
+	sess.Location = body.Location
+	sess.Capacity = body.Capacity
+	if err := DB.Save(&sess).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update session: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionViews([]EventSession{sess}, userID)[0])
+}
+
+func DeleteEventSession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	sess, ok := loadEventSession(c, ev)
+	if !ok {
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", sess.ID).Delete(&SessionRegistration{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&sess).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete session: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session deleted"})
+}
+
+// RegisterForSession takes a seat in a session; only people going to the
+// event can.
+func RegisterForSession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	sess, ok := loadEventSession(c, ev)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
+
+	var reg SessionRegistration
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		limits, err := lockCapacity(tx, capacityScope{ScopeSession, sess.ID})
+		if err != nil {
+			return err
+		}
+		if tx.Where("session_id = ? AND user_id = ?", sess.ID, userID).First(&reg).Error == nil {
+			return nil
+		}
+		var going int64
+		tx.Model(&EventAttendee{}).Where("event_id = ? AND user_id = ? AND status = ?", ev.ID, userID, "Going").Count(&going)
+		if going == 0 {
+			return errNotGoing
+		}
+		if full := firstFull(limits); full != nil {
+			return &CapacityError{Full: *full, Limits: limits}
+		}
+		reg = SessionRegistration{SessionID: sess.ID, EventID: ev.ID, UserID: userID}
+		return tx.Create(&reg).Error
+	})
+	var full *CapacityError
+	if errors.As(err, &full) {
+		capacityConflict(c, full)
+		return
+	}
+	if err == errNotGoing {
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not register: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, reg)
+}
+
+var errNotGoing = errors.New(`RSVP "Going" to the event first`)
+
+func UnregisterFromSession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	sess, ok := loadEventSession(c, ev)
+	if !ok {
+		return
+	}
+
+	res := DB.Where("session_id = ? AND user_id = ?", sess.ID, userID).Delete(&SessionRegistration{})
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "could not unregister: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "not registered for this session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "unregistered"})
+}
+
+type sessionRegistrationView struct {
+	UserID       uint      `json:"user_id"`
+	Email        string    `json:"email"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// GetSessionRegistrations lists who holds a seat, for organizers.
+func GetSessionRegistrations(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	sess, ok := loadEventSession(c, ev)
+	if !ok {
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&SessionRegistration{}).Where("session_id = ?", sess.ID).Order("id asc"))
+	if !ok {
+		return
+	}
+	var regs []SessionRegistration
+	if err := q.Find(&regs).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	ids := make([]uint, 0, len(regs))
+	for _, r := range regs {
+		ids = append(ids, r.UserID)
+	}
+	emails := userEmails(ids)
+	out := make([]sessionRegistrationView, 0, len(regs))
+	for _, r := range regs {
+		out = append(out, sessionRegistrationView{UserID: r.UserID, Email: emails[r.UserID], RegisteredAt: r.CreatedAt})
+	}
+	writePage(c, page, out, len(out))
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Certificates of attendance: organizers word them per event and issue
+// them to checked-in attendees, who download them as PDF. Each carries a
+// code whose public page confirms it is genuine.
+
+// certificateVars are the variables certificate templates can use, with
+// the sample values they are checked against.
+var certificateVars = map[string]string{
+	"Name":       "Sam Example",
+	"EventTitle": "Team offsite",
+	"Date":       "5 June 2026",
+	"Location":   "Lisbon",
+}
+
+var defaultCertificateTemplate = CertificateTemplate{
+	Title: "Certificate of Attendance",
+	Body:  "This certifies that {{.Name}} attended {{.EventTitle}} on {{.Date}}{{if .Location}} in {{.Location}}{{end}}.",
+}
+
+const maxCertificateText = 2000
+
+func certificateTemplateOf(eventID uint) CertificateTemplate {
+	var t CertificateTemplate
+	if err := DB.First(&t, "event_id = ?", eventID).Error; err != nil {
+		t = defaultCertificateTemplate
+		t.EventID = eventID
+	}
+	return t
+}
+
+// checkCertificateTemplate rejects unknown variables and templates that
+// don't render.
+func checkCertificateTemplate(t CertificateTemplate) error {
+	for _, text := range []string{t.Title, t.Body} {
+		for _, action := range templateActionPattern.FindAllString(text, -1) {
+			for _, m := range templateVarPattern.FindAllStringSubmatch(action, -1) {
+				if _, ok := certificateVars[m[1]]; !ok {
+					return fmt.Errorf("%w: %s", errUnknownVariable, m[1])
+				}
+			}
+		}
+		if _, err := executeTemplate(text, certificateVars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func certificateVerifyURL(code string) string {
+	return AppConfig.APIBaseURL + "/certificates/" + code
+}
+
+// ========================
+// TEMPLATE
+// ========================
+
+func GetCertificateTemplate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": certificateTemplateOf(ev.ID), "variables": certificateVars})
+}
+
+type CertificateTemplateRequest struct {
+	Title  string `json:"title"`
+	Body   string `json:"body"`   // text/template over certificateVars, e.g. {{.Name}}
+	Signer string `json:"signer"` // printed under the signature line; optional
+}
+
+func PutCertificateTemplate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body CertificateTemplateRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	t := CertificateTemplate{
+		EventID:     ev.ID,
+		Title:       strings.TrimSpace(body.Title),
+		Body:        strings.TrimSpace(body.Body),
+		Signer:      strings.TrimSpace(body.Signer),
+		UpdatedByID: userID,
+	}
+	if t.Title == "" || t.Body == "" {
+		jsonError(c, http.StatusBadRequest, "title and body are required")
+		return
+	}
+	if len(t.Title)+len(t.Body)+len(t.Signer) > maxCertificateText {
+		jsonError(c, http.StatusBadRequest, "certificate text is too long")
+		return
+	}
+	if err := checkCertificateTemplate(t); err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid template: "+err.Error())
+		return
+	}
+
+	if err := DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&t).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save template: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteCertificateTemplate goes back to the default wording.
+func DeleteCertificateTemplate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if err := DB.Where("event_id = ?", ev.ID).Delete(&CertificateTemplate{}).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": certificateTemplateOf(ev.ID)})
+}
+
+// ========================
+// ISSUING
+// ========================
+
+type IssueCertificatesRequest struct {
+	UserIDs []uint          `json:"user_ids"` // default: everyone checked in
+	Names   map[uint]string `json:"names"`    // printed names by user ID; default derived from the email
+}
+
+var errNotCheckedIn = errors.New("only checked-in attendees can get a certificate")
+
+// IssueCertificates issues certificates to checked-in attendees who don't
+// have one yet, and tells them.
+func IssueCertificates(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body IssueCertificatesRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+
+	q := DB.Where("event_id = ? AND checked_in_at IS NOT NULL", ev.ID).
+		Where("user_id NOT IN (?)", DB.Model(&AttendanceCertificate{}).Select("user_id").Where("event_id = ?", ev.ID))
+	if len(body.UserIDs) > 0 {
+		slices.Sort(body.UserIDs)
+		body.UserIDs = slices.Compact(body.UserIDs)
+		var notIn int64
+		DB.Model(&EventAttendee{}).Where("event_id = ? AND user_id IN ? AND checked_in_at IS NOT NULL", ev.ID, body.UserIDs).Count(&notIn)
+		if int(notIn) < len(body.UserIDs) {
+			jsonError(c, http.StatusBadRequest, errNotCheckedIn.Error())
+			return
+		}
+		q = q.Where("user_id IN ?", body.UserIDs)
+	}
+	var attendees []EventAttendee
+	if err := q.Order("id asc").Find(&attendees).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	tpl := certificateTemplateOf(ev.ID)
+	loc := recurrenceLocation(ev.RecurrenceTimezone)
+	ids := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		ids = append(ids, a.UserID)
+	}
+	names := badgeNames(ids)
+
+	issued := make([]AttendanceCertificate, 0, len(attendees))
+	for _, a := range attendees {
+		name := strings.TrimSpace(body.Names[a.UserID])
+		if name == "" {
+			name = names[a.UserID]
+		}
+		// a series is attended on the day of the check-in
+		attended := ev.Date
+		if ev.RecurrenceRule != "" {
+			attended = *a.CheckedInAt
+		}
+		vars := map[string]string{
+			"Name":       name,
+			"EventTitle": ev.Title,
+			"Date":       attended.In(loc).Format("2 January 2006"),
+			"Location":   ev.Location,
+		}
+		title, err := executeTemplate(tpl.Title, vars)
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not render certificate: "+err.Error())
+			return
+		}
+		text, err := executeTemplate(tpl.Body, vars)
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not render certificate: "+err.Error())
+			return
+		}
+		code, err := randomToken(12)
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not create certificate")
+			return
+		}
+		issued = append(issued, AttendanceCertificate{
+			EventID:    ev.ID,
+			UserID:     a.UserID,
+			Code:       code,
+			Name:       name,
+			EventTitle: ev.Title,
+			AttendedOn: attended,
+			Title:      title,
+			Body:       text,
+			Signer:     tpl.Signer,
+			IssuedByID: userID,
+			IssuedAt:   time.Now(),
+		})
+	}
+
+	created := issued[:0]
+	for _, cert := range issued {
+		// a concurrent issue for the same attendee wins quietly
+		res := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&cert)
+		if res.Error != nil {
+			jsonError(c, http.StatusInternalServerError, "could not issue certificates: "+res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		created = append(created, cert)
+		NotifyTemplate(cert.UserID, "certificate_issued", map[string]string{"EventTitle": ev.Title}, gin.H{"event_id": ev.ID, "certificate_id": cert.ID})
+	}
+	if len(created) > 0 {
+		Audit(userID, "certificates.issued", "event", ev.ID, gin.H{"count": len(created)})
+	}
+	issued = created
+
+	c.JSON(http.StatusCreated, gin.H{"issued": len(issued), "certificates": issued})
+}
+
+// GetEventCertificates lists the certificates issued for an event.
+func GetEventCertificates(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&AttendanceCertificate{}).Where("event_id = ?", ev.ID).Order("id asc"))
+	if !ok {
+		return
+	}
+	var certs []AttendanceCertificate
+	if err := q.Find(&certs).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, certs, len(certs))
+}
+
+// RevokeCertificate withdraws a certificate; its page then says so.
+func RevokeCertificate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	res := DB.Model(&AttendanceCertificate{}).
+		Where("id = ? AND event_id = ? AND revoked_at IS NULL", c.Param("certId"), ev.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "certificate not found")
+		return
+	}
+	Audit(userID, "certificates.revoked", "event", ev.ID, gin.H{"certificate_id": c.Param("certId")})
+
+	c.JSON(http.StatusOK, gin.H{"message": "certificate revoked"})
+}
+
+// ========================
+// ATTENDEES
+// ========================
+
+// GetMyCertificates lists the caller's certificates, revoked ones included.
+func GetMyCertificates(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&AttendanceCertificate{}).Where("user_id = ?", userID).Order("issued_at desc, id desc"))
+	if !ok {
+		return
+	}
+	var certs []AttendanceCertificate
+	if err := q.Find(&certs).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, certs, len(certs))
+}
+
+// DownloadMyCertificate renders one of the caller's certificates as PDF.
+func DownloadMyCertificate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	certID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid certificate id")
+		return
+	}
+
+	var cert AttendanceCertificate
+	if err := DB.Where("id = ? AND user_id = ?", certID, userID).First(&cert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "certificate not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if cert.RevokedAt != nil {
+		jsonError(c, http.StatusGone, "this certificate was revoked")
+		return
+	}
+
+	out, err := renderCertificate(cert)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not render certificate: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%d.pdf"`, cert.ID))
+	c.Data(http.StatusOK, "application/pdf", out)
+}
+
+// renderCertificate lays the certificate out on a landscape A4 page, with
+// the verification QR code in the corner.
+func renderCertificate(cert AttendanceCertificate) ([]byte, error) {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(25, 25, 25)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle(cert.Title, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+	pdf.AddPage()
+	w, h := pdf.GetPageSize()
+
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.SetLineWidth(1.2)
+	pdf.Rect(10, 10, w-20, h-20, "D")
+	pdf.SetLineWidth(0.3)
+	pdf.Rect(13, 13, w-26, h-26, "D")
+
+	pdf.SetY(40)
+	pdf.SetFont("Helvetica", "B", 30)
+	pdf.CellFormat(0, 14, tr(cert.Title), "", 1, "C", false, 0, "")
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.CellFormat(0, 12, tr(cert.Name), "", 1, "C", false, 0, "")
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.SetX(45)
+	pdf.MultiCell(w-90, 7, tr(cert.Body), "", "C", false)
+
+	// signature line
+	pdf.SetLineWidth(0.3)
+	pdf.Line(30, h-45, 110, h-45)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetXY(30, h-43)
+	pdf.CellFormat(80, 5, tr(cert.Signer), "", 2, "C", false, 0, "")
+	pdf.SetX(30)
+	pdf.CellFormat(80, 5, "Issued "+cert.IssuedAt.UTC().Format("2 January 2006"), "", 0, "C", false, 0, "")
+
+	// verification
+	qr, err := qrcode.New(certificateVerifyURL(cert.Code), qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	qr.DisableBorder = true
+	bitmap := qr.Bitmap()
+	const size = 30.0
+	module := size / float64(len(bitmap))
+	qx, qy := w-30-size, h-30-size-6
+	pdf.SetFillColor(0, 0, 0)
+	for r, row := range bitmap {
+		for col, dark := range row {
+			if dark {
+				pdf.Rect(qx+float64(col)*module, qy+float64(r)*module, module, module, "F")
+			}
+		}
+	}
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetXY(qx-20, qy+size+1)
+	pdf.CellFormat(size+20, 4, "Verify: "+cert.Code, "", 0, "C", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ========================
+// VERIFICATION
+// ========================
+
+var certificatePage = template.Must(template.New("certificate").Parse(`<!doctype html>
+<html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Certificate verification</title></head>
+<body style="font-family: sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem">
+{{if .Valid}}<h1>&#10003; Genuine certificate</h1>{{else if .Revoked}}<h1>&#10007; Revoked certificate</h1>{{end}}
+<p><strong>{{.Name}}</strong> attended <strong>{{.EventTitle}}</strong> on {{.AttendedOn}}.</p>
+<p>Issued {{.IssuedAt}}{{if .Revoked}}; revoked {{.RevokedAt}}{{end}}.</p>
+</body></html>`))
+
+// VerifyCertificate is the public page behind a certificate's QR code: an
+// HTML page for browsers, JSON otherwise.
+func VerifyCertificate(c *gin.Context) {
+	var cert AttendanceCertificate
+	if err := DB.Where("code = ?", c.Param("code")).First(&cert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "no certificate with this code")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	const day = "2 January 2006"
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		view := gin.H{
+			"Valid":      cert.RevokedAt == nil,
+			"Revoked":    cert.RevokedAt != nil,
+			"Name":       cert.Name,
+			"EventTitle": cert.EventTitle,
+			"AttendedOn": cert.AttendedOn.UTC().Format(day),
+			"IssuedAt":   cert.IssuedAt.UTC().Format(day),
+		}
+		if cert.RevokedAt != nil {
+			view["RevokedAt"] = cert.RevokedAt.UTC().Format(day)
+		}
+		var b bytes.Buffer
+		if err := certificatePage.Execute(&b, view); err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not render page")
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", b.Bytes())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":       cert.RevokedAt == nil,
+		"name":        cert.Name,
+		"event_title": cert.EventTitle,
+		"attended_on": cert.AttendedOn,
+		"issued_at":   cert.IssuedAt,
+		"revoked_at":  cert.RevokedAt,
+	})
+}
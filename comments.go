@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	CommentSourceApp   = "app"
+	CommentSourceEmail = "email"
+
+	maxCommentLength = 10000
+)
+
+var (
+	errCommentEmpty   = errors.New("comment is empty")
+	errCommentTooLong = errors.New("comment is too long")
+)
+
+// mentionPattern finds @mentions: a whole address (@sam@example.com) or
+// the part before its @ (@sam). One right after a word character is part
+// of an address written out in the text, not a mention.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w.%+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// commentMentions resolves the @mentions in body to the event's
+// participants. A bare name only counts when it is the start of exactly
+// one participant's address.
+func commentMentions(ev Event, body string) []uint {
+	handles := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handles[strings.ToLower(strings.TrimRight(m[1], "."))] = true
+	}
+	if len(handles) == 0 {
+		return nil
+	}
+
+	var attendees []uint
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND invitation IN ?", ev.ID, attendingInvitation).Pluck("user_id", &attendees)
+	byEmail := map[string]uint{}
+	byName := map[string][]uint{}
+	for id, email := range userEmails(append(eventOrganizerIDs(ev), attendees...)) {
+		email = strings.ToLower(email)
+		byEmail[email] = id
+		name, _, _ := strings.Cut(email, "@")
+		byName[name] = append(byName[name], id)
+	}
+
+	out := []uint{}
+	for h := range handles {
+		var id uint
+		if strings.Contains(h, "@") {
+			id = byEmail[h]
+		} else if ids := byName[h]; len(ids) == 1 {
+			id = ids[0]
+		}
+		if id != 0 && !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// notifyMentions tells the people mentioned in cm, other than its author
+// and anyone in skip (already told about an earlier version).
+func notifyMentions(ev Event, cm EventComment, mentioned, skip []uint) {
+	if len(mentioned) == 0 {
+		return
+	}
+	author := userEmails([]uint{cm.AuthorID})[cm.AuthorID]
+	for _, id := range mentioned {
+		if id == cm.AuthorID || slices.Contains(skip, id) {
+			continue
+		}
+		NotifyTemplate(id, "comment_mention",
+			map[string]string{"EventTitle": ev.Title, "Name": author, "Body": cm.Body},
+			gin.H{"event_id": ev.ID, "comment_id": cm.ID})
+	}
+}
+
+// loadViewableEvent parses :id and checks the caller can see the event.
+func loadViewableEvent(c *gin.Context, userID uint) (Event, bool) {
+	var ev Event
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return ev, false
+	}
+	if err := DB.First(&ev, eventID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			eventNotFound(c, uint(eventID))
+			return ev, false
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return ev, false
+	}
+	if !canViewEvent(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can view this event")
+		return ev, false
+	}
+	return ev, true
+}
+
+// postComment stores a comment and tells the other participants about it.
+func postComment(ev Event, authorID uint, body, source string) (EventComment, error) {
+	cm, err := createComment(DB, ev, authorID, body, source)
+	if err != nil {
+		return cm, err
+	}
+	announceComment(ev, cm)
+	return cm, nil
+}
+
+// createComment validates and inserts a comment without side effects, for
+// callers that announce it only once their transaction commits.
+func createComment(tx *gorm.DB, ev Event, authorID uint, body, source string) (EventComment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return EventComment{}, errCommentEmpty
+	}
+	if len(body) > maxCommentLength {
+		return EventComment{}, errCommentTooLong
+	}
+
+	cm := EventComment{EventID: ev.ID, AuthorID: authorID, Body: body, Source: source}
+	if err := tx.Create(&cm).Error; err != nil {
+		return cm, err
+	}
+	recordChange(tx, EntityComment, cm.ID, ev.ID, ChangeUpsert)
+	return cm, nil
+}
+
+func announceComment(ev Event, cm EventComment) {
+	body := cm.Body
+	authorID := cm.AuthorID
+	queueLinkPreviews(body)
+	mentioned := commentMentions(ev, body)
+	notifyMentions(ev, cm, mentioned, nil)
+	// a mention already told the organizer
+	if authorID != ev.OrganizerID && !slices.Contains(mentioned, ev.OrganizerID) {
+		NotifyBundledTemplate(ev.OrganizerID, "comment", fmt.Sprintf("comment:%d", ev.ID),
+			map[string]string{"EventTitle": ev.Title, "Body": body}, gin.H{"event_id": ev.ID, "comment_id": cm.ID})
+	}
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "comment", EventID: ev.ID, Data: commentViews([]EventComment{cm}, 0, false)[0]})
+}
+
+func GetEventComments(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	query, page, ok := paginate(c, DB.Model(&EventComment{}).Where("event_id = ?", ev.ID))
+	if !ok {
+		return
+	}
+	var list []EventComment
+	if err := query.Order("created_at asc, id asc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, commentViews(list, userID, isEventOrganizer(ev, userID)), len(list))
+}
+
+type CreateCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+func CreateEventComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can comment")
+		return
+	}
+
+	var body CreateCommentRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	cm, err := postComment(ev, userID, body.Body, CommentSourceApp)
+	if errors.Is(err, errCommentEmpty) || errors.Is(err, errCommentTooLong) {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not post comment: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, commentViews([]EventComment{cm}, userID, isEventOrganizer(ev, userID))[0])
+}
+
+// loadEventComment finds :commentId on the event and checks the caller
+// wrote it or organizes the event.
+func loadEventComment(c *gin.Context, ev Event, userID uint) (EventComment, bool) {
+	var cm EventComment
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("commentId"), ev.ID).First(&cm).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "comment not found")
+		return cm, false
+	}
+	if cm.AuthorID != userID && !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only the author or an organizer can change this comment")
+		return cm, false
+	}
+	return cm, true
+}
+
+// UpdateEventComment replaces a comment's body. People it newly mentions
+// are notified; those mentioned before are not told again.
+func UpdateEventComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	cm, ok := loadEventComment(c, ev, userID)
+	if !ok {
+		return
+	}
+
+	var body CreateCommentRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	text := strings.TrimSpace(body.Body)
+	if text == "" {
+		jsonError(c, http.StatusBadRequest, errCommentEmpty.Error())
+		return
+	}
+	if len(text) > maxCommentLength {
+		jsonError(c, http.StatusBadRequest, errCommentTooLong.Error())
+		return
+	}
+	if text == cm.Body {
+		c.JSON(http.StatusOK, commentViews([]EventComment{cm}, userID, isEventOrganizer(ev, userID))[0])
+		return
+	}
+
+	before := commentMentions(ev, cm.Body)
+	now := time.Now()
+	cm.Body, cm.EditedAt = text, &now
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("body", "body_html", "edited_at").Save(&cm).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityComment, cm.ID, ev.ID, ChangeUpsert)
+		return nil
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update comment: "+err.Error())
+		return
+	}
+	if cm.AuthorID != userID {
+		Audit(userID, "comment.update", "comment", cm.ID, gin.H{"event_id": ev.ID, "author_id": cm.AuthorID})
+	}
+
+	queueLinkPreviews(cm.Body)
+	notifyMentions(ev, cm, commentMentions(ev, cm.Body), before)
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "comment_updated", EventID: ev.ID, Data: commentViews([]EventComment{cm}, 0, false)[0]})
+	c.JSON(http.StatusOK, commentViews([]EventComment{cm}, userID, isEventOrganizer(ev, userID))[0])
+}
+
+// DeleteEventComment removes a comment; organizers moderate anyone's.
+func DeleteEventComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	cm, ok := loadEventComment(c, ev, userID)
+	if !ok {
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&cm).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityComment, cm.ID, ev.ID, ChangeDelete)
+		return nil
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete comment: "+err.Error())
+		return
+	}
+	if cm.AuthorID != userID {
+		Audit(userID, "comment.delete", "comment", cm.ID, gin.H{"event_id": ev.ID, "author_id": cm.AuthorID})
+	}
+
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "comment_deleted", EventID: ev.ID, Data: gin.H{"id": cm.ID}})
+	c.JSON(http.StatusOK, gin.H{"message": "comment deleted"})
+}
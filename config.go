@@ -0,0 +1,277 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds runtime settings read from the environment.
+type Config struct {
+	// Max accepted request body size in bytes (MAX_BODY_BYTES); upload
+	// routes get MaxUploadBytes instead (MAX_UPLOAD_BYTES), see uploadRoutes
+	MaxBodyBytes   int64
+	MaxUploadBytes int64
+
+	// Public URL of the frontend, used in links we hand out (PUBLIC_BASE_URL)
+	PublicBaseURL string
+
+	// Public URL of this API, used for SSO redirect and ACS URLs (API_BASE_URL)
+	APIBaseURL string
+
+	// Where generated files (exports, uploads) are written (DATA_DIR)
+	DataDir string
+
+	// Security headers (SECURITY_*)
+	FrameOptions              string
+	ReferrerPolicy            string
+	HSTSMaxAge                int64
+	APIContentSecurityPolicy  string
+	PageContentSecurityPolicy string
+
+	// Users promoted to admin on startup (ADMIN_EMAILS, comma separated)
+	AdminEmails []string
+
+	// Feature flags enabled by default (FEATURE_FLAGS, comma separated)
+	FeatureFlags []string
+
+	// Plan assigned to accounts without one (DEFAULT_PLAN) and optional
+	// JSON overrides of the built-in plan limits (PLAN_LIMITS)
+	DefaultPlan string
+	PlanLimits  string
+
+	// JSON overrides of the event health score weights (HEALTH_WEIGHTS)
+	HealthWeights string
+
+	// Invitations a user may send per hour/day (INVITE_LIMIT_HOURLY, INVITE_LIMIT_DAILY)
+	InviteLimitHourly int
+	InviteLimitDaily  int
+
+	// Requests per minute for signed-in users and per IP for anonymous
+	// callers (RATE_LIMIT_PER_MINUTE, RATE_LIMIT_ANON_PER_MINUTE, 0 disables).
+	// Limits are only advertised in headers unless RATE_LIMIT_ENFORCE is set.
+	RateLimitPerMinute     int
+	RateLimitAnonPerMinute int
+	RateLimitEnforce       bool
+
+	// Default days before an event pending invitees are nudged to answer
+	// (NUDGE_DAYS_BEFORE, 0 disables)
+	NudgeDaysBefore int
+
+	// How long deleted tasks, removed attendees and cancellations can be
+	// undone (UNDO_WINDOW_MINUTES)
+	UndoWindowMinutes int
+
+	// Per-IP limits for the embeddable RSVP widget, always enforced
+	// (WIDGET_READ_PER_MINUTE, WIDGET_RSVP_PER_MINUTE)
+	WidgetReadPerMinute int
+	WidgetRSVPPerMinute int
+
+	// Queries slower than SLOW_QUERY_MS are logged (0 disables)
+	SlowQueryMs int64
+
+	// Tracing: spans are exported to OTEL_EXPORTER_OTLP_ENDPOINT when set,
+	// under OTEL_SERVICE_NAME
+	OTLPEndpoint string
+	ServiceName  string
+
+	// Window for bundling bursts of similar notifications
+	// (NOTIFICATION_BUNDLE_SECONDS, 0 disables bundling)
+	NotificationBundleSeconds int64
+
+	// CAPTCHA (CAPTCHA_PROVIDER is "hcaptcha", "recaptcha" or empty to disable)
+	CaptchaProvider string
+	CaptchaSecret   string
+
+	// Uploaded files: STORAGE_DRIVER is "local" (under DATA_DIR) or "s3" for
+	// any S3-compatible bucket (S3_ENDPOINT, S3_REGION, S3_BUCKET and keys).
+	// Download links are signed for STORAGE_URL_TTL_SECONDS
+	StorageDriver        string
+	S3Endpoint           string
+	S3Region             string
+	S3Bucket             string
+	S3AccessKeyID        string
+	S3SecretAccessKey    string
+	StorageURLTTLSeconds int
+
+	// Largest event or task attachment (ATTACHMENT_MAX_BYTES); uploads are
+	// also bound by MAX_UPLOAD_BYTES
+	AttachmentMaxBytes int64
+
+	// Receipt OCR (OCR_PROVIDER is "http" or empty to disable)
+	OCRProvider string
+	OCREndpoint string
+	OCRAPIKey   string
+
+	// Inbound email: replies to event-<alias>@INBOUND_EMAIL_DOMAIN are posted
+	// as comments; the provider webhook must send INBOUND_EMAIL_SECRET
+	InboundEmailDomain string
+	InboundEmailSecret string
+
+	// Outgoing email (SMTP_HOST empty disables it); sent from MAIL_FROM.
+	// MAIL_DRIVER picks the Mailer: "smtp" (default) or "log" for development
+	MailDriver   string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// Start in read-only mode (MAINTENANCE_MODE) showing MAINTENANCE_MESSAGE;
+	// clients are told to retry after MAINTENANCE_RETRY_AFTER seconds
+	MaintenanceMode       bool
+	MaintenanceMessage    string
+	MaintenanceRetryAfter int
+
+	// Public holiday source (HOLIDAY_PROVIDER is "builtin" or "nager")
+	HolidayProvider string
+
+	// Rates for showing prices in the viewer's currency (EXCHANGE_RATE_PROVIDER
+	// is "none" or "ecb"); GEO_COUNTRY_HEADER names the proxy header carrying
+	// the client's country, "" to ignore it
+	ExchangeRateProvider string
+	GeoCountryHeader     string
+
+	// Forecasts for weather contingency plans (WEATHER_PROVIDER is "none"
+	// or "open-meteo")
+	WeatherProvider string
+}
+
+var AppConfig Config
+
+func LoadConfig() {
+	AppConfig = Config{
+		MaxBodyBytes:   envInt64("MAX_BODY_BYTES", 1<<20),
+		MaxUploadBytes: envInt64("MAX_UPLOAD_BYTES", 10<<20),
+		DataDir:        envString("DATA_DIR", "./data"),
+
+		PublicBaseURL: strings.TrimRight(envString("PUBLIC_BASE_URL", "http://localhost:4200"), "/"),
+		APIBaseURL:    strings.TrimRight(envString("API_BASE_URL", "http://localhost:8080"), "/"),
+
+		FrameOptions:              envString("SECURITY_FRAME_OPTIONS", "DENY"),
+		ReferrerPolicy:            envString("SECURITY_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		HSTSMaxAge:                envInt64("SECURITY_HSTS_MAX_AGE", 31536000),
+		APIContentSecurityPolicy:  envString("SECURITY_API_CSP", "default-src 'none'; frame-ancestors 'none'"),
+		PageContentSecurityPolicy: envString("SECURITY_PAGE_CSP", "default-src 'self'; img-src 'self' https: data:; style-src 'self' 'unsafe-inline'; script-src 'self'; frame-ancestors 'none'"),
+
+		AdminEmails: envList("ADMIN_EMAILS", nil),
+
+		FeatureFlags: envList("FEATURE_FLAGS", nil),
+
+		DefaultPlan: envString("DEFAULT_PLAN", "free"),
+		PlanLimits:  envString("PLAN_LIMITS", ""),
+
+		HealthWeights: envString("HEALTH_WEIGHTS", ""),
+
+		InviteLimitHourly: envInt("INVITE_LIMIT_HOURLY", 50),
+		InviteLimitDaily:  envInt("INVITE_LIMIT_DAILY", 200),
+
+		RateLimitPerMinute:     envInt("RATE_LIMIT_PER_MINUTE", 600),
+		RateLimitAnonPerMinute: envInt("RATE_LIMIT_ANON_PER_MINUTE", 60),
+		RateLimitEnforce:       envBool("RATE_LIMIT_ENFORCE", false),
+
+		NudgeDaysBefore: envInt("NUDGE_DAYS_BEFORE", 3),
+
+		UndoWindowMinutes: envInt("UNDO_WINDOW_MINUTES", 10),
+
+		WidgetReadPerMinute: envInt("WIDGET_READ_PER_MINUTE", 60),
+		WidgetRSVPPerMinute: envInt("WIDGET_RSVP_PER_MINUTE", 5),
+
+		SlowQueryMs: envInt64("SLOW_QUERY_MS", 200),
+
+		OTLPEndpoint: envString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName:  envString("OTEL_SERVICE_NAME", "eventplanner-api"),
+
+		NotificationBundleSeconds: envInt64("NOTIFICATION_BUNDLE_SECONDS", 120),
+
+		CaptchaProvider: envString("CAPTCHA_PROVIDER", ""),
+		CaptchaSecret:   envString("CAPTCHA_SECRET", ""),
+
+		StorageDriver:        envString("STORAGE_DRIVER", "local"),
+		S3Endpoint:           strings.TrimRight(envString("S3_ENDPOINT", ""), "/"),
+		S3Region:             envString("S3_REGION", "us-east-1"),
+		S3Bucket:             envString("S3_BUCKET", ""),
+		S3AccessKeyID:        envString("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:    envString("S3_SECRET_ACCESS_KEY", ""),
+		StorageURLTTLSeconds: envInt("STORAGE_URL_TTL_SECONDS", 300),
+
+		AttachmentMaxBytes: envInt64("ATTACHMENT_MAX_BYTES", 10<<20),
+
+		OCRProvider: envString("OCR_PROVIDER", ""),
+		OCREndpoint: envString("OCR_ENDPOINT", ""),
+		OCRAPIKey:   envString("OCR_API_KEY", ""),
+
+		InboundEmailDomain: strings.ToLower(envString("INBOUND_EMAIL_DOMAIN", "")),
+		InboundEmailSecret: envString("INBOUND_EMAIL_SECRET", ""),
+
+		MailDriver:   envString("MAIL_DRIVER", "smtp"),
+		SMTPHost:     envString("SMTP_HOST", ""),
+		SMTPPort:     envInt("SMTP_PORT", 587),
+		SMTPUsername: envString("SMTP_USERNAME", ""),
+		SMTPPassword: envString("SMTP_PASSWORD", ""),
+		MailFrom:     envString("MAIL_FROM", "no-reply@localhost"),
+
+		MaintenanceMode:       envBool("MAINTENANCE_MODE", false),
+		MaintenanceMessage:    envString("MAINTENANCE_MESSAGE", ""),
+		MaintenanceRetryAfter: envInt("MAINTENANCE_RETRY_AFTER", 300),
+
+		HolidayProvider: envString("HOLIDAY_PROVIDER", "builtin"),
+
+		ExchangeRateProvider: envString("EXCHANGE_RATE_PROVIDER", "none"),
+		GeoCountryHeader:     envString("GEO_COUNTRY_HEADER", "CF-IPCountry"),
+
+		WeatherProvider: envString("WEATHER_PROVIDER", "none"),
+	}
+}
+
+func envString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt64(key string, def int64) int64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("⚠️ Warning: invalid %s=%q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envInt(key string, def int) int {
+	return int(envInt64(key, int64(def)))
+}
+
+func envBool(key string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("⚠️ Warning: invalid %s=%q, using %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+func envList(key string, def []string) []string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	out := []string{}
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
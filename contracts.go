@@ -0,0 +1,498 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Contracts are a lightweight stand-in for e-signing: an organizer uploads
+// the document and asks a co-organizer to approve it, who approves or
+// rejects it with a comment. Each upload is a new version, and any
+// pending request is withdrawn by it, so an approval always covers exactly
+// the file that was reviewed. An approved contract is locked.
+
+const (
+	ContractDraft    = "draft"
+	ContractPending  = "pending"
+	ContractApproved = "approved"
+	ContractRejected = "rejected"
+
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalWithdrawn = "withdrawn"
+)
+
+var (
+	errContractLocked  = errors.New("the contract is approved and locked")
+	errApprovalPending = errors.New("the contract is already awaiting approval")
+	errNoApproval      = errors.New("you have no pending approval request for this contract")
+)
+
+type ContractVersionView struct {
+	ContractVersion
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+type ContractView struct {
+	Contract
+	Latest    *ContractVersionView  `json:"latest,omitempty"`
+	Versions  []ContractVersionView `json:"versions,omitempty"`
+	Approvals []ContractApproval    `json:"approvals,omitempty"`
+}
+
+func contractVersionViews(list []ContractVersion) []ContractVersionView {
+	expires := signedURLExpiry()
+	out := make([]ContractVersionView, 0, len(list))
+	for _, v := range list {
+		cv := ContractVersionView{ContractVersion: v, URLExpiresAt: expires}
+		cv.URL, _ = Storage.URL(v.StorageKey, Download{
+			FileName:    v.FileName,
+			ContentType: v.ContentType,
+			Inline:      v.ContentType == "application/pdf",
+		}, expires)
+		out = append(out, cv)
+	}
+	return out
+}
+
+// removeContractVersion deletes the version's file and releases its
+// storage, once its row is gone.
+func removeContractVersion(v ContractVersion) {
+	removeStoredFile(v.StorageKey)
+	reserveStorage(v.UploadedByID, -v.Size)
+}
+
+// storeContractUpload reads the multipart "file" and stores it as the
+// given version of the contract. The row is the caller's to save.
+func storeContractUpload(c *gin.Context, userID uint, ev Event, contractID uint, version int) (ContractVersion, bool) {
+	name, data, contentType, ext, ok := readAttachmentUpload(c)
+	if !ok {
+		return ContractVersion{}, false
+	}
+	size := int64(len(data))
+	if err := reserveStorage(userID, size); err != nil {
+		quotaError(c, "storage", effectiveLimits(userID).StorageQuotaBytes)
+		return ContractVersion{}, false
+	}
+	key, err := newStorageKey(fmt.Sprintf("contracts/%d", ev.ID), ext)
+	if err == nil {
+		err = Storage.Put(c.Request.Context(), key, data, contentType)
+	}
+	if err != nil {
+		reserveStorage(userID, -size)
+		jsonError(c, http.StatusInternalServerError, "could not store contract: "+err.Error())
+		return ContractVersion{}, false
+	}
+	return ContractVersion{
+		ContractID:   contractID,
+		Version:      version,
+		UploadedByID: userID,
+		FileName:     name,
+		ContentType:  contentType,
+		Size:         size,
+		StorageKey:   key,
+	}, true
+}
+
+func loadEventContract(c *gin.Context, ev Event) (Contract, bool) {
+	var ct Contract
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("contractId"), ev.ID).First(&ct).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "contract not found")
+		return ct, false
+	}
+	return ct, true
+}
+
+// contractView has the contract with every version and approval request,
+// newest first.
+func contractView(ct Contract) ContractView {
+	var versions []ContractVersion
+	DB.Where("contract_id = ?", ct.ID).Order("version desc").Find(&versions)
+	var approvals []ContractApproval
+	DB.Where("contract_id = ?", ct.ID).Order("created_at desc, id desc").Find(&approvals)
+	v := ContractView{Contract: ct, Versions: contractVersionViews(versions), Approvals: approvals}
+	if len(v.Versions) > 0 {
+		v.Latest = &v.Versions[0]
+	}
+	return v
+}
+
+// CreateContract uploads a new contract from a multipart "file", titled
+// "title" or after the file.
+func CreateContract(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	version, ok := storeContractUpload(c, userID, ev, 0, 1)
+	if !ok {
+		return
+	}
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = version.FileName
+	}
+	ct := Contract{EventID: ev.ID, Title: title, Status: ContractDraft, CurrentVersion: 1, CreatedByID: userID}
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ct).Error; err != nil {
+			return err
+		}
+		version.ContractID = ct.ID
+		return tx.Create(&version).Error
+	}); err != nil {
+		removeContractVersion(version)
+		jsonError(c, http.StatusInternalServerError, "could not save contract: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, contractView(ct))
+}
+
+func GetContracts(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	query, page, ok := paginate(c, DB.Model(&Contract{}).Where("event_id = ?", ev.ID))
+	if !ok {
+		return
+	}
+	var contracts []Contract
+	if err := query.Order("created_at desc, id desc").Find(&contracts).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	ids := make([]uint, 0, len(contracts))
+	for _, ct := range contracts {
+		ids = append(ids, ct.ID)
+	}
+	var versions []ContractVersion
+	if len(ids) > 0 {
+		DB.Where("contract_id IN ?", ids).
+			Where("version = (SELECT current_version FROM contracts WHERE contracts.id = contract_versions.contract_id)").
+			Find(&versions)
+	}
+	latest := map[uint]ContractVersionView{}
+	for _, v := range contractVersionViews(versions) {
+		latest[v.ContractID] = v
+	}
+
+	out := make([]ContractView, 0, len(contracts))
+	for _, ct := range contracts {
+		v := ContractView{Contract: ct}
+		if l, ok := latest[ct.ID]; ok {
+			v.Latest = &l
+		}
+		out = append(out, v)
+	}
+	writePage(c, page, out, len(out))
+}
+
+// GetContract returns a contract with all its versions and approval
+// requests.
+func GetContract(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	ct, ok := loadEventContract(c, ev)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, contractView(ct))
+}
+
+// UploadContractVersion replaces the document with a new version from a
+// multipart "file". A pending approval request is withdrawn, since it
+// was for the previous file.
+func UploadContractVersion(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	ct, ok := loadEventContract(c, ev)
+	if !ok {
+		return
+	}
+	if ct.LockedAt != nil {
+		jsonError(c, http.StatusConflict, errContractLocked.Error())
+		return
+	}
+
+	version, ok := storeContractUpload(c, userID, ev, ct.ID, 0)
+	if !ok {
+		return
+	}
+	var withdrawn []ContractApproval
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, ct.ID).Error; err != nil {
+			return err
+		}
+		if ct.LockedAt != nil {
+			return errContractLocked
+		}
+		tx.Where("contract_id = ? AND status = ?", ct.ID, ApprovalPending).Find(&withdrawn)
+		if err := tx.Model(&ContractApproval{}).Where("contract_id = ? AND status = ?", ct.ID, ApprovalPending).
+			Update("status", ApprovalWithdrawn).Error; err != nil {
+			return err
+		}
+		version.Version = ct.CurrentVersion + 1
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		ct.CurrentVersion, ct.Status = version.Version, ContractDraft
+		return tx.Model(&ct).Updates(map[string]interface{}{"current_version": ct.CurrentVersion, "status": ct.Status}).Error
+	})
+	if err != nil {
+		removeContractVersion(version)
+		if errors.Is(err, errContractLocked) {
+			jsonError(c, http.StatusConflict, err.Error())
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "could not save contract: "+err.Error())
+		return
+	}
+	for _, a := range withdrawn {
+		NotifyTemplate(a.ApproverID, "contract_approval_withdrawn",
+			map[string]string{"EventTitle": ev.Title, "Contract": ct.Title},
+			gin.H{"event_id": ev.ID, "contract_id": ct.ID, "approval_id": a.ID})
+	}
+	c.JSON(http.StatusCreated, contractView(ct))
+}
+
+type ContractApprovalRequest struct {
+	ApproverID uint   `json:"approver_id" binding:"required"`
+	Message    string `json:"message"`
+}
+
+// RequestContractApproval asks a co-organizer to approve the current
+// version.
+func RequestContractApproval(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	ct, ok := loadEventContract(c, ev)
+	if !ok {
+		return
+	}
+
+	var body ContractApprovalRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.ApproverID == userID {
+		jsonError(c, http.StatusBadRequest, "you can't approve your own request")
+		return
+	}
+	if !slices.Contains(eventOrganizerIDs(ev), body.ApproverID) {
+		jsonError(c, http.StatusBadRequest, "the approver must be an organizer of this event")
+		return
+	}
+
+	approval := ContractApproval{
+		ContractID:    ct.ID,
+		RequestedByID: userID,
+		ApproverID:    body.ApproverID,
+		Message:       strings.TrimSpace(body.Message),
+		Status:        ApprovalPending,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, ct.ID).Error; err != nil {
+			return err
+		}
+		switch {
+		case ct.LockedAt != nil:
+			return errContractLocked
+		case ct.Status == ContractPending:
+			return errApprovalPending
+		}
+		approval.Version = ct.CurrentVersion
+		if err := tx.Create(&approval).Error; err != nil {
+			return err
+		}
+		ct.Status = ContractPending
+		return tx.Model(&ct).Update("status", ct.Status).Error
+	})
+	switch {
+	case errors.Is(err, errContractLocked), errors.Is(err, errApprovalPending):
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "could not request approval: "+err.Error())
+		return
+	}
+
+	NotifyTemplate(body.ApproverID, "contract_approval_requested",
+		map[string]string{"EventTitle": ev.Title, "Name": userEmails([]uint{userID})[userID], "Contract": ct.Title, "Message": approval.Message},
+		gin.H{"event_id": ev.ID, "contract_id": ct.ID, "approval_id": approval.ID})
+	c.JSON(http.StatusCreated, approval)
+}
+
+type ContractDecisionRequest struct {
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment"`
+}
+
+// DecideContract records the caller's answer to their pending approval
+// request. Approving locks the contract at the approved version.
+func DecideContract(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	ct, ok := loadEventContract(c, ev)
+	if !ok {
+		return
+	}
+
+	var body ContractDecisionRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	body.Comment = strings.TrimSpace(body.Comment)
+	if !body.Approve && body.Comment == "" {
+		jsonError(c, http.StatusBadRequest, "comment is required when rejecting")
+		return
+	}
+
+	var approval ContractApproval
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, ct.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("contract_id = ? AND approver_id = ? AND status = ?", ct.ID, userID, ApprovalPending).
+			First(&approval).Error; err != nil {
+			return errNoApproval
+		}
+		now := time.Now()
+		approval.Status, approval.Comment, approval.DecidedAt = ApprovalRejected, body.Comment, &now
+		ct.Status = ContractRejected
+		if body.Approve {
+			approval.Status, ct.Status, ct.LockedAt = ApprovalApproved, ContractApproved, &now
+		}
+		if err := tx.Save(&approval).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ct).Updates(map[string]interface{}{"status": ct.Status, "locked_at": ct.LockedAt}).Error
+	})
+	switch {
+	case errors.Is(err, errNoApproval):
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "could not record decision: "+err.Error())
+		return
+	}
+
+	action := "contract.reject"
+	if body.Approve {
+		action = "contract.approve"
+	}
+	Audit(userID, action, "contract", ct.ID, gin.H{"event_id": ev.ID, "version": approval.Version, "comment": approval.Comment})
+	NotifyTemplate(approval.RequestedByID, "contract_decided",
+		map[string]string{"EventTitle": ev.Title, "Name": userEmails([]uint{userID})[userID], "Contract": ct.Title, "Decision": approval.Status, "Comment": approval.Comment},
+		gin.H{"event_id": ev.ID, "contract_id": ct.ID, "approval_id": approval.ID})
+	c.JSON(http.StatusOK, contractView(ct))
+}
+
+// DeleteContract removes a contract that hasn't been approved, with all
+// its versions.
+func DeleteContract(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	ct, ok := loadEventContract(c, ev)
+	if !ok {
+		return
+	}
+
+	var versions []ContractVersion
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, ct.ID).Error; err != nil {
+			return err
+		}
+		if ct.LockedAt != nil {
+			return errContractLocked
+		}
+		tx.Where("contract_id = ?", ct.ID).Find(&versions)
+		return deleteContractsTx(tx, []uint{ct.ID})
+	})
+	switch {
+	case errors.Is(err, errContractLocked):
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+	for _, v := range versions {
+		removeContractVersion(v)
+	}
+	Audit(userID, "contract.delete", "contract", ct.ID, gin.H{"event_id": ev.ID, "title": ct.Title})
+	c.JSON(http.StatusOK, gin.H{"message": "contract deleted"})
+}
+
+// deleteContractsTx deletes the contracts' rows; their files are the
+// caller's to remove after commit.
+func deleteContractsTx(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("contract_id IN ?", ids).Delete(&ContractApproval{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("contract_id IN ?", ids).Delete(&ContractVersion{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&Contract{}).Error
+}
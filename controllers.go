@@ -1,13 +1,18 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func jsonError(c *gin.Context, code int, msg string) {
@@ -34,10 +39,24 @@ func getUserIDFromContext(c *gin.Context) (uint, bool) {
 }
 
 type CreateEventRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
-	Location    string `json:"location"`
-	Date        string `json:"date" binding:"required"` // expect ISO8601 or "YYYY-MM-DD"
+	Title        string `json:"title" binding:"required"`
+	Description  string `json:"description"`
+	Location     string `json:"location"`
+	Date         string `json:"date" binding:"required"` // expect ISO8601 or "YYYY-MM-DD"
+	MaxAttendees *int   `json:"max_attendees"`
+
+	Accessibility *Accessibility `json:"accessibility"`
+
+	// Organizer-only fields
+	PrivateNotes   string `json:"private_notes"`
+	VendorContacts string `json:"vendor_contacts"`
+	BudgetDetails  string `json:"budget_details"`
+
+	// Create the event for a user who delegated event management to the caller
+	OnBehalfOf *uint `json:"on_behalf_of"`
+
+	// Create the event even though its venue is booked at the same time
+	AllowDoubleBooking bool `json:"allow_double_booking"`
 }
 
 func CreateEvent(c *gin.Context) {
@@ -48,51 +67,234 @@ func CreateEvent(c *gin.Context) {
 	}
 
 	var body CreateEventRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid request: "+err.Error())
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid request", err)
 		return
 	}
 
-	var eventDate time.Time
-	var err error
-	eventDate, err = time.Parse(time.RFC3339, body.Date)
+	eventDate, err := validateEventFields(&body.Title, &body.Date, body.Accessibility)
 	if err != nil {
-		eventDate, err = time.Parse("2006-01-02", body.Date)
-		if err != nil {
-			jsonError(c, http.StatusBadRequest, "invalid date format (use RFC3339 or YYYY-MM-DD)")
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.MaxAttendees != nil && *body.MaxAttendees < 1 {
+		jsonError(c, http.StatusBadRequest, "max_attendees must be at least 1")
+		return
+	}
+	var access Accessibility
+	if body.Accessibility != nil {
+		access = *body.Accessibility
+	}
+
+	organizerID := userID
+	if body.OnBehalfOf != nil && *body.OnBehalfOf != userID {
+		if !isDelegateOf(*body.OnBehalfOf, userID) {
+			jsonError(c, http.StatusForbidden, "you don't manage events for this user")
 			return
 		}
+		organizerID = *body.OnBehalfOf
+	}
+
+	if err := checkEventQuota(organizerID); err != nil {
+		quotaError(c, "events per month", int64(effectiveLimits(organizerID).MaxEventsPerMonth))
+		return
 	}
-	now := time.Now()
 
-	if !eventDate.After(now) {
-		jsonError(c, http.StatusBadRequest, "event date must be in the future")
+	clashes := venueClashes(organizerID, body.Location, eventDate)
+	if len(clashes) > 0 && !body.AllowDoubleBooking {
+		venueClashConflict(c, clashes)
 		return
 	}
 
 	ev := Event{
-		Title:       strings.TrimSpace(body.Title),
-		Description: body.Description,
-		Location:    body.Location,
-		Date:        eventDate,
-		OrganizerID: userID,
+		Title:        body.Title,
+		Description:  body.Description,
+		Location:     body.Location,
+		Date:         eventDate,
+		OrganizerID:  organizerID,
+		MaxAttendees: body.MaxAttendees,
+
+		Accessibility: access,
+
+		PrivateNotes:   body.PrivateNotes,
+		VendorContacts: body.VendorContacts,
+		BudgetDetails:  body.BudgetDetails,
 	}
 
-	if err := DB.Create(&ev).Error; err != nil {
+	if err := saveNewEvent(&ev); err != nil {
 		jsonError(c, http.StatusInternalServerError, "could not create event: "+err.Error())
 		return
 	}
 
+	view := eventView(ev, true)
+	view.Warnings = append(holidayWarnings(c, userID, ev.Date), venueClashWarnings(clashes)...)
+	c.JSON(http.StatusCreated, view)
+}
+
+// validateEventFields checks the fields CreateEvent and UpdateEvent share,
+// trimming the title, and returns the parsed date. Nil fields, which an
+// update leaves alone, pass.
+func validateEventFields(title, date *string, access *Accessibility) (time.Time, error) {
+	if title != nil {
+		*title = strings.TrimSpace(*title)
+		if *title == "" {
+			return time.Time{}, errors.New("title cannot be empty")
+		}
+	}
+	if access != nil {
+		if err := access.validate(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if date == nil {
+		return time.Time{}, nil
+	}
+	return parseEventDate(*date)
+}
+
+// parseEventDate accepts RFC3339 or YYYY-MM-DD and requires a future date.
+func parseEventDate(s string) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		date, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			return date, errors.New("invalid date format (use RFC3339 or YYYY-MM-DD)")
+		}
+	}
+	if !date.After(time.Now()) {
+		return date, errors.New("event date must be in the future")
+	}
+	return date, nil
+}
+
+type UpdateEventRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Location    *string `json:"location"`
+	Date        *string `json:"date"` // same formats as CreateEventRequest.Date
+
+	Accessibility *Accessibility `json:"accessibility"` // replaces all of it
+}
+
+// UpdateEvent edits an event in place; omitted fields are left alone.
+func UpdateEvent(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body UpdateEventRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+
+	date, err := validateEventFields(body.Title, body.Date, body.Accessibility)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.Title != nil {
+		updates["title"] = *body.Title
+	}
+	if body.Description != nil {
+		// BeforeSave doesn't see a map update, so render here
+		updates["description"] = *body.Description
+		updates["description_html"] = RenderRichText(*body.Description)
+	}
+	if body.Location != nil {
+		updates["location"] = *body.Location
+	}
+	if body.Accessibility != nil {
+		for col, v := range body.Accessibility.columns() {
+			updates[col] = v
+		}
+	}
+	dateChanged := false
+	if body.Date != nil {
+		updates["date"] = date
+		dateChanged = !date.Equal(ev.Date)
+		// a series repeats from its date: the occurrences shift, so changes
+		// made to single ones no longer apply
+		if r, _ := eventRule(ev); r != nil && dateChanged {
+			updates["series_ends_at"] = seriesEnd(r, date.In(recurrenceLocation(ev.RecurrenceTimezone)))
+		}
+	}
+	if len(updates) == 0 {
+		jsonError(c, http.StatusBadRequest, "nothing to update")
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if _, shifted := updates["series_ends_at"]; shifted {
+			if err := deleteOccurrenceChanges(tx, ev.ID); err != nil {
+				return err
+			}
+		}
+		if dateChanged {
+			if err := rearmReminders(tx, ev.ID, updates["date"].(time.Time)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update event: "+err.Error())
+		return
+	}
+	DB.First(&ev, ev.ID)
+	if body.Description != nil {
+		queueLinkPreviews(ev.Description)
+	}
+
+	broadcastEventUpdated(ev)
+
+	view := eventView(ev, true)
+	if dateChanged {
+		view.Warnings = holidayWarnings(c, userID, ev.Date)
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// saveNewEvent inserts the event and the organizer's attendee row.
+func saveNewEvent(ev *Event) error {
+	if err := insertEvent(DB, ev); err != nil {
+		return err
+	}
+	Meter(ev.OrganizerID, MetricEventsCreated, 1)
+	queueLinkPreviews(ev.Description)
+	return nil
+}
+
+// insertEvent creates ev together with its organizer's attendee row.
+func insertEvent(tx *gorm.DB, ev *Event) error {
+	if err := tx.Create(ev).Error; err != nil {
+		return err
+	}
+
 	org := EventAttendee{
 		EventID: ev.ID,
-		UserID:  userID,
+		UserID:  ev.OrganizerID,
 		Role:    "organizer",
 		Status:  "",
 	}
 
-	_ = DB.Where("event_id = ? AND user_id = ?", ev.ID, userID).FirstOrCreate(&org)
-
-	c.JSON(http.StatusCreated, ev)
+	_ = tx.Where("event_id = ? AND user_id = ?", ev.ID, ev.OrganizerID).FirstOrCreate(&org)
+	recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	recordChange(tx, EntityAttendee, org.ID, ev.ID, ChangeUpsert)
+	return nil
 }
 
 func GetOrganizedEvents(c *gin.Context) {
@@ -102,18 +304,31 @@ func GetOrganizedEvents(c *gin.Context) {
 		return
 	}
 
-	var events []Event
-	if err := DB.Preload("Tasks").
+	query := ReadDB.Model(&Event{}).
 		Joins("LEFT JOIN event_attendees ea ON ea.event_id = events.id").
-		Where("events.organizer_id = ? OR (ea.user_id = ? AND ea.role = ?)", userID, userID, "organizer").
-		Group("events.id").
-		Order("events.date asc").
-		Find(&events).Error; err != nil {
+		Where("events.organizer_id = ? OR events.organizer_id IN (?) OR (ea.user_id = ? AND ea.role = ? AND ea.invitation IN ?)",
+			userID, principalsQuery(userID), userID, "organizer", attendingInvitation)
+	query, err := applyEventListParams(c, query)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	query, page, ok := paginate(c, query.Group("events.id"))
+	if !ok {
+		return
+	}
+
+	var events []Event
+	if err := query.Preload("Tasks").Find(&events).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, events)
+	attachLinkPreviews(events)
+	views := eventViews(events, userID)
+	attachOccurrences(c, views, events)
+	attachHealth(views, events)
+	writePage(c, page, views, len(views))
 }
 
 func GetInvitedEvents(c *gin.Context) {
@@ -124,13 +339,19 @@ func GetInvitedEvents(c *gin.Context) {
 	}
 
 	var attendances []EventAttendee
-	if err := DB.Where("user_id = ? AND role IN ?", userID, []string{"attendee", "organizer"}).Find(&attendances).Error; err != nil {
+	// pending invitations from suspended users are frozen; declined ones are gone
+	if err := ReadDB.Where("user_id = ? AND role IN ?", userID, []string{"attendee", "organizer"}).
+		Where("invitation <> ?", InvitationDeclined).
+		Where("NOT (invitation = ? AND invited_by_id IN (?))", InvitationPending, suspendedUsersQuery()).
+		Find(&attendances).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
 
 	if len(attendances) == 0 {
-		c.JSON(http.StatusOK, []Event{})
+		if page, ok := pageParams(c); ok {
+			writePage(c, page, []EventView{}, 0)
+		}
 		return
 	}
 
@@ -139,91 +360,178 @@ func GetInvitedEvents(c *gin.Context) {
 		ids = append(ids, a.EventID)
 	}
 
-	var events []Event
-	if err := DB.Preload("Tasks").Where("id IN ?", ids).Order("date asc").Find(&events).Error; err != nil {
-		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+	query, err := applyEventListParams(c, ReadDB.Model(&Event{}).Where("events.id IN ?", ids))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	c.JSON(http.StatusOK, events)
-}
-
-func DeleteEvent(c *gin.Context) {
-	userID, ok := getUserIDFromContext(c)
+	query, page, ok := paginate(c, query)
 	if !ok {
-		jsonError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	idParam := c.Param("id")
-	if idParam == "" {
-		jsonError(c, http.StatusBadRequest, "missing event id")
-		return
-	}
-	id, err := strconv.Atoi(idParam)
-	if err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid event id")
-		return
-	}
-
-	var ev Event
-	if err := DB.First(&ev, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			jsonError(c, http.StatusNotFound, "event not found")
-			return
-		}
+	var events []Event
+	if err := query.Preload("Tasks").Find(&events).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
 
-	if ev.OrganizerID != userID {
-		jsonError(c, http.StatusForbidden, "only organizer can delete the event")
-		return
-	}
+	attachLinkPreviews(events)
+	views := eventViews(events, userID)
+	attachOccurrences(c, views, events)
+	writePage(c, page, views, len(views))
+}
 
+// DeleteEvent runs behind RequireEventRole(EventRoleOwner).
+func DeleteEvent(c *gin.Context) {
+	ev, _ := eventFromContext(c)
+
+	var files eventFiles
 	if err := DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("event_id = ?", ev.ID).Delete(&EventAttendee{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("event_id = ?", ev.ID).Delete(&Task{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Delete(&Event{}, ev.ID).Error; err != nil {
-			return err
-		}
-		return nil
+		var err error
+		files, err = deleteEventTx(tx, ev)
+		return err
 	}); err != nil {
 		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
 		return
 	}
+	files.remove()
 
 	c.JSON(http.StatusOK, gin.H{"message": "event deleted"})
 }
 
+// deleteEventTx removes an event with everything attached to it and
+// tombstones it for everyone who could see it. It returns the stored
+// files to remove once the transaction commits.
+func deleteEventTx(tx *gorm.DB, ev Event) (eventFiles, error) {
+	var files eventFiles
+	tx.Where("event_id = ? AND receipt_path <> ''", ev.ID).Find(&files.receipts)
+	tx.Where("event_id = ?", ev.ID).Find(&files.attachments)
+	if ev.CoverKey != "" {
+		files.covers = append(files.covers, ev)
+	}
+	var contractIDs []uint
+	tx.Model(&Contract{}).Where("event_id = ?", ev.ID).Pluck("id", &contractIDs)
+	if len(contractIDs) > 0 {
+		tx.Where("contract_id IN ?", contractIDs).Find(&files.contracts)
+	}
+
+	// everyone who could see the event gets a sync tombstone
+	var audience []uint
+	tx.Model(&EventAttendee{}).Where("event_id = ? AND user_id <> ?", ev.ID, ev.OrganizerID).Pluck("user_id", &audience)
+	var delegates []uint
+	tx.Model(&Delegation{}).Where("principal_id = ? AND revoked_at IS NULL", ev.OrganizerID).Pluck("delegate_id", &delegates)
+	audience = append(append(audience, ev.OrganizerID), delegates...)
+
+	if err := tx.Where("attendee_id IN (?)", tx.Model(&EventAttendee{}).Select("id").Where("event_id = ?", ev.ID)).
+		Delete(&AttendeeAnswer{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventField{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Announcement{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventComment{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Attachment{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&ResourceBooking{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&WaitlistEntry{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventAlert{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&GuestRSVP{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventSlugHistory{}).Error; err != nil {
+		return files, err
+	}
+	if err := deleteOccurrenceChanges(tx, ev.ID); err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&KioskToken{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&SessionRegistration{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventSession{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&RunOfShowItem{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&WeatherContingency{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&TicketTier{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Reminder{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&CancellationPolicy{}).Error; err != nil {
+		return files, err
+	}
+	// issued certificates stay verifiable; only the wording goes
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&CertificateTemplate{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Expense{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&VendorQuote{}).Error; err != nil {
+		return files, err
+	}
+	if err := deleteContractsTx(tx, contractIDs); err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Vendor{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&BudgetCategory{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&EventAttendee{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Where("event_id = ?", ev.ID).Delete(&Task{}).Error; err != nil {
+		return files, err
+	}
+	// merged-away events pointing here have nowhere left to go
+	if err := tx.Where("to_event_id = ?", ev.ID).Delete(&EventRedirect{}).Error; err != nil {
+		return files, err
+	}
+	if err := tx.Unscoped().Delete(&Event{}, ev.ID).Error; err != nil {
+		return files, err
+	}
+	recordEventRemoved(tx, ev.ID, audience)
+	return files, nil
+}
+
 type InviteRequest struct {
 	UserID uint   `json:"user_id" binding:"required"`
 	Role   string `json:"role" binding:"required"` // "attendee" or "organizer"
 }
 
+// InviteUser runs behind RequireEventRole(eventOrganizerRoles...).
 func InviteUser(c *gin.Context) {
-	userID, ok := getUserIDFromContext(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	// parse event id
-	eventID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event id"})
-		return
-	}
-	eventID := uint(eventID64)
+	userID, _ := getUserIDFromContext(c)
+	ev, _ := eventFromContext(c)
+	eventID := ev.ID
 
 	// bind request
 	var body InviteRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
 		return
 	}
 
@@ -233,67 +541,105 @@ func InviteUser(c *gin.Context) {
 		return
 	}
 
-	// event exists?
-	var ev Event
-	if err := DB.First(&ev, eventID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+	ctx := c.Request.Context()
+	db := dbFor(c)
+
+	// check invitee exists
+	var invitee User
+	if err := db.First(&invitee, body.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invited user not found"})
 		return
 	}
 
-	// permission: only organizer can invite
-	var inviterAtt EventAttendee
-	inviterIsOrganizer := (ev.OrganizerID == userID)
-
-	if !inviterIsOrganizer {
-		// Maybe they were added as organizer previously
-		err := DB.Where("event_id = ? AND user_id = ? AND role = ?", eventID, userID, "organizer").
-			First(&inviterAtt).Error
-		if err == nil {
-			inviterIsOrganizer = true
-		}
+	// respect the invitee's privacy settings
+	_, span := startSpan(ctx, "invite.check_privacy")
+	allowed := canInvite(userID, invitee)
+	span.End()
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this user does not accept invitations from you"})
+		return
 	}
 
-	if !inviterIsOrganizer {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only organizers can invite"})
+	// check if already invited
+	var existing EventAttendee
+	if err := db.Where("event_id = ? AND user_id = ?", eventID, invitee.ID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "user already a participant"})
 		return
 	}
 
-	// check invitee exists
-	var invitee User
-	if err := DB.First(&invitee, body.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invited user not found"})
+	// plan limits and the spam heuristics behind the invite quota are the
+	// expensive part of inviting
+	_, span = startSpan(ctx, "invite.attendee_quota")
+	err := checkAttendeeQuota(ev)
+	endSpan(span, err)
+	if err != nil {
+		quotaError(c, "attendees per event", int64(effectiveLimits(ev.OrganizerID).MaxAttendeesPerEvent))
 		return
 	}
 
-	// check if already invited
-	var existing EventAttendee
-	if err := DB.Where("event_id = ? AND user_id = ?", eventID, invitee.ID).First(&existing).Error; err == nil {
-		c.JSON(http.StatusOK, gin.H{"message": "user already a participant"})
+	_, span = startSpan(ctx, "invite.invite_quota")
+	quota := inviteQuota(userID)
+	span.SetAttributes(attribute.Bool("invite.restricted", quota.Restricted))
+	span.End()
+	if quota.exhausted() {
+		inviteQuotaError(c, quota)
 		return
 	}
 
 	// create attendee
 	newAtt := EventAttendee{
-		EventID: eventID,
-		UserID:  invitee.ID,
-		Role:    role,
-		Status:  "",
+		EventID:     eventID,
+		UserID:      invitee.ID,
+		Role:        role,
+		Status:      "",
+		InvitedByID: &userID,
+		Invitation:  InvitationPending,
 	}
 
-	if err := DB.Create(&newAtt).Error; err != nil {
+	if err := db.Create(&newAtt).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create invitation: " + err.Error()})
 		return
 	}
+	recordChange(db, EntityAttendee, newAtt.ID, eventID, ChangeUpsert)
+
+	var inviter User
+	db.Select("id", "email").First(&inviter, userID)
+	vars := map[string]string{"EventTitle": ev.Title, "InviterEmail": inviter.Email, "StartsAt": startsAtText(ev), "Location": ev.Location}
+	NotifyTemplate(invitee.ID, "invitation", vars, gin.H{"event_id": ev.ID})
+	EmailTemplate(invitee.ID, "invitation", vars, ev)
+
+	quota.HourlyRemaining--
+	quota.DailyRemaining--
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User invited successfully",
-		"user_id": invitee.ID,
-		"role":    role,
+		"message":       "User invited successfully",
+		"user_id":       invitee.ID,
+		"role":          role,
+		"invitation_id": newAtt.ID,
+		"invitation":    newAtt.Invitation,
+		"invite_quota":  quota,
 	})
 }
 
+// rsvpStatuses are the answers an invitee can give. Attendees can also be
+// StatusWaitlisted, or "" while they haven't answered.
+var rsvpStatuses = []string{"Going", "Maybe", "Not Going"}
+
+// attendeeRoles are the values of EventAttendee.Role.
+var attendeeRoles = []string{"organizer", "attendee"}
+
+// normalizeRSVPStatus accepts an answer in any case ("not going").
+func normalizeRSVPStatus(s string) (string, bool) {
+	s = strings.Title(strings.ToLower(strings.TrimSpace(s)))
+	return s, slices.Contains(rsvpStatuses, s)
+}
+
 type AttendanceRequest struct {
-	Status string `json:"status" binding:"required"`
+	Status  string                 `json:"status" binding:"required"`
+	Answers map[string]interface{} `json:"answers"` // custom attendee fields, by key
+
+	TicketTierID *uint           `json:"ticket_tier_id"` // required going to an event with tiers
+	Billing      *BillingRequest `json:"billing"`        // for priced tiers; defaults to the saved details
 }
 
 func SetAttendance(c *gin.Context) {
@@ -312,13 +658,13 @@ func SetAttendance(c *gin.Context) {
 	eventID := uint(eventID64)
 
 	var body AttendanceRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid body: "+err.Error())
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
 		return
 	}
-	normalized := strings.Title(strings.ToLower(strings.TrimSpace(body.Status)))
-	if normalized != "Going" && normalized != "Maybe" && normalized != "Not Going" {
-		jsonError(c, http.StatusBadRequest, "status must be one of: Going, Maybe, Not Going")
+	normalized, ok := normalizeRSVPStatus(body.Status)
+	if !ok {
+		jsonError(c, http.StatusBadRequest, "status must be one of: "+strings.Join(rsvpStatuses, ", "))
 		return
 	}
 
@@ -331,113 +677,345 @@ func SetAttendance(c *gin.Context) {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
 
 	var att EventAttendee
-	if err := DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&att).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	err = DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&att).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	// organizer-defined fields must be filled when accepting
+	answers, verr := validateAnswers(eventID, att.ID, body.Answers, normalized == "Going")
+	if verr != nil {
+		jsonError(c, http.StatusBadRequest, verr.Error())
+		return
+	}
+
+	isNew := err == gorm.ErrRecordNotFound
+	if isNew {
+		if !canViewEvent(ev, userID) {
+			jsonError(c, http.StatusForbidden, "you are not invited to this event")
+			return
+		}
+		if err := checkAttendeeQuota(ev); err != nil {
+			quotaError(c, "attendees per event", int64(effectiveLimits(ev.OrganizerID).MaxAttendeesPerEvent))
+			return
+		}
+	} else if invitationFrozen(att) {
+		jsonError(c, http.StatusConflict, "this invitation is frozen")
+		return
+	}
+
+	tierID := att.TicketTierID
+	if body.TicketTierID != nil {
+		if _, err := eventTier(eventID, *body.TicketTierID); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		tierID = body.TicketTierID
+	}
+	if normalized == "Going" && tierID == nil && hasTicketTiers(eventID) {
+		jsonError(c, http.StatusBadRequest, "ticket_tier_id is required for this event")
+		return
+	}
+	tierChanged := (tierID == nil) != (att.TicketTierID == nil) || (tierID != nil && *tierID != *att.TicketTierID)
+
+	// going on a priced tier is a purchase and needs someone to bill
+	var tier TicketTier
+	if tierID != nil {
+		tier, _ = eventTier(eventID, *tierID)
+	}
+	buying := normalized == "Going" && tier.PriceCents > 0 && (att.Status != "Going" || tierChanged)
+	if body.Billing != nil {
+		if err := body.Billing.validate(); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if _, saved := billingProfileOf(DB, userID); buying && body.Billing == nil && !saved {
+		jsonError(c, http.StatusBadRequest, errBillingRequired.Error())
+		return
+	}
 
+	previous := att.Status
+	var issued, voided *Invoice
+	var promoted []uint
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		// lock the event so concurrent RSVPs can't overshoot capacity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ev, eventID).Error; err != nil {
+			return err
+		}
+
+		status := normalized
+		var scopes []capacityScope
+		if normalized == "Going" && att.Status != "Going" {
+			scopes = append(scopes, capacityScope{ScopeEvent, eventID})
+		}
+		if normalized == "Going" && tierID != nil && (att.Status != "Going" || tierChanged) {
+			scopes = append(scopes, capacityScope{ScopeTicketTier, *tierID})
+		}
+		limits, err := lockCapacity(tx, scopes...)
+		if err != nil {
+			return err
+		}
+		// a sold-out tier refuses; a full event still waitlists
+		if full := firstFull(limits, ScopeTicketTier); full != nil {
+			return &CapacityError{Full: *full, Limits: limits}
+		}
+		if firstFull(limits, ScopeEvent) != nil {
+			status = StatusWaitlisted
+			if err := joinWaitlist(tx, eventID, userID); err != nil {
+				return err
+			}
+		} else if err := leaveWaitlist(tx, eventID, userID); err != nil {
+			return err
+		}
+		if status != "Going" {
+			if err := leaveSessions(tx, eventID, userID); err != nil {
+				return err
+			}
+		}
+
+		if isNew {
 			att = EventAttendee{
 				EventID: eventID,
 				UserID:  userID,
 				Role:    "attendee",
-				Status:  normalized,
+				Status:  status,
+
+				TicketTierID: tierID,
 			}
-			if err := DB.Create(&att).Error; err != nil {
-				jsonError(c, http.StatusInternalServerError, "could not set attendance: "+err.Error())
-				return
+			if err := tx.Create(&att).Error; err != nil {
+				return err
+			}
+		} else {
+			att.Status = status
+			att.TicketTierID = tierID
+			answerInvitation(&att) // answering settles a pending invitation
+			if err := tx.Save(&att).Error; err != nil {
+				return err
+			}
+		}
+		recordChange(tx, EntityAttendee, att.ID, eventID, ChangeUpsert)
+
+		if body.Billing != nil {
+			p := body.Billing.profile(userID)
+			if err := saveBillingProfile(tx, &p); err != nil {
+				return err
+			}
+		}
+		if previous == "Going" && (status != "Going" || tierChanged) {
+			if voided, err = voidInvoice(tx, att.ID); err != nil {
+				return err
+			}
+		}
+		// a seat they gave up goes to the front of the waitlist
+		if previous == "Going" && status != "Going" {
+			if promoted, err = promoteWaitlisted(tx, eventID); err != nil {
+				return err
 			}
-			c.JSON(http.StatusOK, att)
+		}
+		if status == "Going" && buying {
+			bill, _ := billingProfileOf(tx, userID)
+			if issued, err = issueInvoice(tx, ev, att, tier, bill); err != nil {
+				return err
+			}
+		}
+		return saveAnswers(tx, att.ID, answers)
+	}); err != nil {
+		var full *CapacityError
+		if errors.As(err, &full) {
+			capacityConflict(c, full)
 			return
 		}
-		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		jsonError(c, http.StatusInternalServerError, "could not set attendance: "+err.Error())
 		return
 	}
 
-	att.Status = normalized
-	if err := DB.Save(&att).Error; err != nil {
-		jsonError(c, http.StatusInternalServerError, "could not update status: "+err.Error())
+	if ev.OrganizerID != userID {
+		var responder User
+		DB.Select("id", "email").First(&responder, userID)
+		NotifyBundledTemplate(ev.OrganizerID, "rsvp", fmt.Sprintf("rsvp:%d", ev.ID),
+			map[string]string{"EventTitle": ev.Title, "Name": responder.Email, "Status": att.Status}, gin.H{"event_id": ev.ID})
+	}
+	if att.Status != previous && att.Status != StatusWaitlisted {
+		EmailTemplate(userID, "rsvp_confirmation", map[string]string{"EventTitle": ev.Title, "Status": att.Status, "StartsAt": startsAtText(ev)}, ev)
+	}
+
+	view := attendeeView(att, userID, false)
+	if previous == "Going" && att.Status != "Going" && att.TicketTierID != nil {
+		q := ticketCancelled(ev, att, voided)
+		view.Refund = &q
+	}
+	if issued != nil {
+		invoiceIssued(ev, *issued)
+		view.InvoiceID = &issued.ID
+	}
+	if att.Status != previous {
+		broadcastRSVP(ev, userID, att.Status)
+	}
+	notifyPromoted(ev, promoted)
+
+	if att.Status == StatusWaitlisted {
+		c.JSON(http.StatusAccepted, gin.H{
+			"attendee":          view,
+			"waitlist_position": waitlistPosition(eventID, userID),
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, att)
+	c.JSON(http.StatusOK, view)
 }
 
+// GetEventAttendees runs behind RequireEventRole for participants and
+// viewers; organizers also see hidden RSVPs and can filter by label.
 func GetEventAttendees(c *gin.Context) {
-	userID, ok := getUserIDFromContext(c)
-	if !ok {
-		jsonError(c, http.StatusUnauthorized, "unauthorized")
-		return
-	}
+	userID, _ := getUserIDFromContext(c)
+	ev, role := eventFromContext(c)
+	eventID := ev.ID
+	isOrganizer := slices.Contains(eventOrganizerRoles, role)
 
-	idParam := c.Param("id")
-	eventID64, err := strconv.ParseUint(idParam, 10, 64)
-	if err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid event id")
+	label, ok := labelParam(c)
+	if !ok {
 		return
 	}
-	eventID := uint(eventID64)
-
-	var ev Event
-	if err := DB.First(&ev, eventID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			jsonError(c, http.StatusNotFound, "event not found")
+	q := DB.Model(&EventAttendee{}).Where("event_id = ?", eventID)
+	if label != "" {
+		if !isOrganizer {
+			jsonError(c, http.StatusForbidden, "only organizers can filter by label")
 			return
 		}
-		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
-		return
+		q = withLabel(q, label)
 	}
-
-	if ev.OrganizerID != userID {
-		jsonError(c, http.StatusForbidden, "only organizer can view attendees")
+	q, page, ok := paginate(c, q.Order("id asc"))
+	if !ok {
 		return
 	}
 
 	var attendees []EventAttendee
-	if err := DB.Where("event_id = ?", eventID).Find(&attendees).Error; err != nil {
+	if err := q.Find(&attendees).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, attendees)
-}
+	// plain attendees don't see RSVPs of users who hide them
+	if !isOrganizer {
+		maskHiddenRSVPs(attendees, userID)
+	}
 
-type CreateTaskRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+	writePage(c, page, attendeeViews(attendees, userID, isOrganizer), len(attendees))
 }
 
-func CreateTask(c *gin.Context) {
+// RemoveAttendee takes someone off an event along with their answers and
+// waitlist spot. The response carries an undo token for the undo window.
+func RemoveAttendee(c *gin.Context) {
 	userID, ok := getUserIDFromContext(c)
 	if !ok {
 		jsonError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
 
-	idParam := c.Param("id")
-	eventID64, err := strconv.ParseUint(idParam, 10, 64)
+	targetID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
 	if err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid event id")
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if uint(targetID) == ev.OrganizerID {
+		jsonError(c, http.StatusBadRequest, "the organizer cannot be removed")
 		return
 	}
-	eventID := uint(eventID64)
 
-	var ev Event
-	if err := DB.First(&ev, eventID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			jsonError(c, http.StatusNotFound, "event not found")
-			return
+	var snap attendeeSnapshot
+	var undo UndoAction
+	var promoted []uint
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND user_id = ?", ev.ID, targetID).First(&snap.Attendee).Error; err != nil {
+			return err
 		}
-		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		if err := tx.Where("attendee_id = ?", snap.Attendee.ID).Find(&snap.Answers).Error; err != nil {
+			return err
+		}
+		var entry WaitlistEntry
+		if tx.Where("event_id = ? AND user_id = ?", ev.ID, targetID).First(&entry).Error == nil {
+			snap.Waitlisted = &entry
+		}
+
+		if err := tx.Where("attendee_id = ?", snap.Attendee.ID).Delete(&AttendeeAnswer{}).Error; err != nil {
+			return err
+		}
+		if err := leaveWaitlist(tx, ev.ID, uint(targetID)); err != nil {
+			return err
+		}
+		if err := leaveSessions(tx, ev.ID, uint(targetID)); err != nil {
+			return err
+		}
+		// their tasks go back to nobody
+		var assigned []uint
+		tx.Model(&Task{}).Where("event_id = ? AND assignee_id = ?", ev.ID, targetID).Pluck("id", &assigned)
+		if err := tx.Model(&Task{}).Where("id IN ?", assigned).Update("assignee_id", nil).Error; err != nil {
+			return err
+		}
+		for _, id := range assigned {
+			recordChange(tx, EntityTask, id, ev.ID, ChangeUpsert)
+		}
+		if err := tx.Delete(&EventAttendee{}, snap.Attendee.ID).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityAttendee, snap.Attendee.ID, ev.ID, ChangeDelete)
+		recordEventRemoved(tx, ev.ID, []uint{uint(targetID)})
+		// their seat goes to the front of the waitlist
+		if snap.Attendee.Status == "Going" {
+			if promoted, err = promoteWaitlisted(tx, ev.ID); err != nil {
+				return err
+			}
+		}
+
+		undo, err = recordUndo(tx, userID, UndoAttendeeRemove, ev.ID, uint(targetID), snap)
+		return err
+	})
+	if err == gorm.ErrRecordNotFound {
+		jsonError(c, http.StatusNotFound, "user is not an attendee")
 		return
 	}
-	if ev.OrganizerID != userID {
-		jsonError(c, http.StatusForbidden, "only organizer can create tasks")
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not remove attendee: "+err.Error())
 		return
 	}
 
+	Audit(userID, UndoAttendeeRemove, "event", ev.ID, gin.H{"user_id": targetID})
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "attendee_removed", EventID: ev.ID, UserID: uint(targetID)})
+	notifyPromoted(ev, promoted)
+
+	resp := undoResponse(undo)
+	resp["message"] = "attendee removed"
+	c.JSON(http.StatusOK, resp)
+}
+
+type CreateTaskRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	VendorID    *uint  `json:"vendor_id"`
+
+	DueAt *time.Time `json:"due_at"` // RFC3339; defaults to the event's start
+}
+
+// CreateTask runs behind RequireEventRole for the owner and delegates.
+func CreateTask(c *gin.Context) {
+	ev, _ := eventFromContext(c)
+	eventID := ev.ID
+
 	var body CreateTaskRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid body: "+err.Error())
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
 		return
 	}
 
@@ -445,32 +1023,50 @@ func CreateTask(c *gin.Context) {
 		EventID:     eventID,
 		Title:       strings.TrimSpace(body.Title),
 		Description: body.Description,
+		Status:      TaskTodo,
+		Position:    nextTaskPosition(DB, eventID, TaskTodo),
+		VendorID:    body.VendorID,
+		DueAt:       body.DueAt,
+	}
+	if body.VendorID != nil && !eventHasVendor(eventID, *body.VendorID) {
+		jsonError(c, http.StatusBadRequest, "vendor not found for this event")
+		return
 	}
 
 	if err := DB.Create(&task).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "could not create task: "+err.Error())
 		return
 	}
+	recordChange(DB, EntityTask, task.ID, task.EventID, ChangeUpsert)
+	broadcastTask("task_created", task)
 
-	c.JSON(http.StatusCreated, task)
+	c.JSON(http.StatusCreated, taskView(task))
 }
 
+// GetTasksByEvent runs behind RequireEventRole for anyone who can view the
+// event.
 func GetTasksByEvent(c *gin.Context) {
+	ev, _ := eventFromContext(c)
 
-	idParam := c.Param("id")
-	eventID64, err := strconv.ParseUint(idParam, 10, 64)
-	if err != nil {
-		jsonError(c, http.StatusBadRequest, "invalid event id")
+	q := DB.Model(&Task{}).Where("event_id = ?", ev.ID)
+	if status := c.Query("status"); status != "" {
+		if !validTaskStatus(status) {
+			jsonError(c, http.StatusBadRequest, "status must be one of: todo, in_progress, done")
+			return
+		}
+		q = q.Where("status = ?", status)
+	}
+	q, page, ok := paginate(c, q.Order("position asc, id asc"))
+	if !ok {
 		return
 	}
-	eventID := uint(eventID64)
 
 	var tasks []Task
-	if err := DB.Where("event_id = ?", eventID).Find(&tasks).Error; err != nil {
+	if err := q.Find(&tasks).Error; err != nil {
 		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, tasks)
+	writePage(c, page, taskViews(tasks), len(tasks))
 }
 
 type SearchRequest struct {
@@ -496,7 +1092,7 @@ func SearchHandler(c *gin.Context) {
 			_ = err
 		}
 	} else {
-		if err := c.ShouldBindJSON(&req); err != nil {
+		if err := bindJSON(c, &req); err != nil {
 			_ = err
 			_ = c.ShouldBindQuery(&req)
 		}
@@ -534,10 +1130,28 @@ func SearchHandler(c *gin.Context) {
 	keyword := strings.TrimSpace(req.Keyword)
 	kw := "%" + keyword + "%"
 
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	// events come before tasks, so a page can hold the last events and the
+	// first tasks; offset and limit are what's left of the page for each
+	offset, limit, fetched := page.Offset, page.Limit, 0
+	pageOf := func(q *gorm.DB) (*gorm.DB, error) {
+		var n int64
+		if err := q.Session(&gorm.Session{}).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		page.Total += n
+		q = q.Offset(offset).Limit(limit)
+		offset = max(0, offset-int(n))
+		return q, nil
+	}
+
 	results := make([]interface{}, 0)
 
 	if req.Type == "both" || req.Type == "event" {
-		query := DB.Model(&Event{}).Preload("Tasks")
+		query := ReadDB.Model(&Event{})
 
 		if keyword != "" {
 			query = query.Where("title ILIKE ? OR description ILIKE ?", kw, kw)
@@ -562,12 +1176,21 @@ func SearchHandler(c *gin.Context) {
 			}
 		}
 
-		var events []Event
-		if err := query.Order("date asc").Find(&events).Error; err != nil {
+		query, err := pageOf(query)
+		if err != nil {
 			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 			return
 		}
-		for _, e := range events {
+		var events []Event
+		if limit > 0 {
+			if err := query.Preload("Tasks").Order("events.date asc, events.id asc").Find(&events).Error; err != nil {
+				jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+				return
+			}
+		}
+		limit -= len(events)
+		fetched += len(events)
+		for _, e := range eventViews(events, userID) {
 			results = append(results, gin.H{"type": "event", "event": e})
 		}
 	}
@@ -575,7 +1198,7 @@ func SearchHandler(c *gin.Context) {
 	// Search tasks (and attach event info)
 	if req.Type == "both" || req.Type == "task" {
 		// We'll find tasks joining with events to apply date filters and role constraints
-		taskQuery := DB.Model(&Task{}).Joins("JOIN events ON events.id = tasks.event_id")
+		taskQuery := ReadDB.Model(&Task{}).Joins("JOIN events ON events.id = tasks.event_id")
 
 		if keyword != "" {
 			// search task title/description or parent event title/description
@@ -601,22 +1224,30 @@ func SearchHandler(c *gin.Context) {
 		}
 
 		// fetch matching tasks
-		var tasks []Task
-		if err := taskQuery.Select("tasks.*").Order("events.date asc").Find(&tasks).Error; err != nil {
+		taskQuery, err := pageOf(taskQuery)
+		if err != nil {
 			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
 			return
 		}
+		var tasks []Task
+		if limit > 0 {
+			if err := taskQuery.Select("tasks.*").Order("events.date asc, tasks.id asc").Find(&tasks).Error; err != nil {
+				jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+				return
+			}
+		}
+		fetched += len(tasks)
 
 		// attach event data for each task
 		for _, t := range tasks {
 			var ev Event
-			if err := DB.Where("id = ?", t.EventID).First(&ev).Error; err != nil {
+			if err := ReadDB.Where("id = ?", t.EventID).First(&ev).Error; err != nil {
 				// skip if cannot find parent event
 				continue
 			}
-			results = append(results, gin.H{"type": "task", "task": t, "event": ev})
+			results = append(results, gin.H{"type": "task", "task": taskView(t), "event": eventView(ev, isEventOrganizer(ev, userID))})
 		}
 	}
 
-	c.JSON(http.StatusOK, results)
+	writePage(c, page, results, fetched)
 }
@@ -1,24 +1,54 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
+var allowedOrigins = map[string]bool{
+	"http://localhost:4200": true,
+	"https://eventplanner-front-azzohry-dev.apps.rm2.thpm.p1.openshiftapps.com": true,
+}
+
+// openCORSRoutes may be called from any site. Their responses are public,
+// so they are served without credentials.
+var openCORSRoutes = map[string]bool{
+	"/events/:id/summary-card": true,
+}
+
+func allowedOrigin(origin string) bool {
+	return allowedOrigins[origin]
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		allowedOrigins := map[string]bool{
-			"http://localhost:4200": true,
-			"https://eventplanner-front-azzohry-dev.apps.rm2.thpm.p1.openshiftapps.com": true,
+		// the widget checks origins against the event, see WidgetMiddleware
+		if strings.HasPrefix(c.FullPath(), "/widget/") {
+			c.Next()
+			return
+		}
+
+		if openCORSRoutes[c.FullPath()] {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Headers", "Content-Type")
+			c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+			if c.Request.Method == "OPTIONS" {
+				c.AbortWithStatus(204)
+				return
+			}
+			c.Next()
+			return
 		}
 
-		if allowedOrigins[origin] {
+		if allowedOrigin(origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Captcha-Token")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 
 		if c.Request.Method == "OPTIONS" {
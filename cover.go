@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// An event's cover image is kept as uploaded, alongside JPEG copies scaled
+// down to each of coverSizes for listings and pages that don't need the
+// full picture. Event JSON carries signed URLs for all of them.
+
+var coverSizes = []struct {
+	Name  string
+	Width int
+}{
+	{"large", 1600},
+	{"medium", 800},
+	{"thumbnail", 320},
+}
+
+var coverTypes = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+}
+
+const (
+	maxCoverPixels = 40_000_000 // refuse anything bigger before decoding it
+	coverQuality   = 85
+)
+
+// coverSizeKey is where the copy of the cover at one size is kept.
+func coverSizeKey(key, size string) string {
+	base := key
+	if i := strings.LastIndex(key, "."); i > strings.LastIndex(key, "/") {
+		base = key[:i]
+	}
+	return base + "_" + size + ".jpg"
+}
+
+// coverKeys are every file of a cover: the upload and its resized copies.
+func coverKeys(key string) []string {
+	keys := []string{key}
+	for _, s := range coverSizes {
+		keys = append(keys, coverSizeKey(key, s.Name))
+	}
+	return keys
+}
+
+// CoverView has a signed URL for the cover at each size.
+type CoverView struct {
+	Original  string    `json:"original"`
+	Large     string    `json:"large"`
+	Medium    string    `json:"medium"`
+	Thumbnail string    `json:"thumbnail"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func coverView(ev Event) *CoverView {
+	if ev.CoverKey == "" {
+		return nil
+	}
+	expires := signedURLExpiry()
+	url := func(key string) string {
+		u, _ := Storage.URL(key, Download{Inline: true}, expires)
+		return u
+	}
+	return &CoverView{
+		Original:  url(ev.CoverKey),
+		Large:     url(coverSizeKey(ev.CoverKey, "large")),
+		Medium:    url(coverSizeKey(ev.CoverKey, "medium")),
+		Thumbnail: url(coverSizeKey(ev.CoverKey, "thumbnail")),
+		ExpiresAt: expires,
+	}
+}
+
+// removeCover deletes a cover's files and releases its storage.
+func removeCover(organizerID uint, key string, size int64) {
+	if key == "" {
+		return
+	}
+	for _, k := range coverKeys(key) {
+		removeStoredFile(k)
+	}
+	reserveStorage(organizerID, -size)
+}
+
+// scaleToWidth shrinks src to width pixels across, keeping its aspect
+// ratio, by averaging the block of source pixels behind each output pixel.
+// Transparent areas come out white. Images already narrower keep their size.
+func scaleToWidth(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, b.Min, draw.Over)
+	if width >= b.Dx() {
+		return flat
+	}
+
+	sw, sh := b.Dx(), b.Dy()
+	height := max(1, sh*width/sw)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+			var r, g, bl, n int
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[sy*flat.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4:]
+					r, g, bl = r+int(p[0]), g+int(p[1]), bl+int(p[2])
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o], dst.Pix[o+1], dst.Pix[o+2], dst.Pix[o+3] = uint8(r/n), uint8(g/n), uint8(bl/n), 0xff
+		}
+	}
+	return dst
+}
+
+// UploadEventCover replaces the event's cover with a multipart "file"
+// (JPEG, PNG or GIF).
+func UploadEventCover(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	ext, allowed := coverTypes[format]
+	if err != nil || !allowed {
+		jsonError(c, http.StatusUnsupportedMediaType, "cover must be a JPEG, PNG or GIF image")
+		return
+	}
+	if cfg.Width*cfg.Height > maxCoverPixels {
+		jsonError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("cover must be at most %d megapixels", maxCoverPixels/1_000_000))
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		jsonError(c, http.StatusUnsupportedMediaType, "could not decode image: "+err.Error())
+		return
+	}
+
+	key, err := newStorageKey(fmt.Sprintf("covers/%d", ev.ID), ext)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not store cover")
+		return
+	}
+	files := map[string][]byte{key: data}
+	for _, s := range coverSizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaleToWidth(img, s.Width), &jpeg.Options{Quality: coverQuality}); err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not resize cover: "+err.Error())
+			return
+		}
+		files[coverSizeKey(key, s.Name)] = buf.Bytes()
+	}
+	var size int64
+	for _, b := range files {
+		size += int64(len(b))
+	}
+
+	if err := reserveStorage(ev.OrganizerID, size); err != nil {
+		quotaError(c, "storage", effectiveLimits(ev.OrganizerID).StorageQuotaBytes)
+		return
+	}
+	for k, b := range files {
+		contentType := "image/jpeg"
+		if k == key {
+			contentType = "image/" + format
+		}
+		if err := Storage.Put(c.Request.Context(), k, b, contentType); err != nil {
+			removeCover(ev.OrganizerID, key, size)
+			jsonError(c, http.StatusInternalServerError, "could not store cover: "+err.Error())
+			return
+		}
+	}
+
+	previousKey, previousSize := ev.CoverKey, ev.CoverSize
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ev).Updates(map[string]interface{}{"cover_key": key, "cover_size": size}).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	}); err != nil {
+		removeCover(ev.OrganizerID, key, size)
+		jsonError(c, http.StatusInternalServerError, "could not save cover: "+err.Error())
+		return
+	}
+	removeCover(ev.OrganizerID, previousKey, previousSize)
+	ev.CoverKey, ev.CoverSize = key, size
+
+	broadcastEventUpdated(ev)
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "cover": coverView(ev)})
+}
+
+// DeleteEventCover removes the event's cover.
+func DeleteEventCover(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CoverKey == "" {
+		jsonError(c, http.StatusNotFound, "the event has no cover")
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ev).Updates(map[string]interface{}{"cover_key": "", "cover_size": 0}).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not remove cover: "+err.Error())
+		return
+	}
+	removeCover(ev.OrganizerID, ev.CoverKey, ev.CoverSize)
+	ev.CoverKey, ev.CoverSize = "", 0
+
+	broadcastEventUpdated(ev)
+	c.JSON(http.StatusOK, gin.H{"message": "cover removed"})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ticket prices on public pages are shown in the tier's own currency and,
+// where rates allow, converted into the viewer's: ?currency=, else the
+// region of their Accept-Language, else the geo header of the proxy in
+// front of us (GEO_COUNTRY_HEADER). Converted prices are indicative; the
+// invoice is always in the tier's currency.
+
+// ExchangeRates are units of each currency per one Base.
+type ExchangeRates struct {
+	Base  string             `json:"base"`
+	Date  string             `json:"date"` // the day the rates were published
+	Rates map[string]float64 `json:"rates"`
+}
+
+// ExchangeRateProvider fetches the latest rates.
+type ExchangeRateProvider interface {
+	Rates(ctx context.Context) (ExchangeRates, error)
+}
+
+// ========================
+// ECB PROVIDER
+// ========================
+
+// ecbRateProvider reads the European Central Bank's daily reference
+// rates, published against the euro around 16:00 CET on working days.
+type ecbRateProvider struct {
+	url    string
+	client *http.Client
+}
+
+func (p *ecbRateProvider) Rates(ctx context.Context) (ExchangeRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return ExchangeRates{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ExchangeRates{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ExchangeRates{}, fmt.Errorf("exchange rate provider returned %s", resp.Status)
+	}
+
+	var doc struct {
+		Day struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube>Cube"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return ExchangeRates{}, err
+	}
+	out := ExchangeRates{Base: "EUR", Date: doc.Day.Time, Rates: map[string]float64{"EUR": 1}}
+	for _, r := range doc.Day.Rates {
+		if r.Rate > 0 {
+			out.Rates[r.Currency] = r.Rate
+		}
+	}
+	if len(out.Rates) == 1 {
+		return ExchangeRates{}, fmt.Errorf("exchange rate provider returned no rates")
+	}
+	return out, nil
+}
+
+// ========================
+// LOOKUP
+// ========================
+
+const (
+	exchangeRateTTL   = 6 * time.Hour
+	exchangeRateRetry = 10 * time.Minute
+)
+
+// ExchangeRatesSource is the configured provider; nil disables conversion.
+var ExchangeRatesSource ExchangeRateProvider
+
+var rateCache struct {
+	sync.Mutex
+	rates     *ExchangeRates
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+func InitExchangeRates() {
+	switch strings.ToLower(AppConfig.ExchangeRateProvider) {
+	case "", "none":
+		ExchangeRatesSource = nil
+	case "ecb":
+		ExchangeRatesSource = &ecbRateProvider{
+			url:    "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml",
+			client: newHTTPClient(10 * time.Second),
+		}
+	default:
+		log.Fatalf("❌ unknown EXCHANGE_RATE_PROVIDER %q", AppConfig.ExchangeRateProvider)
+	}
+}
+
+// currentRates returns the cached rates, refreshing them when stale. A
+// failed refresh keeps serving the previous rates and is retried a little
+// later rather than on every request.
+func currentRates(ctx context.Context) *ExchangeRates {
+	if ExchangeRatesSource == nil {
+		return nil
+	}
+	rateCache.Lock()
+	defer rateCache.Unlock()
+	now := time.Now()
+	if rateCache.rates != nil && now.Sub(rateCache.fetchedAt) < exchangeRateTTL {
+		return rateCache.rates
+	}
+	if now.Sub(rateCache.triedAt) < exchangeRateRetry {
+		return rateCache.rates
+	}
+	rateCache.triedAt = now
+	rates, err := ExchangeRatesSource.Rates(ctx)
+	if err != nil {
+		log.Printf("⚠️ exchange rate refresh failed: %v", err)
+		return rateCache.rates
+	}
+	rateCache.rates, rateCache.fetchedAt = &rates, now
+	return rateCache.rates
+}
+
+// minorUnits is how many minor units make one of the currency.
+func minorUnits(currency string) float64 {
+	if slices.Contains(zeroDecimalCurrencies, currency) {
+		return 1
+	}
+	return 100
+}
+
+// convertCents converts an amount between currencies' minor units,
+// reporting false when either currency has no rate.
+func convertCents(cents int64, from, to string, rates *ExchangeRates) (int64, float64, bool) {
+	if rates == nil {
+		return 0, 0, false
+	}
+	rf, okFrom := rates.Rates[from]
+	rt, okTo := rates.Rates[to]
+	if !okFrom || !okTo {
+		return 0, 0, false
+	}
+	rate := rt / rf
+	major := float64(cents) / minorUnits(from) * rate
+	return int64(math.Round(major * minorUnits(to))), rate, true
+}
+
+// ========================
+// VIEWER CURRENCY
+// ========================
+
+// countryCurrencies maps ISO 3166 countries to the currency prices are
+// shown in there.
+var countryCurrencies = map[string]string{
+	"AT": "EUR", "BE": "EUR", "BG": "EUR", "CY": "EUR", "DE": "EUR", "EE": "EUR", "ES": "EUR", "FI": "EUR",
+	"FR": "EUR", "GR": "EUR", "HR": "EUR", "IE": "EUR", "IT": "EUR", "LT": "EUR", "LU": "EUR", "LV": "EUR",
+	"MT": "EUR", "NL": "EUR", "PT": "EUR", "SI": "EUR", "SK": "EUR",
+	"AE": "AED", "AU": "AUD", "BR": "BRL", "CA": "CAD", "CH": "CHF", "CN": "CNY", "CZ": "CZK", "DK": "DKK",
+	"EG": "EGP", "GB": "GBP", "HK": "HKD", "HU": "HUF", "ID": "IDR", "IL": "ILS", "IN": "INR", "IS": "ISK",
+	"JP": "JPY", "KR": "KRW", "MA": "MAD", "MX": "MXN", "MY": "MYR", "NO": "NOK", "NZ": "NZD", "PH": "PHP",
+	"PL": "PLN", "RO": "RON", "SA": "SAR", "SE": "SEK", "SG": "SGD", "TH": "THB", "TR": "TRY", "US": "USD",
+	"ZA": "ZAR",
+}
+
+// acceptLanguageCountry is the region of the first Accept-Language tag
+// that has one (e.g. "ar-EG").
+func acceptLanguageCountry(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if i := strings.IndexAny(tag, "-_"); i > 0 {
+			if region := strings.ToUpper(tag[i+1:]); reCountryCode.MatchString(region) {
+				return region
+			}
+		}
+	}
+	return ""
+}
+
+// viewerCurrency picks the currency to show prices in, "" when nothing
+// hints at one. An invalid ?currency is an error.
+func viewerCurrency(c *gin.Context) (string, error) {
+	if q := strings.ToUpper(strings.TrimSpace(c.Query("currency"))); q != "" {
+		if !currencyPattern.MatchString(q) {
+			return "", fmt.Errorf("currency must be a three-letter ISO 4217 code")
+		}
+		return q, nil
+	}
+	if cur, ok := countryCurrencies[acceptLanguageCountry(c.GetHeader("Accept-Language"))]; ok {
+		return cur, nil
+	}
+	if AppConfig.GeoCountryHeader != "" {
+		return countryCurrencies[strings.ToUpper(strings.TrimSpace(c.GetHeader(AppConfig.GeoCountryHeader)))], nil
+	}
+	return "", nil
+}
+
+// varyOnCurrency tells caches the prices depend on the viewer's hints.
+func varyOnCurrency(c *gin.Context) {
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	if AppConfig.GeoCountryHeader != "" {
+		c.Writer.Header().Add("Vary", AppConfig.GeoCountryHeader)
+	}
+}
+
+// ========================
+// PUBLIC PRICES
+// ========================
+
+// LocalPrice is a price converted into the viewer's currency.
+type LocalPrice struct {
+	TotalCents int64   `json:"total_cents"`
+	Currency   string  `json:"currency"`
+	Display    string  `json:"display"` // "≈ 54.10 USD (50.00 EUR)"
+	Rate       float64 `json:"rate"`
+	RatesDate  string  `json:"rates_date"`
+}
+
+// PublicTicket is a ticket tier as the public pages show it, priced with
+// tax included.
+type PublicTicket struct {
+	ID          uint        `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Free        bool        `json:"free"`
+	TotalCents  int64       `json:"total_cents"`
+	TaxRateBps  int         `json:"tax_rate_bps"`
+	Currency    string      `json:"currency,omitempty"`
+	Display     string      `json:"display"`
+	Local       *LocalPrice `json:"local,omitempty"` // when the viewer's currency differs and rates allow
+}
+
+func publicTickets(ctx context.Context, eventID uint, currency string) []PublicTicket {
+	var tiers []TicketTier
+	DB.Where("event_id = ?", eventID).Order("position asc, id asc").Find(&tiers)
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	var rates *ExchangeRates
+	out := make([]PublicTicket, 0, len(tiers))
+	for _, t := range tiers {
+		total := t.PriceCents + taxOf(t.PriceCents, t.TaxRateBps)
+		pt := PublicTicket{
+			ID: t.ID, Name: t.Name, Description: t.Description,
+			Free: total == 0, TotalCents: total, TaxRateBps: t.TaxRateBps, Currency: t.Currency, Display: "Free",
+		}
+		if total > 0 {
+			pt.Display = formatMoney(total, t.Currency)
+			if currency != "" && currency != t.Currency {
+				if rates == nil {
+					rates = currentRates(ctx)
+				}
+				if cents, rate, ok := convertCents(total, t.Currency, currency, rates); ok {
+					pt.Local = &LocalPrice{
+						TotalCents: cents,
+						Currency:   currency,
+						Display:    fmt.Sprintf("≈ %s (%s)", formatMoney(cents, currency), pt.Display),
+						Rate:       math.Round(rate*1e6) / 1e6,
+						RatesDate:  rates.Date,
+					}
+				}
+			}
+		}
+		out = append(out, pt)
+	}
+	return out
+}
+
+// publicSummaryCard is the summary card with ticket prices for the viewer.
+// It writes a 400 and returns false for an invalid ?currency.
+func publicSummaryCard(c *gin.Context, ev Event) (SummaryCard, bool) {
+	currency, err := viewerCurrency(c)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return SummaryCard{}, false
+	}
+	card := summaryCard(ev)
+	card.Tickets = publicTickets(c.Request.Context(), ev.ID, currency)
+	varyOnCurrency(c)
+	return card, true
+}
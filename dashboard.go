@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type activityItem struct {
+	Type    string    `json:"type"`
+	EventID uint      `json:"event_id"`
+	UserID  uint      `json:"user_id,omitempty"`
+	Summary string    `json:"summary"`
+	At      time.Time `json:"at"`
+}
+
+// participatingEventIDs are the events the user organizes or has a row for.
+func participatingEventIDs(userID uint) []uint {
+	var ids []uint
+	DB.Model(&EventAttendee{}).Where("user_id = ?", userID).Distinct().Pluck("event_id", &ids)
+	var owned []uint
+	DB.Model(&Event{}).Where("organizer_id = ?", userID).Pluck("id", &owned)
+	seen := map[uint]bool{}
+	out := []uint{}
+	for _, id := range append(ids, owned...) {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// GetDashboard returns everything the SPA home page needs in one payload.
+func GetDashboard(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	now := time.Now()
+	eventIDs := participatingEventIDs(userID)
+
+	upcoming := []Event{}
+	if len(eventIDs) > 0 {
+		if err := ReadDB.Where("id IN ? AND date >= ?", eventIDs, now).Order("date asc").Limit(5).Find(&upcoming).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+	}
+
+	var pendingInvitations int64
+	ReadDB.Model(&EventAttendee{}).
+		Where("user_id = ? AND invitation = ?", userID, InvitationPending).
+		Where("invited_by_id NOT IN (?)", suspendedUsersQuery()).
+		Count(&pendingInvitations)
+
+	// open tasks of upcoming events assigned to the user, or nobody's yet
+	// on events they organize
+	openTasks := []Task{}
+	ReadDB.Joins("JOIN events ON events.id = tasks.event_id").
+		Where("events.date >= ? AND tasks.status <> ?", now, TaskDone).
+		Where("tasks.assignee_id = ? OR (tasks.assignee_id IS NULL AND events.organizer_id = ?)", userID, userID).
+		Order("events.date asc").
+		Limit(20).
+		Find(&openTasks)
+
+	var unread int64
+	ReadDB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	upcomingViews := eventViews(upcoming, userID)
+	attachHealth(upcomingViews, upcoming)
+
+	c.JSON(http.StatusOK, gin.H{
+		"upcoming_events":      upcomingViews,
+		"pending_invitations":  pendingInvitations,
+		"open_tasks":           taskViews(openTasks),
+		"unread_notifications": unread,
+		"recent_activity":      recentActivity(userID, eventIDs, 10),
+	})
+}
+
+// recentActivity merges the latest RSVP changes and new tasks on the user's events.
+func recentActivity(userID uint, eventIDs []uint, limit int) []activityItem {
+	items := []activityItem{}
+	if len(eventIDs) == 0 {
+		return items
+	}
+
+	var rsvps []EventAttendee
+	ReadDB.Where("event_id IN ? AND user_id <> ? AND status <> ''", eventIDs, userID).
+		Order("updated_at desc").Limit(limit).Find(&rsvps)
+	for _, a := range rsvps {
+		items = append(items, activityItem{
+			Type: "rsvp", EventID: a.EventID, UserID: a.UserID,
+			Summary: "responded " + a.Status, At: a.UpdatedAt,
+		})
+	}
+
+	var tasks []Task
+	ReadDB.Where("event_id IN ?", eventIDs).Order("created_at desc").Limit(limit).Find(&tasks)
+	for _, t := range tasks {
+		items = append(items, activityItem{
+			Type: "task_created", EventID: t.EventID,
+			Summary: "task added: " + t.Title, At: t.CreatedAt,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].At.After(items[j].At) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
@@ -8,10 +8,19 @@ import (
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 var DB *gorm.DB
 
+// ReadDB serves read-only handlers (listings, search, stats) from the read
+// replica when DB_REPLICA_DSN is set, and is DB otherwise. Reads through it
+// may lag behind writes, so anything that reads its own writes, runs in a
+// transaction or locks rows must use DB.
+var ReadDB *gorm.DB
+
+const replicaResolver = "replica"
+
 func InitDB() {
 	godotenv.Load()
 
@@ -30,18 +39,57 @@ func InitDB() {
 		host, user, pass, name, port,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: newQueryLogger()})
 	if err != nil {
 		log.Fatalf("❌ Failed to connect: %v", err)
 	}
 
 	DB = db
+	useDBTracing(DB)
+
+	ReadDB = DB
+	if replicaDSN := os.Getenv("DB_REPLICA_DSN"); replicaDSN != "" {
+		// registered by name only, so nothing reaches the replica unless
+		// it asks for it through ReadDB
+		err := DB.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: []gorm.Dialector{postgres.Open(replicaDSN)},
+		}, replicaResolver))
+		if err != nil {
+			log.Fatalf("❌ Failed to connect to read replica: %v", err)
+		}
+		ReadDB = DB.Clauses(dbresolver.Use(replicaResolver)).Session(&gorm.Session{})
+		log.Println("📚 Read replica enabled for read-only endpoints")
+	}
 
 	// Migrate all models
-	err = DB.AutoMigrate(&User{}, &Event{}, &Task{}, &EventAttendee{})
+	err = DB.AutoMigrate(
+		&User{}, &Event{}, &Task{}, &EventAttendee{},
+		&Session{}, &RefreshToken{}, &PasswordResetToken{}, &Job{}, &Notification{}, &DataExport{},
+		&UserSuspension{}, &AuditLog{},
+		&Organization{}, &OrganizationMember{}, &FeatureFlag{}, &FeatureFlagOverride{},
+		&MeterEvent{}, &MeterRollup{}, &Announcement{}, &LinkPreview{},
+		&EventField{}, &AttendeeAnswer{}, &WaitlistEntry{},
+		&InvitationReport{}, &Vendor{}, &Expense{}, &BudgetCategory{}, &VendorQuote{},
+		&Contract{}, &ContractVersion{}, &ContractApproval{},
+		&Delegation{}, &OrgSSOConfig{}, &SSODomain{}, &SSOLoginState{},
+		&ScimToken{}, &ScimUser{}, &EventComment{}, &ChangeRecord{}, &SyncMutationLog{},
+		&EventRedirect{}, &UndoAction{}, &GuestRSVP{}, &EventSlugHistory{},
+		&NotificationTemplate{},
+		&DeprecationUsage{},
+		&EventOccurrenceException{}, &EventOccurrenceOverride{}, &KioskToken{},
+		&TicketTier{}, &EventSession{}, &SessionRegistration{}, &RunOfShowItem{}, &Reminder{},
+		&WeatherContingency{},
+		&CancellationPolicy{},
+		&CertificateTemplate{}, &AttendanceCertificate{},
+		&BillingProfile{}, &Invoice{}, &InvoiceSequence{},
+		&EventAlert{}, &Attachment{}, &Resource{}, &ResourceBooking{},
+	)
 	if err != nil {
 		log.Fatalf("❌ Migration failed: %v", err)
 	}
+	if err := backfillInvitations(DB); err != nil {
+		log.Fatalf("❌ Migration failed: %v", err)
+	}
 
 	fmt.Println("✅ Database connected and migrated successfully")
 }
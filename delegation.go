@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// isDelegateOf reports whether userID currently manages principalID's events.
+func isDelegateOf(principalID, userID uint) bool {
+	if principalID == userID {
+		return false
+	}
+	var n int64
+	DB.Model(&Delegation{}).
+		Where("principal_id = ? AND delegate_id = ? AND revoked_at IS NULL", principalID, userID).
+		Count(&n)
+	return n > 0
+}
+
+// principalsQuery selects the users whose events userID manages, for use
+// as an IN (?) subquery.
+func principalsQuery(userID uint) *gorm.DB {
+	return DB.Model(&Delegation{}).Select("principal_id").
+		Where("delegate_id = ? AND revoked_at IS NULL", userID)
+}
+
+type delegationItem struct {
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+func listDelegations(c *gin.Context, where string, userID uint, other func(Delegation) uint) {
+	var list []Delegation
+	if err := DB.Where(where+" = ? AND revoked_at IS NULL", userID).Order("created_at asc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	ids := make([]uint, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, other(d))
+	}
+	emails := userEmails(ids)
+
+	out := make([]delegationItem, 0, len(list))
+	for _, d := range list {
+		out = append(out, delegationItem{UserID: other(d), Email: emails[other(d)], GrantedAt: d.UpdatedAt})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetMyDelegates lists the users who may manage the caller's events.
+func GetMyDelegates(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	listDelegations(c, "principal_id", userID, func(d Delegation) uint { return d.DelegateID })
+}
+
+// GetMyPrincipals lists the users whose events the caller manages.
+func GetMyPrincipals(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	listDelegations(c, "delegate_id", userID, func(d Delegation) uint { return d.PrincipalID })
+}
+
+type GrantDelegationRequest struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// GrantDelegation gives another user standing permission to create, edit
+// and invite on all of the caller's events. Ownership does not change.
+func GrantDelegation(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body GrantDelegationRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	var delegate User
+	q := DB.Select("id", "email")
+	switch {
+	case body.UserID != 0:
+		q = q.Where("id = ?", body.UserID)
+	case strings.TrimSpace(body.Email) != "":
+		q = q.Where("email = ?", strings.TrimSpace(body.Email))
+	default:
+		jsonError(c, http.StatusBadRequest, "user_id or email is required")
+		return
+	}
+	if err := q.First(&delegate).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	if delegate.ID == userID {
+		jsonError(c, http.StatusBadRequest, "you cannot delegate to yourself")
+		return
+	}
+
+	d := Delegation{PrincipalID: userID, DelegateID: delegate.ID}
+	if err := DB.Where("principal_id = ? AND delegate_id = ?", userID, delegate.ID).
+		Assign(map[string]interface{}{"revoked_at": nil}).
+		FirstOrCreate(&d).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not grant access: "+err.Error())
+		return
+	}
+
+	var principal User
+	DB.Select("id", "email").First(&principal, userID)
+	NotifyTemplate(delegate.ID, "delegation_granted", map[string]string{"PrincipalEmail": principal.Email},
+		gin.H{"principal_id": userID})
+
+	c.JSON(http.StatusOK, delegationItem{UserID: delegate.ID, Email: delegate.Email, GrantedAt: d.UpdatedAt})
+}
+
+// RevokeDelegation takes effect immediately; events the delegate created
+// stay with the principal.
+func RevokeDelegation(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	delegateID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	res := DB.Model(&Delegation{}).
+		Where("principal_id = ? AND delegate_id = ? AND revoked_at IS NULL", userID, delegateID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "no active delegation for this user")
+		return
+	}
+
+	// the delegate's offline copies of events they no longer see
+	var eventIDs []uint
+	q := DB.Model(&Event{}).Where("organizer_id = ?", userID)
+	if own := participatingEventIDs(uint(delegateID)); len(own) > 0 {
+		q = q.Where("id NOT IN ?", own)
+	}
+	q.Pluck("id", &eventIDs)
+	for _, id := range eventIDs {
+		recordUserChange(DB, uint(delegateID), EntityEvent, id, id, ChangeDelete)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "access revoked"})
+}
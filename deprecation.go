@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A Deprecation marks an API surface clients should move off: a route
+// ("GET /api/..."), a request field ("field:...") or a client behaviour.
+// Calls get Deprecation (RFC 9745) and Sunset (RFC 8594) headers and are
+// counted per caller so we know who still depends on it.
+type Deprecation struct {
+	Key    string     `json:"key"`
+	Since  time.Time  `json:"since"`
+	Sunset *time.Time `json:"sunset,omitempty"` // when it stops working
+	Link   string     `json:"link,omitempty"`   // migration notes
+	Note   string     `json:"note,omitempty"`
+}
+
+var deprecations = map[string]Deprecation{}
+
+// RegisterDeprecation declares a deprecated surface. Call it from init().
+func RegisterDeprecation(d Deprecation) {
+	deprecations[d.Key] = d
+}
+
+const DeprecationSessionlessToken = "auth:token-without-session"
+
+func init() {
+	RegisterDeprecation(Deprecation{
+		Key:   DeprecationSessionlessToken,
+		Since: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Note:  "Access tokens issued before sessions existed; sign in again to get a revocable one.",
+	})
+}
+
+// Deprecated marks every call to a route as using the given surface.
+func Deprecated(key string) gin.HandlerFunc {
+	if _, ok := deprecations[key]; !ok {
+		log.Fatalf("❌ route marked with unregistered deprecation %q", key)
+	}
+	return func(c *gin.Context) {
+		useDeprecated(c, key)
+		c.Next()
+	}
+}
+
+// useDeprecated advertises the deprecation on the response and counts the
+// call. Handlers call it directly for deprecated fields.
+func useDeprecated(c *gin.Context, key string) {
+	d, ok := deprecations[key]
+	if !ok {
+		return
+	}
+	h := c.Writer.Header()
+	// several deprecations in one call: advertise the earliest
+	since := d.Since.Unix()
+	if cur, err := strconv.ParseInt(strings.TrimPrefix(h.Get("Deprecation"), "@"), 10, 64); err != nil || since < cur {
+		h.Set("Deprecation", "@"+strconv.FormatInt(since, 10))
+	}
+	if d.Sunset != nil {
+		if cur, err := http.ParseTime(h.Get("Sunset")); err != nil || d.Sunset.Before(cur) {
+			h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+	}
+	h.Add("X-Deprecated", key)
+
+	userID, _ := getUserIDFromContext(c)
+	var sessionID uint
+	if sid, ok := c.Get("session_id"); ok {
+		sessionID = sid.(uint)
+	}
+	deprecationUsage.add(usageKey{Key: key, UserID: userID, SessionID: sessionID}, c.Request.UserAgent(), c.ClientIP())
+}
+
+// Usage is counted in memory and written out every minute, so a busy
+// deprecated route costs no extra query per call.
+type usageKey struct {
+	Key       string
+	UserID    uint
+	SessionID uint
+}
+
+type usageCount struct {
+	N         int64
+	UserAgent string
+	IP        string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+type usageBuffer struct {
+	mu     sync.Mutex
+	counts map[usageKey]*usageCount
+}
+
+var deprecationUsage = &usageBuffer{counts: map[usageKey]*usageCount{}}
+
+func (b *usageBuffer) add(k usageKey, userAgent, ip string) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u := b.counts[k]
+	if u == nil {
+		u = &usageCount{FirstSeen: now}
+		b.counts[k] = u
+	}
+	u.N++
+	u.UserAgent, u.IP, u.LastSeen = userAgent, ip, now
+}
+
+func (b *usageBuffer) take() map[usageKey]*usageCount {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.counts
+	b.counts = map[usageKey]*usageCount{}
+	return out
+}
+
+// FlushDeprecationUsage adds the buffered counts to deprecation_usages.
+func FlushDeprecationUsage(ctx context.Context) {
+	for k, u := range deprecationUsage.take() {
+		row := DeprecationUsage{
+			Key:         k.Key,
+			UserID:      k.UserID,
+			SessionID:   k.SessionID,
+			UserAgent:   u.UserAgent,
+			IP:          u.IP,
+			Count:       u.N,
+			FirstSeenAt: u.FirstSeen,
+			LastSeenAt:  u.LastSeen,
+		}
+		err := DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}, {Name: "user_id"}, {Name: "session_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":        gorm.Expr("deprecation_usages.count + ?", u.N),
+				"user_agent":   row.UserAgent,
+				"ip":           row.IP,
+				"last_seen_at": row.LastSeenAt,
+			}),
+		}).Create(&row).Error
+		if err != nil {
+			log.Printf("⚠️ could not record usage of %s: %v", k.Key, err)
+		}
+	}
+}
+
+type deprecationReport struct {
+	Deprecation
+	Calls    int64      `json:"calls"`
+	Users    int64      `json:"users"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+type deprecationCaller struct {
+	DeprecationUsage
+	Email string `json:"email,omitempty"`
+}
+
+// GetDeprecationReport lists deprecated surfaces with their usage, or with
+// ?key= the callers still using one, most recent first.
+func GetDeprecationReport(c *gin.Context) {
+	if key := c.Query("key"); key != "" {
+		d, ok := deprecations[key]
+		if !ok {
+			jsonError(c, http.StatusNotFound, "unknown deprecation")
+			return
+		}
+		var callers []deprecationCaller
+		if err := dbFor(c).Table("deprecation_usages").
+			Select("deprecation_usages.*, users.email").
+			Joins("LEFT JOIN users ON users.id = deprecation_usages.user_id").
+			Where("deprecation_usages.key = ?", key).
+			Order("deprecation_usages.last_seen_at desc").
+			Limit(500).
+			Scan(&callers).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deprecation": d, "callers": callers})
+		return
+	}
+
+	var rows []struct {
+		Key      string
+		Calls    int64
+		Users    int64
+		LastSeen *time.Time
+	}
+	if err := dbFor(c).Model(&DeprecationUsage{}).
+		Select("key, SUM(count) AS calls, COUNT(DISTINCT user_id) AS users, MAX(last_seen_at) AS last_seen").
+		Group("key").
+		Scan(&rows).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	report := make([]deprecationReport, 0, len(deprecations))
+	for _, d := range deprecations {
+		r := deprecationReport{Deprecation: d}
+		for _, row := range rows {
+			if row.Key == d.Key {
+				r.Calls, r.Users, r.LastSeen = row.Calls, row.Users, row.LastSeen
+			}
+		}
+		report = append(report, r)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"strings"
+)
+
+// Notification emails go out through the job queue, so a slow or failing
+// mail server never holds up a request and failed sends are retried. The
+// wording is the notification template of the kind, in the recipient's
+// language; the HTML version wraps it in a small branded layout.
+
+// Mailer delivers one message. Drivers register under the MAIL_DRIVER
+// name that selects them.
+type Mailer interface {
+	Send(ctx context.Context, m MailMessage) DeliveryResult
+}
+
+var mailers = map[string]Mailer{}
+
+// RegisterMailer adds a mail driver. Call it from init().
+func RegisterMailer(name string, m Mailer) {
+	mailers[name] = m
+}
+
+type smtpMailer struct{}
+
+func (smtpMailer) Send(ctx context.Context, m MailMessage) DeliveryResult {
+	return sendMail(ctx, m)
+}
+
+// logMailer prints messages instead of sending them, for development.
+type logMailer struct{}
+
+func (logMailer) Send(ctx context.Context, m MailMessage) DeliveryResult {
+	log.Printf("✉️ to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return DeliveryResult{Delivered: true}
+}
+
+const jobSendEmail = "send_email"
+
+func init() {
+	RegisterMailer("smtp", smtpMailer{})
+	RegisterMailer("log", logMailer{})
+	RegisterJob(jobSendEmail, runSendEmail)
+}
+
+// activeMailer is the configured driver, nil when email is off.
+func activeMailer() Mailer {
+	if AppConfig.MailDriver == "smtp" && !mailConfigured() {
+		return nil
+	}
+	return mailers[AppConfig.MailDriver]
+}
+
+type emailJob struct {
+	UserID  uint   `json:"user_id"`
+	EventID uint   `json:"event_id,omitempty"`
+	BillTo  uint   `json:"bill_to"` // account metered for the email
+	Kind    string `json:"kind"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	ReplyTo string `json:"reply_to,omitempty"`
+	Link    string `json:"link,omitempty"`
+	// Button text for Link; "Open the event" when empty
+	LinkText string `json:"link_text,omitempty"`
+}
+
+// startsAtText is an event's start as written in notifications.
+func startsAtText(ev Event) string {
+	return ev.Date.In(recurrenceLocation(ev.RecurrenceTimezone)).Format("Mon Jan 2, 15:04 MST")
+}
+
+// EmailTemplate queues an email about ev to the user, worded by kind's
+// template. Nothing is queued while email is off or for suspended users.
+func EmailTemplate(userID uint, kind string, vars map[string]string, ev Event) {
+	if activeMailer() == nil {
+		return
+	}
+	var u User
+	if err := DB.Select("id", "email").First(&u, userID).Error; err != nil || u.Email == "" {
+		return
+	}
+	if activeSuspension(userID) != nil {
+		return
+	}
+
+	msg := renderNotification(userID, kind, vars)
+	job := emailJob{
+		UserID:  userID,
+		EventID: ev.ID,
+		BillTo:  ev.OrganizerID,
+		Kind:    kind,
+		To:      u.Email,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+		ReplyTo: eventReplyTo(&ev),
+		Link:    eventURL(ev),
+	}
+	if err := Enqueue(jobSendEmail, job); err != nil {
+		log.Printf("⚠️ could not queue %s email for user %d: %v", kind, userID, err)
+	}
+}
+
+// EmailAccount queues an email about the user's own account rather than
+// an event, with link as its button.
+func EmailAccount(userID uint, kind string, vars map[string]string, link, linkText string) {
+	if activeMailer() == nil {
+		return
+	}
+	var u User
+	if err := DB.Select("id", "email").First(&u, userID).Error; err != nil || u.Email == "" {
+		return
+	}
+
+	msg := renderNotification(userID, kind, vars)
+	job := emailJob{
+		UserID:   userID,
+		BillTo:   userID,
+		Kind:     kind,
+		To:       u.Email,
+		Subject:  msg.Subject,
+		Body:     msg.Body,
+		Link:     link,
+		LinkText: linkText,
+	}
+	if err := Enqueue(jobSendEmail, job); err != nil {
+		log.Printf("⚠️ could not queue %s email for user %d: %v", kind, userID, err)
+	}
+}
+
+var emailLayout = template.Must(template.New("email").Parse(`<!doctype html>
+<html><body style="margin:0;padding:24px;background:#f5f5f5">
+<div style="max-width:560px;margin:0 auto;padding:24px;background:#ffffff;font-family:Helvetica,Arial,sans-serif;color:#222222">
+{{if .Logo}}<img src="{{.Logo}}" alt="{{.Brand}}" style="max-height:48px;margin-bottom:16px">{{end}}
+<h2 style="margin-top:0">{{.Subject}}</h2>
+{{range .Paragraphs}}<p style="line-height:1.5">{{.}}</p>
+{{end}}{{if .Link}}<p><a href="{{.Link}}" style="display:inline-block;padding:10px 18px;background:{{.Accent}};color:#ffffff;text-decoration:none;border-radius:4px">{{.LinkText}}</a></p>
+{{end}}</div>
+</body></html>`))
+
+// emailHTML renders the HTML alternative of a queued email.
+func emailHTML(job emailJob) (string, error) {
+	view := map[string]interface{}{
+		"Subject":    job.Subject,
+		"Paragraphs": strings.Split(strings.TrimSpace(job.Body), "\n\n"),
+		"Link":       job.Link,
+		"LinkText":   job.LinkText,
+		"Accent":     "#2f6fed",
+	}
+	if job.LinkText == "" {
+		view["LinkText"] = "Open the event"
+	}
+	if job.EventID != 0 {
+		var ev Event
+		if DB.First(&ev, job.EventID).Error == nil {
+			if b := eventBranding(ev); b != nil {
+				view["Brand"], view["Logo"] = b.Name, b.LogoURL
+				if b.AccentColor != "" {
+					view["Accent"] = b.AccentColor
+				}
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := emailLayout.Execute(&b, view); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func runSendEmail(ctx context.Context, payload []byte) error {
+	var job emailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	m := activeMailer()
+	if m == nil {
+		return errMailNotConfigured
+	}
+
+	html, err := emailHTML(job)
+	if err != nil {
+		log.Printf("⚠️ %s email to user %d goes out as plain text: %v", job.Kind, job.UserID, err)
+	}
+	body := job.Body
+	if job.Link != "" {
+		body += "\n\n" + job.Link
+	}
+	res := m.Send(ctx, MailMessage{To: job.To, Subject: job.Subject, Body: body, HTML: html, ReplyTo: job.ReplyTo})
+	if !res.Delivered {
+		return errors.New("not delivered: " + res.Error + res.Response)
+	}
+	Meter(job.BillTo, MetricEmailsSent, 1)
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Cloning copies an event's structure into a new event owned by the
+// caller in an organization they belong to: its settings, tasks, custom
+// fields, budget categories, ticket tiers, sessions, cancellation policy
+// and certificate wording, with dates shifted to the new start. Vendors
+// belong to the organization that hired them, so each one is remapped to
+// the target organization's vendor of the same name when it has one, and
+// left behind otherwise. People and money never move; the response lists
+// everything that stayed behind.
+
+type CloneEventRequest struct {
+	OrganizationID uint   `json:"organization_id" binding:"required"`
+	Date           string `json:"date" binding:"required"` // same formats as CreateEventRequest.Date
+	Title          string `json:"title"`                   // defaults to the source's
+}
+
+type cloneCounts struct {
+	Tasks            int `json:"tasks"`
+	Fields           int `json:"fields"`
+	BudgetCategories int `json:"budget_categories"`
+	TicketTiers      int `json:"ticket_tiers"`
+	Sessions         int `json:"sessions"`
+	Vendors          int `json:"vendors"`
+}
+
+// cloneRemap is a source item replaced by the target organization's own.
+type cloneRemap struct {
+	Kind   string `json:"kind"`
+	FromID uint   `json:"from_id"`
+	ToID   uint   `json:"to_id"` // the item it was copied from
+	Name   string `json:"name"`
+}
+
+// cloneSkip is something that was not transferred, one item or a count.
+type cloneSkip struct {
+	Kind   string `json:"kind"`
+	ID     uint   `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Count  int64  `json:"count,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type cloneReport struct {
+	Copied         cloneCounts  `json:"copied"`
+	Remapped       []cloneRemap `json:"remapped"`
+	NotTransferred []cloneSkip  `json:"not_transferred"`
+}
+
+// orgVendors indexes the latest vendor of each name used on the
+// organization's events.
+func orgVendors(tx *gorm.DB, orgID uint) map[string]Vendor {
+	var vendors []Vendor
+	tx.Joins("JOIN events ON events.id = vendors.event_id AND events.deleted_at IS NULL").
+		Where("events.organization_id = ?", orgID).
+		Order("vendors.updated_at asc").Find(&vendors)
+	out := map[string]Vendor{}
+	for _, v := range vendors {
+		out[strings.ToLower(strings.TrimSpace(v.Name))] = v
+	}
+	return out
+}
+
+func shiftTime(t *time.Time, by time.Duration) *time.Time {
+	if t == nil {
+		return nil
+	}
+	shifted := t.Add(by)
+	return &shifted
+}
+
+// cloneEventTx creates the copy of source described by clone, which must
+// already carry the new event's own fields.
+func cloneEventTx(tx *gorm.DB, source Event, clone *Event) (cloneReport, error) {
+	report := cloneReport{Remapped: []cloneRemap{}, NotTransferred: []cloneSkip{}}
+	if err := insertEvent(tx, clone); err != nil {
+		return report, err
+	}
+	shift := clone.Date.Sub(source.Date)
+
+	// vendors first, so tasks can point at them
+	var vendors []Vendor
+	if err := tx.Where("event_id = ?", source.ID).Order("id asc").Find(&vendors).Error; err != nil {
+		return report, err
+	}
+	known := orgVendors(tx, *clone.OrganizationID)
+	vendorIDs := map[uint]uint{}
+	for _, v := range vendors {
+		match, ok := known[strings.ToLower(strings.TrimSpace(v.Name))]
+		if !ok {
+			report.NotTransferred = append(report.NotTransferred, cloneSkip{
+				Kind: "vendor", ID: v.ID, Name: v.Name, Reason: "not a vendor of the target organization; its tasks were copied without it",
+			})
+			continue
+		}
+		copied := Vendor{
+			EventID: clone.ID, Name: match.Name, Category: match.Category,
+			ContactName: match.ContactName, ContactEmail: match.ContactEmail, ContactPhone: match.ContactPhone,
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return report, err
+		}
+		vendorIDs[v.ID] = copied.ID
+		report.Remapped = append(report.Remapped, cloneRemap{Kind: "vendor", FromID: v.ID, ToID: match.ID, Name: match.Name})
+		report.Copied.Vendors++
+	}
+
+	var tasks []Task
+	if err := tx.Where("event_id = ?", source.ID).Order("id asc").Find(&tasks).Error; err != nil {
+		return report, err
+	}
+	for _, t := range tasks {
+		copied := Task{EventID: clone.ID, Title: t.Title, Description: t.Description, Status: TaskTodo, Position: t.Position}
+		if t.VendorID != nil {
+			if id, ok := vendorIDs[*t.VendorID]; ok {
+				copied.VendorID = &id
+			}
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return report, err
+		}
+		recordChange(tx, EntityTask, copied.ID, clone.ID, ChangeUpsert)
+		report.Copied.Tasks++
+	}
+
+	var fields []EventField
+	if err := tx.Where("event_id = ?", source.ID).Find(&fields).Error; err != nil {
+		return report, err
+	}
+	for _, f := range fields {
+		f.ID, f.EventID = 0, clone.ID
+		if err := tx.Create(&f).Error; err != nil {
+			return report, err
+		}
+		report.Copied.Fields++
+	}
+
+	var categories []BudgetCategory
+	if err := tx.Where("event_id = ?", source.ID).Find(&categories).Error; err != nil {
+		return report, err
+	}
+	for _, bc := range categories {
+		bc.ID, bc.EventID = 0, clone.ID
+		if err := tx.Create(&bc).Error; err != nil {
+			return report, err
+		}
+		report.Copied.BudgetCategories++
+	}
+
+	var tiers []TicketTier
+	if err := tx.Where("event_id = ?", source.ID).Find(&tiers).Error; err != nil {
+		return report, err
+	}
+	for _, t := range tiers {
+		t.ID, t.EventID = 0, clone.ID
+		if err := tx.Create(&t).Error; err != nil {
+			return report, err
+		}
+		report.Copied.TicketTiers++
+	}
+
+	var sessions []EventSession
+	if err := tx.Where("event_id = ?", source.ID).Find(&sessions).Error; err != nil {
+		return report, err
+	}
+	for _, s := range sessions {
+		s.ID, s.EventID = 0, clone.ID
+		s.StartsAt = s.StartsAt.Add(shift)
+		s.EndsAt = shiftTime(s.EndsAt, shift)
+		if err := tx.Create(&s).Error; err != nil {
+			return report, err
+		}
+		report.Copied.Sessions++
+	}
+
+	var policy CancellationPolicy
+	if tx.First(&policy, "event_id = ?", source.ID).Error == nil {
+		policy.EventID = clone.ID
+		if err := tx.Create(&policy).Error; err != nil {
+			return report, err
+		}
+	}
+	var certificate CertificateTemplate
+	if tx.First(&certificate, "event_id = ?", source.ID).Error == nil {
+		certificate.EventID = clone.ID
+		if err := tx.Create(&certificate).Error; err != nil {
+			return report, err
+		}
+	}
+
+	// what stays with the source
+	left := []struct {
+		kind, reason string
+		q            *gorm.DB
+	}{
+		{"attendees", "people are invited to each event separately",
+			tx.Model(&EventAttendee{}).Where("event_id = ? AND user_id <> ?", source.ID, source.OrganizerID)},
+		{"guest_rsvps", "people are invited to each event separately", tx.Model(&GuestRSVP{}).Where("event_id = ?", source.ID)},
+		{"expenses", "money spent belongs to the source event", tx.Model(&Expense{}).Where("event_id = ?", source.ID)},
+		{"comments", "discussion belongs to the source event", tx.Model(&EventComment{}).Where("event_id = ?", source.ID)},
+		{"announcements", "messages belong to the source event", tx.Model(&Announcement{}).Where("event_id = ?", source.ID)},
+		{"occurrence_exceptions", "skipped occurrences are tied to the source's dates",
+			tx.Model(&EventOccurrenceException{}).Where("event_id = ?", source.ID)},
+		{"occurrence_overrides", "moved occurrences are tied to the source's dates",
+			tx.Model(&EventOccurrenceOverride{}).Where("event_id = ?", source.ID)},
+	}
+	for _, l := range left {
+		var n int64
+		l.q.Count(&n)
+		if n > 0 {
+			report.NotTransferred = append(report.NotTransferred, cloneSkip{Kind: l.kind, Count: n, Reason: l.reason})
+		}
+	}
+	notes := []struct{ kind, text string }{
+		{"private_notes", source.PrivateNotes}, {"vendor_contacts", source.VendorContacts}, {"budget_details", source.BudgetDetails},
+	}
+	for _, n := range notes {
+		if strings.TrimSpace(n.text) != "" {
+			report.NotTransferred = append(report.NotTransferred, cloneSkip{Kind: n.kind, Reason: "organizer notes stay with the source organization"})
+		}
+	}
+	return report, nil
+}
+
+// CloneEvent copies :id's structure into a new event in another
+// organization the caller belongs to.
+func CloneEvent(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	source, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body CloneEventRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if orgRole(body.OrganizationID, userID) == "" {
+		jsonError(c, http.StatusForbidden, "not a member of this organization")
+		return
+	}
+	date, err := parseEventDate(body.Date)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	title := strings.TrimSpace(body.Title)
+	if title == "" {
+		title = source.Title
+	}
+	if err := checkEventQuota(userID); err != nil {
+		quotaError(c, "events per month", int64(effectiveLimits(userID).MaxEventsPerMonth))
+		return
+	}
+
+	shift := date.Sub(source.Date)
+	orgID := body.OrganizationID
+	clone := Event{
+		Title:        title,
+		Description:  source.Description,
+		Location:     source.Location,
+		Date:         date,
+		OrganizerID:  userID,
+		MaxAttendees: source.MaxAttendees,
+
+		Accessibility: source.Accessibility,
+
+		MinAttendees:     source.MinAttendees,
+		DecisionDeadline: shiftTime(source.DecisionDeadline, shift),
+		AutoCancel:       source.AutoCancel,
+
+		OrganizationID: &orgID,
+		Visibility:     source.Visibility,
+
+		RecurrenceRule:     source.RecurrenceRule,
+		RecurrenceTimezone: source.RecurrenceTimezone,
+		SeriesEndsAt:       shiftTime(source.SeriesEndsAt, shift),
+
+		ReminderMinutes: source.ReminderMinutes,
+		NudgeDaysBefore: source.NudgeDaysBefore,
+	}
+
+	var report cloneReport
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		report, err = cloneEventTx(tx, source, &clone)
+		return err
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not clone event: "+err.Error())
+		return
+	}
+	Meter(userID, MetricEventsCreated, 1)
+	queueLinkPreviews(clone.Description)
+	Audit(userID, "event.clone", "event", clone.ID, gin.H{
+		"source_event_id": source.ID, "organization_id": orgID, "copied": report.Copied, "not_transferred": len(report.NotTransferred),
+	})
+
+	DB.First(&clone, clone.ID)
+	view := eventView(clone, true)
+	view.Warnings = holidayWarnings(c, userID, clone.Date)
+	c.JSON(http.StatusCreated, gin.H{
+		"event":           view,
+		"source_id":       source.ID,
+		"copied":          report.Copied,
+		"remapped":        report.Remapped,
+		"not_transferred": report.NotTransferred,
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	EventStatusScheduled = "scheduled"
+	EventStatusCancelled = "cancelled"
+	EventStatusPast      = "past"
+)
+
+// eventStatuses match SummaryCard.Status.
+var eventStatuses = []string{EventStatusScheduled, EventStatusCancelled, EventStatusPast}
+
+// eventSorts maps the sort parameter to an ORDER BY; a leading "-" sorts
+// descending.
+var eventSorts = map[string]string{
+	"date":    "events.date",
+	"title":   "LOWER(events.title)",
+	"created": "events.created_at",
+}
+
+// applyEventListParams narrows an events query with the list parameters
+// shared by the event listings:
+//
+//	status   scheduled, cancelled or past (comma separated for several)
+//	from, to RFC3339 or YYYY-MM-DD; to includes the whole day. A series
+//	         matches from its first to its last occurrence
+//	q        matched against title, description and location
+//	sort     date, title or created, "-" prefixed for descending
+//	include_archived=true to list archived events too
+//	accessibility=wheelchair_access,sign_language to list events offering
+//	         all the features given (see accessibilityFeatures)
+func applyEventListParams(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if c.Query("include_archived") != "true" {
+		query = query.Where("events.archived_at IS NULL")
+	}
+
+	if raw := c.Query("status"); raw != "" {
+		now := time.Now()
+		conds := DB.Where("1 = 0")
+		for _, s := range strings.Split(raw, ",") {
+			switch strings.TrimSpace(s) {
+			case EventStatusScheduled:
+				conds = conds.Or("events.cancelled_at IS NULL AND "+eventEndsExpr+" >= ?", now)
+			case EventStatusCancelled:
+				conds = conds.Or("events.cancelled_at IS NOT NULL")
+			case EventStatusPast:
+				conds = conds.Or("events.cancelled_at IS NULL AND "+eventEndsExpr+" < ?", now)
+			default:
+				return nil, errors.New("status must be one of: " + strings.Join(eventStatuses, ", "))
+			}
+		}
+		query = query.Where(conds)
+	}
+
+	if raw := c.Query("from"); raw != "" {
+		from, _, err := parseListDate(raw)
+		if err != nil {
+			return nil, errors.New("invalid from (use RFC3339 or YYYY-MM-DD)")
+		}
+		query = query.Where(eventEndsExpr+" >= ?", from)
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, dayOnly, err := parseListDate(raw)
+		if err != nil {
+			return nil, errors.New("invalid to (use RFC3339 or YYYY-MM-DD)")
+		}
+		if dayOnly {
+			to = to.AddDate(0, 0, 1)
+			query = query.Where("events.date < ?", to)
+		} else {
+			query = query.Where("events.date <= ?", to)
+		}
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		kw := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where("events.title ILIKE ? OR events.description ILIKE ? OR events.location ILIKE ?", kw, kw, kw)
+	}
+
+	if raw := c.Query("accessibility"); raw != "" {
+		var err error
+		if query, err = withAccessibility(query, "events", raw); err != nil {
+			return nil, err
+		}
+	}
+
+	sort := c.DefaultQuery("sort", "date")
+	dir := " asc"
+	if strings.HasPrefix(sort, "-") {
+		sort, dir = sort[1:], " desc"
+	}
+	col, ok := eventSorts[sort]
+	if !ok {
+		return nil, errors.New("sort must be one of: date, title, created (prefix - for descending)")
+	}
+	// ties broken by id so pages are stable
+	return query.Order(col + dir).Order("events.id" + dir), nil
+}
+
+// parseListDate reports whether only a day was given.
+func parseListDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	return t, true, err
+}
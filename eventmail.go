@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const eventAliasPrefix = "event-"
+
+// eventEmailAddress returns the event's inbound address, assigning an
+// alias on first use. Empty when inbound email is not configured.
+func eventEmailAddress(ev *Event) (string, error) {
+	if AppConfig.InboundEmailDomain == "" {
+		return "", nil
+	}
+	if ev.EmailAlias == nil {
+		alias, err := randomToken(8)
+		if err != nil {
+			return "", err
+		}
+		res := DB.Model(&Event{}).Where("id = ? AND email_alias IS NULL", ev.ID).Update("email_alias", alias)
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if res.RowsAffected == 0 {
+			// assigned concurrently
+			if err := DB.Select("email_alias").First(ev, ev.ID).Error; err != nil {
+				return "", err
+			}
+		} else {
+			ev.EmailAlias = &alias
+		}
+	}
+	return eventAliasPrefix + *ev.EmailAlias + "@" + AppConfig.InboundEmailDomain, nil
+}
+
+// eventReplyTo is the Reply-To for emails about an event: the branded
+// organization's address if it set one, otherwise the event's inbound
+// address so guest replies land on its comment thread. Empty when neither
+// applies.
+func eventReplyTo(ev *Event) string {
+	if b := eventBranding(*ev); b != nil && b.ReplyTo != "" {
+		return b.ReplyTo
+	}
+	addr, err := eventEmailAddress(ev)
+	if err != nil {
+		log.Printf("⚠️ could not assign email alias for event %d: %v", ev.ID, err)
+		return ""
+	}
+	return addr
+}
+
+// GetEventEmailAlias shows participants the address they can email to
+// post on the event.
+func GetEventEmailAlias(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can post by email")
+		return
+	}
+	if AppConfig.InboundEmailDomain == "" {
+		jsonError(c, http.StatusNotFound, "inbound email is not enabled")
+		return
+	}
+
+	addr, err := eventEmailAddress(&ev)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not assign address: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "address": addr})
+}
+
+// RotateEventEmailAlias replaces a leaked or spammed address; mail to the
+// old one is dropped.
+func RotateEventEmailAlias(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if AppConfig.InboundEmailDomain == "" {
+		jsonError(c, http.StatusNotFound, "inbound email is not enabled")
+		return
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Update("email_alias", nil).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	ev.EmailAlias = nil
+	addr, err := eventEmailAddress(&ev)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not assign address: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "address": addr})
+}
+
+// ========================
+// INBOUND EMAIL
+// ========================
+
+// inboundEmail is the common shape of inbound-parse webhooks. Mailgun
+// (sender, recipient, stripped-text, body-plain) and SendGrid (from, to,
+// text) form posts are both accepted, as is the JSON form below.
+type inboundEmail struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+func readInboundEmail(c *gin.Context) (inboundEmail, error) {
+	var in inboundEmail
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		err := bindJSON(c, &in)
+		return in, err
+	}
+
+	if err := c.Request.ParseMultipartForm(AppConfig.MaxUploadBytes); err != nil && err != http.ErrNotMultipart {
+		return in, err
+	}
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := c.Request.FormValue(k); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	in.From = first("sender", "from")
+	in.To = first("recipient", "to")
+	in.Subject = first("subject")
+	in.Text = first("stripped-text", "text", "body-plain")
+	return in, nil
+}
+
+// findAliasedEvent picks the first recipient addressed to an event alias
+// on our inbound domain.
+func findAliasedEvent(to string) (Event, bool) {
+	var ev Event
+	addrs, err := mail.ParseAddressList(to)
+	if err != nil {
+		return ev, false
+	}
+	suffix := "@" + strings.ToLower(AppConfig.InboundEmailDomain)
+	for _, a := range addrs {
+		addr := strings.ToLower(a.Address)
+		if !strings.HasSuffix(addr, suffix) || !strings.HasPrefix(addr, eventAliasPrefix) {
+			continue
+		}
+		alias := strings.TrimSuffix(strings.TrimPrefix(addr, eventAliasPrefix), suffix)
+		// tolerate plus tags some clients add: event-abc+reply@...
+		alias, _, _ = strings.Cut(alias, "+")
+		if DB.Where("email_alias = ?", alias).First(&ev).Error == nil {
+			return ev, true
+		}
+	}
+	return ev, false
+}
+
+var replyHeaderRe = regexp.MustCompile(`(?i)^(on .+ wrote:|-----\s*original message\s*-----|from: .+|sent from my .+)$`)
+
+// stripQuotedReply keeps the new part of a reply: everything above the
+// first quoted line, reply header or signature separator.
+func stripQuotedReply(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") || trimmed == "--" || line == "-- " || replyHeaderRe.MatchString(trimmed) {
+			break
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// InboundEmailWebhook ingests replies sent to event aliases and posts them
+// as comments from the matching participant. Mail we can't place is
+// acknowledged and dropped so the provider doesn't retry it. The sender is
+// taken from the From header, so the provider must reject mail failing
+// SPF/DKIM before it reaches us.
+func InboundEmailWebhook(c *gin.Context) {
+	if AppConfig.InboundEmailDomain == "" || AppConfig.InboundEmailSecret == "" {
+		jsonError(c, http.StatusNotFound, "inbound email is not enabled")
+		return
+	}
+	secret := c.GetHeader("X-Inbound-Secret")
+	if secret == "" {
+		secret = c.Query("secret")
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(AppConfig.InboundEmailSecret)) != 1 {
+		jsonError(c, http.StatusUnauthorized, "invalid secret")
+		return
+	}
+
+	in, err := readInboundEmail(c)
+	if err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	drop := func(reason string) {
+		log.Printf("📭 inbound email from %q dropped: %s", in.From, reason)
+		c.JSON(http.StatusOK, gin.H{"accepted": false, "reason": reason})
+	}
+
+	ev, ok := findAliasedEvent(in.To)
+	if !ok {
+		drop("unknown recipient")
+		return
+	}
+	from, err := mail.ParseAddress(in.From)
+	if err != nil {
+		drop("invalid sender")
+		return
+	}
+	var user User
+	if err := DB.Select("id", "email").Where("LOWER(email) = ?", strings.ToLower(from.Address)).First(&user).Error; err != nil {
+		drop("sender has no account")
+		return
+	}
+	if !isEventParticipant(ev, user.ID) {
+		drop("sender is not a participant")
+		return
+	}
+	if s := activeSuspension(user.ID); s != nil {
+		drop("sender is suspended")
+		return
+	}
+
+	cm, err := postComment(ev, user.ID, stripQuotedReply(in.Text), CommentSourceEmail)
+	if errors.Is(err, errCommentEmpty) || errors.Is(err, errCommentTooLong) {
+		drop(err.Error())
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not post comment: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": true, "comment_id": cm.ID})
+}
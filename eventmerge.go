@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// eventNotFound answers for an event ID that no longer loads. Events merged
+// into another get a 308 to the same URL on the surviving event, so
+// clients holding old links follow along; anything else is a plain 404.
+func eventNotFound(c *gin.Context, eventID uint) {
+	var redirect EventRedirect
+	if DB.First(&redirect, "from_event_id = ?", eventID).Error != nil {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+
+	from, to := strconv.FormatUint(uint64(eventID), 10), strconv.FormatUint(uint64(redirect.ToEventID), 10)
+	parts := strings.Split(c.Request.URL.Path, "/")
+	for i := 1; i < len(parts); i++ {
+		if parts[i-1] == "events" && parts[i] == from {
+			parts[i] = to
+			break
+		}
+	}
+	location := strings.Join(parts, "/")
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+	c.Header("Location", location)
+	c.JSON(http.StatusPermanentRedirect, gin.H{"error": "event was merged", "merged_into": redirect.ToEventID})
+}
+
+type mergeCounts struct {
+	Attendees     int `json:"attendees"`
+	Tasks         int `json:"tasks"`
+	Comments      int `json:"comments"`
+	Announcements int `json:"announcements"`
+	Expenses      int `json:"expenses"`
+	Vendors       int `json:"vendors"`
+}
+
+var errMergeConflict = errors.New("cannot merge")
+
+// MergeEvent folds :otherId into :id: attendees, tasks, comments,
+// announcements, expenses, vendors, budget categories and custom fields
+// move over, and the other event is soft-deleted behind a redirect. People
+// invited to both keep their answer on :id; where both events define the
+// same field or budget category, :id's definition wins. Only the owner of
+// both events may merge them.
+func MergeEvent(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	sourceID, err := strconv.ParseUint(c.Param("otherId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	if targetID == sourceID {
+		jsonError(c, http.StatusBadRequest, "cannot merge an event into itself")
+		return
+	}
+
+	var target, source Event
+	var counts mergeCounts
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// lock in id order so two merges of the same pair can't deadlock
+		var pair []Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint64{targetID, sourceID}).Order("id asc").Find(&pair).Error; err != nil {
+			return err
+		}
+		for _, ev := range pair {
+			if uint64(ev.ID) == targetID {
+				target = ev
+			} else {
+				source = ev
+			}
+		}
+		if target.ID == 0 || source.ID == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if target.OrganizerID != userID || source.OrganizerID != userID {
+			return errMergeConflict
+		}
+
+		var err error
+		counts, err = mergeEventTx(tx, target, source)
+		if err != nil {
+			return err
+		}
+
+		// earlier merges into the source now lead to the target
+		if err := tx.Model(&EventRedirect{}).Where("to_event_id = ?", source.ID).Update("to_event_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&EventRedirect{FromEventID: source.ID, ToEventID: target.ID, MergedByID: userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Event{}).Where("id = ?", source.ID).Update("email_alias", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Event{}, source.ID).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if target.ID == 0 {
+			eventNotFound(c, uint(targetID))
+		} else {
+			jsonError(c, http.StatusNotFound, "event to merge not found")
+		}
+		return
+	}
+	if errors.Is(err, errMergeConflict) {
+		jsonError(c, http.StatusForbidden, "only the organizer of both events can merge them")
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "merge failed: "+err.Error())
+		return
+	}
+
+	if source.CoverKey != "" && target.CoverKey != "" {
+		removeCover(source.OrganizerID, source.CoverKey, source.CoverSize)
+	}
+	Audit(userID, "event.merge", "event", target.ID, gin.H{"merged_event_id": source.ID, "moved": counts})
+
+	DB.First(&target, target.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"event":     eventView(target, true),
+		"merged_id": source.ID,
+		"moved":     counts,
+	})
+}
+
+// mergeEventTx moves everything attached to source over to target.
+func mergeEventTx(tx *gorm.DB, target, source Event) (mergeCounts, error) {
+	var counts mergeCounts
+
+	// everyone who could see the source loses it; those moved over pick
+	// the target up through their attendee records
+	var audience []uint
+	tx.Model(&EventAttendee{}).Where("event_id = ? AND user_id <> ?", source.ID, source.OrganizerID).Pluck("user_id", &audience)
+	var delegates []uint
+	tx.Model(&Delegation{}).Where("principal_id = ? AND revoked_at IS NULL", source.OrganizerID).Pluck("delegate_id", &delegates)
+	audience = append(append(audience, source.OrganizerID), delegates...)
+
+	// attendees: people already on the target keep that record
+	var attendees []EventAttendee
+	if err := tx.Where("event_id = ?", source.ID).Find(&attendees).Error; err != nil {
+		return counts, err
+	}
+	for _, a := range attendees {
+		var existing []EventAttendee
+		tx.Where("event_id = ? AND user_id = ?", target.ID, a.UserID).Limit(1).Find(&existing)
+		if len(existing) > 0 && a.Labels != "" {
+			// keep the labels the organizer gave them on either event
+			merged := attendeeLabels(existing[0])
+			for _, l := range attendeeLabels(a) {
+				if !slices.Contains(merged, l) {
+					merged = append(merged, l)
+				}
+			}
+			sort.Strings(merged)
+			if len(merged) > maxAttendeeLabels {
+				merged = merged[:maxAttendeeLabels]
+			}
+			if err := tx.Model(&existing[0]).Update("labels", strings.Join(merged, ",")).Error; err != nil {
+				return counts, err
+			}
+			recordChange(tx, EntityAttendee, existing[0].ID, target.ID, ChangeUpsert)
+		}
+		if len(existing) > 0 && a.CheckedInAt != nil && (existing[0].CheckedInAt == nil || a.CheckedInAt.Before(*existing[0].CheckedInAt)) {
+			// checked in at either door counts
+			if err := tx.Model(&existing[0]).Update("checked_in_at", a.CheckedInAt).Error; err != nil {
+				return counts, err
+			}
+			recordChange(tx, EntityAttendee, existing[0].ID, target.ID, ChangeUpsert)
+		}
+		if len(existing) > 0 || a.UserID == target.OrganizerID {
+			if err := tx.Where("attendee_id = ?", a.ID).Delete(&AttendeeAnswer{}).Error; err != nil {
+				return counts, err
+			}
+			if err := tx.Delete(&EventAttendee{}, a.ID).Error; err != nil {
+				return counts, err
+			}
+			continue
+		}
+		if err := tx.Model(&EventAttendee{}).Where("id = ?", a.ID).Update("event_id", target.ID).Error; err != nil {
+			return counts, err
+		}
+		recordChange(tx, EntityAttendee, a.ID, target.ID, ChangeUpsert)
+		counts.Attendees++
+	}
+
+	// custom fields: answers to a field the target also has move onto the
+	// target's field
+	var fields []EventField
+	if err := tx.Where("event_id = ?", source.ID).Order("position asc").Find(&fields).Error; err != nil {
+		return counts, err
+	}
+	var nextPos *int
+	tx.Model(&EventField{}).Where("event_id = ?", target.ID).Select("MAX(position)").Scan(&nextPos)
+	pos := 0
+	if nextPos != nil {
+		pos = *nextPos + 1
+	}
+	for _, f := range fields {
+		var twin EventField
+		if tx.Where("event_id = ? AND key = ?", target.ID, f.Key).First(&twin).Error != nil {
+			if err := tx.Model(&EventField{}).Where("id = ?", f.ID).
+				Updates(map[string]interface{}{"event_id": target.ID, "position": pos}).Error; err != nil {
+				return counts, err
+			}
+			pos++
+			continue
+		}
+		if err := tx.Model(&AttendeeAnswer{}).Where("field_id = ?", f.ID).
+			Where("attendee_id NOT IN (?)", tx.Model(&AttendeeAnswer{}).Select("attendee_id").Where("field_id = ?", twin.ID)).
+			Update("field_id", twin.ID).Error; err != nil {
+			return counts, err
+		}
+		if err := tx.Where("field_id = ?", f.ID).Delete(&AttendeeAnswer{}).Error; err != nil {
+			return counts, err
+		}
+		if err := tx.Delete(&EventField{}, f.ID).Error; err != nil {
+			return counts, err
+		}
+	}
+
+	// waitlist: nobody queues for an event they're already on
+	if err := tx.Where("event_id = ?", source.ID).
+		Where("user_id IN (?) OR user_id IN (?)",
+			tx.Model(&WaitlistEntry{}).Select("user_id").Where("event_id = ?", target.ID),
+			tx.Model(&EventAttendee{}).Select("user_id").Where("event_id = ?", target.ID)).
+		Delete(&WaitlistEntry{}).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&WaitlistEntry{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+
+	// widget guests: the target's answer wins for the same email
+	if err := tx.Where("event_id = ? AND email IN (?)", source.ID,
+		tx.Model(&GuestRSVP{}).Select("email").Where("event_id = ?", target.ID)).
+		Delete(&GuestRSVP{}).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&GuestRSVP{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+
+	// changes to single occurrences only make sense in the source's series
+	if err := deleteOccurrenceChanges(tx, source.ID); err != nil {
+		return counts, err
+	}
+	// certificates already issued for the source keep pointing at it
+	if err := tx.Where("event_id = ?", source.ID).Delete(&CertificateTemplate{}).Error; err != nil {
+		return counts, err
+	}
+	// tickets moved over fall under the target's policy
+	if err := tx.Where("event_id = ?", source.ID).Delete(&CancellationPolicy{}).Error; err != nil {
+		return counts, err
+	}
+	// the source's door devices stop working rather than checking people
+	// into the target
+	if err := tx.Model(&KioskToken{}).Where("event_id = ? AND revoked_at IS NULL", source.ID).Update("revoked_at", time.Now()).Error; err != nil {
+		return counts, err
+	}
+
+	// ticket tiers and sessions move with the people holding them
+	if err := tx.Model(&TicketTier{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&EventSession{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&SessionRegistration{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	// the source's run-of-show was timed from its own start; the target's
+	// can be regenerated to pick up the moved sessions
+	if err := tx.Where("event_id = ?", source.ID).Delete(&RunOfShowItem{}).Error; err != nil {
+		return counts, err
+	}
+	// so was its weather plan, and the target can only have one
+	if err := tx.Where("event_id = ?", source.ID).Delete(&WeatherContingency{}).Error; err != nil {
+		return counts, err
+	}
+
+	// reminders move over unless the person already has one at that
+	// offset, and count from the target's start
+	if err := tx.Where("event_id = ?", source.ID).
+		Where("(user_id, offset_minutes) IN (?)", tx.Model(&Reminder{}).Select("user_id, offset_minutes").Where("event_id = ?", target.ID)).
+		Delete(&Reminder{}).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&Reminder{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := rearmReminders(tx, target.ID, target.Date); err != nil {
+		return counts, err
+	}
+
+	// the source's slugs keep working, leading to the target
+	if err := tx.Model(&EventSlugHistory{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if source.Slug != nil {
+		if err := tx.Model(&Event{}).Where("id = ?", source.ID).Update("slug", nil).Error; err != nil {
+			return counts, err
+		}
+		if err := tx.Create(&EventSlugHistory{Slug: *source.Slug, EventID: target.ID}).Error; err != nil {
+			return counts, err
+		}
+	}
+
+	// tasks go to the bottom of their column, keeping their order
+	var tasks []Task
+	if err := tx.Where("event_id = ?", source.ID).Order("position asc, id asc").Find(&tasks).Error; err != nil {
+		return counts, err
+	}
+	next := map[string]int{}
+	for _, t := range tasks {
+		if _, ok := next[t.Status]; !ok {
+			next[t.Status] = nextTaskPosition(tx, target.ID, t.Status)
+		}
+		if err := tx.Model(&Task{}).Where("id = ?", t.ID).
+			Updates(map[string]interface{}{"event_id": target.ID, "position": next[t.Status]}).Error; err != nil {
+			return counts, err
+		}
+		next[t.Status]++
+		recordChange(tx, EntityTask, t.ID, target.ID, ChangeUpsert)
+		counts.Tasks++
+	}
+
+	var commentIDs, announcementIDs []uint
+	tx.Model(&EventComment{}).Where("event_id = ?", source.ID).Pluck("id", &commentIDs)
+	tx.Model(&Announcement{}).Where("event_id = ?", source.ID).Pluck("id", &announcementIDs)
+	if err := tx.Model(&EventComment{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&Announcement{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&Contract{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&Attachment{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&ResourceBooking{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	// the target keeps its own cover, or takes over the source's
+	if source.CoverKey != "" && target.CoverKey == "" {
+		if err := tx.Model(&Event{}).Where("id = ?", target.ID).
+			Updates(map[string]interface{}{"cover_key": source.CoverKey, "cover_size": source.CoverSize}).Error; err != nil {
+			return counts, err
+		}
+	}
+	if err := tx.Model(&Event{}).Where("id = ?", source.ID).
+		Updates(map[string]interface{}{"cover_key": "", "cover_size": 0}).Error; err != nil {
+		return counts, err
+	}
+	for _, id := range commentIDs {
+		recordChange(tx, EntityComment, id, target.ID, ChangeUpsert)
+	}
+	for _, id := range announcementIDs {
+		recordChange(tx, EntityAnnouncement, id, target.ID, ChangeUpsert)
+	}
+	counts.Comments, counts.Announcements = len(commentIDs), len(announcementIDs)
+
+	// budget: vendors, their quotes and expenses move as they are,
+	// categories only when the target has none by that name
+	res := tx.Model(&Vendor{}).Where("event_id = ?", source.ID).Update("event_id", target.ID)
+	if res.Error != nil {
+		return counts, res.Error
+	}
+	counts.Vendors = int(res.RowsAffected)
+	if err := tx.Model(&VendorQuote{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+	res = tx.Model(&Expense{}).Where("event_id = ?", source.ID).Update("event_id", target.ID)
+	if res.Error != nil {
+		return counts, res.Error
+	}
+	counts.Expenses = int(res.RowsAffected)
+	if err := tx.Where("event_id = ? AND name IN (?)", source.ID,
+		tx.Model(&BudgetCategory{}).Select("name").Where("event_id = ?", target.ID)).
+		Delete(&BudgetCategory{}).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&BudgetCategory{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+
+	// reports about invitations to the source now concern the target
+	if err := tx.Where("event_id = ? AND reporter_id IN (?)", source.ID,
+		tx.Model(&InvitationReport{}).Select("reporter_id").Where("event_id = ?", target.ID)).
+		Delete(&InvitationReport{}).Error; err != nil {
+		return counts, err
+	}
+	if err := tx.Model(&InvitationReport{}).Where("event_id = ?", source.ID).Update("event_id", target.ID).Error; err != nil {
+		return counts, err
+	}
+
+	recordEventRemoved(tx, source.ID, audience)
+	recordChange(tx, EntityEvent, target.ID, target.ID, ChangeUpsert)
+	return counts, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EventPrivateRequest struct {
+	PrivateNotes   *string `json:"private_notes"`
+	VendorContacts *string `json:"vendor_contacts"`
+	BudgetDetails  *string `json:"budget_details"`
+}
+
+// UpdateEventPrivate edits the organizer-only fields of an event.
+func UpdateEventPrivate(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body EventPrivateRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	if body.PrivateNotes != nil {
+		ev.PrivateNotes = *body.PrivateNotes
+	}
+	if body.VendorContacts != nil {
+		ev.VendorContacts = *body.VendorContacts
+	}
+	if body.BudgetDetails != nil {
+		ev.BudgetDetails = *body.BudgetDetails
+	}
+	if err := DB.Save(&ev).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update event: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+
+	c.JSON(http.StatusOK, eventView(ev, true))
+}
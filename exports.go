@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const exportLinkTTL = 48 * time.Hour
+
+func init() {
+	RegisterJob("data_export", runDataExport)
+}
+
+type dataExportJob struct {
+	ExportID uint `json:"export_id"`
+}
+
+func RequestDataExport(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	// one export at a time per user; stale ones (failed jobs) stop blocking after an hour
+	var running int64
+	DB.Model(&DataExport{}).
+		Where("user_id = ? AND status = ? AND created_at > ?", userID, JobPending, time.Now().Add(-time.Hour)).
+		Count(&running)
+	if running > 0 {
+		jsonError(c, http.StatusConflict, "an export is already in progress")
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create export")
+		return
+	}
+
+	exp := DataExport{UserID: userID, Status: JobPending, Token: token}
+	if err := DB.Create(&exp).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create export: "+err.Error())
+		return
+	}
+	if err := Enqueue("data_export", dataExportJob{ExportID: exp.ID}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not queue export: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, exp)
+}
+
+// DownloadDataExport serves a finished archive by its unguessable token.
+func DownloadDataExport(c *gin.Context) {
+	var exp DataExport
+	if err := DB.Where("token = ?", c.Param("token")).First(&exp).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "export not found")
+		return
+	}
+	if exp.Status != JobDone || exp.ExpiresAt == nil {
+		jsonError(c, http.StatusNotFound, "export not ready")
+		return
+	}
+	if time.Now().After(*exp.ExpiresAt) {
+		jsonError(c, http.StatusGone, "download link expired")
+		return
+	}
+
+	c.FileAttachment(exp.FilePath, fmt.Sprintf("eventplanner-export-%d.zip", exp.UserID))
+}
+
+func runDataExport(ctx context.Context, payload []byte) error {
+	var job dataExportJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var exp DataExport
+	if err := DB.First(&exp, job.ExportID).Error; err != nil {
+		return err
+	}
+
+	dir := filepath.Join(AppConfig.DataDir, "exports")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, exp.Token+".zip")
+
+	if err := writeUserArchive(path, exp.UserID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expires := now.Add(exportLinkTTL)
+	exp.Status = JobDone
+	exp.FilePath = path
+	exp.CompletedAt = &now
+	exp.ExpiresAt = &expires
+	if err := DB.Save(&exp).Error; err != nil {
+		return err
+	}
+
+	NotifyTemplate(exp.UserID, "data_export_ready", map[string]string{"ExpiresAt": expires.Format(time.RFC1123)},
+		gin.H{"download_url": "/exports/" + exp.Token, "expires_at": expires})
+	return nil
+}
+
+// collectUserData gathers everything we store about a user, keyed by file name.
+func collectUserData(userID uint) (map[string]interface{}, error) {
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	user.Password = ""
+
+	var organized []Event
+	if err := DB.Preload("Tasks").Where("organizer_id = ?", userID).Find(&organized).Error; err != nil {
+		return nil, err
+	}
+
+	var rsvps []EventAttendee
+	if err := DB.Where("user_id = ?", userID).Find(&rsvps).Error; err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	if err := DB.Where("user_id = ?", userID).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	var notifications []Notification
+	if err := DB.Where("user_id = ?", userID).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+
+	var certificates []AttendanceCertificate
+	if err := DB.Where("user_id = ?", userID).Find(&certificates).Error; err != nil {
+		return nil, err
+	}
+
+	var registrations []SessionRegistration
+	if err := DB.Where("user_id = ?", userID).Find(&registrations).Error; err != nil {
+		return nil, err
+	}
+
+	var reminders []Reminder
+	if err := DB.Where("user_id = ?", userID).Find(&reminders).Error; err != nil {
+		return nil, err
+	}
+
+	var invoices []Invoice
+	if err := DB.Where("user_id = ?", userID).Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	var billing []BillingProfile
+	if err := DB.Where("user_id = ?", userID).Find(&billing).Error; err != nil {
+		return nil, err
+	}
+
+	var comments []EventComment
+	if err := DB.Where("author_id = ?", userID).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	var expenses []Expense
+	if err := DB.Where("created_by_id = ?", userID).Find(&expenses).Error; err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"profile.json":          user,
+		"certificates.json":     certificates,
+		"events_organized.json": organized,
+		"rsvps.json":            rsvps,
+		"sessions.json":         sessions,
+		"notifications.json":    notifications,
+
+		"session_registrations.json": registrations,
+		"reminders.json":             reminders,
+		"invoices.json":              invoices,
+		"billing.json":               billing,
+		"expenses.json":              expenses,
+		"comments.json":              comments,
+	}, nil
+}
+
+func writeUserArchive(path string, userID uint) error {
+	data, err := collectUserData(userID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, v := range data {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
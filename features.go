@@ -0,0 +1,220 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureEnabled resolves a flag for a user. Precedence: user override,
+// organization override, DB flag (enabled or inside the rollout slice),
+// then the FEATURE_FLAGS config default.
+func FeatureEnabled(key string, userID uint) bool {
+	var overrides []FeatureFlagOverride
+	DB.Where("flag_key = ? AND subject_type = ? AND subject_id = ?", key, "user", userID).Find(&overrides)
+	if len(overrides) > 0 {
+		return overrides[0].Enabled
+	}
+
+	if orgIDs := userOrgIDs(userID); len(orgIDs) > 0 {
+		DB.Where("flag_key = ? AND subject_type = ? AND subject_id IN ?", key, "org", orgIDs).Find(&overrides)
+		if len(overrides) > 0 {
+			// any org enabling the feature is enough
+			for _, o := range overrides {
+				if o.Enabled {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	var flag FeatureFlag
+	if err := DB.Where("key = ?", key).First(&flag).Error; err == nil {
+		return flag.Enabled || inRollout(key, userID, flag.RolloutPercent)
+	}
+
+	return configFeatureDefault(key)
+}
+
+// inRollout puts a user in a stable bucket per flag.
+func inRollout(key string, userID uint, percent int) bool {
+	if percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{byte(userID), byte(userID >> 8), byte(userID >> 16), byte(userID >> 24)})
+	return int(h.Sum32()%100) < percent
+}
+
+func configFeatureDefault(key string) bool {
+	for _, k := range AppConfig.FeatureFlags {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireFeature hides a route (404) from users without the flag.
+func RequireFeature(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok || !FeatureEnabled(key, userID) {
+			jsonError(c, http.StatusNotFound, "feature not available")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func knownFeatureKeys() []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, k := range AppConfig.FeatureFlags {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	var dbKeys []string
+	DB.Model(&FeatureFlag{}).Pluck("key", &dbKeys)
+	for _, k := range dbKeys {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func GetMyFeatures(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	out := gin.H{}
+	for _, k := range knownFeatureKeys() {
+		out[k] = FeatureEnabled(k, userID)
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// ========================
+// ADMIN
+// ========================
+
+type UpsertFeatureFlagRequest struct {
+	Description    string `json:"description"`
+	Enabled        bool   `json:"enabled"`
+	RolloutPercent int    `json:"rollout_percent"`
+}
+
+func ListFeatureFlags(c *gin.Context) {
+	var flags []FeatureFlag
+	if err := DB.Order("key asc").Find(&flags).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var overrides []FeatureFlagOverride
+	DB.Order("flag_key asc").Find(&overrides)
+
+	c.JSON(http.StatusOK, gin.H{
+		"flags":           flags,
+		"overrides":       overrides,
+		"config_defaults": AppConfig.FeatureFlags,
+	})
+}
+
+func UpsertFeatureFlag(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+	key := strings.TrimSpace(c.Param("key"))
+
+	var body UpsertFeatureFlagRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.RolloutPercent < 0 || body.RolloutPercent > 100 {
+		jsonError(c, http.StatusBadRequest, "rollout_percent must be between 0 and 100")
+		return
+	}
+
+	flag := FeatureFlag{Key: key}
+	DB.Where("key = ?", key).First(&flag)
+	flag.Description = body.Description
+	flag.Enabled = body.Enabled
+	flag.RolloutPercent = body.RolloutPercent
+	if err := DB.Save(&flag).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save flag: "+err.Error())
+		return
+	}
+
+	Audit(adminID, "feature.update", "feature_flag", flag.ID, gin.H{"key": key, "enabled": flag.Enabled, "rollout_percent": flag.RolloutPercent})
+
+	c.JSON(http.StatusOK, flag)
+}
+
+type FeatureOverrideRequest struct {
+	SubjectType string `json:"subject_type" binding:"required"`
+	SubjectID   uint   `json:"subject_id" binding:"required"`
+	Enabled     bool   `json:"enabled"`
+}
+
+func SetFeatureOverride(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+	key := strings.TrimSpace(c.Param("key"))
+
+	var body FeatureOverrideRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.SubjectType != "user" && body.SubjectType != "org" {
+		jsonError(c, http.StatusBadRequest, "subject_type must be user or org")
+		return
+	}
+
+	o := FeatureFlagOverride{FlagKey: key, SubjectType: body.SubjectType, SubjectID: body.SubjectID}
+	DB.Where(&o).First(&o)
+	o.Enabled = body.Enabled
+	if err := DB.Save(&o).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save override: "+err.Error())
+		return
+	}
+
+	Audit(adminID, "feature.override", "feature_flag", 0, gin.H{"key": key, "subject_type": o.SubjectType, "subject_id": o.SubjectID, "enabled": o.Enabled})
+
+	c.JSON(http.StatusOK, o)
+}
+
+func DeleteFeatureOverride(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+	key := strings.TrimSpace(c.Param("key"))
+	subjectType := c.Query("subject_type")
+	subjectID := c.Query("subject_id")
+
+	res := DB.Where("flag_key = ? AND subject_type = ? AND subject_id = ?", key, subjectType, subjectID).Delete(&FeatureFlagOverride{})
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "override not found")
+		return
+	}
+
+	Audit(adminID, "feature.override_removed", "feature_flag", 0, gin.H{"key": key, "subject_type": subjectType, "subject_id": subjectID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "override removed"})
+}
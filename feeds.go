@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedTokenMiddleware authenticates read-only feeds by the personal feed
+// token (?token= or "Authorization: Feed <token>"), for clients that
+// can't hold a JWT such as widgets and calendar apps.
+func FeedTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if h := c.GetHeader("Authorization"); token == "" && strings.HasPrefix(h, "Feed ") {
+			token = strings.TrimPrefix(h, "Feed ")
+		}
+		if token == "" {
+			jsonError(c, http.StatusUnauthorized, "missing feed token")
+			c.Abort()
+			return
+		}
+
+		var user User
+		if err := DB.Select("id").Where("feed_token_hash = ?", hashToken(token)).First(&user).Error; err != nil {
+			jsonError(c, http.StatusUnauthorized, "invalid feed token")
+			c.Abort()
+			return
+		}
+		if s := activeSuspension(user.ID); s != nil && s.Mode == SuspensionBanned {
+			jsonError(c, http.StatusForbidden, "account banned")
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Next()
+	}
+}
+
+// RotateFeedToken issues a new feed token, invalidating the previous one.
+// The token is only shown in this response.
+func RotateFeedToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token")
+		return
+	}
+	if err := DB.Model(&User{}).Where("id = ?", userID).Update("feed_token_hash", hashToken(token)).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feed_token": token})
+}
+
+type feedItem struct {
+	ID       uint      `json:"id"`
+	Title    string    `json:"title"`
+	Location string    `json:"location"`
+	Date     time.Time `json:"date"`
+	Status   string    `json:"status,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+}
+
+// GetUpcomingFeed lists the user's events in the next ?days= days (default 7)
+// as compact JSON or RSS 2.0 (?format=rss).
+func GetUpcomingFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	days := 7
+	if d := c.Query("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > 365 {
+			jsonError(c, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	items := []feedItem{}
+	if ids := participatingEventIDs(userID); len(ids) > 0 {
+		var events []Event
+		if err := DB.Where("id IN ? AND date >= ? AND date < ?", ids, now, now.AddDate(0, 0, days)).
+			Order("date asc").Find(&events).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+
+		var rsvps []EventAttendee
+		DB.Where("user_id = ? AND event_id IN ?", userID, ids).Find(&rsvps)
+		status := map[uint]string{}
+		for _, a := range rsvps {
+			status[a.EventID] = a.Status
+		}
+
+		for _, e := range events {
+			items = append(items, feedItem{ID: e.ID, Title: e.Title, Location: e.Location, Date: e.Date, Status: status[e.ID]})
+		}
+	}
+
+	if c.Query("format") != "rss" {
+		c.JSON(http.StatusOK, gin.H{"days": days, "events": items})
+		return
+	}
+
+	base := AppConfig.PublicBaseURL
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       "Upcoming events",
+			Link:        base,
+			Description: fmt.Sprintf("Your events in the next %d days", days),
+		},
+	}
+	for _, it := range items {
+		desc := it.Date.Format(time.RFC1123)
+		if it.Location != "" {
+			desc += " · " + it.Location
+		}
+		link := fmt.Sprintf("%s/events/%d", base, it.ID)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       it.Title,
+			Link:        link,
+			Description: desc,
+			GUID:        link,
+			PubDate:     it.Date.Format(time.RFC1123Z),
+		})
+	}
+
+	c.XML(http.StatusOK, feed)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The headcount forecast turns RSVPs into the number of people likely to
+// walk in, for catering and seating. Show-up rates come from the
+// organizer's past events that used check-in: how many of those who said
+// Going, or Maybe, were checked in. Attendee labels with enough history
+// ("vip", "family") get their own rate. Rates are shrunk towards a prior so
+// a few past events can't swing them to 0 or 1, and the range treats each
+// attendee as an independent coin toss.
+
+const (
+	forecastPastEvents = 20  // most recent events with check-ins that count
+	forecastPriorShow  = 0.8 // Going attendees' show-up rate without history
+	forecastPriorMaybe = 0.3 // Maybe attendees' show-up rate without history
+	forecastPriorSize  = 10  // how many attendees the prior weighs as
+	forecastMinLabel   = 10  // past attendees a label needs for its own rate
+	forecastConfidence = 0.8
+	forecastZ          = 1.2816 // two-sided 80% normal quantile
+)
+
+type labelShowRate struct {
+	Label    string  `json:"label"`
+	ShowRate float64 `json:"show_rate"`
+	Samples  int     `json:"samples"`
+}
+
+type HeadcountForecast struct {
+	Expected      int             `json:"expected"`
+	Low           int             `json:"low"`
+	High          int             `json:"high"`
+	Confidence    float64         `json:"confidence"`
+	Basis         string          `json:"basis"` // "history" or "default"
+	PastEvents    int             `json:"past_events"`
+	ShowRate      float64         `json:"show_rate"`
+	MaybeShowRate float64         `json:"maybe_show_rate"`
+	Labels        []labelShowRate `json:"labels"`
+}
+
+// showRates are the show-up rates learned from an organizer's history.
+type showRates struct {
+	pastEvents int
+	going      float64
+	maybe      float64
+	labels     map[string]labelShowRate
+}
+
+// shrink blends shows out of n with a prior worth forecastPriorSize attendees.
+func shrink(shows, n int, prior float64) float64 {
+	return (float64(shows) + prior*forecastPriorSize) / float64(n+forecastPriorSize)
+}
+
+func organizerShowRates(organizerID, excludeEventID uint, before time.Time) showRates {
+	var eventIDs []uint
+	ReadDB.Model(&Event{}).
+		Where("organizer_id = ? AND id <> ? AND date < ? AND cancelled_at IS NULL", organizerID, excludeEventID, before).
+		Where("EXISTS (SELECT 1 FROM event_attendees ci WHERE ci.event_id = events.id AND ci.checked_in_at IS NOT NULL)").
+		Order("date desc").Limit(forecastPastEvents).Pluck("id", &eventIDs)
+	rates := showRates{pastEvents: len(eventIDs), going: forecastPriorShow, maybe: forecastPriorMaybe, labels: map[string]labelShowRate{}}
+	if len(eventIDs) == 0 {
+		return rates
+	}
+
+	var past []EventAttendee
+	ReadDB.Select("status", "labels", "checked_in_at").
+		Where("event_id IN ? AND role <> ? AND status IN ?", eventIDs, "organizer", []string{"Going", "Maybe"}).
+		Find(&past)
+	var going, goingShows, maybe, maybeShows int
+	type tally struct{ n, shows int }
+	byLabel := map[string]*tally{}
+	for _, a := range past {
+		showed := a.CheckedInAt != nil
+		if a.Status == "Maybe" {
+			maybe++
+			if showed {
+				maybeShows++
+			}
+			continue
+		}
+		going++
+		if showed {
+			goingShows++
+		}
+		for _, l := range attendeeLabels(a) {
+			if byLabel[l] == nil {
+				byLabel[l] = &tally{}
+			}
+			byLabel[l].n++
+			if showed {
+				byLabel[l].shows++
+			}
+		}
+	}
+	rates.going = shrink(goingShows, going, forecastPriorShow)
+	rates.maybe = shrink(maybeShows, maybe, forecastPriorMaybe)
+	for l, t := range byLabel {
+		if t.n >= forecastMinLabel {
+			rates.labels[l] = labelShowRate{Label: l, ShowRate: shrink(t.shows, t.n, rates.going), Samples: t.n}
+		}
+	}
+	return rates
+}
+
+// forecastHeadcount predicts how many of the event's Going and Maybe
+// attendees and guests will turn up.
+func forecastHeadcount(ev Event) HeadcountForecast {
+	rates := organizerShowRates(ev.OrganizerID, ev.ID, time.Now())
+
+	var attendees []EventAttendee
+	ReadDB.Select("status", "labels").
+		Where("event_id = ? AND role <> ? AND status IN ?", ev.ID, "organizer", []string{"Going", "Maybe"}).
+		Find(&attendees)
+	var guests int64
+	ReadDB.Model(&GuestRSVP{}).Where("event_id = ? AND status = ?", ev.ID, "Going").Count(&guests)
+
+	var mean, variance float64
+	add := func(p float64) {
+		mean += p
+		variance += p * (1 - p)
+	}
+	for _, a := range attendees {
+		if a.Status == "Maybe" {
+			add(rates.maybe)
+			continue
+		}
+		// the least reliable of their labels, so one "vip" tag doesn't
+		// outweigh a "plus-one"
+		p, labelled := 0.0, false
+		for _, l := range attendeeLabels(a) {
+			if r, ok := rates.labels[l]; ok && (!labelled || r.ShowRate < p) {
+				p, labelled = r.ShowRate, true
+			}
+		}
+		if !labelled {
+			p = rates.going
+		}
+		add(p)
+	}
+	for i := int64(0); i < guests; i++ {
+		add(rates.going)
+	}
+
+	spread := forecastZ * math.Sqrt(variance)
+	f := HeadcountForecast{
+		Expected:      int(math.Round(mean)),
+		Low:           int(math.Max(0, math.Floor(mean-spread))),
+		High:          int(math.Ceil(mean + spread)),
+		Confidence:    forecastConfidence,
+		Basis:         "default",
+		PastEvents:    rates.pastEvents,
+		ShowRate:      math.Round(rates.going*1000) / 1000,
+		MaybeShowRate: math.Round(rates.maybe*1000) / 1000,
+		Labels:        []labelShowRate{},
+	}
+	if rates.pastEvents > 0 {
+		f.Basis = "history"
+	}
+	for _, r := range rates.labels {
+		r.ShowRate = math.Round(r.ShowRate*1000) / 1000
+		f.Labels = append(f.Labels, r)
+	}
+	sort.Slice(f.Labels, func(i, j int) bool { return f.Labels[i].Label < f.Labels[j].Label })
+	return f
+}
+
+// GetEventStats returns an event's RSVP counts and the headcount forecast.
+func GetEventStats(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event_id": ev.ID,
+		"rsvps":    compareAttendanceOf([]uint{ev.ID})[ev.ID],
+		"forecast": forecastHeadcount(ev),
+	})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// An event's health is a 0-100 readiness score for its organizers, the
+// weighted average of how far along it is: tasks done, invitees who have
+// answered, spending against the budget and time left to fix the rest.
+// Components that don't apply yet (no tasks, nobody invited, no budget)
+// are left out and the others reweighted, so a new event isn't penalised
+// for what it hasn't started. Weights come from HEALTH_WEIGHTS.
+
+// HealthWeights are the relative weights of each component; TimeHorizonDays
+// is how far out an event counts as having all the time it needs.
+type HealthWeights struct {
+	Tasks           float64 `json:"tasks"`
+	RSVPs           float64 `json:"rsvps"`
+	Budget          float64 `json:"budget"`
+	Time            float64 `json:"time"`
+	TimeHorizonDays int     `json:"time_horizon_days"`
+}
+
+var healthWeights = HealthWeights{Tasks: 40, RSVPs: 30, Budget: 20, Time: 10, TimeHorizonDays: 30}
+
+const (
+	HealthGood     = "good"
+	HealthAtRisk   = "at_risk"
+	HealthCritical = "critical"
+)
+
+// LoadHealthWeights merges HEALTH_WEIGHTS (a JSON object with any of the
+// HealthWeights fields) over the defaults.
+func LoadHealthWeights() {
+	if AppConfig.HealthWeights == "" {
+		return
+	}
+	w := healthWeights
+	if err := json.Unmarshal([]byte(AppConfig.HealthWeights), &w); err != nil {
+		log.Fatalf("❌ invalid HEALTH_WEIGHTS: %v", err)
+	}
+	if w.Tasks < 0 || w.RSVPs < 0 || w.Budget < 0 || w.Time < 0 || w.Tasks+w.RSVPs+w.Budget+w.Time == 0 {
+		log.Fatalf("❌ invalid HEALTH_WEIGHTS: weights must be non-negative and not all zero")
+	}
+	if w.TimeHorizonDays <= 0 {
+		log.Fatalf("❌ invalid HEALTH_WEIGHTS: time_horizon_days must be positive")
+	}
+	healthWeights = w
+}
+
+// HealthComponent is one input to the score; Score is 0..1.
+type HealthComponent struct {
+	Score  float64 `json:"score"`
+	Weight float64 `json:"weight"`
+	Detail string  `json:"detail"`
+}
+
+type EventHealth struct {
+	Score      int                        `json:"score"`
+	Level      string                     `json:"level"`
+	Components map[string]HealthComponent `json:"components"`
+}
+
+// healthInputs are the per-event counts the score is computed from.
+type healthInputs struct {
+	Tasks, TasksDone        int64
+	Invited, Responded      int64
+	BudgetCents, SpentCents int64
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func computeHealth(ev Event, in healthInputs, now time.Time) EventHealth {
+	w := healthWeights
+	components := map[string]HealthComponent{}
+
+	if in.Tasks > 0 {
+		components["tasks"] = HealthComponent{
+			Score:  float64(in.TasksDone) / float64(in.Tasks),
+			Weight: w.Tasks,
+			Detail: fmt.Sprintf("%d/%d tasks done", in.TasksDone, in.Tasks),
+		}
+	}
+	if in.Invited > 0 {
+		components["rsvps"] = HealthComponent{
+			Score:  float64(in.Responded) / float64(in.Invited),
+			Weight: w.RSVPs,
+			Detail: fmt.Sprintf("%d/%d invitees answered", in.Responded, in.Invited),
+		}
+	}
+	if in.BudgetCents > 0 {
+		budget := HealthComponent{Score: 1, Weight: w.Budget, Detail: "within budget"}
+		if over := in.SpentCents - in.BudgetCents; over > 0 {
+			budget.Score = clamp01(1 - float64(over)/float64(in.BudgetCents))
+			budget.Detail = fmt.Sprintf("over budget by %.0f%%", 100*float64(over)/float64(in.BudgetCents))
+		}
+		components["budget"] = budget
+	}
+	daysLeft := ev.Date.Sub(now).Hours() / 24
+	timeLeft := HealthComponent{
+		Score:  clamp01(daysLeft / float64(w.TimeHorizonDays)),
+		Weight: w.Time,
+		Detail: "event has passed",
+	}
+	if daysLeft > 0 {
+		timeLeft.Detail = fmt.Sprintf("%.0f days left", math.Ceil(daysLeft))
+	}
+	components["time"] = timeLeft
+
+	var sum, weights float64
+	for _, c := range components {
+		sum += c.Score * c.Weight
+		weights += c.Weight
+	}
+	h := EventHealth{Components: components, Level: HealthCritical}
+	if weights > 0 {
+		h.Score = int(math.Round(100 * sum / weights))
+	}
+	switch {
+	case h.Score >= 75:
+		h.Level = HealthGood
+	case h.Score >= 50:
+		h.Level = HealthAtRisk
+	}
+	for k, c := range components {
+		c.Score = math.Round(c.Score*100) / 100
+		components[k] = c
+	}
+	return h
+}
+
+// eventHealth scores events in a handful of grouped queries.
+func eventHealth(events []Event) map[uint]EventHealth {
+	out := map[uint]EventHealth{}
+	if len(events) == 0 {
+		return out
+	}
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	inputs := map[uint]*healthInputs{}
+	for _, id := range ids {
+		inputs[id] = &healthInputs{}
+	}
+
+	var tasks []struct {
+		EventID     uint
+		Total, Done int64
+	}
+	ReadDB.Model(&Task{}).
+		Select("event_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS done", TaskDone).
+		Where("event_id IN ?", ids).Group("event_id").Scan(&tasks)
+	for _, t := range tasks {
+		inputs[t.EventID].Tasks, inputs[t.EventID].TasksDone = t.Total, t.Done
+	}
+
+	var rsvps []struct {
+		EventID          uint
+		Total, Responded int64
+	}
+	ReadDB.Model(&EventAttendee{}).
+		Select("event_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status <> '') AS responded").
+		Where("event_id IN ? AND role <> ?", ids, "organizer").Group("event_id").Scan(&rsvps)
+	for _, r := range rsvps {
+		inputs[r.EventID].Invited, inputs[r.EventID].Responded = r.Total, r.Responded
+	}
+
+	var budgets []struct {
+		EventID uint
+		Total   int64
+	}
+	ReadDB.Model(&BudgetCategory{}).Select("event_id, SUM(budget_cents) AS total").
+		Where("event_id IN ?", ids).Group("event_id").Scan(&budgets)
+	for _, b := range budgets {
+		inputs[b.EventID].BudgetCents = b.Total
+	}
+	var spent []struct {
+		EventID uint
+		Total   int64
+	}
+	ReadDB.Model(&Expense{}).Select("event_id, SUM(amount_cents) AS total").
+		Where("event_id IN ?", ids).Group("event_id").Scan(&spent)
+	for _, s := range spent {
+		inputs[s.EventID].SpentCents = s.Total
+	}
+
+	now := time.Now()
+	for _, ev := range events {
+		out[ev.ID] = computeHealth(ev, *inputs[ev.ID], now)
+	}
+	return out
+}
+
+// attachHealth adds the health score to the views the caller organizes.
+func attachHealth(views []EventView, events []Event) {
+	organized := []Event{}
+	for i, v := range views {
+		if v.IsOrganizer {
+			organized = append(organized, events[i])
+		}
+	}
+	health := eventHealth(organized)
+	for i := range views {
+		if h, ok := health[views[i].ID]; ok {
+			views[i].Health = &h
+		}
+	}
+}
+
+// GetEventHealth returns the health score of one organized event.
+func GetEventHealth(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	h := eventHealth([]Event{ev})[ev.ID]
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "health": h, "weights": healthWeights})
+}
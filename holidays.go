@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Holiday is a public holiday in one country.
+type Holiday struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Name    string `json:"name"`
+	Country string `json:"country"`
+}
+
+// HolidayProvider lists the public holidays of a country for a year.
+type HolidayProvider interface {
+	Holidays(ctx context.Context, country string, year int) ([]Holiday, error)
+}
+
+// ========================
+// BUILT-IN DATASET
+// ========================
+
+// holidayRule is a holiday on a fixed date (Weekday unset) or on the Nth
+// weekday of a month (N = -1 for the last one).
+type holidayRule struct {
+	Month   time.Month
+	Day     int
+	Weekday *time.Weekday
+	N       int
+	Name    string
+}
+
+func nthWeekday(wd time.Weekday, n int, month time.Month, name string) holidayRule {
+	return holidayRule{Month: month, Weekday: &wd, N: n, Name: name}
+}
+
+// builtinHolidays only covers holidays with a rule-based date; movable
+// religious holidays need the nager provider.
+var builtinHolidays = map[string][]holidayRule{
+	"EG": {
+		{Month: time.January, Day: 7, Name: "Coptic Christmas"},
+		{Month: time.January, Day: 25, Name: "Revolution Day (25 January)"},
+		{Month: time.April, Day: 25, Name: "Sinai Liberation Day"},
+		{Month: time.May, Day: 1, Name: "Labour Day"},
+		{Month: time.June, Day: 30, Name: "June 30 Revolution"},
+		{Month: time.July, Day: 23, Name: "Revolution Day (23 July)"},
+		{Month: time.October, Day: 6, Name: "Armed Forces Day"},
+	},
+	"US": {
+		{Month: time.January, Day: 1, Name: "New Year's Day"},
+		nthWeekday(time.Monday, 3, time.January, "Martin Luther King Jr. Day"),
+		nthWeekday(time.Monday, 3, time.February, "Presidents' Day"),
+		nthWeekday(time.Monday, -1, time.May, "Memorial Day"),
+		{Month: time.June, Day: 19, Name: "Juneteenth"},
+		{Month: time.July, Day: 4, Name: "Independence Day"},
+		nthWeekday(time.Monday, 1, time.September, "Labor Day"),
+		{Month: time.November, Day: 11, Name: "Veterans Day"},
+		nthWeekday(time.Thursday, 4, time.November, "Thanksgiving Day"),
+		{Month: time.December, Day: 25, Name: "Christmas Day"},
+	},
+	"GB": {
+		{Month: time.January, Day: 1, Name: "New Year's Day"},
+		nthWeekday(time.Monday, 1, time.May, "Early May Bank Holiday"),
+		nthWeekday(time.Monday, -1, time.May, "Spring Bank Holiday"),
+		{Month: time.December, Day: 25, Name: "Christmas Day"},
+		{Month: time.December, Day: 26, Name: "Boxing Day"},
+	},
+	"DE": {
+		{Month: time.January, Day: 1, Name: "Neujahr"},
+		{Month: time.May, Day: 1, Name: "Tag der Arbeit"},
+		{Month: time.October, Day: 3, Name: "Tag der Deutschen Einheit"},
+		{Month: time.December, Day: 25, Name: "Erster Weihnachtstag"},
+		{Month: time.December, Day: 26, Name: "Zweiter Weihnachtstag"},
+	},
+	"FR": {
+		{Month: time.January, Day: 1, Name: "Jour de l'an"},
+		{Month: time.May, Day: 1, Name: "Fête du Travail"},
+		{Month: time.May, Day: 8, Name: "Victoire 1945"},
+		{Month: time.July, Day: 14, Name: "Fête nationale"},
+		{Month: time.August, Day: 15, Name: "Assomption"},
+		{Month: time.November, Day: 1, Name: "Toussaint"},
+		{Month: time.November, Day: 11, Name: "Armistice 1918"},
+		{Month: time.December, Day: 25, Name: "Noël"},
+	},
+}
+
+type builtinHolidayProvider struct{}
+
+func (builtinHolidayProvider) Holidays(ctx context.Context, country string, year int) ([]Holiday, error) {
+	out := []Holiday{}
+	for _, r := range builtinHolidays[country] {
+		var d time.Time
+		switch {
+		case r.Weekday == nil:
+			d = time.Date(year, r.Month, r.Day, 0, 0, 0, 0, time.UTC)
+		case r.N > 0:
+			d = time.Date(year, r.Month, 1, 0, 0, 0, 0, time.UTC)
+			for d.Weekday() != *r.Weekday {
+				d = d.AddDate(0, 0, 1)
+			}
+			d = d.AddDate(0, 0, 7*(r.N-1))
+		default:
+			d = time.Date(year, r.Month+1, 0, 0, 0, 0, 0, time.UTC)
+			for d.Weekday() != *r.Weekday {
+				d = d.AddDate(0, 0, -1)
+			}
+		}
+		out = append(out, Holiday{Date: d.Format("2006-01-02"), Name: r.Name, Country: country})
+	}
+	return out, nil
+}
+
+// ========================
+// NAGER.DATE PROVIDER
+// ========================
+
+// nagerHolidayProvider uses the public date.nager.at API.
+type nagerHolidayProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (p *nagerHolidayProvider) Holidays(ctx context.Context, country string, year int) ([]Holiday, error) {
+	url := fmt.Sprintf("%s/api/v3/PublicHolidays/%d/%s", p.baseURL, year, country)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent {
+		return []Holiday{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("holiday provider returned %s", resp.Status)
+	}
+
+	var list []struct {
+		Date   string `json:"date"`
+		Name   string `json:"name"`
+		Global bool   `json:"global"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	out := make([]Holiday, 0, len(list))
+	for _, h := range list {
+		if !h.Global {
+			continue // regional holidays only apply to part of the country
+		}
+		out = append(out, Holiday{Date: h.Date, Name: h.Name, Country: country})
+	}
+	return out, nil
+}
+
+// ========================
+// LOOKUP
+// ========================
+
+// Holidays is the configured provider; results are cached per country and year.
+var Holidays HolidayProvider = builtinHolidayProvider{}
+
+var holidayCache = struct {
+	sync.Mutex
+	m map[string]map[string]Holiday
+}{m: map[string]map[string]Holiday{}}
+
+func InitHolidays() {
+	switch strings.ToLower(AppConfig.HolidayProvider) {
+	case "", "builtin":
+		Holidays = builtinHolidayProvider{}
+	case "nager":
+		Holidays = &nagerHolidayProvider{baseURL: "https://date.nager.at", client: newHTTPClient(10 * time.Second)}
+	default:
+		log.Fatalf("❌ unknown HOLIDAY_PROVIDER %q", AppConfig.HolidayProvider)
+	}
+}
+
+// holidaysByDate returns a country's holidays for a year keyed by date.
+// Provider errors are logged and treated as "no holidays" so they never
+// block event creation.
+func holidaysByDate(ctx context.Context, country string, year int) map[string]Holiday {
+	key := fmt.Sprintf("%s/%d", country, year)
+	holidayCache.Lock()
+	cached, ok := holidayCache.m[key]
+	holidayCache.Unlock()
+	if ok {
+		return cached
+	}
+
+	list, err := Holidays.Holidays(ctx, country, year)
+	if err != nil {
+		log.Printf("⚠️ holiday lookup %s failed: %v", key, err)
+		return map[string]Holiday{}
+	}
+	byDate := map[string]Holiday{}
+	for _, h := range list {
+		byDate[h.Date] = h
+	}
+	holidayCache.Lock()
+	holidayCache.m[key] = byDate
+	holidayCache.Unlock()
+	return byDate
+}
+
+// holidayOn reports the public holiday on day (in its own location), if any.
+func holidayOn(ctx context.Context, country string, day time.Time) (Holiday, bool) {
+	if country == "" {
+		return Holiday{}, false
+	}
+	h, ok := holidaysByDate(ctx, country, day.Year())[day.Format("2006-01-02")]
+	return h, ok
+}
+
+var reCountryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// localeCountry is the user's configured country, falling back to the
+// region of the request's Accept-Language (e.g. "ar-EG").
+func localeCountry(c *gin.Context, userID uint) string {
+	var user User
+	if err := DB.Select("id", "country").First(&user, userID).Error; err == nil && user.Country != "" {
+		return user.Country
+	}
+	return acceptLanguageCountry(c.GetHeader("Accept-Language"))
+}
+
+// holidayWarnings are the notes CreateEvent attaches when a date falls on
+// a public holiday in the organizer's country.
+func holidayWarnings(c *gin.Context, userID uint, date time.Time) []string {
+	country := localeCountry(c, userID)
+	if h, ok := holidayOn(c.Request.Context(), country, date); ok {
+		return []string{fmt.Sprintf("%s is a public holiday in %s (%s)", h.Date, country, h.Name)}
+	}
+	return nil
+}
+
+// GetHolidays lists holidays between ?from and ?to (YYYY-MM-DD, at most a
+// year apart) for ?country or the caller's locale.
+func GetHolidays(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	country := strings.ToUpper(c.Query("country"))
+	if country == "" {
+		country = localeCountry(c, userID)
+	}
+	if !reCountryCode.MatchString(country) {
+		jsonError(c, http.StatusBadRequest, "country must be an ISO 3166 alpha-2 code")
+		return
+	}
+
+	from, err := time.Parse("2006-01-02", c.DefaultQuery("from", time.Now().Format("2006-01-02")))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "from must be YYYY-MM-DD")
+		return
+	}
+	to := from.AddDate(1, 0, 0)
+	if s := c.Query("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			jsonError(c, http.StatusBadRequest, "to must be YYYY-MM-DD")
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) > 366*24*time.Hour {
+		jsonError(c, http.StatusBadRequest, "range must be positive and at most a year")
+		return
+	}
+
+	out := []Holiday{}
+	for year := from.Year(); year <= to.Year(); year++ {
+		for _, h := range holidaysByDate(c.Request.Context(), country, year) {
+			if h.Date >= from.Format("2006-01-02") && h.Date <= to.Format("2006-01-02") {
+				out = append(out, h)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	c.JSON(http.StatusOK, gin.H{"country": country, "holidays": out})
+}
+
+type LocaleRequest struct {
+	Country  *string `json:"country"`  // ISO 3166 alpha-2, "" to clear
+	Language *string `json:"language"` // en, pt-BR; "" for the default
+}
+
+func UpdateMyLocale(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body LocaleRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	updates := map[string]interface{}{}
+	if body.Country != nil {
+		country := strings.ToUpper(strings.TrimSpace(*body.Country))
+		if country != "" && !reCountryCode.MatchString(country) {
+			jsonError(c, http.StatusBadRequest, "country must be an ISO 3166 alpha-2 code")
+			return
+		}
+		updates["country"] = country
+	}
+	if body.Language != nil {
+		lang := strings.TrimSpace(*body.Language)
+		if lang != "" && !localePattern.MatchString(lang) {
+			jsonError(c, http.StatusBadRequest, "language must look like en or pt-BR")
+			return
+		}
+		updates["language"] = lang
+	}
+
+	if len(updates) > 0 {
+		if err := DB.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not update locale: "+err.Error())
+			return
+		}
+	}
+	var u User
+	DB.Select("id", "country", "language").First(&u, userID)
+	c.JSON(http.StatusOK, gin.H{"country": u.Country, "language": u.Language})
+}
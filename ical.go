@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Events don't have an end time yet; calendars get this default length.
+const defaultEventDuration = time.Hour
+
+// defaultReminderMinutes applies to events whose organizer never set reminders.
+var defaultReminderMinutes = []int{24 * 60, 60}
+
+const maxReminderMinutes = 4 * 7 * 24 * 60
+
+// reminderMinutes is the event's reminder configuration, in minutes before
+// the start, latest first. Shared by the ICS alarms and, for people who
+// haven't set their own, the reminders sent by reminders.go.
+func reminderMinutes(ev Event) []int {
+	if ev.ReminderMinutes == nil {
+		return defaultReminderMinutes
+	}
+	out := []int{}
+	for _, p := range strings.Split(*ev.ReminderMinutes, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(p)); err == nil && n >= 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// normalizeReminderMinutes validates a list of reminders and returns it
+// deduplicated, latest first.
+func normalizeReminderMinutes(in []int) ([]int, error) {
+	if len(in) > 5 {
+		return nil, fmt.Errorf("at most 5 reminders per event")
+	}
+	seen := map[int]bool{}
+	mins := []int{}
+	for _, m := range in {
+		if m < 0 || m > maxReminderMinutes {
+			return nil, fmt.Errorf("minutes_before must be between 0 and 40320 (4 weeks)")
+		}
+		if !seen[m] {
+			seen[m] = true
+			mins = append(mins, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(mins)))
+	return mins, nil
+}
+
+type RemindersRequest struct {
+	MinutesBefore []int `json:"minutes_before"` // empty list disables reminders
+	UseDefault    bool  `json:"use_default"`
+}
+
+// SetEventReminders configures when participants are reminded of an event.
+func SetEventReminders(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body RemindersRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	var value *string
+	if !body.UseDefault {
+		mins, err := normalizeReminderMinutes(body.MinutesBefore)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		parts := make([]string, len(mins))
+		for i, m := range mins {
+			parts[i] = strconv.Itoa(m)
+		}
+		joined := strings.Join(parts, ",")
+		value = &joined
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Update("reminder_minutes", value).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update reminders: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	ev.ReminderMinutes = value
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "minutes_before": reminderMinutes(ev), "default": value == nil})
+}
+
+// ========================
+// ICS RENDERING
+// ========================
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsLine folds a content line at 75 octets as RFC 5545 requires,
+// without splitting UTF-8 sequences.
+func icsLine(b *strings.Builder, name, value string) {
+	line := name + ":" + value
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+func icsHost() string {
+	if u, err := url.Parse(AppConfig.PublicBaseURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "eventplanner"
+}
+
+// icsPartStat maps an RSVP status to the iCalendar participation status.
+func icsPartStat(status string) string {
+	switch status {
+	case "Going":
+		return "ACCEPTED"
+	case "Maybe":
+		return "TENTATIVE"
+	case "Not Going":
+		return "DECLINED"
+	}
+	return "NEEDS-ACTION" // no answer yet, or waitlisted
+}
+
+// icsAttendees loads the ATTENDEE entries viewerID may see per event:
+// everyone on events they organize, only themselves elsewhere.
+func icsAttendees(events []Event, viewerID uint) (map[uint][]EventAttendee, map[uint]string) {
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	organized := []uint{}
+	for id := range organizedEventSet(viewerID, ids) {
+		organized = append(organized, id)
+	}
+
+	byEvent := map[uint][]EventAttendee{}
+	if len(ids) == 0 {
+		return byEvent, map[uint]string{}
+	}
+	q := DB.Where("event_id IN ? AND user_id = ?", ids, viewerID)
+	if len(organized) > 0 {
+		q = DB.Where("event_id IN ? AND (user_id = ? OR event_id IN ?)", ids, viewerID, organized)
+	}
+	var attendees []EventAttendee
+	q.Order("id asc").Find(&attendees)
+
+	userIDs := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		byEvent[a.EventID] = append(byEvent[a.EventID], a)
+		userIDs = append(userIDs, a.UserID)
+	}
+	return byEvent, userEmails(userIDs)
+}
+
+// renderICS writes a VCALENDAR with one VEVENT per event, each carrying a
+// display VALARM per configured reminder and the attendees viewerID may
+// see with their RSVP as PARTSTAT.
+func renderICS(name string, events []Event, viewerID uint) string {
+	var b strings.Builder
+	host := icsHost()
+	now := icsTime(time.Now())
+
+	icsLine(&b, "BEGIN", "VCALENDAR")
+	icsLine(&b, "VERSION", "2.0")
+	icsLine(&b, "PRODID", "-//Eventplanner//EN")
+	icsLine(&b, "CALSCALE", "GREGORIAN")
+	icsLine(&b, "METHOD", "PUBLISH")
+	icsLine(&b, "X-WR-CALNAME", icsEscaper.Replace(name))
+
+	// a calendar of one branded organization takes its accent color
+	brands := eventBrandings(events)
+	if len(events) > 0 && events[0].OrganizationID != nil {
+		orgID := *events[0].OrganizationID
+		same := true
+		for _, ev := range events {
+			same = same && ev.OrganizationID != nil && *ev.OrganizationID == orgID
+		}
+		if brand := brands[orgID]; same && brand != nil && brand.AccentColor != "" {
+			icsLine(&b, "X-APPLE-CALENDAR-COLOR", strings.ToUpper(brand.AccentColor))
+		}
+	}
+
+	recurring := []uint{}
+	for _, ev := range events {
+		if ev.RecurrenceRule != "" {
+			recurring = append(recurring, ev.ID)
+		}
+	}
+	changes := loadOccurrenceChanges(recurring)
+	attendees, emails := icsAttendees(events, viewerID)
+
+	// vevent writes the event, or with occ one changed occurrence of its
+	// series (same UID, told apart by RECURRENCE-ID)
+	vevent := func(ev Event, occ *Occurrence) {
+		start, title, description, location := ev.Date, ev.Title, ev.Description, ev.Location
+		if occ != nil {
+			start, title, location = occ.StartsAt, occ.Title, occ.Location
+			if occ.Description != nil {
+				description = *occ.Description
+			}
+		}
+		icsLine(&b, "BEGIN", "VEVENT")
+		icsLine(&b, "UID", fmt.Sprintf("event-%d@%s", ev.ID, host))
+		icsLine(&b, "DTSTAMP", now)
+		icsLine(&b, "LAST-MODIFIED", icsTime(ev.UpdatedAt))
+		icsZonedLine(&b, "DTSTART", ev, start)
+		icsZonedLine(&b, "DTEND", ev, start.Add(defaultEventDuration))
+		if occ != nil {
+			icsZonedLine(&b, "RECURRENCE-ID", ev, occ.OccursAt)
+		} else if ev.RecurrenceRule != "" {
+			icsLine(&b, "RRULE", ev.RecurrenceRule)
+			if ch := changes[ev.ID]; len(ch.cancelled) > 0 {
+				exdates := make([]int64, 0, len(ch.cancelled))
+				for at := range ch.cancelled {
+					exdates = append(exdates, at)
+				}
+				sort.Slice(exdates, func(i, j int) bool { return exdates[i] < exdates[j] })
+				for _, at := range exdates {
+					icsZonedLine(&b, "EXDATE", ev, time.Unix(at, 0))
+				}
+			}
+		}
+		icsLine(&b, "SUMMARY", icsEscaper.Replace(title))
+		if description != "" {
+			icsLine(&b, "DESCRIPTION", icsEscaper.Replace(description))
+		}
+		if location != "" {
+			icsLine(&b, "LOCATION", icsEscaper.Replace(location))
+		}
+		icsLine(&b, "URL", eventURL(ev))
+		for _, a := range attendees[ev.ID] {
+			if emails[a.UserID] == "" {
+				continue
+			}
+			role := "REQ-PARTICIPANT"
+			if a.Role == "organizer" {
+				role = "CHAIR"
+			}
+			icsLine(&b, "ATTENDEE;ROLE="+role+";PARTSTAT="+icsPartStat(a.Status), "mailto:"+emails[a.UserID])
+		}
+		if ev.OrganizationID != nil {
+			if brand := brands[*ev.OrganizationID]; brand != nil {
+				if brand.ReplyTo != "" {
+					icsLine(&b, `ORGANIZER;CN="`+strings.ReplaceAll(brand.Name, `"`, "'")+`"`, "mailto:"+brand.ReplyTo)
+				}
+				if brand.LogoURL != "" {
+					icsLine(&b, "IMAGE;VALUE=URI;DISPLAY=BADGE", brand.LogoURL)
+				}
+			}
+		}
+		if ev.CancelledAt != nil {
+			icsLine(&b, "STATUS", "CANCELLED")
+		} else {
+			icsLine(&b, "STATUS", "CONFIRMED")
+			for _, m := range reminderMinutes(ev) {
+				icsLine(&b, "BEGIN", "VALARM")
+				icsLine(&b, "ACTION", "DISPLAY")
+				icsLine(&b, "TRIGGER", fmt.Sprintf("-PT%dM", m))
+				icsLine(&b, "DESCRIPTION", icsEscaper.Replace(title))
+				icsLine(&b, "END", "VALARM")
+			}
+		}
+		icsLine(&b, "END", "VEVENT")
+	}
+
+	for _, ev := range events {
+		vevent(ev, nil)
+		ch := changes[ev.ID]
+		if ch == nil {
+			continue
+		}
+		ats := make([]int64, 0, len(ch.overrides))
+		for at := range ch.overrides {
+			ats = append(ats, at)
+		}
+		sort.Slice(ats, func(i, j int) bool { return ats[i] < ats[j] })
+		for _, at := range ats {
+			occ := buildOccurrence(ev, time.Unix(at, 0), ch)
+			vevent(ev, &occ)
+		}
+	}
+
+	icsLine(&b, "END", "VCALENDAR")
+	return b.String()
+}
+
+// icsZonedLine writes a time of a recurring event in its timezone, so the
+// series keeps its wall-clock time across DST; other times are UTC.
+func icsZonedLine(b *strings.Builder, name string, ev Event, t time.Time) {
+	if ev.RecurrenceRule == "" || ev.RecurrenceTimezone == "" || ev.RecurrenceTimezone == "UTC" {
+		icsLine(b, name, icsTime(t))
+		return
+	}
+	icsLine(b, name+";TZID="+ev.RecurrenceTimezone, t.In(recurrenceLocation(ev.RecurrenceTimezone)).Format("20060102T150405"))
+}
+
+func writeICS(c *gin.Context, filename, body string) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(body))
+}
+
+// GetEventICS exports a single event for participants.
+func GetEventICS(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	var ev Event
+	if err := DB.First(&ev, eventID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !canViewEvent(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can export this event")
+		return
+	}
+
+	writeICS(c, fmt.Sprintf("event-%d.ics", ev.ID), renderICS(ev.Title, []Event{ev}, userID))
+}
+
+// GetCalendarFeed is the subscribable calendar of the user's events from
+// the last 30 days on (series still running), excluding ones they declined.
+func GetCalendarFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	events := []Event{}
+	if ids := participatingEventIDs(userID); len(ids) > 0 {
+		var declined []uint
+		DB.Model(&EventAttendee{}).Where("user_id = ? AND status = ?", userID, "Not Going").Pluck("event_id", &declined)
+		q := DB.Where("id IN ? AND "+eventEndsExpr+" >= ?", ids, time.Now().AddDate(0, 0, -30))
+		if len(declined) > 0 {
+			q = q.Where("id NOT IN ?", declined)
+		}
+		if err := q.Order("date asc").Find(&events).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+	}
+
+	writeICS(c, "calendar.ics", renderICS("My events", events, userID))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Invitations are attendee rows created by InviteUser. They start pending;
+// the invitee accepts or declines them here, or implicitly by answering
+// the RSVP. Declining is also answering "Not Going", so nudges, reminders
+// and calendars leave declined invitees alone.
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// backfillInvitations sets the state of invitations sent before it was
+// tracked, from the answer given so far.
+func backfillInvitations(db *gorm.DB) error {
+	return db.Model(&EventAttendee{}).
+		Where("invitation = '' AND invited_by_id IS NOT NULL").
+		UpdateColumn("invitation", gorm.Expr("CASE WHEN status = '' THEN ? WHEN status = ? THEN ? ELSE ? END",
+			InvitationPending, "Not Going", InvitationDeclined, InvitationAccepted)).Error
+}
+
+// answerInvitation resolves att's invitation, if any, from its status.
+func answerInvitation(att *EventAttendee) {
+	if att.Invitation == "" {
+		return
+	}
+	state := InvitationAccepted
+	if att.Status == "Not Going" {
+		state = InvitationDeclined
+	}
+	if state != att.Invitation {
+		now := time.Now()
+		att.Invitation, att.RespondedAt = state, &now
+	}
+}
+
+// loadMyInvitation loads the caller's invitation named by :id with its
+// event, writing the error response itself.
+func loadMyInvitation(c *gin.Context, userID uint) (EventAttendee, Event, bool) {
+	var att EventAttendee
+	var ev Event
+	invitationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid invitation id")
+		return att, ev, false
+	}
+	if err := DB.Where("id = ? AND user_id = ? AND invitation <> ''", invitationID, userID).First(&att).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "invitation not found")
+		return att, ev, false
+	}
+	if err := DB.First(&ev, att.EventID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "invitation not found")
+		return att, ev, false
+	}
+	return att, ev, true
+}
+
+func saveInvitationAnswer(att *EventAttendee) error {
+	if err := DB.Model(att).Select("status", "invitation", "responded_at").Updates(att).Error; err != nil {
+		return err
+	}
+	recordChange(DB, EntityAttendee, att.ID, att.EventID, ChangeUpsert)
+	return nil
+}
+
+// invitationAnswered tells whoever sent the invitation.
+func invitationAnswered(ev Event, att EventAttendee) {
+	if att.InvitedByID == nil {
+		return
+	}
+	var invitee User
+	DB.Select("id", "email").First(&invitee, att.UserID)
+	NotifyTemplate(*att.InvitedByID, "invitation_answered",
+		map[string]string{"EventTitle": ev.Title, "Name": invitee.Email, "Answer": att.Invitation},
+		gin.H{"event_id": ev.ID, "invitation_id": att.ID, "invitation": att.Invitation})
+}
+
+type invitationView struct {
+	ID           uint      `json:"id"`
+	Role         string    `json:"role"`
+	Invitation   string    `json:"invitation"`
+	InvitedByID  *uint     `json:"invited_by_id,omitempty"`
+	InviterEmail string    `json:"inviter_email,omitempty"`
+	InvitedAt    time.Time `json:"invited_at"`
+	Event        EventView `json:"event"`
+}
+
+// ========================
+// ENDPOINTS
+// ========================
+
+// GetMyInvitations lists the caller's pending invitations to events that
+// haven't ended. Frozen ones (from suspended inviters) are left out.
+func GetMyInvitations(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := ReadDB.Model(&EventAttendee{}).
+		Joins("JOIN events ON events.id = event_attendees.event_id").
+		Where("event_attendees.user_id = ? AND event_attendees.invitation = ?", userID, InvitationPending).
+		Where("event_attendees.invited_by_id NOT IN (?)", suspendedUsersQuery()).
+		Where(eventEndsExpr+" >= ?", time.Now()).
+		Order("event_attendees.created_at desc, event_attendees.id desc")
+	q, page, ok := paginate(c, q)
+	if !ok {
+		return
+	}
+	var invites []EventAttendee
+	if err := q.Select("event_attendees.*").Find(&invites).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	eventIDs := make([]uint, 0, len(invites))
+	inviterIDs := make([]uint, 0, len(invites))
+	for _, a := range invites {
+		eventIDs = append(eventIDs, a.EventID)
+		if a.InvitedByID != nil {
+			inviterIDs = append(inviterIDs, *a.InvitedByID)
+		}
+	}
+	var events []Event
+	if len(eventIDs) > 0 {
+		if err := ReadDB.Where("id IN ?", eventIDs).Find(&events).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+	}
+	views := map[uint]EventView{}
+	for _, v := range eventViews(events, userID) {
+		views[v.ID] = v
+	}
+	emails := userEmails(inviterIDs)
+
+	out := make([]invitationView, 0, len(invites))
+	for _, a := range invites {
+		v := invitationView{ID: a.ID, Role: a.Role, Invitation: a.Invitation, InvitedByID: a.InvitedByID, InvitedAt: a.CreatedAt, Event: views[a.EventID]}
+		if a.InvitedByID != nil {
+			v.InviterEmail = emails[*a.InvitedByID]
+		}
+		out = append(out, v)
+	}
+	writePage(c, page, out, len(out))
+}
+
+// AcceptInvitation accepts a pending invitation, or one declined earlier.
+// Accepting doesn't RSVP; the invitee still answers Going or Maybe.
+func AcceptInvitation(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	att, ev, ok := loadMyInvitation(c, userID)
+	if !ok {
+		return
+	}
+	if att.Invitation == InvitationAccepted {
+		c.JSON(http.StatusOK, attendeeView(att, userID, false))
+		return
+	}
+	if invitationFrozen(att) {
+		jsonError(c, http.StatusConflict, "this invitation is frozen")
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
+
+	if att.Status == "Not Going" {
+		att.Status = "" // back to not having answered
+	}
+	now := time.Now()
+	att.Invitation, att.RespondedAt = InvitationAccepted, &now
+	if err := saveInvitationAnswer(&att); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not accept invitation: "+err.Error())
+		return
+	}
+	invitationAnswered(ev, att)
+	broadcastRSVP(ev, userID, att.Status)
+
+	c.JSON(http.StatusOK, attendeeView(att, userID, false))
+}
+
+// DeclineInvitation turns an invitation down, answering "Not Going". Once
+// the invitee has said Going or Maybe, they change their RSVP instead.
+func DeclineInvitation(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	att, ev, ok := loadMyInvitation(c, userID)
+	if !ok {
+		return
+	}
+	if att.Invitation == InvitationDeclined {
+		c.JSON(http.StatusOK, attendeeView(att, userID, false))
+		return
+	}
+	if att.Status != "" {
+		jsonError(c, http.StatusConflict, fmt.Sprintf("you already answered %s; change your RSVP instead", att.Status))
+		return
+	}
+
+	att.Status = "Not Going"
+	answerInvitation(&att)
+	if err := saveInvitationAnswer(&att); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not decline invitation: "+err.Error())
+		return
+	}
+	invitationAnswered(ev, att)
+	broadcastRSVP(ev, userID, att.Status)
+
+	c.JSON(http.StatusOK, attendeeView(att, userID, false))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spam heuristics over the last 30 days of a user's invitations
+const (
+	spamWindow          = 30 * 24 * time.Hour
+	spamMinInvites      = 20
+	spamDeclineRatio    = 0.8
+	spamReportThreshold = 3
+)
+
+type InviteQuota struct {
+	HourlyLimit     int       `json:"hourly_limit"`
+	HourlyRemaining int       `json:"hourly_remaining"`
+	DailyLimit      int       `json:"daily_limit"`
+	DailyRemaining  int       `json:"daily_remaining"`
+	Restricted      bool      `json:"restricted"`
+	ResetAt         time.Time `json:"reset_at"`
+}
+
+func invitesSentSince(userID uint, since time.Time) int64 {
+	var n int64
+	DB.Model(&EventAttendee{}).Where("invited_by_id = ? AND created_at >= ?", userID, since).Count(&n)
+	return n
+}
+
+// looksLikeInviteSpam flags senders whose invitations are mostly declined or reported.
+func looksLikeInviteSpam(userID uint) bool {
+	since := time.Now().Add(-spamWindow)
+
+	var reports int64
+	DB.Model(&InvitationReport{}).Where("inviter_id = ? AND created_at >= ?", userID, since).Count(&reports)
+	if reports >= spamReportThreshold {
+		return true
+	}
+
+	sent := invitesSentSince(userID, since)
+	if sent < spamMinInvites {
+		return false
+	}
+	var declined int64
+	DB.Model(&EventAttendee{}).
+		Where("invited_by_id = ? AND created_at >= ? AND status = ?", userID, since, "Not Going").
+		Count(&declined)
+	return float64(declined)/float64(sent) >= spamDeclineRatio
+}
+
+// inviteQuota computes what the user may still send. Suspected spammers get
+// a tenth of the normal allowance until their record improves.
+func inviteQuota(userID uint) InviteQuota {
+	now := time.Now()
+	q := InviteQuota{
+		HourlyLimit: AppConfig.InviteLimitHourly,
+		DailyLimit:  AppConfig.InviteLimitDaily,
+		ResetAt:     now.Truncate(time.Hour).Add(time.Hour),
+	}
+	if looksLikeInviteSpam(userID) {
+		q.Restricted = true
+		q.HourlyLimit = max(1, q.HourlyLimit/10)
+		q.DailyLimit = max(1, q.DailyLimit/10)
+	}
+
+	q.HourlyRemaining = max(0, q.HourlyLimit-int(invitesSentSince(userID, now.Add(-time.Hour))))
+	q.DailyRemaining = max(0, q.DailyLimit-int(invitesSentSince(userID, now.Add(-24*time.Hour))))
+	return q
+}
+
+func (q InviteQuota) exhausted() bool {
+	return q.HourlyRemaining == 0 || q.DailyRemaining == 0
+}
+
+func inviteQuotaError(c *gin.Context, q InviteQuota) {
+	retry := time.Until(q.ResetAt)
+	if q.DailyRemaining == 0 {
+		retry = time.Hour
+	}
+	c.Header("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":        "invitation limit reached",
+		"invite_quota": q,
+	})
+}
+
+type ReportInvitationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportInvitation lets an invitee flag an unwanted invitation; it also declines it.
+func ReportInvitation(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var body ReportInvitationRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+
+	var att EventAttendee
+	if err := DB.Where("event_id = ? AND user_id = ? AND invited_by_id IS NOT NULL", eventID, userID).First(&att).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "invitation not found")
+		return
+	}
+
+	report := InvitationReport{EventID: uint(eventID), ReporterID: userID, InviterID: *att.InvitedByID, Reason: body.Reason}
+	if err := DB.Where("event_id = ? AND reporter_id = ?", eventID, userID).FirstOrCreate(&report).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not report invitation: "+err.Error())
+		return
+	}
+
+	if att.Status == "" {
+		att.Status = "Not Going"
+		answerInvitation(&att)
+		saveInvitationAnswer(&att)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation reported"})
+}
+
+func GetMyInviteQuota(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	c.JSON(http.StatusOK, inviteQuota(userID))
+}
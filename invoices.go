@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invoices for paid registrations. Going to an event on a priced ticket
+// tier is the checkout: the RSVP carries the attendee's billing details
+// (or reuses their BillingProfile) and issues an invoice in the same
+// transaction. Payment itself happens outside the app for now.
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// zeroDecimalCurrencies have no minor unit, so their amounts are whole.
+var zeroDecimalCurrencies = []string{
+	"BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF", "UGX", "VND", "VUV", "XAF", "XOF", "XPF",
+}
+
+var errBillingRequired = errors.New("billing details are required for paid tickets")
+
+func formatMoney(cents int64, currency string) string {
+	if slices.Contains(zeroDecimalCurrencies, currency) {
+		return fmt.Sprintf("%d %s", cents, currency)
+	}
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, currency)
+}
+
+func formatTaxRate(bps int) string {
+	return strconv.FormatFloat(float64(bps)/100, 'f', -1, 64) + "%"
+}
+
+// taxOf rounds half up to the minor unit.
+func taxOf(net int64, bps int) int64 {
+	return (net*int64(bps) + 5000) / 10000
+}
+
+type BillingRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Address string `json:"address" binding:"required"`
+	Country string `json:"country" binding:"required"` // ISO 3166-1 alpha-2
+	TaxID   string `json:"tax_id"`
+}
+
+func (r *BillingRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Address = strings.TrimSpace(r.Address)
+	r.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+	r.TaxID = strings.TrimSpace(r.TaxID)
+	if r.Name == "" || len(r.Name) > 200 {
+		return fmt.Errorf("billing name must be 1-200 characters")
+	}
+	if r.Address == "" || len(r.Address) > 500 {
+		return fmt.Errorf("billing address must be 1-500 characters")
+	}
+	if !reCountryCode.MatchString(r.Country) {
+		return fmt.Errorf("billing country must be a two-letter ISO 3166 code")
+	}
+	if len(r.TaxID) > 50 {
+		return fmt.Errorf("tax_id is too long")
+	}
+	return nil
+}
+
+func (r BillingRequest) profile(userID uint) BillingProfile {
+	return BillingProfile{UserID: userID, Name: r.Name, Address: r.Address, Country: r.Country, TaxID: r.TaxID}
+}
+
+func billingProfileOf(tx *gorm.DB, userID uint) (BillingProfile, bool) {
+	var p BillingProfile
+	if err := tx.First(&p, "user_id = ?", userID).Error; err != nil {
+		return p, false
+	}
+	return p, true
+}
+
+func saveBillingProfile(tx *gorm.DB, p *BillingProfile) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(p).Error
+}
+
+// nextInvoiceNumber hands out the seller's next number for the year,
+// holding the sequence row until tx ends so numbers have no gaps.
+func nextInvoiceNumber(tx *gorm.DB, sellerID uint, year int) (string, error) {
+	seq := InvoiceSequence{SellerID: sellerID, Year: year}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("seller_id = ? AND year = ?", sellerID, year).First(&seq).Error; err != nil {
+		return "", err
+	}
+	seq.Last++
+	if err := tx.Model(&InvoiceSequence{}).Where("seller_id = ? AND year = ?", sellerID, year).
+		Update("last", seq.Last).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%06d", year, seq.Last), nil
+}
+
+// issueInvoice bills att for tier, made out to bill.
+func issueInvoice(tx *gorm.DB, ev Event, att EventAttendee, tier TicketTier, bill BillingProfile) (*Invoice, error) {
+	now := time.Now()
+	number, err := nextInvoiceNumber(tx, ev.OrganizerID, now.Year())
+	if err != nil {
+		return nil, err
+	}
+	var seller User
+	tx.Select("id", "email").First(&seller, ev.OrganizerID)
+
+	tax := taxOf(tier.PriceCents, tier.TaxRateBps)
+	inv := Invoice{
+		Number:       number,
+		SellerID:     ev.OrganizerID,
+		SellerName:   seller.Email,
+		EventID:      ev.ID,
+		EventTitle:   ev.Title,
+		UserID:       att.UserID,
+		AttendeeID:   att.ID,
+		TicketTierID: tier.ID,
+		Description:  fmt.Sprintf("%s: %s ticket", ev.Title, tier.Name),
+		NetCents:     tier.PriceCents,
+		TaxRateBps:   tier.TaxRateBps,
+		TaxCents:     tax,
+		TotalCents:   tier.PriceCents + tax,
+		Currency:     tier.Currency,
+		BillTo:       bill.Name,
+		BillAddress:  bill.Address,
+		BillCountry:  bill.Country,
+		BillTaxID:    bill.TaxID,
+		IssuedAt:     now,
+	}
+	if err := tx.Create(&inv).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// voidInvoice voids the registration's live invoice, if it has one.
+func voidInvoice(tx *gorm.DB, attendeeID uint) (*Invoice, error) {
+	var inv Invoice
+	err := tx.Where("attendee_id = ? AND voided_at IS NULL", attendeeID).First(&inv).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	inv.VoidedAt = &now
+	if err := tx.Model(&inv).Update("voided_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// invoiceIssued tells the attendee their invoice is ready.
+func invoiceIssued(ev Event, inv Invoice) {
+	vars := map[string]string{"EventTitle": ev.Title, "Number": inv.Number, "Total": formatMoney(inv.TotalCents, inv.Currency)}
+	data := gin.H{"event_id": ev.ID, "invoice_id": inv.ID, "pdf_url": fmt.Sprintf("/me/invoices/%d.pdf", inv.ID)}
+	NotifyTemplate(inv.UserID, "invoice_issued", vars, data)
+	EmailTemplate(inv.UserID, "invoice_issued", vars, ev)
+}
+
+// ========================
+// BILLING DETAILS
+// ========================
+
+func GetMyBilling(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	p, found := billingProfileOf(DB, userID)
+	if !found {
+		jsonError(c, http.StatusNotFound, "no billing details yet")
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// PutMyBilling sets the details future invoices are made out to; issued
+// invoices keep theirs.
+func PutMyBilling(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var body BillingRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := body.profile(userID)
+	if err := saveBillingProfile(DB, &p); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save billing details: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// ========================
+// INVOICES
+// ========================
+
+func GetMyInvoices(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&Invoice{}).Where("user_id = ?", userID).Order("issued_at desc, id desc"))
+	if !ok {
+		return
+	}
+	var invoices []Invoice
+	if err := q.Find(&invoices).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, invoices, len(invoices))
+}
+
+// GetMyInvoice serves one of the caller's invoices: /me/invoices/:id as
+// JSON, /me/invoices/:id.pdf as a PDF.
+func GetMyInvoice(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	serveInvoice(c, c.Param("id"), DB.Where("user_id = ?", userID))
+}
+
+// GetEventInvoices lists what was invoiced for an event, voided included.
+func GetEventInvoices(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&Invoice{}).Where("event_id = ? AND seller_id = ?", ev.ID, userID).Order("issued_at desc, id desc"))
+	if !ok {
+		return
+	}
+	var invoices []Invoice
+	if err := q.Find(&invoices).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, invoices, len(invoices))
+}
+
+type invoiceTotals struct {
+	Currency   string `json:"currency"`
+	Invoices   int64  `json:"invoices"`
+	NetCents   int64  `json:"net_cents"`
+	TaxCents   int64  `json:"tax_cents"`
+	TotalCents int64  `json:"total_cents"`
+}
+
+// GetEventInvoiceSummary totals the event's live invoices per currency.
+func GetEventInvoiceSummary(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	totals := []invoiceTotals{}
+	if err := DB.Model(&Invoice{}).
+		Select("currency, COUNT(*) AS invoices, SUM(net_cents) AS net_cents, SUM(tax_cents) AS tax_cents, SUM(total_cents) AS total_cents").
+		Where("event_id = ? AND seller_id = ? AND voided_at IS NULL", ev.ID, userID).
+		Group("currency").Order("currency").
+		Scan(&totals).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var voided int64
+	DB.Model(&Invoice{}).Where("event_id = ? AND seller_id = ? AND voided_at IS NOT NULL", ev.ID, userID).Count(&voided)
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "totals": totals, "voided": voided})
+}
+
+// GetEventInvoice is GetMyInvoice for the organizer.
+func GetEventInvoice(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	serveInvoice(c, c.Param("invoiceId"), DB.Where("event_id = ? AND seller_id = ?", ev.ID, userID))
+}
+
+// serveInvoice looks param up within scope and writes it as JSON, or as a
+// PDF when param ends in ".pdf".
+func serveInvoice(c *gin.Context, param string, scope *gorm.DB) {
+	idText, asPDF := strings.CutSuffix(param, ".pdf")
+	invoiceID, err := strconv.ParseUint(idText, 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid invoice id")
+		return
+	}
+
+	var inv Invoice
+	if err := scope.Where("id = ?", invoiceID).First(&inv).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "invoice not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !asPDF {
+		c.JSON(http.StatusOK, inv)
+		return
+	}
+
+	out, err := renderInvoice(inv)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not render invoice: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="invoice-%s.pdf"`, inv.Number))
+	c.Data(http.StatusOK, "application/pdf", out)
+}
+
+// renderInvoice lays the invoice out on a portrait A4 page.
+func renderInvoice(inv Invoice) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetTitle("Invoice "+inv.Number, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+	pdf.AddPage()
+	w, _ := pdf.GetPageSize()
+	content := w - 40
+
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.CellFormat(content/2, 12, "INVOICE", "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(content/2, 6, "No. "+inv.Number, "", 2, "R", false, 0, "")
+	pdf.CellFormat(content/2, 6, "Issued "+inv.IssuedAt.UTC().Format("2 January 2006"), "", 1, "R", false, 0, "")
+
+	pdf.Ln(10)
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(content/2, 5, "From", "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(content/2-5, 5, tr(inv.SellerName), "", "L", false)
+
+	pdf.SetXY(20+content/2, top)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(content/2, 5, "Bill to", "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	billTo := inv.BillTo + "\n" + inv.BillAddress + "\n" + inv.BillCountry
+	if inv.BillTaxID != "" {
+		billTo += "\nTax ID: " + inv.BillTaxID
+	}
+	pdf.SetX(20 + content/2)
+	pdf.MultiCell(content/2, 5, tr(billTo), "", "L", false)
+
+	pdf.Ln(12)
+	cols := []float64{content - 105, 35, 35, 35}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, head := range []string{"Description", "Net", "Tax " + formatTaxRate(inv.TaxRateBps), "Total"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(cols[i], 8, head, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(cols[0], 8, tr(fitText(pdf, inv.Description, cols[0])), "", 0, "L", false, 0, "")
+	pdf.CellFormat(cols[1], 8, formatMoney(inv.NetCents, inv.Currency), "", 0, "R", false, 0, "")
+	pdf.CellFormat(cols[2], 8, formatMoney(inv.TaxCents, inv.Currency), "", 0, "R", false, 0, "")
+	pdf.CellFormat(cols[3], 8, formatMoney(inv.TotalCents, inv.Currency), "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(content-35, 10, "Amount due", "T", 0, "R", false, 0, "")
+	pdf.CellFormat(35, 10, formatMoney(inv.TotalCents, inv.Currency), "T", 1, "R", false, 0, "")
+
+	if inv.VoidedAt != nil {
+		pdf.Ln(10)
+		pdf.SetTextColor(200, 0, 0)
+		pdf.SetFont("Helvetica", "B", 28)
+		pdf.CellFormat(content, 14, "VOID", "", 1, "C", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(content, 5, "Voided "+inv.VoidedAt.UTC().Format("2 January 2006"), "", 1, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// JobHandler runs one job; returning an error schedules a retry.
+type JobHandler func(ctx context.Context, payload []byte) error
+
+var jobHandlers = map[string]JobHandler{}
+
+// RegisterJob binds a job type to its handler. Call it from init().
+func RegisterJob(kind string, h JobHandler) {
+	jobHandlers[kind] = h
+}
+
+// Enqueue stores a job to run as soon as a worker is free.
+func Enqueue(kind string, payload interface{}) error {
+	return EnqueueAt(kind, payload, time.Now())
+}
+
+func EnqueueAt(kind string, payload interface{}, runAt time.Time) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	job := Job{
+		Type:        kind,
+		Payload:     string(raw),
+		Status:      JobPending,
+		MaxAttempts: 5,
+		RunAt:       runAt,
+	}
+	return DB.Create(&job).Error
+}
+
+// StartJobWorker polls the jobs table until ctx is cancelled.
+func StartJobWorker(ctx context.Context) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			// drain everything that is due before sleeping again; jobs
+			// wait in the queue while maintenance is on or we shut down.
+			// A job already running finishes even if ctx is cancelled.
+			for ctx.Err() == nil && !currentMaintenance().Enabled && runNextJob(context.WithoutCancel(ctx)) {
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runNextJob claims and runs one due job. It reports whether a job was found.
+func runNextJob(ctx context.Context) bool {
+	var job Job
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", JobPending, time.Now()).
+			Order("run_at asc").
+			First(&job).Error; err != nil {
+			return err
+		}
+		job.Status = JobRunning
+		job.Attempts++
+		return tx.Save(&job).Error
+	})
+	if err != nil {
+		return false
+	}
+
+	ctx, span := startSpan(ctx, "job "+job.Type,
+		attribute.Int64("job.id", int64(job.ID)), attribute.Int("job.attempt", job.Attempts))
+	h, ok := jobHandlers[job.Type]
+	if !ok {
+		err := fmt.Errorf("no handler for job type %q", job.Type)
+		endSpan(span, err)
+		finishJob(&job, err)
+		return true
+	}
+
+	err = safeRun(ctx, h, []byte(job.Payload))
+	endSpan(span, err)
+	finishJob(&job, err)
+	return true
+}
+
+func safeRun(ctx context.Context, h JobHandler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, payload)
+}
+
+func finishJob(job *Job, runErr error) {
+	if runErr == nil {
+		job.Status = JobDone
+		job.LastError = ""
+	} else {
+		job.LastError = runErr.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = JobFailed
+			log.Printf("⚠️ job %d (%s) failed permanently: %v", job.ID, job.Type, runErr)
+		} else {
+			// exponential backoff: 30s, 1m, 2m, ...
+			job.Status = JobPending
+			job.RunAt = time.Now().Add(time.Duration(1<<uint(job.Attempts-1)) * 30 * time.Second)
+		}
+	}
+	DB.Save(job)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// A running job that hasn't been touched for this long lost its worker
+// (crash or deploy) and may be retried by hand.
+const jobStaleAfter = 15 * time.Minute
+
+const maxJobRequeue = 1000
+
+// jobView shows the payload as JSON rather than a quoted string.
+type jobView struct {
+	Job
+	Payload json.RawMessage `json:"payload"`
+	Stale   bool            `json:"stale,omitempty"`
+}
+
+func viewJob(j Job) jobView {
+	v := jobView{Job: j, Payload: json.RawMessage(j.Payload)}
+	if !json.Valid(v.Payload) {
+		v.Payload, _ = json.Marshal(j.Payload)
+	}
+	v.Stale = j.Status == JobRunning && time.Since(j.UpdatedAt) > jobStaleAfter
+	return v
+}
+
+// retryableJobs matches failed jobs and running jobs whose worker is gone.
+func retryableJobs(tx *gorm.DB) *gorm.DB {
+	return tx.Where("status = ? OR (status = ? AND updated_at < ?)", JobFailed, JobRunning, time.Now().Add(-jobStaleAfter))
+}
+
+// requeueJobs puts jobs back in the queue with a fresh set of attempts.
+// The last error is kept until the next run.
+func requeueJobs(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&Job{}).Updates(map[string]interface{}{
+		"status":   JobPending,
+		"attempts": 0,
+		"run_at":   time.Now(),
+	})
+}
+
+// ListJobs lists background jobs, newest first, with a count per status.
+// Filters: status, type, before_id for paging.
+func ListJobs(c *gin.Context) {
+	query := dbFor(c).Model(&Job{})
+	if s := c.Query("status"); s != "" {
+		if s != JobPending && s != JobRunning && s != JobDone && s != JobFailed {
+			jsonError(c, http.StatusBadRequest, "status must be one of: pending, running, done, failed")
+			return
+		}
+		query = query.Where("status = ?", s)
+	}
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+	if b := c.Query("before_id"); b != "" {
+		beforeID, err := strconv.ParseUint(b, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid before_id")
+			return
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var jobs []Job
+	if err := query.Order("id desc").Limit(100).Find(&jobs).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	views := make([]jobView, 0, len(jobs))
+	for _, j := range jobs {
+		views = append(views, viewJob(j))
+	}
+
+	var counts []struct {
+		Status string
+		N      int64
+	}
+	dbFor(c).Model(&Job{}).Select("status, COUNT(*) AS n").Group("status").Scan(&counts)
+	byStatus := gin.H{JobPending: 0, JobRunning: 0, JobDone: 0, JobFailed: 0}
+	for _, row := range counts {
+		byStatus[row.Status] = row.N
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": views, "counts": byStatus})
+}
+
+func GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid job id")
+		return
+	}
+	var job Job
+	if err := dbFor(c).First(&job, id).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "job not found")
+		return
+	}
+	c.JSON(http.StatusOK, viewJob(job))
+}
+
+// RetryJob requeues one failed (or stale running) job.
+func RetryJob(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid job id")
+		return
+	}
+	var job Job
+	if err := dbFor(c).First(&job, id).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "job not found")
+		return
+	}
+
+	res := requeueJobs(retryableJobs(dbFor(c)).Where("id = ?", job.ID))
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "could not retry job: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusConflict, "only failed or stale running jobs can be retried")
+		return
+	}
+	Audit(userID, "job.retry", "job", job.ID, gin.H{"type": job.Type, "last_error": job.LastError})
+
+	dbFor(c).First(&job, job.ID)
+	c.JSON(http.StatusOK, viewJob(job))
+}
+
+type RequeueJobsRequest struct {
+	IDs  []uint `json:"ids"`  // specific jobs; otherwise every retryable one
+	Type string `json:"type"` // only jobs of this type
+}
+
+// RequeueJobs retries failed (and stale running) jobs in bulk.
+func RequeueJobs(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var body RequeueJobsRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if len(body.IDs) > maxJobRequeue {
+		jsonError(c, http.StatusBadRequest, "at most 1000 ids")
+		return
+	}
+
+	query := retryableJobs(dbFor(c))
+	if len(body.IDs) > 0 {
+		query = query.Where("id IN ?", body.IDs)
+	}
+	if body.Type != "" {
+		query = query.Where("type = ?", body.Type)
+	}
+	res := requeueJobs(query)
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "could not requeue jobs: "+res.Error.Error())
+		return
+	}
+	Audit(userID, "job.requeue", "job", 0, gin.H{"ids": body.IDs, "type": body.Type, "requeued": res.RowsAffected})
+
+	c.JSON(http.StatusOK, gin.H{"requeued": res.RowsAffected})
+}
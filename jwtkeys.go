@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one HMAC secret in the JWT keyset.
+// Retired keys stay in the set for verification until RetireAt passes,
+// so tokens issued before a rotation keep working until they expire.
+type SigningKey struct {
+	KID      string     `json:"kid"`
+	Secret   string     `json:"secret"`
+	Active   bool       `json:"active"`
+	RetireAt *time.Time `json:"retire_at,omitempty"`
+}
+
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]SigningKey
+	active string
+}
+
+var JWTKeys = &KeySet{keys: map[string]SigningKey{}}
+
+const legacyKID = "default"
+
+// LoadJWTKeys builds the keyset from JWT_KEYS_FILE (a JSON array of keys)
+// and/or JWT_SECRET, which is kept under the "default" kid so tokens
+// issued before key ids existed still verify.
+func LoadJWTKeys() error {
+	keys := map[string]SigningKey{}
+	active := ""
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read JWT_KEYS_FILE: %w", err)
+		}
+		var list []SigningKey
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("parse JWT_KEYS_FILE: %w", err)
+		}
+		for _, k := range list {
+			k.KID = strings.TrimSpace(k.KID)
+			if k.KID == "" || k.Secret == "" {
+				return errors.New("JWT_KEYS_FILE: every key needs kid and secret")
+			}
+			if k.Active {
+				if active != "" {
+					return errors.New("JWT_KEYS_FILE: more than one active key")
+				}
+				active = k.KID
+			}
+			keys[k.KID] = k
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if _, exists := keys[legacyKID]; !exists {
+			keys[legacyKID] = SigningKey{KID: legacyKID, Secret: secret}
+		}
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no JWT signing keys configured (set JWT_SECRET or JWT_KEYS_FILE)")
+	}
+	if active == "" {
+		if _, ok := keys[legacyKID]; !ok {
+			return errors.New("JWT_KEYS_FILE: no active key")
+		}
+		active = legacyKID
+	}
+
+	JWTKeys.mu.Lock()
+	JWTKeys.keys = keys
+	JWTKeys.active = active
+	JWTKeys.mu.Unlock()
+
+	log.Printf("🔐 Loaded %d JWT key(s), active kid %q", len(keys), active)
+	return nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() (SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.keys[ks.active]
+	return k, ok
+}
+
+// Lookup returns a key usable for verification.
+func (ks *KeySet) Lookup(kid string) (SigningKey, bool) {
+	if kid == "" {
+		kid = legacyKID
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.keys[kid]
+	if !ok {
+		return SigningKey{}, false
+	}
+	if k.RetireAt != nil && time.Now().After(*k.RetireAt) {
+		return SigningKey{}, false
+	}
+	return k, true
+}
+
+// jwtKeyFunc resolves the verification key from the token's kid header.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("invalid signing method")
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := JWTKeys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return []byte(key.Secret), nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	key, ok := JWTKeys.Active()
+	if !ok {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString([]byte(key.Secret))
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Kiosk tokens are for the device at the door: a volunteer can look
+// attendees up and check them in (scanning the attendee ID on their badge)
+// for one event, and nothing else. Organizers issue and revoke them.
+
+const (
+	defaultKioskHours = 12
+	maxKioskHours     = 72
+
+	kioskSearchLimit = 20
+)
+
+// ========================
+// TOKENS
+// ========================
+
+type KioskTokenRequest struct {
+	Name  string `json:"name"`  // e.g. "Front door"
+	Hours int    `json:"hours"` // lifetime, default 12, at most 72
+}
+
+// GetKioskTokens lists the event's active kiosk tokens (never the secret).
+func GetKioskTokens(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var tokens []KioskToken
+	if err := DB.Where("event_id = ? AND revoked_at IS NULL AND expires_at > ?", ev.ID, time.Now()).Order("created_at asc").Find(&tokens).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateKioskToken issues a kiosk token for the event. The token is only
+// shown in this response.
+func CreateKioskToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "event is cancelled")
+		return
+	}
+
+	var body KioskTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+	if body.Hours == 0 {
+		body.Hours = defaultKioskHours
+	}
+	if body.Hours < 1 || body.Hours > maxKioskHours {
+		jsonError(c, http.StatusBadRequest, "hours must be between 1 and "+strconv.Itoa(maxKioskHours))
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token")
+		return
+	}
+	t := KioskToken{
+		EventID:     ev.ID,
+		Name:        strings.TrimSpace(body.Name),
+		TokenHash:   hashToken(token),
+		CreatedByID: userID,
+		ExpiresAt:   time.Now().Add(time.Duration(body.Hours) * time.Hour),
+	}
+	if err := DB.Create(&t).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token: "+err.Error())
+		return
+	}
+	Audit(userID, "kiosk.token_created", "event", ev.ID, gin.H{"token_id": t.ID, "expires_at": t.ExpiresAt})
+
+	c.JSON(http.StatusCreated, gin.H{"token": t, "secret": token})
+}
+
+func RevokeKioskToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	res := DB.Model(&KioskToken{}).
+		Where("id = ? AND event_id = ? AND revoked_at IS NULL", c.Param("tokenId"), ev.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "token not found")
+		return
+	}
+	Audit(userID, "kiosk.token_revoked", "event", ev.ID, gin.H{"token_id": c.Param("tokenId")})
+
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
+
+// KioskAuthMiddleware admits a live kiosk token and pins the request to
+// its event.
+func KioskAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.GetHeader("Authorization")
+		if !strings.HasPrefix(h, "Bearer ") {
+			jsonError(c, http.StatusUnauthorized, "missing bearer token")
+			c.Abort()
+			return
+		}
+
+		var t KioskToken
+		err := DB.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(strings.TrimPrefix(h, "Bearer ")), time.Now()).First(&t).Error
+		if err != nil {
+			jsonError(c, http.StatusUnauthorized, "invalid or expired token")
+			c.Abort()
+			return
+		}
+		var ev Event
+		if err := DB.First(&ev, t.EventID).Error; err != nil || ev.CancelledAt != nil {
+			jsonError(c, http.StatusForbidden, "event is no longer open for check-in")
+			c.Abort()
+			return
+		}
+		DB.Model(&t).Update("last_used_at", time.Now())
+
+		c.Set("kiosk_token", t)
+		c.Set("kiosk_event", ev)
+		c.Next()
+	}
+}
+
+func kioskFromContext(c *gin.Context) (KioskToken, Event) {
+	return c.MustGet("kiosk_token").(KioskToken), c.MustGet("kiosk_event").(Event)
+}
+
+// ========================
+// AT THE DOOR
+// ========================
+
+// KioskAttendee is what the door sees of an attendee. People are found by
+// name or email.
+type KioskAttendee struct {
+	ID          uint       `json:"id"` // attendee ID, as printed on the badge
+	Name        string     `json:"name,omitempty"`
+	Email       string     `json:"email"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+func kioskAttendees(attendees []EventAttendee) []KioskAttendee {
+	ids := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		ids = append(ids, a.UserID)
+	}
+	users := map[uint]User{}
+	var list []User
+	DB.Select("id", "email", "name").Where("id IN ?", ids).Find(&list)
+	for _, u := range list {
+		users[u.ID] = u
+	}
+	out := make([]KioskAttendee, 0, len(attendees))
+	for _, a := range attendees {
+		u := users[a.UserID]
+		out = append(out, KioskAttendee{ID: a.ID, Name: u.Name, Email: u.Email, Status: a.Status, CheckedInAt: a.CheckedInAt})
+	}
+	return out
+}
+
+// GetKioskEvent is the kiosk's header: the event and how many are in.
+func GetKioskEvent(c *gin.Context) {
+	_, ev := kioskFromContext(c)
+
+	var going, checkedIn int64
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND status = ?", ev.ID, "Going").Count(&going)
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND checked_in_at IS NOT NULL", ev.ID).Count(&checkedIn)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         ev.ID,
+		"title":      ev.Title,
+		"date":       ev.Date,
+		"location":   ev.Location,
+		"going":      going,
+		"checked_in": checkedIn,
+	})
+}
+
+// SearchKioskAttendees finds attendees whose name or email contains q (at
+// least two characters).
+func SearchKioskAttendees(c *gin.Context) {
+	_, ev := kioskFromContext(c)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < 2 {
+		jsonError(c, http.StatusBadRequest, "q must be at least 2 characters")
+		return
+	}
+
+	like := "%" + likeEscaper.Replace(q) + "%"
+	var attendees []EventAttendee
+	err := DB.Joins("JOIN users ON users.id = event_attendees.user_id").
+		Where("event_attendees.event_id = ?", ev.ID).
+		Where("users.name ILIKE ? OR users.email ILIKE ?", like, like).
+		Order("COALESCE(NULLIF(users.name, ''), users.email) asc, users.id asc").
+		Limit(kioskSearchLimit).
+		Find(&attendees).Error
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, kioskAttendees(attendees))
+}
+
+// KioskCheckIn checks an attendee in; checking in twice keeps the first
+// arrival. Anyone not "Going" is still checked in, with a warning for the
+// volunteer.
+func KioskCheckIn(c *gin.Context) {
+	setKioskCheckIn(c, true)
+}
+
+// KioskUndoCheckIn takes back a check-in made by mistake.
+func KioskUndoCheckIn(c *gin.Context) {
+	setKioskCheckIn(c, false)
+}
+
+func setKioskCheckIn(c *gin.Context, in bool) {
+	t, ev := kioskFromContext(c)
+
+	var a EventAttendee
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("attendeeId"), ev.ID).First(&a).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "not on this event's list")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	changed := in == (a.CheckedInAt == nil)
+	if changed {
+		var at *time.Time
+		if in {
+			now := time.Now()
+			at = &now
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&a).Update("checked_in_at", at).Error; err != nil {
+				return err
+			}
+			recordChange(tx, EntityAttendee, a.ID, ev.ID, ChangeUpsert)
+			return nil
+		})
+		if err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not update check-in: "+err.Error())
+			return
+		}
+		a.CheckedInAt = at
+		action := "kiosk.checkin"
+		if !in {
+			action = "kiosk.checkin_undone"
+		}
+		Audit(t.CreatedByID, action, "attendee", a.ID, gin.H{"event_id": ev.ID, "kiosk_token_id": t.ID})
+	}
+
+	resp := gin.H{"attendee": kioskAttendees([]EventAttendee{a})[0], "changed": changed}
+	if in && a.Status != "Going" {
+		resp["warning"] = "not marked as going"
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Organizers tag attendees with labels ("family", "vip") to filter the
+// attendee list and exports and to target announcements ("label:vip").
+// Labels are for organizers only; attendees never see their own.
+
+const maxAttendeeLabels = 10
+
+// AudienceLabelPrefix targets an announcement at attendees with a label.
+const AudienceLabelPrefix = "label:"
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9 _-]{0,31}$`)
+
+var errInvalidLabel = errors.New("labels must be 1-32 lowercase letters, digits, spaces, - or _")
+
+func normalizeLabel(s string) (string, error) {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if !labelPattern.MatchString(s) {
+		return "", errInvalidLabel
+	}
+	return s, nil
+}
+
+// normalizeLabels cleans, de-duplicates and sorts a label set.
+func normalizeLabels(in []string) ([]string, error) {
+	out := []string{}
+	for _, raw := range in {
+		l, err := normalizeLabel(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, l) {
+			out = append(out, l)
+		}
+	}
+	if len(out) > maxAttendeeLabels {
+		return nil, errors.New("at most 10 labels per attendee")
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// attendeeLabels parses EventAttendee.Labels.
+func attendeeLabels(a EventAttendee) []string {
+	if a.Labels == "" {
+		return []string{}
+	}
+	return strings.Split(a.Labels, ",")
+}
+
+// withLabel narrows an event_attendees query to rows carrying the label.
+func withLabel(q *gorm.DB, label string) *gorm.DB {
+	return q.Where("(',' || event_attendees.labels || ',') LIKE ?", "%,"+likeEscaper.Replace(label)+",%")
+}
+
+// labelParam reads the optional ?label= filter.
+func labelParam(c *gin.Context) (string, bool) {
+	raw := c.Query("label")
+	if raw == "" {
+		return "", true
+	}
+	l, err := normalizeLabel(raw)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return l, true
+}
+
+type AttendeeLabelsRequest struct {
+	Labels []string `json:"labels"` // replaces the attendee's labels; empty clears them
+}
+
+// SetAttendeeLabels replaces the labels of one attendee.
+func SetAttendeeLabels(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	targetID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var body AttendeeLabelsRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	labels, err := normalizeLabels(body.Labels)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var att EventAttendee
+	if err := DB.Where("event_id = ? AND user_id = ?", ev.ID, targetID).First(&att).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "attendee not found")
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&att).Update("labels", strings.Join(labels, ",")).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityAttendee, att.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update labels: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, attendeeView(att, userID, true))
+}
+
+type labelCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// GetEventLabels lists the labels in use on an event with how many
+// attendees carry each, for the organizer's filter menu.
+func GetEventLabels(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var rows []string
+	if err := DB.Model(&EventAttendee{}).Where("event_id = ? AND labels <> ''", ev.ID).Pluck("labels", &rows).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	counts := map[string]int{}
+	for _, r := range rows {
+		for _, l := range strings.Split(r, ",") {
+			counts[l]++
+		}
+	}
+	out := make([]labelCount, 0, len(counts))
+	for l, n := range counts {
+		out = append(out, labelCount{Label: l, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	linkPreviewTTL      = 7 * 24 * time.Hour
+	linkPreviewMaxBytes = 512 << 10
+	maxLinksPerContent  = 5
+)
+
+var reURL = regexp.MustCompile(`https?://[^\s<>()"'\]]+`)
+
+func init() {
+	RegisterJob("link_preview", runLinkPreview)
+}
+
+type linkPreviewJob struct {
+	URL string `json:"url"`
+}
+
+// extractURLs returns the distinct http(s) links in text, capped per content.
+func extractURLs(text string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, u := range reURL.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+		if len(out) == maxLinksPerContent {
+			break
+		}
+	}
+	return out
+}
+
+// queueLinkPreviews schedules a fetch for every link we don't have fresh metadata for.
+func queueLinkPreviews(text string) {
+	for _, u := range extractURLs(text) {
+		var p LinkPreview
+		err := DB.Where("url = ?", u).First(&p).Error
+		if err == nil && (p.Status == "pending" || (p.FetchedAt != nil && time.Since(*p.FetchedAt) < linkPreviewTTL)) {
+			continue
+		}
+		if err != nil {
+			p = LinkPreview{URL: u}
+		}
+		p.Status = "pending"
+		if DB.Save(&p).Error == nil {
+			Enqueue("link_preview", linkPreviewJob{URL: u})
+		}
+	}
+}
+
+// previewsFor returns cached previews for the links in text (only fetched ones).
+func previewsFor(text string, cache map[string]LinkPreview) []LinkPreview {
+	out := []LinkPreview{}
+	for _, u := range extractURLs(text) {
+		if p, ok := cache[u]; ok && p.Status == "ok" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// loadPreviewCache fetches all previews needed for a batch of texts in one query.
+func loadPreviewCache(texts ...string) map[string]LinkPreview {
+	urls := []string{}
+	for _, t := range texts {
+		urls = append(urls, extractURLs(t)...)
+	}
+	cache := map[string]LinkPreview{}
+	if len(urls) == 0 {
+		return cache
+	}
+	var list []LinkPreview
+	DB.Where("url IN ?", urls).Find(&list)
+	for _, p := range list {
+		cache[p.URL] = p
+	}
+	return cache
+}
+
+// attachLinkPreviews fills Event.LinkPreviews from the cache.
+func attachLinkPreviews(events []Event) {
+	texts := make([]string, 0, len(events))
+	for _, e := range events {
+		texts = append(texts, e.Description)
+	}
+	cache := loadPreviewCache(texts...)
+	for i := range events {
+		events[i].LinkPreviews = previewsFor(events[i].Description, cache)
+	}
+}
+
+// ========================
+// FETCHING
+// ========================
+
+var errPrivateAddress = errors.New("refusing to fetch private address")
+
+// previewClient refuses to connect to loopback/private ranges so user
+// supplied links can't be used to probe the internal network.
+var previewClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: tracedTransport(&http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+					return errPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+	}),
+}
+
+func runLinkPreview(ctx context.Context, payload []byte) error {
+	var job linkPreviewJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var p LinkPreview
+	if err := DB.Where("url = ?", job.URL).First(&p).Error; err != nil {
+		p = LinkPreview{URL: job.URL}
+	}
+
+	meta, err := fetchOpenGraph(ctx, job.URL)
+	now := time.Now()
+	p.FetchedAt = &now
+	if err != nil {
+		// a dead link isn't worth retrying; remember it failed
+		p.Status = "failed"
+		return DB.Save(&p).Error
+	}
+
+	p.Status = "ok"
+	p.Title = meta["title"]
+	p.Description = meta["description"]
+	p.ImageURL = meta["image"]
+	p.SiteName = meta["site_name"]
+	return DB.Save(&p).Error
+}
+
+func fetchOpenGraph(ctx context.Context, rawURL string) (map[string]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("unsupported url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "EventPlannerLinkPreview/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := previewClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, errors.New(resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, errors.New("not an html page")
+	}
+
+	return parseOpenGraph(io.LimitReader(resp.Body, linkPreviewMaxBytes), u), nil
+}
+
+// parseOpenGraph reads og:* meta tags, falling back to <title> and description.
+func parseOpenGraph(r io.Reader, base *url.URL) map[string]string {
+	meta := map[string]string{}
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return finishOpenGraph(meta, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for _, a := range t.Attr {
+					switch a.Key {
+					case "property", "name":
+						key = strings.ToLower(a.Val)
+					case "content":
+						content = strings.TrimSpace(a.Val)
+					}
+				}
+				switch key {
+				case "og:title", "og:description", "og:image", "og:site_name":
+					meta[strings.TrimPrefix(key, "og:")] = content
+				case "description":
+					if meta["description"] == "" {
+						meta["description"] = content
+					}
+				}
+			case "body":
+				// OG tags live in <head>; stop reading once the body starts
+				return finishOpenGraph(meta, base)
+			}
+		case html.TextToken:
+			if inTitle && meta["title"] == "" {
+				meta["title"] = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+func finishOpenGraph(meta map[string]string, base *url.URL) map[string]string {
+	if img := meta["image"]; img != "" {
+		if ref, err := url.Parse(img); err == nil {
+			meta["image"] = base.ResolveReference(ref).String()
+		}
+	}
+	if len(meta["description"]) > 300 {
+		meta["description"] = meta["description"][:300]
+	}
+	return meta
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const smtpTimeout = 15 * time.Second
+
+var errMailNotConfigured = errors.New("email is not configured (SMTP_HOST)")
+
+// MailMessage is an email with a plain-text body and, optionally, an HTML
+// alternative.
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+	ReplyTo string // eventReplyTo for event emails
+}
+
+// DeliveryResult is what the SMTP server said about a message.
+type DeliveryResult struct {
+	Delivered bool   `json:"delivered"`
+	Code      int    `json:"code,omitempty"`     // final SMTP reply code
+	Response  string `json:"response,omitempty"` // final SMTP reply text
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+func mailConfigured() bool {
+	return AppConfig.SMTPHost != ""
+}
+
+func (m MailMessage) bytes(from string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	if m.ReplyTo != "" {
+		fmt.Fprintf(&b, "Reply-To: %s\r\n", m.ReplyTo)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mimeHeader(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if id, err := randomToken(12); err == nil {
+		fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", id, icsHost())
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if m.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+		b.WriteString(crlf(m.Body))
+		return []byte(b.String())
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Body},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		io.WriteString(w, crlf(part.body))
+	}
+	mw.Close()
+	return []byte(b.String())
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// mimeHeader encodes non-ASCII header values.
+func mimeHeader(s string) string {
+	return mime.BEncoding.Encode("utf-8", s)
+}
+
+// sendMail delivers one message over SMTP, upgrading to TLS when the server
+// offers it, and reports the server's final reply.
+func sendMail(ctx context.Context, m MailMessage) (res DeliveryResult) {
+	ctx, span := startSpan(ctx, "smtp.send", attribute.String("smtp.host", AppConfig.SMTPHost))
+	start := time.Now()
+	var err error
+	defer func() {
+		res.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			res.Error = err.Error()
+		}
+		endSpan(span, err)
+	}()
+	if !mailConfigured() {
+		err = errMailNotConfigured
+		return
+	}
+	if _, err = mail.ParseAddress(m.To); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(AppConfig.SMTPHost, strconv.Itoa(AppConfig.SMTPPort))
+	var conn net.Conn
+	if conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr); err != nil {
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	var cl *smtp.Client
+	if cl, err = smtp.NewClient(conn, AppConfig.SMTPHost); err != nil {
+		conn.Close()
+		return
+	}
+	defer cl.Close()
+	if ok, _ := cl.Extension("STARTTLS"); ok {
+		if err = cl.StartTLS(&tls.Config{ServerName: AppConfig.SMTPHost}); err != nil {
+			return
+		}
+	}
+	if AppConfig.SMTPUsername != "" {
+		if err = cl.Auth(smtp.PlainAuth("", AppConfig.SMTPUsername, AppConfig.SMTPPassword, AppConfig.SMTPHost)); err != nil {
+			return
+		}
+	}
+	if err = cl.Mail(AppConfig.MailFrom); err != nil {
+		return
+	}
+	if err = cl.Rcpt(m.To); err != nil {
+		return
+	}
+
+	// DATA by hand: smtp.Client drops the reply text after the message
+	id, err := cl.Text.Cmd("DATA")
+	if err != nil {
+		return
+	}
+	cl.Text.StartResponse(id)
+	_, _, err = cl.Text.ReadResponse(354)
+	cl.Text.EndResponse(id)
+	if err != nil {
+		return
+	}
+	w := cl.Text.DotWriter()
+	if _, err = w.Write(m.bytes(AppConfig.MailFrom)); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	res.Code, res.Response, err = cl.Text.ReadResponse(250)
+	if err != nil {
+		return
+	}
+	res.Delivered = true
+	cl.Quit()
+	return
+}
+
+type TestEmailRequest struct {
+	To string `json:"to"` // defaults to the admin's own address
+}
+
+// SendTestEmail sends a sample message so the SMTP settings can be checked
+// without waiting for a real notification.
+func SendTestEmail(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var body TestEmailRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+	if !mailConfigured() {
+		jsonError(c, http.StatusServiceUnavailable, errMailNotConfigured.Error())
+		return
+	}
+	to := strings.TrimSpace(body.To)
+	if to == "" {
+		var u User
+		if err := dbFor(c).Select("id", "email").First(&u, userID).Error; err != nil {
+			jsonError(c, http.StatusNotFound, "user not found")
+			return
+		}
+		to = u.Email
+	}
+
+	res := sendMail(c.Request.Context(), MailMessage{
+		To:      to,
+		Subject: "Test email from Eventplanner",
+		Body: fmt.Sprintf("This is a test message sent at %s to check the email settings.\n\nSMTP server: %s:%d\nSender: %s\n",
+			time.Now().UTC().Format(time.RFC1123), AppConfig.SMTPHost, AppConfig.SMTPPort, AppConfig.MailFrom),
+	})
+	Audit(userID, "admin.test_email", "user", userID, gin.H{"to": to, "delivered": res.Delivered, "code": res.Code})
+
+	c.JSON(http.StatusOK, gin.H{"to": to, "server": AppConfig.SMTPHost, "result": res})
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func LoadEnv() {
@@ -18,26 +23,95 @@ func main() {
 
 	// Load .env variables
 	LoadEnv()
+	LoadConfig()
+	LoadPlans()
+	LoadHealthWeights()
 
-	// OPTIONAL: Log JWT_SECRET to confirm it loaded (remove in production)
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Fatal("❌ JWT_SECRET is missing in .env")
+	// OpenTelemetry (OTEL_EXPORTER_OTLP_ENDPOINT)
+	InitTracing(context.Background())
+
+	// JWT signing keys (JWT_SECRET and/or JWT_KEYS_FILE)
+	if err := LoadJWTKeys(); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
-	log.Println("🔐 JWT_SECRET loaded successfully")
+
+	// Optional CAPTCHA provider
+	InitCaptcha()
+	InitOCR()
+	InitStorage()
+	InitHolidays()
+	InitExchangeRates()
+	InitWeather()
 
 	// Connect DB
 	InitDB()
+	PromoteConfiguredAdmins()
+
+	// Background jobs, stopped on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	StartJobWorker(ctx)
+	StartPeriodic(ctx, "meter-rollup", 15*time.Minute, RollupMeters)
+	StartPeriodic(ctx, "attendance-thresholds", 5*time.Minute, CheckAttendanceThresholds)
+	StartPeriodic(ctx, "attendance-nudges", 15*time.Minute, SendAttendanceNudges)
+	StartPeriodic(ctx, "event-anomalies", 10*time.Minute, DetectEventAnomalies)
+	StartPeriodic(ctx, "weather-contingencies", time.Hour, CheckWeatherContingencies)
+	StartPeriodic(ctx, "sso-states", time.Hour, PurgeSSOStates)
+	StartPeriodic(ctx, "refresh-token-prune", time.Hour, PruneRefreshTokens)
+	StartPeriodic(ctx, "password-reset-prune", time.Hour, PrunePasswordResets)
+	StartPeriodic(ctx, "change-feed-prune", 6*time.Hour, PruneChangeFeed)
+	StartPeriodic(ctx, "rate-limit-sweep", 5*time.Minute, SweepRateLimits)
+	StartPeriodic(ctx, "undo-prune", time.Hour, PruneUndoActions)
+	StartPeriodic(ctx, "deprecation-usage", time.Minute, FlushDeprecationUsage)
+	StartPeriodicNow(ctx, "event-reminders", time.Minute, SendDueReminders)
 
 	// Start Gin
 	r := gin.Default()
 
+	// Tracing first, so the span covers every other middleware
+	r.Use(TracingMiddleware())
+
 	// CORS
 	r.Use(CORSMiddleware())
 
+	// Security headers
+	r.Use(SecurityHeadersMiddleware())
+
+	// Reject oversized payloads before they reach handlers
+	r.Use(BodyLimitMiddleware(AppConfig.MaxBodyBytes, AppConfig.MaxUploadBytes))
+
+	// Per-minute request budgets, advertised in X-RateLimit-* headers
+	r.Use(RateLimitMiddleware())
+
+	// Read-only mode for maintenance windows
+	LoadMaintenance()
+	r.Use(MaintenanceMiddleware())
+
 	// Routes
 	SetupRoutes(r)
 
 	// Start server
-	log.Println("🚀 Server running on http://localhost:8080")
-	r.Run(":8080") // do NOT add space or quotes incorrectly
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	srv.RegisterOnShutdown(NotificationStreams.Close)
+	go func() {
+		log.Println("🚀 Server running on http://localhost:8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("❌ Server failed: %v", err)
+		}
+	}()
+
+	// Graceful shutdown: stop taking requests, then let in-flight requests
+	// and background runs finish
+	<-ctx.Done()
+	log.Println("🛑 Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ server shutdown: %v", err)
+	}
+	if !WaitForBackground(shutdownTimeout) {
+		log.Println("⚠️ background work still running at exit")
+	}
 }
+
+const shutdownTimeout = 15 * time.Second
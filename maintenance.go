@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maintenancePath = "/api/admin/maintenance"
+
+const defaultMaintenanceMessage = "Eventplanner is read-only for scheduled maintenance. Changes can be made again shortly."
+
+// MaintenanceState puts the API into read-only mode: reads keep working,
+// anything that would write gets a 503. It is held in memory, so the
+// admin endpoint switches the instance it reaches; set MAINTENANCE_MODE
+// to switch a whole deployment.
+type MaintenanceState struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter int        `json:"retry_after_seconds,omitempty"` // used while Until is unset
+	Until      *time.Time `json:"until,omitempty"`               // expected end
+	Since      *time.Time `json:"since,omitempty"`
+}
+
+var (
+	maintenanceMu sync.RWMutex
+	maintenance   MaintenanceState
+)
+
+// LoadMaintenance applies MAINTENANCE_MODE at startup.
+func LoadMaintenance() {
+	if !AppConfig.MaintenanceMode {
+		return
+	}
+	now := time.Now()
+	setMaintenance(MaintenanceState{Enabled: true, Message: AppConfig.MaintenanceMessage, Since: &now})
+}
+
+func currentMaintenance() MaintenanceState {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return maintenance
+}
+
+func setMaintenance(s MaintenanceState) {
+	maintenanceMu.Lock()
+	maintenance = s
+	maintenanceMu.Unlock()
+}
+
+// retryAfterSeconds is how long clients should wait before writing again.
+func (s MaintenanceState) retryAfterSeconds() int {
+	if s.Until != nil {
+		if left := int(time.Until(*s.Until).Seconds()) + 1; left > 0 {
+			return left
+		}
+	}
+	if s.RetryAfter > 0 {
+		return s.RetryAfter
+	}
+	return AppConfig.MaintenanceRetryAfter
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// MaintenanceMiddleware turns writes away while maintenance is on. The
+// maintenance endpoint itself stays writable so it can be switched off.
+func MaintenanceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := currentMaintenance()
+		if !s.Enabled {
+			c.Next()
+			return
+		}
+		c.Header("X-Maintenance", "read-only")
+		if isReadMethod(c.Request.Method) || strings.TrimRight(c.Request.URL.Path, "/") == maintenancePath {
+			c.Next()
+			return
+		}
+
+		retry := s.retryAfterSeconds()
+		message := s.Message
+		if message == "" {
+			message = defaultMaintenanceMessage
+		}
+		c.Header("Retry-After", strconv.Itoa(retry))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":       "maintenance",
+			"message":     message,
+			"retry_after": retry,
+			"until":       s.Until,
+		})
+		c.Abort()
+	}
+}
+
+func GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, currentMaintenance())
+}
+
+type MaintenanceRequest struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message" binding:"max=500"`
+	RetryAfter int        `json:"retry_after_seconds" binding:"min=0,max=86400"`
+	Until      *time.Time `json:"until"`
+}
+
+// SetMaintenance switches read-only mode on or off for this instance.
+func SetMaintenance(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var body MaintenanceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.Until != nil && !body.Until.After(time.Now()) {
+		jsonError(c, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+
+	s := MaintenanceState{}
+	if body.Enabled {
+		prev := currentMaintenance()
+		now := time.Now()
+		if prev.Enabled && prev.Since != nil {
+			now = *prev.Since
+		}
+		s = MaintenanceState{
+			Enabled:    true,
+			Message:    strings.TrimSpace(body.Message),
+			RetryAfter: body.RetryAfter,
+			Until:      body.Until,
+			Since:      &now,
+		}
+	}
+	setMaintenance(s)
+	// recorded before writes stop; if the database is what's under
+	// maintenance the entry may be lost, which is fine
+	Audit(userID, "admin.maintenance", "system", 0, gin.H{"enabled": s.Enabled, "message": s.Message, "until": s.Until})
+
+	c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enums are the value sets the API validates against, for generated
+// clients and the frontend. Every list is the one validation uses, so the
+// two can't drift apart.
+var enums = gin.H{
+	"rsvp_statuses":           rsvpStatuses,
+	"attendee_statuses":       append(append([]string{}, rsvpStatuses...), StatusWaitlisted, ""), // "" is no answer yet
+	"attendee_roles":          attendeeRoles,
+	"invitation_statuses":     []string{InvitationPending, InvitationAccepted, InvitationDeclined},
+	"org_roles":               []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember},
+	"task_statuses":           taskColumns,
+	"event_visibilities":      []string{VisibilityInvited, VisibilityOrg},
+	"announcement_audiences":  audiences,
+	"privacy_levels":          privacyLevels,
+	"vendor_payment_statuses": vendorPaymentStatuses,
+	"attendee_field_types":    fieldTypes,
+	"resource_kinds":          resourceKinds,
+	"accessibility_features":  accessibilityFeatureNames,
+	"quote_statuses":          []string{QuotePending, QuoteAccepted, QuoteRejected},
+	"notification_kinds":      templateKindNames(),
+}
+
+// GetEnums serves the canonical enum values. They only change with a
+// deploy, so clients may cache them.
+func GetEnums(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, enums)
+}
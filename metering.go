@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MetricEventsCreated = "events_created"
+	MetricEmailsSent    = "emails_sent"
+	MetricStorageBytes  = "storage_bytes"
+)
+
+// Meter records a billable occurrence for a user account.
+func Meter(userID uint, metric string, qty int64) {
+	e := MeterEvent{UserID: userID, Metric: metric, Quantity: qty, OccurredAt: time.Now()}
+	if err := DB.Create(&e).Error; err != nil {
+		log.Printf("⚠️ metering %s for user %d failed: %v", metric, userID, err)
+	}
+}
+
+type meterSum struct {
+	UserID   uint
+	Metric   string
+	Day      time.Time
+	Quantity int64
+}
+
+// RollupMeters folds raw meter events into daily totals and snapshots storage usage.
+func RollupMeters(ctx context.Context) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&MeterEvent{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("rolled_up = ?", false).
+			Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+			return err
+		}
+
+		var sums []meterSum
+		if err := tx.Model(&MeterEvent{}).
+			Select("user_id, metric, DATE(occurred_at) AS day, SUM(quantity) AS quantity").
+			Where("id IN ?", ids).
+			Group("user_id, metric, DATE(occurred_at)").
+			Scan(&sums).Error; err != nil {
+			return err
+		}
+
+		for _, s := range sums {
+			r := MeterRollup{UserID: s.UserID, Metric: s.Metric, Day: s.Day, Quantity: s.Quantity}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "metric"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"quantity": gorm.Expr("meter_rollups.quantity + ?", s.Quantity), "updated_at": time.Now()}),
+			}).Create(&r).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&MeterEvent{}).Where("id IN ?", ids).Update("rolled_up", true).Error
+	})
+	if err != nil {
+		log.Printf("⚠️ meter rollup failed: %v", err)
+	}
+
+	snapshotStorage()
+}
+
+// snapshotStorage stores today's storage gauge per user (overwritten on each run).
+func snapshotStorage() {
+	var users []User
+	DB.Select("id", "storage_used_bytes").Where("storage_used_bytes > 0").Find(&users)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, u := range users {
+		r := MeterRollup{UserID: u.ID, Metric: MetricStorageBytes, Day: today, Quantity: u.StorageUsedBytes}
+		DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "metric"}, {Name: "day"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+		}).Create(&r)
+	}
+}
+
+// ExportMetering returns daily rollups in a date range as JSON or CSV
+// (?from=YYYY-MM-DD&to=YYYY-MM-DD&format=csv).
+func ExportMetering(c *gin.Context) {
+	query := ReadDB.Model(&MeterRollup{})
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid from date (use YYYY-MM-DD)")
+			return
+		}
+		query = query.Where("day >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid to date (use YYYY-MM-DD)")
+			return
+		}
+		query = query.Where("day <= ?", t)
+	}
+	if uid := c.Query("user_id"); uid != "" {
+		query = query.Where("user_id = ?", uid)
+	}
+
+	var rows []MeterRollup
+	if err := query.Order("day asc, user_id asc, metric asc").Find(&rows).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, rows)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="metering.csv"`)
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"day", "user_id", "metric", "quantity"})
+	for _, r := range rows {
+		w.Write([]string{
+			r.Day.Format("2006-01-02"),
+			strconv.FormatUint(uint64(r.UserID), 10),
+			r.Metric,
+			strconv.FormatInt(r.Quantity, 10),
+		})
+	}
+	w.Flush()
+}
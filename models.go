@@ -1,16 +1,43 @@
 package main
 
 import (
-	"time"
 	"gorm.io/gorm"
+	"time"
 )
 
 // User represents a registered user
 type User struct {
 	gorm.Model
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string    `json:"password,omitempty"` // FIXED: bind JSON but do not return in responses
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Email    string `json:"email" gorm:"uniqueIndex;not null"`
+	Password string `json:"password,omitempty"` // FIXED: bind JSON but do not return in responses
+
+	// Privacy: "everyone", "shared" (people I share an event with) or "nobody"
+	FindableBy  string `json:"findable_by,omitempty" gorm:"type:varchar(16);default:everyone"`
+	InvitableBy string `json:"invitable_by,omitempty" gorm:"type:varchar(16);default:everyone"`
+	ShowRSVP    *bool  `json:"show_rsvp,omitempty" gorm:"default:true"`
+
+	IsAdmin bool `json:"is_admin,omitempty"`
+
+	// ISO 3166 alpha-2 country used for holiday warnings
+	Country string `json:"country,omitempty" gorm:"type:varchar(2)"`
+
+	// Language tag (en, pt-BR) notifications are written in; empty is English
+	Language string `json:"language,omitempty" gorm:"type:varchar(16)"`
+
+	// Profile shown to other users; see profile.go
+	Name      string `json:"name,omitempty" gorm:"type:varchar(100)"`
+	Bio       string `json:"bio,omitempty" gorm:"type:text"`
+	Timezone  string `json:"timezone,omitempty" gorm:"type:varchar(64)"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+
+	// Billing plan and bytes of uploaded files counted against its storage quota
+	Plan             string `json:"plan,omitempty" gorm:"type:varchar(32)"`
+	StorageUsedBytes int64  `json:"storage_used_bytes,omitempty"`
+
+	// SHA-256 of the personal feed token used by widgets and calendar apps
+	FeedTokenHash string `json:"-" gorm:"type:varchar(64);index"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -22,17 +49,92 @@ type LoginRequest struct {
 
 // Event is the core event model
 type Event struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title" gorm:"not null"`
-	Description string    `json:"description"`
-	Location    string    `json:"location"`
-	Date        time.Time `json:"date" gorm:"not null"`
-	OrganizerID uint      `json:"organizer_id" gorm:"not null"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Title           string    `json:"title" gorm:"not null"`
+	Description     string    `json:"description"`
+	DescriptionHTML string    `json:"description_html"` // sanitized HTML rendered from Description
+	Location        string    `json:"location"`
+	Date            time.Time `json:"date" gorm:"not null"`
+	OrganizerID     uint      `json:"organizer_id" gorm:"not null"`
+	MaxAttendees    *int      `json:"max_attendees,omitempty"` // "Going" cap; nil means unlimited
+
+	// What the event offers people with access needs, see accessibility.go
+	Accessibility Accessibility `json:"accessibility" gorm:"embedded;embeddedPrefix:a11y_"`
+
+	// Minimum "Going" headcount checked at DecisionDeadline; below it the
+	// organizer is told, or the event is cancelled when AutoCancel is set
+	MinAttendees       *int       `json:"min_attendees,omitempty"`
+	DecisionDeadline   *time.Time `json:"decision_deadline,omitempty"`
+	AutoCancel         bool       `json:"auto_cancel"`
+	ThresholdCheckedAt *time.Time `json:"-"`
+
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty" gorm:"index"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
+
+	// Archived events are kept but left out of the event listings
+	ArchivedAt *time.Time `json:"archived_at,omitempty" gorm:"index"`
+
+	// Set on events merged into another, see EventRedirect
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// "invited" (default) or "org": every member of OrganizationID can see
+	// the event and RSVP without an invitation
+	OrganizationID *uint  `json:"organization_id,omitempty" gorm:"index"`
+	Visibility     string `json:"visibility" gorm:"type:varchar(16);default:invited"`
+
+	// Vanity slug for the public link (/e/<slug>); old ones are kept in
+	// EventSlugHistory
+	Slug *string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+
+	// Published summary card for embedding on other sites, see SummaryCard
+	PublicCard bool `json:"-" gorm:"not null;default:false"`
+
+	// Comma separated origins allowed to embed the RSVP widget; empty
+	// means the event has no widget
+	WidgetOrigins string `json:"-" gorm:"type:text"`
+
+	// Canonical RRULE ("FREQ=WEEKLY;BYDAY=MO") repeating the event from Date,
+	// expanded in RecurrenceTimezone; empty for a one-off event. SeriesEndsAt
+	// is the last occurrence, NULL while the series has no end.
+	RecurrenceRule     string     `json:"-" gorm:"type:varchar(255);not null;default:''"`
+	RecurrenceTimezone string     `json:"-" gorm:"type:varchar(64)"`
+	SeriesEndsAt       *time.Time `json:"-"`
+
+	// Comma separated minutes-before-start; NULL means the default reminders
+	ReminderMinutes *string `json:"-" gorm:"type:varchar(255)"`
+
+	// Days before the start pending invitees get a nudge; NULL means
+	// NUDGE_DAYS_BEFORE, 0 disables
+	NudgeDaysBefore *int `json:"-"`
+
+	// Local part token of the event's inbound address (event-<alias>@...),
+	// assigned on first use
+	EmailAlias *string `json:"-" gorm:"type:varchar(32);uniqueIndex"`
+
+	// Cover image in Storage: the upload under CoverKey and resized copies
+	// beside it (see coverSizes), CoverSize bytes in all, charged to the
+	// organizer
+	CoverKey  string `json:"-"`
+	CoverSize int64  `json:"-"`
+
+	// Organizer-only; never serialized directly, see EventView
+	PrivateNotes   string `json:"-" gorm:"type:text"`
+	VendorContacts string `json:"-" gorm:"type:text"`
+	BudgetDetails  string `json:"-" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	Organizer User   `gorm:"foreignKey:OrganizerID" json:"organizer,omitempty"`
 	Tasks     []Task `gorm:"foreignKey:EventID" json:"tasks,omitempty"`
+
+	LinkPreviews []LinkPreview `gorm:"-" json:"link_previews,omitempty"`
+}
+
+// BeforeSave keeps the rendered description in sync with the raw Markdown
+func (e *Event) BeforeSave(tx *gorm.DB) error {
+	e.DescriptionHTML = RenderRichText(e.Description)
+	return nil
 }
 
 type Task struct {
@@ -40,16 +142,875 @@ type Task struct {
 	EventID     uint      `json:"event_id" gorm:"index;not null"`
 	Title       string    `json:"title" gorm:"not null"`
 	Description string    `json:"description"`
+	Status      string    `json:"status" gorm:"type:varchar(16);default:todo;not null"` // board column
+	Position    int       `json:"position" gorm:"not null;default:0"`                   // order within the column
+	VendorID    *uint     `json:"vendor_id,omitempty" gorm:"index"`
+	AssigneeID  *uint     `json:"assignee_id,omitempty" gorm:"index"` // a participant; see AssignTask
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Set while the task is done
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CompletedByID *uint      `json:"completed_by_id,omitempty"`
+
+	// Deadline; tasks without one are due when the event starts
+	DueAt *time.Time `json:"due_at,omitempty"`
 }
 
 type EventAttendee struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EventID     uint      `json:"event_id" gorm:"index;not null"`
+	UserID      uint      `json:"user_id" gorm:"index;not null"`
+	Role        string    `json:"role" gorm:"type:varchar(32);not null"`
+	Status      string    `json:"status" gorm:"type:varchar(32)"`
+	InvitedByID *uint     `json:"invited_by_id,omitempty" gorm:"index"`
+	Labels      string    `json:"-" gorm:"type:text;not null;default:''"` // comma separated, see labels.go
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Last "haven't heard from you" nudge, automatic or manual
+	NudgedAt *time.Time `json:"-"`
+
+	// Arrival at the door, recorded from a check-in kiosk
+	CheckedInAt *time.Time `json:"-"`
+
+	// Ticket type held, on events that sell tiers; see capacity.go
+	TicketTierID *uint `json:"-" gorm:"index"`
+
+	// Set once they choose their own reminders instead of the event's
+	CustomReminders bool `json:"-" gorm:"not null;default:false"`
+
+	// Where an invitation stands: "pending", "accepted" or "declined"; ""
+	// for people who weren't invited. See invitations.go.
+	Invitation  string     `json:"-" gorm:"type:varchar(16);not null;default:''"`
+	RespondedAt *time.Time `json:"-"`
+}
+
+// Session is one logged-in device; its ID is carried in the JWT "sid" claim
+type Session struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip" gorm:"type:varchar(64)"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RefreshToken is one link in a session's chain of refresh tokens. Using
+// a token rotates it: it gets UsedAt and a successor. A used token shown
+// again has leaked, and revokes the session, see refresh.go.
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	SessionID    uint       `json:"session_id" gorm:"index;not null"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash    string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// PasswordResetToken lets the holder of a reset email choose a new
+// password once, before ExpiresAt. See passwordreset.go.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	IP        string     `json:"ip" gorm:"type:varchar(64)"` // who asked for it
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Job is a unit of background work picked up by the job worker
+type Job struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Type        string    `json:"type" gorm:"type:varchar(64);index;not null"`
+	Payload     string    `json:"payload" gorm:"type:text"`
+	Status      string    `json:"status" gorm:"type:varchar(16);index;not null"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error"`
+	RunAt       time.Time `json:"run_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Notification is an in-app message shown to a single user
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	Kind      string     `json:"kind" gorm:"type:varchar(64)"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Data      string     `json:"data,omitempty" gorm:"type:text"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Bundled notifications absorb same-key ones until BundleUntil; Count
+	// is how many were folded in (see NotifyBundled)
+	BundleKey   string     `json:"-" gorm:"type:varchar(128);index"`
+	BundleUntil *time.Time `json:"-"`
+	Count       int        `json:"count" gorm:"default:1"`
+}
+
+// DataExport is a user's takeout archive
+type DataExport struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Status      string     `json:"status" gorm:"type:varchar(16)"`
+	FilePath    string     `json:"-"`
+	Token       string     `json:"-" gorm:"uniqueIndex;type:varchar(64)"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// UserSuspension restricts an account until it expires or is lifted
+type UserSuspension struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Mode        string     `json:"mode" gorm:"type:varchar(16);not null"` // "read_only" or "banned"
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedByID uint       `json:"created_by_id"`
+	LiftedAt    *time.Time `json:"lifted_at,omitempty"`
+	LiftedByID  *uint      `json:"lifted_by_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AuditLog records administrative and destructive actions
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ActorID    uint      `json:"actor_id" gorm:"index"`
+	Action     string    `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string    `json:"target_type" gorm:"type:varchar(32);index:idx_audit_target"`
+	TargetID   uint      `json:"target_id" gorm:"index:idx_audit_target"`
+	Details    string    `json:"details" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Organization groups users (a company, club, ...) for shared settings
+type Organization struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	Name    string `json:"name" gorm:"not null"`
+	OwnerID uint   `json:"owner_id" gorm:"index;not null"`
+	Plan    string `json:"plan" gorm:"type:varchar(32)"`
+
+	// Branding for the organization's events, see Branding
+	BrandLogoURL     string `json:"-"`
+	BrandAccentColor string `json:"-" gorm:"type:varchar(7)"`
+	BrandReplyTo     string `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type OrganizationMember struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_org_member;not null"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_org_member;index;not null"`
+	Role           string    `json:"role" gorm:"type:varchar(16);not null"` // "owner", "admin" or "member"
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// FeatureFlag is a named switch; RolloutPercent enables it for a stable
+// slice of users on top of Enabled
+type FeatureFlag struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Key            string    `json:"key" gorm:"uniqueIndex;type:varchar(64);not null"`
+	Description    string    `json:"description"`
+	Enabled        bool      `json:"enabled"`
+	RolloutPercent int       `json:"rollout_percent"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// FeatureFlagOverride forces a flag on or off for one user or organization
+type FeatureFlagOverride struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	FlagKey     string    `json:"flag_key" gorm:"type:varchar(64);uniqueIndex:idx_flag_subject;not null"`
+	SubjectType string    `json:"subject_type" gorm:"type:varchar(8);uniqueIndex:idx_flag_subject;not null"` // "user" or "org"
+	SubjectID   uint      `json:"subject_id" gorm:"uniqueIndex:idx_flag_subject;not null"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MeterEvent is one raw billable occurrence, rolled up daily into MeterRollup
+type MeterEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index;not null"`
+	Metric     string    `json:"metric" gorm:"type:varchar(32);index;not null"`
+	Quantity   int64     `json:"quantity"`
+	RolledUp   bool      `json:"rolled_up" gorm:"index"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"index"`
+}
+
+type MeterRollup struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_meter_rollup;not null"`
+	Metric    string    `json:"metric" gorm:"type:varchar(32);uniqueIndex:idx_meter_rollup;not null"`
+	Day       time.Time `json:"day" gorm:"type:date;uniqueIndex:idx_meter_rollup;not null"`
+	Quantity  int64     `json:"quantity"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Announcement is an organizer message to everyone in an event
+type Announcement struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	EventID   uint      `json:"event_id" gorm:"index;not null"`
-	UserID    uint      `json:"user_id" gorm:"index;not null"`
-	Role      string    `json:"role" gorm:"type:varchar(32);not null"`
-	Status    string    `json:"status" gorm:"type:varchar(32)"`
+	AuthorID  uint      `json:"author_id" gorm:"not null"`
+	Title     string    `json:"title" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text"`
+	BodyHTML  string    `json:"body_html" gorm:"type:text"`
+	Audience  string    `json:"audience" gorm:"type:varchar(48);default:all;not null"` // who it was sent to, see audienceStatuses
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+func (a *Announcement) BeforeSave(tx *gorm.DB) error {
+	a.BodyHTML = RenderRichText(a.Body)
+	return nil
+}
+
+// LinkPreview caches OpenGraph metadata for a URL found in user content
+type LinkPreview struct {
+	ID          uint       `json:"-" gorm:"primaryKey"`
+	URL         string     `json:"url" gorm:"uniqueIndex;not null"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ImageURL    string     `json:"image_url"`
+	SiteName    string     `json:"site_name"`
+	Status      string     `json:"status" gorm:"type:varchar(16)"` // "pending", "ok" or "failed"
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	CreatedAt   time.Time  `json:"-"`
+}
+
+// EventField is an organizer-defined question attendees answer when accepting
+type EventField struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"uniqueIndex:idx_event_field_key;not null"`
+	Key       string    `json:"key" gorm:"type:varchar(64);uniqueIndex:idx_event_field_key;not null"`
+	Label     string    `json:"label" gorm:"not null"`
+	Type      string    `json:"type" gorm:"type:varchar(16);not null"` // text, number, email, boolean, select
+	Options   string    `json:"options,omitempty"`                     // comma separated choices for select
+	Required  bool      `json:"required"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AttendeeAnswer struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	AttendeeID uint      `json:"attendee_id" gorm:"uniqueIndex:idx_attendee_field;not null"`
+	FieldID    uint      `json:"field_id" gorm:"uniqueIndex:idx_attendee_field;not null"`
+	Value      string    `json:"value"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WaitlistEntry queues a user who wanted to go while the event was full
+type WaitlistEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"uniqueIndex:idx_waitlist_user;not null"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_waitlist_user;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvitationReport is an invitee flagging an invitation as unwanted
+type InvitationReport struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	EventID    uint      `json:"event_id" gorm:"uniqueIndex:idx_invite_report;not null"`
+	ReporterID uint      `json:"reporter_id" gorm:"uniqueIndex:idx_invite_report;not null"`
+	InviterID  uint      `json:"inviter_id" gorm:"index;not null"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Vendor is a supplier hired for an event (caterer, DJ, venue, ...)
+type Vendor struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	EventID       uint      `json:"event_id" gorm:"index;not null"`
+	Name          string    `json:"name" gorm:"not null"`
+	Category      string    `json:"category" gorm:"type:varchar(64)"`
+	ContactName   string    `json:"contact_name"`
+	ContactEmail  string    `json:"contact_email"`
+	ContactPhone  string    `json:"contact_phone"`
+	QuoteCents    int64     `json:"quote_cents"`
+	PaymentStatus string    `json:"payment_status" gorm:"type:varchar(16);default:unpaid"` // unpaid, deposit_paid, paid
+	Notes         string    `json:"notes" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Expense is money spent on an event, optionally tied to a vendor or task
+type Expense struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EventID     uint      `json:"event_id" gorm:"index;not null"`
+	VendorID    *uint     `json:"vendor_id,omitempty" gorm:"index"`
+	TaskID      *uint     `json:"task_id,omitempty" gorm:"index"`
+	Category    string    `json:"category" gorm:"type:varchar(64)"`
+	Description string    `json:"description"`
+	AmountCents int64     `json:"amount_cents" gorm:"not null"`
+	IncurredOn  time.Time `json:"incurred_on" gorm:"type:date"`
+	Merchant    string    `json:"merchant,omitempty"`
+	CreatedByID uint      `json:"created_by_id" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Attached receipt image and what OCR read from it, pending confirmation
+	ReceiptPath        string     `json:"-"`
+	ReceiptContentType string     `json:"-" gorm:"type:varchar(64)"`
+	ReceiptSize        int64      `json:"-"`
+	ReceiptUploadedBy  *uint      `json:"-"`
+	OCRStatus          string     `json:"ocr_status,omitempty" gorm:"type:varchar(16)"` // pending, extracted, failed, confirmed
+	OCRAmountCents     *int64     `json:"-"`
+	OCRDate            *time.Time `json:"-" gorm:"type:date"`
+	OCRMerchant        string     `json:"-"`
+	OCRError           string     `json:"-"`
+}
+
+// Attachment is a file shared on an event, or on one of its tasks. The
+// file itself is in Storage under StorageKey.
+type Attachment struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	EventID      uint      `json:"event_id" gorm:"index;not null"`
+	TaskID       *uint     `json:"task_id,omitempty" gorm:"index"`
+	UploadedByID uint      `json:"uploaded_by_id" gorm:"not null"`
+	FileName     string    `json:"file_name" gorm:"not null"`
+	ContentType  string    `json:"content_type" gorm:"type:varchar(128);not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	StorageKey   string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Contract is a document organizers sign off on before committing to it.
+// Every upload adds a ContractVersion; once a co-organizer approves the
+// current version the contract is locked and takes no more uploads.
+type Contract struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	EventID        uint       `json:"event_id" gorm:"index;not null"`
+	Title          string     `json:"title" gorm:"not null"`
+	Status         string     `json:"status" gorm:"type:varchar(16);default:draft;not null"` // draft, pending, approved, rejected
+	CurrentVersion int        `json:"current_version" gorm:"not null"`
+	LockedAt       *time.Time `json:"locked_at,omitempty"`
+	CreatedByID    uint       `json:"created_by_id" gorm:"not null"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type ContractVersion struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ContractID   uint      `json:"contract_id" gorm:"uniqueIndex:idx_contract_version;not null"`
+	Version      int       `json:"version" gorm:"uniqueIndex:idx_contract_version;not null"`
+	UploadedByID uint      `json:"uploaded_by_id" gorm:"not null"`
+	FileName     string    `json:"file_name" gorm:"not null"`
+	ContentType  string    `json:"content_type" gorm:"type:varchar(128);not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	StorageKey   string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ContractApproval asks one co-organizer to approve a version of a
+// contract, and records their answer.
+type ContractApproval struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ContractID    uint       `json:"contract_id" gorm:"index;not null"`
+	Version       int        `json:"version" gorm:"not null"`
+	RequestedByID uint       `json:"requested_by_id" gorm:"not null"`
+	ApproverID    uint       `json:"approver_id" gorm:"index;not null"`
+	Message       string     `json:"message,omitempty" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"type:varchar(16);default:pending;not null"` // pending, approved, rejected, withdrawn
+	Comment       string     `json:"comment,omitempty" gorm:"type:text"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Resource is something an organization lends to its events: a projector,
+// the minibus, a meeting room. Each can only be booked once at a time.
+type Resource struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	Name           string    `json:"name" gorm:"not null"`
+	Kind           string    `json:"kind" gorm:"type:varchar(16);not null;default:other"` // room, vehicle, equipment, other
+	Description    string    `json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// For rooms, handed on to the events booking them
+	Accessibility Accessibility `json:"accessibility" gorm:"embedded;embeddedPrefix:a11y_"`
+}
+
+// ResourceBooking holds a resource for an event, or one of its tasks, from
+// StartsAt until EndsAt
+type ResourceBooking struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ResourceID uint      `json:"resource_id" gorm:"index;not null"`
+	EventID    uint      `json:"event_id" gorm:"index;not null"`
+	TaskID     *uint     `json:"task_id,omitempty" gorm:"index"`
+	BookedByID uint      `json:"booked_by_id" gorm:"not null"`
+	StartsAt   time.Time `json:"starts_at" gorm:"not null"`
+	EndsAt     time.Time `json:"ends_at" gorm:"not null"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BudgetCategory is the amount planned for one expense category of an event
+type BudgetCategory struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EventID     uint      `json:"event_id" gorm:"uniqueIndex:idx_budget_category;not null"`
+	Name        string    `json:"name" gorm:"type:varchar(64);uniqueIndex:idx_budget_category;not null"`
+	BudgetCents int64     `json:"budget_cents"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// VendorQuote is a vendor's offer for one budget category. Quotes in a
+// category are compared side by side; accepting one turns it into an
+// expense and a task to pay the vendor by PaymentDueAt.
+type VendorQuote struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	EventID      uint       `json:"event_id" gorm:"index:idx_quote_category;not null"`
+	Category     string     `json:"category" gorm:"type:varchar(64);index:idx_quote_category;not null"`
+	VendorID     uint       `json:"vendor_id" gorm:"index;not null"`
+	AmountCents  int64      `json:"amount_cents" gorm:"not null"`
+	Description  string     `json:"description"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty" gorm:"type:date"`
+	PaymentDueAt *time.Time `json:"payment_due_at,omitempty"`
+	Status       string     `json:"status" gorm:"type:varchar(16);default:pending;not null"` // pending, accepted, rejected
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+	AcceptedByID *uint      `json:"accepted_by_id,omitempty"`
+	ExpenseID    *uint      `json:"expense_id,omitempty" gorm:"index"`
+	TaskID       *uint      `json:"task_id,omitempty" gorm:"index"`
+	CreatedByID  uint       `json:"created_by_id" gorm:"not null"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Delegation lets DelegateID manage every event PrincipalID owns
+// (executive-assistant mode) until it is revoked
+type Delegation struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	PrincipalID uint       `json:"principal_id" gorm:"uniqueIndex:idx_delegation;not null"`
+	DelegateID  uint       `json:"delegate_id" gorm:"uniqueIndex:idx_delegation;index;not null"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// OrgSSOConfig is an organization's identity provider. Secrets and raw
+// metadata never leave the server.
+type OrgSSOConfig struct {
+	ID               uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID   uint   `json:"organization_id" gorm:"uniqueIndex;not null"`
+	Protocol         string `json:"protocol" gorm:"type:varchar(8);not null"` // "oidc" or "saml"
+	Enabled          bool   `json:"enabled"`
+	OIDCIssuer       string `json:"oidc_issuer,omitempty"`
+	OIDCClientID     string `json:"oidc_client_id,omitempty"`
+	OIDCClientSecret string `json:"-"`
+	SAMLMetadataURL  string `json:"saml_metadata_url,omitempty"`
+	SAMLMetadataXML  string `json:"-" gorm:"type:text"`
+
+	// RoleClaim names the claim/attribute holding IdP groups; RoleMapping is
+	// a JSON object of group -> "member"/"admin"
+	RoleClaim   string    `json:"role_claim,omitempty"`
+	RoleMapping string    `json:"-" gorm:"type:text"`
+	DefaultRole string    `json:"default_role" gorm:"type:varchar(16);default:member"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SSODomain routes logins for an email domain to its organization's IdP
+// once ownership is proven through a DNS TXT record.
+type SSODomain struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint       `json:"organization_id" gorm:"index;not null"`
+	Domain            string     `json:"domain" gorm:"uniqueIndex;not null"`
+	VerificationToken string     `json:"-" gorm:"not null"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// SSOLoginState ties an IdP round trip to the login that started it; Nonce
+// holds the OIDC nonce or the SAML request ID.
+type SSOLoginState struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	State          string    `json:"-" gorm:"uniqueIndex;not null"`
+	OrganizationID uint      `json:"organization_id" gorm:"not null"`
+	Nonce          string    `json:"-" gorm:"not null"`
+	ExpiresAt      time.Time `json:"expires_at" gorm:"index"`
+}
+
+// ScimToken authenticates an organization's identity provider on the SCIM
+// API; only the SHA-256 of the secret is stored.
+type ScimToken struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uint       `json:"organization_id" gorm:"index;not null"`
+	Name           string     `json:"name"`
+	TokenHash      string     `json:"-" gorm:"uniqueIndex;not null"`
+	CreatedByID    uint       `json:"created_by_id"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// KioskToken lets a check-in device at the door look attendees up and
+// check them in for one event, until ExpiresAt; see kiosk.go.
+type KioskToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	EventID     uint       `json:"event_id" gorm:"index;not null"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	CreatedByID uint       `json:"created_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TicketTier is one kind of ticket for an event ("Early bird", "VIP").
+// Going attendees holding it count against Capacity; nil is unlimited.
+type TicketTier struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EventID     uint      `json:"event_id" gorm:"index;not null"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	Capacity    *int      `json:"capacity"`
+	Position    int       `json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// PriceCents is net of tax, in Currency's minor unit; 0 is free.
+	PriceCents int64  `json:"price_cents"`
+	Currency   string `json:"currency,omitempty" gorm:"type:varchar(3)"`
+	TaxRateBps int    `json:"tax_rate_bps"` // 1900 is 19%
+}
+
+// EventSession is a sub-event (a talk, a workshop slot) that going
+// attendees register for separately, with its own Capacity.
+type EventSession struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	EventID   uint       `json:"event_id" gorm:"index;not null"`
+	Title     string     `json:"title" gorm:"not null"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Location  string     `json:"location"`
+	Capacity  *int       `json:"capacity"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// RunOfShowItem is one line of an event's run-of-show, timed relative to
+// the event's start. Lines generated from a session or task remember it
+// until someone edits them.
+type RunOfShowItem struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	EventID         uint      `json:"event_id" gorm:"index;not null"`
+	OffsetMinutes   int       `json:"offset_minutes" gorm:"not null"` // negative before the start
+	DurationMinutes *int      `json:"duration_minutes,omitempty"`
+	Title           string    `json:"title" gorm:"not null"`
+	Notes           string    `json:"notes,omitempty" gorm:"type:text"`
+	AssigneeID      *uint     `json:"assignee_id,omitempty"`
+	Source          string    `json:"source" gorm:"type:varchar(16);not null"` // session, task or manual
+	SourceID        *uint     `json:"source_id,omitempty"`
+	Edited          bool      `json:"edited" gorm:"not null;default:false"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// WeatherContingency is an event's plan for bad weather: the forecast
+// thresholds that set it off and the announcement to publish when they
+// do. It fires once; saving it again re-arms it. See weather.go.
+type WeatherContingency struct {
+	ID        uint    `json:"id" gorm:"primaryKey"`
+	EventID   uint    `json:"event_id" gorm:"uniqueIndex;not null"`
+	Latitude  float64 `json:"latitude" gorm:"not null"`
+	Longitude float64 `json:"longitude" gorm:"not null"`
+	PlaceName string  `json:"place_name,omitempty"` // what the event's location geocoded to
+
+	// Thresholds; any one met during the event sets the plan off
+	RainProbability *int     `json:"rain_probability,omitempty"` // percent, at or above
+	MinTempC        *float64 `json:"min_temp_c,omitempty"`       // the low falls below
+	MaxTempC        *float64 `json:"max_temp_c,omitempty"`       // the high rises above
+	LeadHours       int      `json:"lead_hours" gorm:"not null;default:48"`
+
+	AnnouncementTitle string `json:"announcement_title"`
+	AnnouncementBody  string `json:"announcement_body" gorm:"type:text"`
+	AutoPublish       bool   `json:"auto_publish" gorm:"not null;default:false"`
+
+	// Latest forecast for the event's hours
+	CheckedAt               *time.Time `json:"checked_at,omitempty"`
+	ForecastRainProbability *int       `json:"forecast_rain_probability,omitempty"`
+	ForecastMinTempC        *float64   `json:"forecast_min_temp_c,omitempty"`
+	ForecastMaxTempC        *float64   `json:"forecast_max_temp_c,omitempty"`
+
+	TriggeredAt    *time.Time `json:"triggered_at,omitempty" gorm:"index"`
+	TriggerReason  string     `json:"trigger_reason,omitempty"`
+	AnnouncementID *uint      `json:"announcement_id,omitempty"` // once published
+
+	CreatedByID uint      `json:"created_by_id" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SessionRegistration holds a user's seat in an EventSession.
+type SessionRegistration struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	SessionID uint      `json:"session_id" gorm:"uniqueIndex:idx_session_user;not null"`
+	EventID   uint      `json:"event_id" gorm:"index;not null"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_session_user;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reminder is a reminder for a user, OffsetMinutes before an event starts.
+// People following the event's reminders get a row once one is sent; those
+// who set their own have one per chosen offset. SentAt claims it.
+type Reminder struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	EventID       uint       `json:"event_id" gorm:"uniqueIndex:idx_reminder_slot;not null"`
+	UserID        uint       `json:"user_id" gorm:"uniqueIndex:idx_reminder_slot;index;not null"`
+	OffsetMinutes int        `json:"offset_minutes" gorm:"uniqueIndex:idx_reminder_slot;not null"`
+	Channel       string     `json:"channel" gorm:"type:varchar(16);not null;default:'all'"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// CancellationPolicy is how much of a ticket is refunded when given up;
+// events without one use defaultCancellationPolicy. See refunds.go.
+type CancellationPolicy struct {
+	EventID              uint      `json:"event_id" gorm:"primaryKey;autoIncrement:false"`
+	FullRefundDaysBefore int       `json:"full_refund_days_before"`
+	PartialRefundPercent int       `json:"partial_refund_percent"`
+	UpdatedByID          uint      `json:"updated_by_id"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// CertificateTemplate is an event's wording for certificates of
+// attendance; events without one use defaultCertificateTemplate.
+type CertificateTemplate struct {
+	EventID     uint      `json:"event_id" gorm:"primaryKey;autoIncrement:false"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	Signer      string    `json:"signer"`
+	UpdatedByID uint      `json:"updated_by_id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AttendanceCertificate is issued to a checked-in attendee. It keeps the
+// rendered text so it reads the same, and stays verifiable by Code, after
+// the event changes or is deleted.
+type AttendanceCertificate struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	EventID    uint       `json:"event_id" gorm:"uniqueIndex:idx_certificate_attendee;not null"`
+	UserID     uint       `json:"user_id" gorm:"uniqueIndex:idx_certificate_attendee;index;not null"`
+	Code       string     `json:"code" gorm:"type:varchar(32);uniqueIndex;not null"`
+	Name       string     `json:"name"`
+	EventTitle string     `json:"event_title"`
+	AttendedOn time.Time  `json:"attended_on"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	Signer     string     `json:"signer,omitempty"`
+	IssuedByID uint       `json:"issued_by_id"`
+	IssuedAt   time.Time  `json:"issued_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// BillingProfile is who a user's invoices are made out to, kept from
+// their last paid registration.
+type BillingProfile struct {
+	UserID    uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Country   string    `json:"country" gorm:"type:varchar(2)"` // ISO 3166-1 alpha-2
+	TaxID     string    `json:"tax_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Invoice is issued for a Going registration on a priced ticket tier. Like
+// certificates it is a snapshot and outlives the event; giving up the
+// ticket voids it rather than deleting it. Numbers run per seller (the
+// organizer) per year.
+type Invoice struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Number       string     `json:"number" gorm:"type:varchar(20);uniqueIndex:idx_invoice_number;not null"`
+	SellerID     uint       `json:"seller_id" gorm:"uniqueIndex:idx_invoice_number;not null"`
+	SellerName   string     `json:"seller_name"`
+	EventID      uint       `json:"event_id" gorm:"index;not null"`
+	EventTitle   string     `json:"event_title"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	AttendeeID   uint       `json:"-" gorm:"index"`
+	TicketTierID uint       `json:"ticket_tier_id"`
+	Description  string     `json:"description"`
+	NetCents     int64      `json:"net_cents"`
+	TaxRateBps   int        `json:"tax_rate_bps"`
+	TaxCents     int64      `json:"tax_cents"`
+	TotalCents   int64      `json:"total_cents"`
+	Currency     string     `json:"currency" gorm:"type:varchar(3)"`
+	BillTo       string     `json:"bill_to"`
+	BillAddress  string     `json:"bill_address"`
+	BillCountry  string     `json:"bill_country" gorm:"type:varchar(2)"`
+	BillTaxID    string     `json:"bill_tax_id,omitempty"`
+	IssuedAt     time.Time  `json:"issued_at"`
+	VoidedAt     *time.Time `json:"voided_at,omitempty"`
+}
+
+// InvoiceSequence holds the last number a seller used in a year.
+type InvoiceSequence struct {
+	SellerID uint  `gorm:"primaryKey;autoIncrement:false"`
+	Year     int   `gorm:"primaryKey;autoIncrement:false"`
+	Last     int64 `gorm:"not null"`
+}
+
+// ScimUser links an account to the organization that provisioned it.
+// Active mirrors the IdP; inactive users are not org members.
+type ScimUser struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_scim_user;not null"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_scim_user;index;not null"`
+	ExternalID     string    `json:"external_id" gorm:"index"`
+	Active         bool      `json:"active" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// EventComment is a message on an event's discussion thread. Source is
+// "app" or "email" (a reply ingested through the event's email alias).
+type EventComment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"index;not null"`
+	AuthorID  uint      `json:"author_id" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	BodyHTML  string    `json:"body_html" gorm:"type:text"`
+	Source    string    `json:"source" gorm:"type:varchar(16);default:app"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set once the body has been edited
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+}
+
+func (cm *EventComment) BeforeSave(tx *gorm.DB) error {
+	cm.BodyHTML = RenderRichText(cm.Body)
+	return nil
+}
+
+// ChangeRecord is one entry of the sync change feed; ID is the cursor.
+// UserID 0 means every participant of EventID sees it.
+type ChangeRecord struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"index"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Entity    string    `json:"entity" gorm:"type:varchar(32);not null;index:idx_change_entity"`
+	EntityID  uint      `json:"entity_id" gorm:"not null;index:idx_change_entity"`
+	Op        string    `json:"op" gorm:"type:varchar(8);not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// SyncMutationLog remembers the result of an applied offline mutation so a
+// client retrying after a lost response gets the same answer.
+type SyncMutationLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_sync_mutation_client"`
+	ClientID  string    `json:"client_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_sync_mutation_client"`
+	Result    string    `json:"result" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// EventRedirect points requests for an event merged away to the event it
+// was merged into.
+type EventRedirect struct {
+	FromEventID uint      `json:"from_event_id" gorm:"primaryKey;autoIncrement:false"`
+	ToEventID   uint      `json:"to_event_id" gorm:"index;not null"`
+	MergedByID  uint      `json:"merged_by_id" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UndoAction is a destructive action that can still be reversed until
+// ExpiresAt. Snapshot holds what the action removed, as JSON.
+type UndoAction struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Token     string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	Action    string     `json:"action" gorm:"type:varchar(32);not null"` // "task.delete", "attendee.remove" or "event.cancel"
+	EventID   uint       `json:"event_id" gorm:"not null"`
+	TargetID  uint       `json:"target_id" gorm:"not null"`
+	Snapshot  string     `json:"-" gorm:"type:text"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// GuestRSVP is an answer from someone without an account, sent through
+// the embeddable RSVP widget. Going guests count towards capacity.
+type GuestRSVP struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   uint      `json:"event_id" gorm:"uniqueIndex:idx_guest_rsvp_email;not null"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	Email     string    `json:"email" gorm:"type:varchar(255);uniqueIndex:idx_guest_rsvp_email;not null"`
+	Status    string    `json:"status" gorm:"type:varchar(32);not null"`
+	Origin    string    `json:"origin"` // site the widget was embedded on
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventSlugHistory is a slug an event used to have. It keeps redirecting
+// to the event and stays unavailable to other events.
+type EventSlugHistory struct {
+	Slug      string    `json:"slug" gorm:"primaryKey;type:varchar(64)"`
+	EventID   uint      `json:"event_id" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationTemplate overrides the built-in wording of a notification
+// kind for one locale, see templateKinds.
+type NotificationTemplate struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Kind        string    `json:"kind" gorm:"type:varchar(64);uniqueIndex:idx_template_kind_locale;not null"`
+	Locale      string    `json:"locale" gorm:"type:varchar(16);uniqueIndex:idx_template_kind_locale;not null"`
+	Subject     string    `json:"subject" gorm:"not null"`
+	Body        string    `json:"body" gorm:"type:text"`
+	Summary     string    `json:"summary"`
+	UpdatedByID uint      `json:"updated_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DeprecationUsage counts one caller's use of a deprecated API surface; the
+// caller is the user and the session (token) they called with.
+type DeprecationUsage struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"key" gorm:"type:varchar(128);uniqueIndex:idx_deprecation_caller;not null"`
+	UserID      uint      `json:"user_id" gorm:"uniqueIndex:idx_deprecation_caller"`
+	SessionID   uint      `json:"session_id" gorm:"uniqueIndex:idx_deprecation_caller"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip" gorm:"type:varchar(64)"`
+	Count       int64     `json:"count"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" gorm:"index"`
+}
+
+// EventOccurrenceException cancels one occurrence of a recurring event,
+// identified by its original start (EXDATE).
+type EventOccurrenceException struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EventID     uint      `json:"event_id" gorm:"uniqueIndex:idx_occurrence_exception;not null"`
+	OccursAt    time.Time `json:"occurs_at" gorm:"uniqueIndex:idx_occurrence_exception;not null"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EventOccurrenceOverride changes one occurrence of a recurring event,
+// identified by its original start (RECURRENCE-ID); nil fields follow the
+// series.
+type EventOccurrenceOverride struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	EventID     uint       `json:"event_id" gorm:"uniqueIndex:idx_occurrence_override;not null"`
+	OccursAt    time.Time  `json:"occurs_at" gorm:"uniqueIndex:idx_occurrence_override;not null"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Location    *string    `json:"location,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	UpdatedByID uint       `json:"updated_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notify stores an in-app notification for one user.
+func Notify(userID uint, kind, title, body string, data gin.H) {
+	n := Notification{
+		UserID: userID,
+		Kind:   kind,
+		Title:  title,
+		Body:   body,
+	}
+	if data != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			n.Data = string(raw)
+		}
+	}
+	if err := DB.Create(&n).Error; err != nil {
+		log.Printf("⚠️ could not store notification for user %d: %v", userID, err)
+		return
+	}
+	NotificationStreams.wake(userID)
+}
+
+// maxBundleLines caps how many individual lines a bundled body keeps.
+const maxBundleLines = 10
+
+// NotifyBundled debounces bursts of similar notifications (20 RSVPs in a
+// minute) into one per user and bundleKey. While a bundle is open and
+// unread, new items fold into it: the title becomes summary (a format with
+// one %d for the count), the body lists the latest items and the window is
+// pushed back, up to 3x the configured length. Email delivery, when added,
+// should wait for BundleUntil to pass.
+func NotifyBundled(userID uint, kind, bundleKey, title, body, summary string, data gin.H) {
+	window := time.Duration(AppConfig.NotificationBundleSeconds) * time.Second
+	if window <= 0 {
+		Notify(userID, kind, title, body, data)
+		return
+	}
+
+	var raw string
+	if data != nil {
+		if b, err := json.Marshal(data); err == nil {
+			raw = string(b)
+		}
+	}
+
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var open Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND bundle_key = ? AND read_at IS NULL AND bundle_until > ?", userID, bundleKey, now).
+			Order("id desc").First(&open).Error
+		if err == gorm.ErrRecordNotFound {
+			until := now.Add(window)
+			return tx.Create(&Notification{
+				UserID: userID, Kind: kind, Title: title, Body: body, Data: raw,
+				BundleKey: bundleKey, BundleUntil: &until, Count: 1,
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		lines := append([]string{body}, strings.Split(open.Body, "\n")...)
+		if len(lines) > maxBundleLines {
+			lines = lines[:maxBundleLines]
+		}
+		until := now.Add(window)
+		if limit := open.CreatedAt.Add(3 * window); until.After(limit) {
+			until = limit
+		}
+		return tx.Model(&open).Updates(map[string]interface{}{
+			"title":        fmt.Sprintf(summary, open.Count+1),
+			"body":         strings.Join(lines, "\n"),
+			"data":         raw,
+			"count":        open.Count + 1,
+			"bundle_until": until,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("⚠️ could not store notification for user %d: %v", userID, err)
+		return
+	}
+	NotificationStreams.wake(userID)
+}
+
+func GetMyNotifications(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	query := DB.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []Notification
+	if err := query.Order("COALESCE(updated_at, created_at) desc").Limit(100).Find(&notifications).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid notification id")
+		return
+	}
+
+	res := DB.Model(&Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected > 0 {
+		NotificationStreams.wake(userID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification marked as read"})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notifications are also streamed as Server-Sent Events, for clients whose
+// proxies break WebSockets. Every event is a notification as the list
+// endpoint returns it, sent when it is created, folded into a bundle or
+// marked read. Event ids are cursors over (updated_at, id), so a client
+// that reconnects with Last-Event-ID picks up where it left off. The rows
+// stay the source of truth: a local wake-up makes delivery immediate and a
+// short poll catches notifications stored by other instances.
+
+const (
+	streamHeartbeat   = 25 * time.Second
+	streamPoll        = 5 * time.Second
+	streamRetry       = 3 * time.Second // reconnection delay suggested to clients
+	streamReplayLimit = 100
+)
+
+// notificationBroker wakes a user's open streams when something of theirs
+// changes.
+type notificationBroker struct {
+	mu        sync.Mutex
+	subs      map[uint]map[chan struct{}]bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var NotificationStreams = &notificationBroker{
+	subs:   map[uint]map[chan struct{}]bool{},
+	closed: make(chan struct{}),
+}
+
+func (b *notificationBroker) subscribe(userID uint) chan struct{} {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[chan struct{}]bool{}
+	}
+	b.subs[userID][ch] = true
+	return ch
+}
+
+func (b *notificationBroker) unsubscribe(userID uint, ch chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs[userID], ch)
+	if len(b.subs[userID]) == 0 {
+		delete(b.subs, userID)
+	}
+}
+
+// wake tells the user's streams to look for changes. It never blocks: a
+// stream already due to look doesn't need telling twice.
+func (b *notificationBroker) wake(userID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close ends every stream, so server shutdown isn't held up by them.
+func (b *notificationBroker) Close() {
+	b.closeOnce.Do(func() { close(b.closed) })
+}
+
+// streamCursor is the position of the last notification sent.
+type streamCursor struct {
+	at time.Time
+	id uint
+}
+
+func (s streamCursor) String() string {
+	return fmt.Sprintf("%d-%d", s.at.UnixMicro(), s.id)
+}
+
+func parseStreamCursor(raw string) (streamCursor, error) {
+	micros, id, ok := strings.Cut(raw, "-")
+	if !ok {
+		return streamCursor{}, errors.New("invalid Last-Event-ID")
+	}
+	m, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return streamCursor{}, errors.New("invalid Last-Event-ID")
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return streamCursor{}, errors.New("invalid Last-Event-ID")
+	}
+	return streamCursor{at: time.UnixMicro(m), id: uint(n)}, nil
+}
+
+func isEventStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// StreamMyNotifications streams the caller's notifications as they change.
+// A new stream starts from now; Last-Event-ID (or ?last_event_id=, for
+// clients that can't set it) replays what changed since that event.
+func StreamMyNotifications(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	cursor := streamCursor{at: time.Now()}
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		var err error
+		if cursor, err = parseStreamCursor(lastID); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	wake := NotificationStreams.subscribe(userID)
+	defer NotificationStreams.unsubscribe(userID, wake)
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry.Milliseconds())
+	c.Writer.Flush()
+
+	// send writes everything past the cursor; false means the client is gone
+	send := func() bool {
+		for {
+			var rows []Notification
+			if err := DB.Where("user_id = ? AND (updated_at > ? OR (updated_at = ? AND id > ?))", userID, cursor.at, cursor.at, cursor.id).
+				Order("updated_at asc, id asc").Limit(streamReplayLimit).Find(&rows).Error; err != nil {
+				return true // try again on the next poll
+			}
+			for _, n := range rows {
+				raw, _ := json.Marshal(n)
+				cursor = streamCursor{at: n.UpdatedAt, id: n.ID}
+				if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: notification\ndata: %s\n\n", cursor, raw); err != nil {
+					return false
+				}
+			}
+			c.Writer.Flush()
+			if len(rows) < streamReplayLimit {
+				return true
+			}
+		}
+	}
+	if !send() {
+		return
+	}
+
+	poll := time.NewTicker(streamPoll)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	var expired <-chan time.Time
+	if exp := c.GetTime("token_expires_at"); !exp.IsZero() {
+		timer := time.NewTimer(time.Until(exp))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-NotificationStreams.closed:
+			return
+		case <-expired:
+			// the client reconnects with a fresh token and its Last-Event-ID
+			fmt.Fprint(c.Writer, "event: token_expired\ndata: {}\n\n")
+			c.Writer.Flush()
+			return
+		case <-wake:
+			if !send() {
+				return
+			}
+		case <-poll.C:
+			if !send() {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxNudgeDaysBefore = 30
+
+	// a manual nudge reaches the same person at most once per cooldown
+	manualNudgeCooldown = 24 * time.Hour
+)
+
+// nudgeDaysBefore is how many days before the start pending invitees are
+// nudged; 0 means never.
+func nudgeDaysBefore(ev Event) int {
+	if ev.NudgeDaysBefore == nil {
+		return AppConfig.NudgeDaysBefore
+	}
+	return *ev.NudgeDaysBefore
+}
+
+// pendingInvitees are attendees who haven't answered yet. Answering takes
+// them out, which is all the suppression a nudge needs.
+func pendingInvitees(tx *gorm.DB, eventID uint) *gorm.DB {
+	return tx.Model(&EventAttendee{}).Where("event_id = ? AND status = ? AND role <> ?", eventID, "", "organizer")
+}
+
+func sendNudge(ev Event, userID uint) {
+	NotifyTemplate(userID, "attendance_nudge",
+		map[string]string{"EventTitle": ev.Title, "StartsAt": startsAtText(ev)},
+		gin.H{"event_id": ev.ID})
+}
+
+type NudgeSettingsRequest struct {
+	DaysBefore *int `json:"days_before"` // 0 disables nudges
+	UseDefault bool `json:"use_default"`
+}
+
+// SetEventNudges configures the automatic nudge for pending invitees.
+func SetEventNudges(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body NudgeSettingsRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	var value *int
+	if !body.UseDefault {
+		if body.DaysBefore == nil {
+			jsonError(c, http.StatusBadRequest, "days_before is required unless use_default is set")
+			return
+		}
+		if *body.DaysBefore < 0 || *body.DaysBefore > maxNudgeDaysBefore {
+			jsonError(c, http.StatusBadRequest, "days_before must be between 0 and 30")
+			return
+		}
+		value = body.DaysBefore
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Update("nudge_days_before", value).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update nudges: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	ev.NudgeDaysBefore = value
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "days_before": nudgeDaysBefore(ev), "default": value == nil})
+}
+
+type NudgeRequest struct {
+	UserIDs []uint `json:"user_ids"` // empty nudges everyone still pending
+}
+
+// NudgeInvitees lets an organizer nudge pending invitees right away.
+// People who already answered, or were nudged in the last day, are skipped.
+func NudgeInvitees(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
+	if !ev.Date.After(time.Now()) {
+		jsonError(c, http.StatusConflict, "this event has already started")
+		return
+	}
+
+	var body NudgeRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+
+	q := pendingInvitees(DB, ev.ID)
+	if len(body.UserIDs) > 0 {
+		q = q.Where("user_id IN ?", body.UserIDs)
+	}
+	var pending []EventAttendee
+	if err := q.Find(&pending).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	nudged := []uint{}
+	now := time.Now()
+	for _, a := range pending {
+		res := DB.Model(&EventAttendee{}).
+			Where("id = ? AND status = ? AND (nudged_at IS NULL OR nudged_at < ?)", a.ID, "", now.Add(-manualNudgeCooldown)).
+			Update("nudged_at", now)
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		sendNudge(ev, a.UserID)
+		nudged = append(nudged, a.UserID)
+	}
+
+	if len(nudged) > 0 {
+		Audit(userID, "event.nudge", "event", ev.ID, gin.H{"user_ids": nudged})
+	}
+	c.JSON(http.StatusOK, gin.H{"nudged": nudged, "skipped": len(pending) - len(nudged)})
+}
+
+// SendAttendanceNudges nudges pending invitees of events entering their
+// nudge window. Each attendee is claimed with a conditional update, so they
+// hear from us once per window even with several instances running; a
+// manual nudge inside the window counts.
+func SendAttendanceNudges(ctx context.Context) {
+	now := time.Now()
+	var events []Event
+	if err := DB.WithContext(ctx).
+		Where("cancelled_at IS NULL AND date > ? AND date <= ?", now, now.AddDate(0, 0, maxNudgeDaysBefore)).
+		Find(&events).Error; err != nil {
+		log.Printf("⚠️ attendance nudges failed: %v", err)
+		return
+	}
+
+	for _, ev := range events {
+		days := nudgeDaysBefore(ev)
+		if days <= 0 {
+			continue
+		}
+		windowStart := ev.Date.AddDate(0, 0, -days)
+		if now.Before(windowStart) {
+			continue
+		}
+
+		var pending []EventAttendee
+		// someone invited moments ago hasn't had the chance to answer yet
+		pendingInvitees(DB, ev.ID).
+			Where("(nudged_at IS NULL OR nudged_at < ?) AND created_at < ?", windowStart, now.Add(-manualNudgeCooldown)).
+			Find(&pending)
+		for _, a := range pending {
+			res := DB.Model(&EventAttendee{}).
+				Where("id = ? AND status = ? AND (nudged_at IS NULL OR nudged_at < ?)", a.ID, "", windowStart).
+				Update("nudged_at", now)
+			if res.Error != nil || res.RowsAffected == 0 {
+				continue
+			}
+			sendNudge(ev, a.UserID)
+		}
+	}
+}
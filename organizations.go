@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// userOrgIDs lists the organizations a user belongs to.
+func userOrgIDs(userID uint) []uint {
+	var ids []uint
+	DB.Model(&OrganizationMember{}).Where("user_id = ?", userID).Pluck("organization_id", &ids)
+	return ids
+}
+
+// orgRole returns the user's role in the organization, or "" if not a member.
+func orgRole(orgID, userID uint) string {
+	var m OrganizationMember
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&m).Error; err != nil {
+		return ""
+	}
+	return m.Role
+}
+
+func isOrgAdmin(orgID, userID uint) bool {
+	role := orgRole(orgID, userID)
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func CreateOrganization(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body CreateOrganizationRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	org := Organization{Name: strings.TrimSpace(body.Name), OwnerID: userID}
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{OrganizationID: org.ID, UserID: userID, Role: OrgRoleOwner}).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create organization: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+func GetMyOrganizations(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var orgs []Organization
+	if err := DB.Where("id IN ?", userOrgIDs(userID)).Order("name asc").Find(&orgs).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid organization id")
+		return
+	}
+	if orgRole(uint(orgID), userID) == "" {
+		jsonError(c, http.StatusForbidden, "not a member of this organization")
+		return
+	}
+
+	var members []OrganizationMember
+	if err := DB.Where("organization_id = ?", orgID).Find(&members).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, memberViews(members, userID, isOrgAdmin(uint(orgID), userID)))
+}
+
+type AddOrganizationMemberRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role"`
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid organization id")
+		return
+	}
+	if !isOrgAdmin(uint(orgID), userID) {
+		jsonError(c, http.StatusForbidden, "only organization admins can add members")
+		return
+	}
+
+	var body AddOrganizationMemberRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	role := strings.ToLower(body.Role)
+	if role == "" {
+		role = OrgRoleMember
+	}
+	if role != OrgRoleMember && role != OrgRoleAdmin {
+		jsonError(c, http.StatusBadRequest, "role must be member or admin")
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, body.UserID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+
+	m := OrganizationMember{OrganizationID: uint(orgID), UserID: user.ID, Role: role}
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgID, user.ID).FirstOrCreate(&m).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not add member: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, memberViews([]OrganizationMember{m}, userID, true)[0])
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid organization id")
+		return
+	}
+	memberID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	// members may leave; admins may remove others
+	if uint(memberID) != userID && !isOrgAdmin(uint(orgID), userID) {
+		jsonError(c, http.StatusForbidden, "only organization admins can remove members")
+		return
+	}
+	if orgRole(uint(orgID), uint(memberID)) == OrgRoleOwner {
+		jsonError(c, http.StatusBadRequest, "the owner cannot be removed")
+		return
+	}
+
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgID, memberID).Delete(&OrganizationMember{}).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not remove member: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	VisibilityInvited = "invited"
+	VisibilityOrg     = "org"
+)
+
+type VisibilityRequest struct {
+	Visibility     string `json:"visibility" binding:"required"`
+	OrganizationID *uint  `json:"organization_id"` // required for "org"
+}
+
+// SetEventVisibility makes an event visible to a whole organization the
+// caller belongs to, or back to invitation-only.
+func SetEventVisibility(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body VisibilityRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	updates := map[string]interface{}{"visibility": body.Visibility}
+	switch body.Visibility {
+	case VisibilityInvited:
+		if body.OrganizationID != nil {
+			updates["organization_id"] = *body.OrganizationID
+		}
+	case VisibilityOrg:
+		if body.OrganizationID == nil {
+			jsonError(c, http.StatusBadRequest, "organization_id is required for org visibility")
+			return
+		}
+		updates["organization_id"] = *body.OrganizationID
+	default:
+		jsonError(c, http.StatusBadRequest, "visibility must be invited or org")
+		return
+	}
+	if body.OrganizationID != nil && orgRole(*body.OrganizationID, userID) == "" {
+		jsonError(c, http.StatusForbidden, "not a member of this organization")
+		return
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Updates(updates).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update visibility: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	DB.First(&ev, ev.ID)
+
+	c.JSON(http.StatusOK, eventView(ev, true))
+}
+
+// orgVisibleEvents loads the org-visible events of an organization from
+// the given time on.
+func orgVisibleEvents(orgID uint, from time.Time) ([]Event, error) {
+	var events []Event
+	err := DB.Where("organization_id = ? AND visibility = ? AND "+eventEndsExpr+" >= ?", orgID, VisibilityOrg, from).
+		Order("date asc").Find(&events).Error
+	return events, err
+}
+
+// orgMemberParam parses :id and checks the caller belongs to the organization.
+func orgMemberParam(c *gin.Context, userID uint) (uint, bool) {
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid organization id")
+		return 0, false
+	}
+	if orgRole(uint(orgID), userID) == "" {
+		jsonError(c, http.StatusForbidden, "not a member of this organization")
+		return 0, false
+	}
+	return uint(orgID), true
+}
+
+// GetOrganizationEvents is the company calendar: every org-visible event,
+// upcoming by default (?from=YYYY-MM-DD to include older ones).
+func GetOrganizationEvents(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return
+	}
+
+	from := time.Now()
+	if s := c.Query("from"); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "from must be YYYY-MM-DD")
+			return
+		}
+		from = d
+	}
+
+	events, err := orgVisibleEvents(orgID, from)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	attachLinkPreviews(events)
+	c.JSON(http.StatusOK, eventViews(events, userID))
+}
+
+// GetOrganizationCalendarFeed serves the company calendar as ICS for
+// calendar apps, authenticated by the member's feed token.
+func GetOrganizationCalendarFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return
+	}
+
+	events, err := orgVisibleEvents(orgID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	var org Organization
+	DB.First(&org, orgID)
+	writeICS(c, "org-calendar.ics", renderICS(org.Name+" events", events, userID))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// List endpoints page with ?per_page (default 50, at most 200) and either
+// ?page (1-based) or ?cursor (the next_cursor of the previous page), and
+// answer with a Page. Callers sending none of these get the first page.
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 200
+)
+
+// Page is the envelope of a paged list.
+type Page struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"` // absent on the last page
+}
+
+type pagination struct {
+	Limit  int
+	Offset int
+	Total  int64
+}
+
+// pageParams reads the paging parameters; it writes the 400 itself.
+func pageParams(c *gin.Context) (*pagination, bool) {
+	return pageParamsWithin(c, defaultPerPage, maxPerPage)
+}
+
+// pageParamsWithin is pageParams for lists with their own page sizes.
+func pageParamsWithin(c *gin.Context, perPage, most int) (*pagination, bool) {
+	p := &pagination{Limit: perPage}
+	if raw := c.Query("per_page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > most {
+			jsonError(c, http.StatusBadRequest, "per_page must be between 1 and "+strconv.Itoa(most))
+			return nil, false
+		}
+		p.Limit = n
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		offset, err := decodeCursor(raw)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid cursor")
+			return nil, false
+		}
+		p.Offset = offset
+	} else if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			jsonError(c, http.StatusBadRequest, "page must be a positive number")
+			return nil, false
+		}
+		p.Offset = (n - 1) * p.Limit
+	}
+	return p, true
+}
+
+// paginate counts q and narrows it to the requested page. Add preloads
+// after paginating, not before: they would run for the count too.
+func paginate(c *gin.Context, q *gorm.DB) (*gorm.DB, *pagination, bool) {
+	p, ok := pageParams(c)
+	if !ok {
+		return nil, nil, false
+	}
+	if err := q.Session(&gorm.Session{}).Count(&p.Total).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return nil, nil, false
+	}
+	return q.Offset(p.Offset).Limit(p.Limit), p, true
+}
+
+// writePage answers with the page envelope for data, n items long.
+func writePage(c *gin.Context, p *pagination, data interface{}, n int) {
+	page := Page{Data: data, Total: p.Total}
+	if next := p.Offset + n; n > 0 && int64(next) < p.Total {
+		page.NextCursor = encodeCursor(next)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// Cursors are opaque to clients; today they carry the offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) < 3 || string(b[:2]) != "o:" {
+		return 0, strconv.ErrSyntax
+	}
+	n, err := strconv.Atoi(string(b[2:]))
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Users who forgot their password ask for a reset link by email. The link
+// carries a random token, stored only hashed, that sets a new password
+// once within passwordResetTTL; asking again replaces any earlier link.
+// The answer never says whether the email has an account. A reset signs
+// the user out everywhere, since whoever knew the old password may still
+// hold a session.
+
+const (
+	passwordResetTTL     = time.Hour
+	passwordResetsPerDay = 5 // links sent per account, so the form can't flood an inbox
+)
+
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ForgotPassword emails a reset link if the address has an account.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+
+	// org-managed domains reset their passwords at the identity provider
+	if cfg, ok := ssoConfigForEmail(email); ok {
+		ssoRequiredError(c, cfg)
+		return
+	}
+
+	sent := gin.H{"message": "If an account exists for this email, a reset link is on its way."}
+	var user User
+	if err := DB.Select("id", "email").Where("email = ?", email).First(&user).Error; err != nil {
+		c.JSON(http.StatusAccepted, sent)
+		return
+	}
+	if s := activeSuspension(user.ID); s != nil && s.Mode == SuspensionBanned {
+		c.JSON(http.StatusAccepted, sent)
+		return
+	}
+	var recent int64
+	DB.Model(&PasswordResetToken{}).Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-24*time.Hour)).Count(&recent)
+	if recent >= passwordResetsPerDay {
+		c.JSON(http.StatusAccepted, sent)
+		return
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create reset token")
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// only the newest link works; the older ones still count towards
+		// the daily limit
+		now := time.Now()
+		if err := tx.Model(&PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.ID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(raw),
+			ExpiresAt: now.Add(passwordResetTTL),
+			IP:        c.ClientIP(),
+		}).Error
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create reset token: "+err.Error())
+		return
+	}
+
+	// fragment so the token never reaches server logs
+	link := AppConfig.PublicBaseURL + "/reset-password#token=" + url.QueryEscape(raw)
+	EmailAccount(user.ID, "password_reset", map[string]string{"ExpiresIn": "1 hour"}, link, "Reset your password")
+	c.JSON(http.StatusAccepted, sent)
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ResetPassword sets a new password with a token from a reset email and
+// ends the user's sessions.
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var userID uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var t PasswordResetToken
+		if err := tx.Where("token_hash = ?", hashToken(req.Token)).First(&t).Error; err != nil {
+			return errResetTokenInvalid
+		}
+		// claim the token so it can't be used twice
+		now := time.Now()
+		res := tx.Model(&PasswordResetToken{}).Where("id = ? AND used_at IS NULL AND expires_at > ?", t.ID, now).Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+		userID = t.UserID
+
+		// stored as given, like Signup does; passwords aren't hashed yet
+		if err := tx.Model(&User{}).Where("id = ?", userID).Update("password", req.Password).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error
+	})
+	if errors.Is(err, errResetTokenInvalid) {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not reset password: "+err.Error())
+		return
+	}
+
+	Audit(userID, "user.password_reset", "user", userID, gin.H{"ip": c.ClientIP()})
+	NotifyTemplate(userID, "password_changed", map[string]string{"IP": c.ClientIP()}, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated; sign in with the new one"})
+}
+
+// PrunePasswordResets drops reset tokens older than a day: they expired
+// long ago and no longer count towards passwordResetsPerDay.
+func PrunePasswordResets(ctx context.Context) {
+	if err := DB.WithContext(ctx).Where("created_at < ?", time.Now().Add(-24*time.Hour)).Delete(&PasswordResetToken{}).Error; err != nil {
+		log.Printf("⚠️ password reset cleanup failed: %v", err)
+	}
+}
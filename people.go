@@ -0,0 +1,200 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxPeopleResults = 20
+
+// likeEscaper escapes LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// sharedEventUsersQuery selects everyone attending (or organizing) an event
+// with the user.
+func sharedEventUsersQuery(userID uint) *gorm.DB {
+	return DB.Table("event_attendees ea1").
+		Select("ea2.user_id").
+		Joins("JOIN event_attendees ea2 ON ea2.event_id = ea1.event_id").
+		Where("ea1.user_id = ?", userID)
+}
+
+// sharedOrgUsersQuery selects the members of the user's organizations.
+func sharedOrgUsersQuery(userID uint) *gorm.DB {
+	return DB.Model(&OrganizationMember{}).
+		Select("user_id").
+		Where("organization_id IN (?)", DB.Model(&OrganizationMember{}).Select("organization_id").Where("user_id = ?", userID))
+}
+
+type personResult struct {
+	ID          uint   `json:"id"`
+	Email       string `json:"email"`
+	SharesEvent bool   `json:"shares_event"`
+	CanInvite   bool   `json:"can_invite"` // their invitable_by lets the caller invite them
+
+	InvitableBy string `json:"-"`
+}
+
+// SearchPeople finds people the caller knows: those they share an event or
+// an organization with, narrowed to one event's participants with event_id.
+// People who aren't findable by the caller, are suspended or reported one
+// of the caller's invitations never show up.
+func SearchPeople(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	var eventID uint
+	if raw := c.Query("event_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid event_id")
+			return
+		}
+		var ev Event
+		if err := DB.First(&ev, id).Error; err != nil || !canViewEvent(ev, userID) {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		eventID = ev.ID
+	}
+	// without an event there are too many people to list them all
+	if q == "" && eventID == 0 {
+		jsonError(c, http.StatusBadRequest, "q is required without event_id")
+		return
+	}
+
+	sharedEvents := sharedEventUsersQuery(userID)
+	query := ReadDB.Model(&User{}).
+		Select("users.id, users.email, users.invitable_by, users.id IN (?) AS shares_event", sharedEvents).
+		Where("users.id <> ?", userID).
+		Where("users.id IN (?) OR users.id IN (?)", sharedEvents, sharedOrgUsersQuery(userID)).
+		Where("COALESCE(users.findable_by, '') IN ? OR (users.findable_by = ? AND users.id IN (?))",
+			[]string{"", PrivacyEveryone}, PrivacyShared, sharedEvents).
+		Where("users.id NOT IN (?)", suspendedUsersQuery()).
+		Where("users.id NOT IN (?)", DB.Model(&InvitationReport{}).Select("reporter_id").Where("inviter_id = ?", userID))
+	if eventID != 0 {
+		query = query.Where("users.id IN (?)", DB.Model(&EventAttendee{}).Select("user_id").Where("event_id = ?", eventID))
+	}
+	order := clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "users.email"}}}}
+	if q != "" {
+		escaped := likeEscaper.Replace(q)
+		// prefix matches first, so typing a name narrows quickly; Order
+		// ignores bare expressions, so this takes a whole clause
+		query = query.Where("users.email ILIKE ?", "%"+escaped+"%")
+		order = clause.OrderBy{Expression: clause.Expr{SQL: "users.email ILIKE ? DESC, users.email ASC", Vars: []interface{}{escaped + "%"}}}
+	}
+
+	var people []personResult
+	if err := query.Order(order).Limit(maxPeopleResults).Scan(&people).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	for i := range people {
+		people[i].CanInvite = invitableWith(people[i].InvitableBy, people[i].SharesEvent)
+	}
+
+	c.JSON(http.StatusOK, people)
+}
+
+// invitableWith applies an invitable_by level knowing whether the inviter
+// shares an event with the person, sparing canInvite's query.
+func invitableWith(level string, sharesEvent bool) bool {
+	switch level {
+	case PrivacyNobody:
+		return false
+	case PrivacyShared:
+		return sharesEvent
+	default:
+		return true
+	}
+}
+
+type inviteCandidate struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	Email     string `json:"email"`
+	CanInvite bool   `json:"can_invite"`
+
+	InvitableBy string `json:"-"`
+	SharesEvent bool   `json:"-"`
+}
+
+// SearchUsers autocompletes the invite dialog: anyone findable by the
+// caller whose email, name or a word of their name starts with q. With
+// event_id, people already on that event (invited, attending or declined)
+// are left out. Results come in pages of at most maxPeopleResults.
+func SearchUsers(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < 2 {
+		jsonError(c, http.StatusBadRequest, "q must be at least 2 characters")
+		return
+	}
+	var eventID uint
+	if raw := c.Query("event_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid event_id")
+			return
+		}
+		var ev Event
+		if err := DB.First(&ev, id).Error; err != nil || !canViewEvent(ev, userID) {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		eventID = ev.ID
+	}
+	page, ok := pageParamsWithin(c, maxPeopleResults, maxPeopleResults)
+	if !ok {
+		return
+	}
+
+	prefix := likeEscaper.Replace(q) + "%"
+	sharedEvents := sharedEventUsersQuery(userID)
+	query := ReadDB.Model(&User{}).
+		Select("users.id, users.name, users.avatar_url, users.email, users.invitable_by, users.id IN (?) AS shares_event", sharedEvents).
+		Where("users.id <> ?", userID).
+		Where("users.email ILIKE ? OR users.name ILIKE ? OR users.name ILIKE ?", prefix, prefix, "% "+prefix).
+		Where("COALESCE(users.findable_by, '') IN ? OR (users.findable_by = ? AND users.id IN (?))",
+			[]string{"", PrivacyEveryone}, PrivacyShared, sharedEvents).
+		Where("users.id NOT IN (?)", suspendedUsersQuery()).
+		Where("users.id NOT IN (?)", DB.Model(&InvitationReport{}).Select("reporter_id").Where("inviter_id = ?", userID))
+	if eventID != 0 {
+		query = query.Where("users.id NOT IN (?)", DB.Model(&EventAttendee{}).Select("user_id").Where("event_id = ?", eventID))
+	}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	// an exact email first, then people the caller knows
+	var people []inviteCandidate
+	if err := query.
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "LOWER(users.email) = LOWER(?) DESC, shares_event DESC, COALESCE(NULLIF(users.name, ''), users.email) ASC, users.id ASC",
+			Vars: []interface{}{q},
+		}}).
+		Offset(page.Offset).Limit(page.Limit).Scan(&people).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	for i := range people {
+		people[i].CanInvite = invitableWith(people[i].InvitableBy, people[i].SharesEvent)
+	}
+	writePage(c, page, people, len(people))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// attendingInvitation are the invitation states of attendee rows that
+// grant access: people who weren't invited and invitations accepted. A
+// pending or declined invitation gives nothing until it is accepted.
+var attendingInvitation = []string{"", InvitationAccepted}
+
+// isEventOrganizer is true for the event owner, the owner's delegates and
+// co-organizers who accepted an invitation with role "organizer".
+func isEventOrganizer(ev Event, userID uint) bool {
+	if ev.OrganizerID == userID || isDelegateOf(ev.OrganizerID, userID) {
+		return true
+	}
+	var count int64
+	DB.Model(&EventAttendee{}).
+		Where("event_id = ? AND user_id = ? AND role = ?", ev.ID, userID, "organizer").
+		Where("invitation IN ?", attendingInvitation).
+		Count(&count)
+	return count > 0
+}
+
+// eventOrganizerIDs lists everyone isEventOrganizer is true for.
+func eventOrganizerIDs(ev Event) []uint {
+	ids := []uint{ev.OrganizerID}
+	var more []uint
+	DB.Model(&Delegation{}).Where("principal_id = ? AND revoked_at IS NULL", ev.OrganizerID).Pluck("delegate_id", &more)
+	ids = append(ids, more...)
+	more = nil
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND role = ?", ev.ID, "organizer").
+		Where("invitation IN ?", attendingInvitation).Pluck("user_id", &more)
+	return append(ids, more...)
+}
+
+// canViewEvent adds members of the owning organization for org-visible
+// events to the participants.
+func canViewEvent(ev Event, userID uint) bool {
+	if isEventParticipant(ev, userID) {
+		return true
+	}
+	return ev.Visibility == VisibilityOrg && ev.OrganizationID != nil && orgRole(*ev.OrganizationID, userID) != ""
+}
+
+// isEventParticipant is true for anyone with an event_attendees row, once
+// any invitation behind it was accepted.
+func isEventParticipant(ev Event, userID uint) bool {
+	if ev.OrganizerID == userID || isDelegateOf(ev.OrganizerID, userID) {
+		return true
+	}
+	var count int64
+	DB.Model(&EventAttendee{}).
+		Where("event_id = ? AND user_id = ?", ev.ID, userID).
+		Where("invitation IN ?", attendingInvitation).
+		Count(&count)
+	return count > 0
+}
+
+// A caller's role on an event, from most to least powerful. Routes list
+// the roles they accept rather than a minimum, since some actions are the
+// owner's alone while others are shared with delegates or co-organizers.
+const (
+	EventRoleOwner     = "owner"     // ev.OrganizerID
+	EventRoleDelegate  = "delegate"  // acts for the owner, see delegation.go
+	EventRoleOrganizer = "organizer" // co-organizer, invited with role "organizer"
+	EventRoleAttendee  = "attendee"  // any other event_attendees row, see attendingInvitation
+	EventRoleViewer    = "viewer"    // member of the owning org of an org-visible event
+)
+
+// eventParticipantRoles are the roles isEventParticipant is true for.
+var eventParticipantRoles = []string{EventRoleOwner, EventRoleDelegate, EventRoleOrganizer, EventRoleAttendee}
+
+// eventViewerRoles are the roles canViewEvent is true for.
+var eventViewerRoles = append(slices.Clone(eventParticipantRoles), EventRoleViewer)
+
+// eventOrganizerRoles are the roles isEventOrganizer is true for.
+var eventOrganizerRoles = []string{EventRoleOwner, EventRoleDelegate, EventRoleOrganizer}
+
+// eventRole returns the caller's role on the event, or "" if they have
+// none.
+func eventRole(ev Event, userID uint) string {
+	if ev.OrganizerID == userID {
+		return EventRoleOwner
+	}
+	if isDelegateOf(ev.OrganizerID, userID) {
+		return EventRoleDelegate
+	}
+	var roles []string
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND user_id = ?", ev.ID, userID).
+		Where("invitation IN ?", attendingInvitation).Pluck("role", &roles)
+	switch {
+	case slices.Contains(roles, "organizer"):
+		return EventRoleOrganizer
+	case len(roles) > 0:
+		return EventRoleAttendee
+	case ev.Visibility == VisibilityOrg && ev.OrganizationID != nil && orgRole(*ev.OrganizationID, userID) != "":
+		return EventRoleViewer
+	}
+	return ""
+}
+
+// RequireEventRole resolves the event in :id and the caller's role on it,
+// rejecting callers whose role isn't one of roles. Handlers behind it read
+// both with eventFromContext instead of loading them again.
+func RequireEventRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			jsonError(c, http.StatusUnauthorized, "unauthorized")
+			c.Abort()
+			return
+		}
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid event id")
+			c.Abort()
+			return
+		}
+
+		var ev Event
+		if err := dbFor(c).First(&ev, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				eventNotFound(c, uint(id))
+			} else {
+				jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			}
+			c.Abort()
+			return
+		}
+
+		_, span := startSpan(c.Request.Context(), "event.resolve_role")
+		role := eventRole(ev, userID)
+		span.SetAttributes(attribute.String("event.role", role))
+		span.End()
+		if !slices.Contains(roles, role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":    "your role on this event does not allow this",
+				"role":     role,
+				"required": roles,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("event", ev)
+		c.Set("event_role", role)
+		c.Next()
+	}
+}
+
+// eventFromContext returns the event and role RequireEventRole resolved.
+func eventFromContext(c *gin.Context) (Event, string) {
+	return c.MustGet("event").(Event), c.MustGet("event_role").(string)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PrivacyEveryone = "everyone"
+	PrivacyShared   = "shared"
+	PrivacyNobody   = "nobody"
+)
+
+var privacyLevels = []string{PrivacyEveryone, PrivacyShared, PrivacyNobody}
+
+func validPrivacyLevel(v string) bool {
+	return slices.Contains(privacyLevels, v)
+}
+
+// sharesEvent reports whether two users participate in at least one common event.
+func sharesEvent(a, b uint) bool {
+	var count int64
+	DB.Table("event_attendees ea1").
+		Joins("JOIN event_attendees ea2 ON ea2.event_id = ea1.event_id").
+		Where("ea1.user_id = ? AND ea2.user_id = ?", a, b).
+		Count(&count)
+	return count > 0
+}
+
+// privacyAllows applies a user's privacy level to a viewer.
+func privacyAllows(level string, viewerID, targetID uint) bool {
+	if viewerID == targetID {
+		return true
+	}
+	switch level {
+	case PrivacyNobody:
+		return false
+	case PrivacyShared:
+		return sharesEvent(viewerID, targetID)
+	default:
+		return true
+	}
+}
+
+func canInvite(inviterID uint, invitee User) bool {
+	return privacyAllows(invitee.InvitableBy, inviterID, invitee.ID)
+}
+
+func showsRSVP(u User) bool {
+	return u.ShowRSVP == nil || *u.ShowRSVP
+}
+
+// hiddenRSVPUsers returns the attendees who opted out of showing their RSVP.
+func hiddenRSVPUsers(attendees []EventAttendee) map[uint]bool {
+	ids := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		ids = append(ids, a.UserID)
+	}
+	hidden := map[uint]bool{}
+	if len(ids) == 0 {
+		return hidden
+	}
+	var users []User
+	DB.Select("id", "show_rsvp").Where("id IN ? AND show_rsvp = ?", ids, false).Find(&users)
+	for _, u := range users {
+		hidden[u.ID] = true
+	}
+	return hidden
+}
+
+// maskHiddenRSVPs blanks the status of attendees who hide their RSVP,
+// except the viewer's own. Organizers see every status; don't call it for them.
+func maskHiddenRSVPs(attendees []EventAttendee, viewerID uint) {
+	hidden := hiddenRSVPUsers(attendees)
+	for i := range attendees {
+		if hidden[attendees[i].UserID] && attendees[i].UserID != viewerID {
+			attendees[i].Status = ""
+		}
+	}
+}
+
+type PrivacySettings struct {
+	FindableBy  string `json:"findable_by"`
+	InvitableBy string `json:"invitable_by"`
+	ShowRSVP    bool   `json:"show_rsvp"`
+}
+
+type UpdatePrivacyRequest struct {
+	FindableBy  *string `json:"findable_by"`
+	InvitableBy *string `json:"invitable_by"`
+	ShowRSVP    *bool   `json:"show_rsvp"`
+}
+
+func privacyOf(u User) PrivacySettings {
+	p := PrivacySettings{
+		FindableBy:  u.FindableBy,
+		InvitableBy: u.InvitableBy,
+		ShowRSVP:    showsRSVP(u),
+	}
+	if p.FindableBy == "" {
+		p.FindableBy = PrivacyEveryone
+	}
+	if p.InvitableBy == "" {
+		p.InvitableBy = PrivacyEveryone
+	}
+	return p
+}
+
+func GetMyPrivacy(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, privacyOf(user))
+}
+
+func UpdateMyPrivacy(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body UpdatePrivacyRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.FindableBy != nil {
+		if !validPrivacyLevel(*body.FindableBy) {
+			jsonError(c, http.StatusBadRequest, "findable_by must be one of: everyone, shared, nobody")
+			return
+		}
+		updates["findable_by"] = *body.FindableBy
+	}
+	if body.InvitableBy != nil {
+		if !validPrivacyLevel(*body.InvitableBy) {
+			jsonError(c, http.StatusBadRequest, "invitable_by must be one of: everyone, shared, nobody")
+			return
+		}
+		updates["invitable_by"] = *body.InvitableBy
+	}
+	if body.ShowRSVP != nil {
+		updates["show_rsvp"] = *body.ShowRSVP
+	}
+
+	if len(updates) > 0 {
+		if err := DB.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not update privacy: "+err.Error())
+			return
+		}
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, privacyOf(user))
+}
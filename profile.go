@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A profile is what people see of each other: a name, a short bio and an
+// avatar, so lists of attendees show more than user IDs. The caller's own
+// profile adds their email, timezone and account details. Other users'
+// profiles are only served to those allowed to find them (see privacy.go).
+
+const (
+	maxProfileName = 100
+	maxProfileBio  = 1000
+)
+
+// UserSummary is the display info embedded wherever another user appears.
+type UserSummary struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+}
+
+// PublicProfile is what GET /users/:id shows of someone else.
+type PublicProfile struct {
+	UserSummary
+	Bio string `json:"bio,omitempty"`
+}
+
+// ProfileView is the caller's own profile.
+type ProfileView struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Bio       string    `json:"bio"`
+	Timezone  string    `json:"timezone"`
+	AvatarURL string    `json:"avatar_url"`
+	Country   string    `json:"country,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Plan      string    `json:"plan,omitempty"`
+	IsAdmin   bool      `json:"is_admin,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func profileView(u User) ProfileView {
+	return ProfileView{
+		ID:        u.ID,
+		Email:     u.Email,
+		Name:      u.Name,
+		Bio:       u.Bio,
+		Timezone:  u.Timezone,
+		AvatarURL: u.AvatarURL,
+		Country:   u.Country,
+		Language:  u.Language,
+		Plan:      u.Plan,
+		IsAdmin:   u.IsAdmin,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+func userSummary(u User) UserSummary {
+	return UserSummary{ID: u.ID, Name: u.Name, AvatarURL: u.AvatarURL}
+}
+
+// userSummaries loads the display info of the given users in one query.
+func userSummaries(ids []uint) map[uint]UserSummary {
+	out := map[uint]UserSummary{}
+	if len(ids) == 0 {
+		return out
+	}
+	var users []User
+	DB.Select("id", "name", "avatar_url").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		out[u.ID] = userSummary(u)
+	}
+	return out
+}
+
+func GetMe(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	c.JSON(http.StatusOK, profileView(user))
+}
+
+type UpdateProfileRequest struct {
+	Name      *string `json:"name"`
+	Bio       *string `json:"bio"`
+	Timezone  *string `json:"timezone"`   // IANA name like Europe/Lisbon, "" to clear
+	AvatarURL *string `json:"avatar_url"` // https URL, "" to clear
+}
+
+// updates validates the fields present in the request and returns the
+// columns to change.
+func (r *UpdateProfileRequest) updates() (map[string]interface{}, string) {
+	updates := map[string]interface{}{}
+	if r.Name != nil {
+		name := strings.Join(strings.Fields(*r.Name), " ")
+		if utf8.RuneCountInString(name) > maxProfileName {
+			return nil, "name must be at most 100 characters"
+		}
+		updates["name"] = name
+	}
+	if r.Bio != nil {
+		bio := strings.TrimSpace(*r.Bio)
+		if utf8.RuneCountInString(bio) > maxProfileBio {
+			return nil, "bio must be at most 1000 characters"
+		}
+		updates["bio"] = bio
+	}
+	if r.Timezone != nil {
+		tz := strings.TrimSpace(*r.Timezone)
+		if tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+				return nil, "unknown timezone"
+			}
+		}
+		updates["timezone"] = tz
+	}
+	if r.AvatarURL != nil {
+		avatar := strings.TrimSpace(*r.AvatarURL)
+		if avatar != "" {
+			// avatars are embedded in pages served over https
+			if u, err := url.Parse(avatar); err != nil || u.Scheme != "https" || u.Host == "" || len(avatar) > 2048 {
+				return nil, "avatar_url must be an https URL"
+			}
+		}
+		updates["avatar_url"] = avatar
+	}
+	return updates, ""
+}
+
+// UpdateMe changes the fields of the caller's profile present in the body.
+func UpdateMe(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body UpdateProfileRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	updates, problem := body.updates()
+	if problem != "" {
+		jsonError(c, http.StatusBadRequest, problem)
+		return
+	}
+	if len(updates) > 0 {
+		if err := DB.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "could not update profile: "+err.Error())
+			return
+		}
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	c.JSON(http.StatusOK, profileView(user))
+}
+
+// GetUserProfile shows another user's public profile, if their privacy
+// settings let the caller find them.
+func GetUserProfile(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	var user User
+	if err := DB.Select("id", "name", "bio", "avatar_url", "findable_by").First(&user, targetID).Error; err != nil ||
+		!privacyAllows(user.FindableBy, userID, user.ID) {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	c.JSON(http.StatusOK, PublicProfile{UserSummary: userSummary(user), Bio: user.Bio})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+)
+
+// Distinct statements tracked; anything past this is counted under
+// otherQueries so a stream of unique SQL can't grow the map forever.
+const (
+	maxTrackedQueries = 1000
+	otherQueries      = "(other)"
+)
+
+// QueryStat aggregates every execution of one statement shape.
+type QueryStat struct {
+	Query     string    `json:"query"`
+	Calls     int64     `json:"calls"`
+	Errors    int64     `json:"errors"`
+	SlowCalls int64     `json:"slow_calls"`
+	Rows      int64     `json:"rows"`
+	TotalMs   float64   `json:"total_ms"`
+	MeanMs    float64   `json:"mean_ms"`
+	MaxMs     float64   `json:"max_ms"`
+	Slowest   string    `json:"slowest,omitempty"` // sanitized SQL of the slowest call
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type queryStats struct {
+	mu    sync.Mutex
+	since time.Time
+	byKey map[string]*QueryStat
+}
+
+var QueryStats = &queryStats{since: time.Now(), byKey: map[string]*QueryStat{}}
+
+func (s *queryStats) record(fingerprint, sql string, elapsed time.Duration, rows int64, failed, slow bool) {
+	ms := float64(elapsed.Microseconds()) / 1000
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.byKey[fingerprint]
+	if !ok {
+		if len(s.byKey) >= maxTrackedQueries {
+			fingerprint = otherQueries
+			st = s.byKey[fingerprint]
+		}
+		if st == nil {
+			st = &QueryStat{Query: fingerprint}
+			s.byKey[fingerprint] = st
+		}
+	}
+	st.Calls++
+	st.TotalMs += ms
+	st.LastSeen = time.Now()
+	if rows > 0 {
+		st.Rows += rows
+	}
+	if failed {
+		st.Errors++
+	}
+	if slow {
+		st.SlowCalls++
+	}
+	if ms >= st.MaxMs {
+		st.MaxMs = ms
+		st.Slowest = sql
+	}
+}
+
+func (s *queryStats) snapshot() (time.Time, []QueryStat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]QueryStat, 0, len(s.byKey))
+	for _, st := range s.byKey {
+		v := *st
+		v.MeanMs = v.TotalMs / float64(v.Calls)
+		out = append(out, v)
+	}
+	return s.since, out
+}
+
+func (s *queryStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = time.Now()
+	s.byKey = map[string]*QueryStat{}
+}
+
+var (
+	sqlStringRe = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumberRe = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlInListRe = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	sqlSpaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// fingerprintSQL reduces a statement to its shape, so the same query with
+// different values (or IN lists of different lengths) aggregates together.
+func fingerprintSQL(sql string) string {
+	sql = sqlStringRe.ReplaceAllString(sql, "?")
+	sql = sqlNumberRe.ReplaceAllString(sql, "?")
+	sql = sqlInListRe.ReplaceAllString(sql, "(?)")
+	return strings.TrimSpace(sqlSpaceRe.ReplaceAllString(sql, " "))
+}
+
+// sanitizeParams keeps values that help reproduce a query (ids, numbers,
+// flags, times) and redacts text and bytes, which carry emails, password
+// hashes and tokens.
+func sanitizeParams(params []interface{}) []interface{} {
+	out := make([]interface{}, len(params))
+	for i, p := range params {
+		switch v := p.(type) {
+		case string:
+			out[i] = "<redacted " + strconv.Itoa(len(v)) + " chars>"
+		case *string:
+			if v != nil {
+				out[i] = "<redacted " + strconv.Itoa(len(*v)) + " chars>"
+			}
+		case []byte:
+			out[i] = "<redacted " + strconv.Itoa(len(v)) + " bytes>"
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+			*int, *int64, *uint, *uint64, float32, float64, bool, *bool, time.Time, *time.Time, nil:
+			out[i] = v
+		default:
+			out[i] = "<redacted>"
+		}
+	}
+	return out
+}
+
+// queryLogger feeds QueryStats and logs statements slower than the
+// threshold; everything else is left to the default GORM logger.
+type queryLogger struct {
+	base          logger.Interface
+	slowThreshold time.Duration
+}
+
+func newQueryLogger() logger.Interface {
+	base := logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		LogLevel: logger.Warn,
+		Colorful: true,
+		// slow queries are logged below, sanitized
+		SlowThreshold: 0,
+	})
+	return &queryLogger{base: base, slowThreshold: time.Duration(AppConfig.SlowQueryMs) * time.Millisecond}
+}
+
+func (l *queryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	cp := *l
+	cp.base = l.base.LogMode(level)
+	return &cp
+}
+
+func (l *queryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.base.Info(ctx, msg, args...)
+}
+
+func (l *queryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.base.Warn(ctx, msg, args...)
+}
+
+func (l *queryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.base.Error(ctx, msg, args...)
+}
+
+// ParamsFilter is called by GORM before rendering the SQL handed to Trace.
+func (l *queryLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, sanitizeParams(params)
+}
+
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	sql, rows := fc()
+	slow := l.slowThreshold > 0 && elapsed >= l.slowThreshold
+	QueryStats.record(fingerprintSQL(sql), sql, elapsed, rows, err != nil, slow)
+
+	if slow {
+		log.Printf("🐢 slow query (%.1fms, %d rows): %s", float64(elapsed.Microseconds())/1000, rows, sql)
+	}
+	l.base.Trace(ctx, begin, func() (string, int64) { return sql, rows }, err)
+}
+
+// ========================
+// ADMIN
+// ========================
+
+// GetDBStats lists aggregate query statistics since startup or the last
+// reset, worst first (?sort=total|mean|max|calls|slow, ?limit=), along
+// with connection pool usage.
+func GetDBStats(c *gin.Context) {
+	since, stats := QueryStats.snapshot()
+
+	key := map[string]func(a, b QueryStat) bool{
+		"total": func(a, b QueryStat) bool { return a.TotalMs > b.TotalMs },
+		"mean":  func(a, b QueryStat) bool { return a.MeanMs > b.MeanMs },
+		"max":   func(a, b QueryStat) bool { return a.MaxMs > b.MaxMs },
+		"calls": func(a, b QueryStat) bool { return a.Calls > b.Calls },
+		"slow":  func(a, b QueryStat) bool { return a.SlowCalls > b.SlowCalls },
+	}
+	sortBy := c.DefaultQuery("sort", "total")
+	less, ok := key[sortBy]
+	if !ok {
+		jsonError(c, http.StatusBadRequest, "sort must be one of: total, mean, max, calls, slow")
+		return
+	}
+	sort.Slice(stats, func(i, j int) bool { return less(stats[i], stats[j]) })
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		jsonError(c, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	total := len(stats)
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+
+	resp := gin.H{
+		"since":             since,
+		"slow_threshold_ms": AppConfig.SlowQueryMs,
+		"distinct_queries":  total,
+		"queries":           stats,
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		resp["pool"] = sqlDB.Stats()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ResetDBStats starts aggregation over, e.g. to measure a deploy.
+func ResetDBStats(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+	QueryStats.reset()
+	Audit(adminID, "db_stats.reset", "database", 0, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "query statistics reset"})
+}
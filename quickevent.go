@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========================
+// PARSER
+// ========================
+
+// QuickParse is the structured reading of a free-text event description.
+type QuickParse struct {
+	Title    string            `json:"title"`
+	Location string            `json:"location"`
+	Date     time.Time         `json:"date"`
+	Matched  map[string]string `json:"matched"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+var (
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+	months = map[string]time.Month{
+		"jan": time.January, "january": time.January, "feb": time.February, "february": time.February,
+		"mar": time.March, "march": time.March, "apr": time.April, "april": time.April,
+		"may": time.May, "jun": time.June, "june": time.June, "jul": time.July, "july": time.July,
+		"aug": time.August, "august": time.August, "sep": time.September, "sept": time.September,
+		"september": time.September, "oct": time.October, "october": time.October,
+		"nov": time.November, "november": time.November, "dec": time.December, "december": time.December,
+	}
+
+	reISODate  = regexp.MustCompile(`(?i)\b(?:on\s+)?(\d{4})-(\d{2})-(\d{2})\b`)
+	reRelDay   = regexp.MustCompile(`(?i)\b(today|tonight|tomorrow|day after tomorrow)\b`)
+	reInDays   = regexp.MustCompile(`(?i)\bin\s+(\d{1,3})\s+(day|days|week|weeks)\b`)
+	reWeekday  = regexp.MustCompile(`(?i)\b(?:on\s+)?(next|this)?\s*(sunday|sun|monday|mon|tuesday|tues|tue|wednesday|wed|thursday|thurs|thu|friday|fri|saturday|sat)\b`)
+	reMonthDay = regexp.MustCompile(`(?i)\b(?:on\s+)?(jan|january|feb|february|mar|march|apr|april|may|jun|june|jul|july|aug|august|sep|sept|september|oct|october|nov|november|dec|december)\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+	reDayMonth = regexp.MustCompile(`(?i)\b(?:on\s+)?(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?(jan|january|feb|february|mar|march|apr|april|may|jun|june|jul|july|aug|august|sep|sept|september|oct|october|nov|november|dec|december)\b`)
+	reClock    = regexp.MustCompile(`(?i)\b(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b`)
+	reClock24  = regexp.MustCompile(`(?i)\b(?:at\s+)?([01]?\d|2[0-3]):([0-5]\d)\b`)
+	reNamedHr  = regexp.MustCompile(`(?i)\b(?:at\s+)?(noon|midnight)\b`)
+	reLocation = regexp.MustCompile(`(?i)\s(?:at|@|in)\s+(.+)$`)
+	reSpaces   = regexp.MustCompile(`\s+`)
+)
+
+var errNoDate = errors.New("could not find a date in the text")
+
+// ParseQuickEvent reads phrases like "Dinner with team next Friday 7pm at Mario's".
+// Relative dates are resolved against now in loc.
+func ParseQuickEvent(text string, now time.Time, loc *time.Location) (QuickParse, error) {
+	now = now.In(loc)
+	out := QuickParse{Matched: map[string]string{}}
+	rest := " " + strings.TrimSpace(text) + " "
+
+	take := func(re *regexp.Regexp) []string {
+		m := re.FindStringSubmatchIndex(rest)
+		if m == nil {
+			return nil
+		}
+		groups := make([]string, len(m)/2)
+		for i := range groups {
+			if m[2*i] >= 0 {
+				groups[i] = rest[m[2*i]:m[2*i+1]]
+			}
+		}
+		rest = rest[:m[0]] + " " + rest[m[1]:]
+		return groups
+	}
+
+	// ---- date ----
+	var day time.Time
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if g := take(reISODate); g != nil {
+		y, _ := strconv.Atoi(g[1])
+		m, _ := strconv.Atoi(g[2])
+		d, _ := strconv.Atoi(g[3])
+		day = time.Date(y, time.Month(m), d, 0, 0, 0, 0, loc)
+		out.Matched["date"] = strings.TrimSpace(g[0])
+	} else if g := take(reRelDay); g != nil {
+		switch strings.ToLower(g[1]) {
+		case "today", "tonight":
+			day = today
+		case "tomorrow":
+			day = today.AddDate(0, 0, 1)
+		default:
+			day = today.AddDate(0, 0, 2)
+		}
+		out.Matched["date"] = g[0]
+	} else if g := take(reInDays); g != nil {
+		n, _ := strconv.Atoi(g[1])
+		if strings.HasPrefix(strings.ToLower(g[2]), "week") {
+			n *= 7
+		}
+		day = today.AddDate(0, 0, n)
+		out.Matched["date"] = g[0]
+	} else if g := take(reMonthDay); g != nil {
+		d, _ := strconv.Atoi(g[2])
+		day = nextMonthDay(today, months[strings.ToLower(g[1])], d)
+		out.Matched["date"] = strings.TrimSpace(g[0])
+	} else if g := take(reDayMonth); g != nil {
+		d, _ := strconv.Atoi(g[1])
+		day = nextMonthDay(today, months[strings.ToLower(g[2])], d)
+		out.Matched["date"] = strings.TrimSpace(g[0])
+	} else if g := take(reWeekday); g != nil {
+		wd := weekdays[strings.ToLower(g[2])]
+		diff := (int(wd) - int(today.Weekday()) + 7) % 7
+		// "this Friday" on a Friday is today; otherwise the next occurrence
+		if diff == 0 && !strings.EqualFold(g[1], "this") {
+			diff = 7
+		}
+		day = today.AddDate(0, 0, diff)
+		out.Matched["date"] = strings.TrimSpace(g[0])
+	}
+
+	// ---- time ----
+	hour, minute := -1, 0
+	if g := take(reClock); g != nil {
+		hour, _ = strconv.Atoi(g[1])
+		if g[2] != "" {
+			minute, _ = strconv.Atoi(g[2])
+		}
+		pm := strings.EqualFold(g[3], "pm")
+		if hour == 12 {
+			hour = 0
+		}
+		if pm {
+			hour += 12
+		}
+		out.Matched["time"] = strings.TrimSpace(g[0])
+	} else if g := take(reClock24); g != nil {
+		hour, _ = strconv.Atoi(g[1])
+		minute, _ = strconv.Atoi(g[2])
+		out.Matched["time"] = strings.TrimSpace(g[0])
+	} else if g := take(reNamedHr); g != nil {
+		hour = 12
+		if strings.EqualFold(g[1], "midnight") {
+			hour = 0
+		}
+		out.Matched["time"] = strings.TrimSpace(g[0])
+	}
+
+	if day.IsZero() {
+		if hour < 0 {
+			return out, errNoDate
+		}
+		// only a time: today if still ahead, otherwise tomorrow
+		day = today
+		if time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc).Before(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+		out.Warnings = append(out.Warnings, "no date given, assumed "+day.Format("Monday 2 January"))
+	}
+	if hour < 0 {
+		hour = 9
+		out.Warnings = append(out.Warnings, "no time given, assumed 09:00")
+	}
+	out.Date = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+
+	// ---- location & title ----
+	rest = strings.TrimSpace(reSpaces.ReplaceAllString(rest, " "))
+	if m := reLocation.FindStringSubmatchIndex(" " + rest); m != nil {
+		padded := " " + rest
+		out.Location = strings.TrimSpace(padded[m[2]:m[3]])
+		rest = strings.TrimSpace(padded[:m[0]])
+		out.Matched["location"] = out.Location
+	}
+	out.Title = strings.Trim(rest, " ,.-")
+	if out.Title == "" {
+		out.Title = "Untitled event"
+		out.Warnings = append(out.Warnings, "no title found")
+	}
+
+	return out, nil
+}
+
+// nextMonthDay picks this year's date, or next year's if it already passed.
+func nextMonthDay(today time.Time, m time.Month, d int) time.Time {
+	t := time.Date(today.Year(), m, d, 0, 0, 0, 0, today.Location())
+	if t.Before(today) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t
+}
+
+// ========================
+// HANDLER
+// ========================
+
+type QuickEventRequest struct {
+	Text     string `json:"text" binding:"required"`
+	Timezone string `json:"timezone"`
+	Confirm  bool   `json:"confirm"`
+
+	// Optional corrections applied on confirm
+	Title    *string `json:"title"`
+	Location *string `json:"location"`
+	Date     *string `json:"date"`
+}
+
+// QuickCreateEvent interprets free text. Without confirm it only returns the
+// interpretation; with confirm it creates the event (applying any corrections).
+func QuickCreateEvent(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body QuickEventRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	loc := time.UTC
+	if body.Timezone != "" {
+		l, err := time.LoadLocation(body.Timezone)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "unknown timezone")
+			return
+		}
+		loc = l
+	}
+
+	parsed, err := ParseQuickEvent(body.Text, time.Now(), loc)
+	if err != nil {
+		jsonError(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if !body.Confirm {
+		c.JSON(http.StatusOK, gin.H{
+			"interpretation": parsed,
+			"confirmed":      false,
+			"warnings":       holidayWarnings(c, userID, parsed.Date),
+		})
+		return
+	}
+
+	if body.Title != nil {
+		parsed.Title = strings.TrimSpace(*body.Title)
+	}
+	if body.Location != nil {
+		parsed.Location = strings.TrimSpace(*body.Location)
+	}
+	if body.Date != nil {
+		d, err := time.Parse(time.RFC3339, *body.Date)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid date format (use RFC3339)")
+			return
+		}
+		parsed.Date = d
+	}
+	if !parsed.Date.After(time.Now()) {
+		jsonError(c, http.StatusBadRequest, "event date must be in the future")
+		return
+	}
+
+	if err := checkEventQuota(userID); err != nil {
+		quotaError(c, "events per month", int64(effectiveLimits(userID).MaxEventsPerMonth))
+		return
+	}
+
+	ev := Event{
+		Title:       parsed.Title,
+		Location:    parsed.Location,
+		Date:        parsed.Date.UTC(),
+		OrganizerID: userID,
+	}
+	if err := saveNewEvent(&ev); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create event: "+err.Error())
+		return
+	}
+
+	view := eventView(ev, true)
+	view.Warnings = holidayWarnings(c, userID, parsed.Date)
+	c.JSON(http.StatusCreated, gin.H{"interpretation": parsed, "confirmed": true, "event": view})
+}
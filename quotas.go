@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlanLimits caps usage for an account. Zero means unlimited.
+type PlanLimits struct {
+	MaxEventsPerMonth    int   `json:"max_events_per_month"`
+	MaxAttendeesPerEvent int   `json:"max_attendees_per_event"`
+	StorageQuotaBytes    int64 `json:"storage_quota_bytes"`
+}
+
+var plans = map[string]PlanLimits{
+	"free":       {MaxEventsPerMonth: 10, MaxAttendeesPerEvent: 50, StorageQuotaBytes: 100 << 20},
+	"pro":        {MaxEventsPerMonth: 100, MaxAttendeesPerEvent: 500, StorageQuotaBytes: 5 << 30},
+	"enterprise": {},
+}
+
+var errQuotaExceeded = errors.New("plan limit reached")
+
+// LoadPlans merges PLAN_LIMITS (a JSON object of plan name to limits) over the built-ins.
+func LoadPlans() {
+	if AppConfig.PlanLimits == "" {
+		return
+	}
+	var custom map[string]PlanLimits
+	if err := json.Unmarshal([]byte(AppConfig.PlanLimits), &custom); err != nil {
+		log.Fatalf("❌ invalid PLAN_LIMITS: %v", err)
+	}
+	for name, limits := range custom {
+		plans[name] = limits
+	}
+}
+
+func planLimits(name string) PlanLimits {
+	if name == "" {
+		name = AppConfig.DefaultPlan
+	}
+	if l, ok := plans[name]; ok {
+		return l
+	}
+	return plans[AppConfig.DefaultPlan]
+}
+
+// maxLimit treats zero as unlimited.
+func maxLimit(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// effectiveLimits is the most generous combination of the user's own plan
+// and the plans of every organization they belong to.
+func effectiveLimits(userID uint) PlanLimits {
+	var user User
+	DB.Select("id", "plan").First(&user, userID)
+	limits := planLimits(user.Plan)
+
+	var orgPlans []string
+	DB.Model(&Organization{}).Where("id IN ?", userOrgIDs(userID)).Pluck("plan", &orgPlans)
+	for _, p := range orgPlans {
+		o := planLimits(p)
+		limits.MaxEventsPerMonth = int(maxLimit(int64(limits.MaxEventsPerMonth), int64(o.MaxEventsPerMonth)))
+		limits.MaxAttendeesPerEvent = int(maxLimit(int64(limits.MaxAttendeesPerEvent), int64(o.MaxAttendeesPerEvent)))
+		limits.StorageQuotaBytes = maxLimit(limits.StorageQuotaBytes, o.StorageQuotaBytes)
+	}
+	return limits
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func eventsCreatedThisMonth(userID uint) int64 {
+	var n int64
+	DB.Model(&Event{}).Where("organizer_id = ? AND created_at >= ?", userID, monthStart(time.Now())).Count(&n)
+	return n
+}
+
+func attendeeCount(eventID uint) int64 {
+	var n int64
+	DB.Model(&EventAttendee{}).Where("event_id = ?", eventID).Count(&n)
+	return n
+}
+
+// checkEventQuota fails when the user already created their monthly allowance.
+func checkEventQuota(userID uint) error {
+	limit := effectiveLimits(userID).MaxEventsPerMonth
+	if limit > 0 && eventsCreatedThisMonth(userID) >= int64(limit) {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// checkAttendeeQuota uses the organizer's plan, since they pay for the event.
+func checkAttendeeQuota(ev Event) error {
+	limit := effectiveLimits(ev.OrganizerID).MaxAttendeesPerEvent
+	if limit > 0 && attendeeCount(ev.ID) >= int64(limit) {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// reserveStorage charges n bytes to the user's storage quota.
+// Pass a negative n to release space after a delete.
+func reserveStorage(userID uint, n int64) error {
+	if n > 0 {
+		quota := effectiveLimits(userID).StorageQuotaBytes
+		if quota > 0 {
+			res := DB.Model(&User{}).
+				Where("id = ? AND storage_used_bytes + ? <= ?", userID, n, quota).
+				UpdateColumn("storage_used_bytes", gorm.Expr("storage_used_bytes + ?", n))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errQuotaExceeded
+			}
+			return nil
+		}
+	}
+	return DB.Model(&User{}).Where("id = ?", userID).
+		UpdateColumn("storage_used_bytes", gorm.Expr("GREATEST(storage_used_bytes + ?, 0)", n)).Error
+}
+
+func quotaError(c *gin.Context, what string, limit int64) {
+	c.JSON(http.StatusPaymentRequired, gin.H{
+		"error": errQuotaExceeded.Error() + ": " + what,
+		"limit": limit,
+	})
+}
+
+func GetMyUsage(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	plan := user.Plan
+	if plan == "" {
+		plan = AppConfig.DefaultPlan
+	}
+
+	// largest event the user organizes, as that's what hits the attendee cap
+	var counts []int64
+	DB.Model(&EventAttendee{}).
+		Joins("JOIN events ON events.id = event_attendees.event_id").
+		Where("events.organizer_id = ?", userID).
+		Group("event_attendees.event_id").
+		Pluck("COUNT(*)", &counts)
+	var largest int64
+	for _, n := range counts {
+		if n > largest {
+			largest = n
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plan":   plan,
+		"limits": effectiveLimits(userID),
+		"usage": gin.H{
+			"events_this_month":       eventsCreatedThisMonth(userID),
+			"largest_event_attendees": largest,
+			"storage_used_bytes":      user.StorageUsedBytes,
+		},
+	})
+}
+
+// ========================
+// ADMIN
+// ========================
+
+type SetPlanRequest struct {
+	Plan string `json:"plan" binding:"required"`
+}
+
+func setPlan(c *gin.Context, model interface{}, targetType string) {
+	adminID, _ := getUserIDFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var body SetPlanRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if _, ok := plans[body.Plan]; !ok {
+		jsonError(c, http.StatusBadRequest, "unknown plan")
+		return
+	}
+
+	res := DB.Model(model).Where("id = ?", id).Update("plan", body.Plan)
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, targetType+" not found")
+		return
+	}
+
+	Audit(adminID, "plan.update", targetType, uint(id), gin.H{"plan": body.Plan})
+
+	c.JSON(http.StatusOK, gin.H{"message": "plan updated", "plan": body.Plan})
+}
+
+func SetUserPlan(c *gin.Context) {
+	setPlan(c, &User{}, "user")
+}
+
+func SetOrganizationPlan(c *gin.Context) {
+	setPlan(c, &Organization{}, "organization")
+}
+
+func ListPlans(c *gin.Context) {
+	c.JSON(http.StatusOK, plans)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Organizers collect quotes from several vendors for a budget category and
+// compare them against its budget. Accepting one commits to that vendor:
+// the other quotes in the category are rejected, the amount is booked as
+// an expense, and a task to pay the vendor is due on the quote's deadline.
+// Only one quote per category can be accepted at a time; deleting it opens
+// the category up again, leaving its expense and task alone.
+
+const (
+	QuotePending  = "pending"
+	QuoteAccepted = "accepted"
+	QuoteRejected = "rejected"
+)
+
+var (
+	errQuoteAlreadyAccepted = errors.New("this quote is already accepted")
+	errQuoteExpired         = errors.New("this quote is no longer valid")
+	errCategoryAccepted     = errors.New("another quote is already accepted for this category")
+)
+
+type QuoteRequest struct {
+	VendorID     uint       `json:"vendor_id" binding:"required"`
+	AmountCents  int64      `json:"amount_cents" binding:"required"`
+	Description  string     `json:"description"`
+	ValidUntil   string     `json:"valid_until"`    // YYYY-MM-DD
+	PaymentDueAt *time.Time `json:"payment_due_at"` // RFC3339; when the vendor wants paying
+}
+
+func (r *QuoteRequest) apply(q *VendorQuote) error {
+	if r.AmountCents <= 0 {
+		return errors.New("amount_cents must be positive")
+	}
+	q.ValidUntil = nil
+	if r.ValidUntil != "" {
+		d, err := time.Parse("2006-01-02", r.ValidUntil)
+		if err != nil {
+			return errors.New("valid_until must be YYYY-MM-DD")
+		}
+		q.ValidUntil = &d
+	}
+	q.VendorID = r.VendorID
+	q.AmountCents = r.AmountCents
+	q.Description = strings.TrimSpace(r.Description)
+	q.PaymentDueAt = r.PaymentDueAt
+	return nil
+}
+
+// expired reports whether the quote's validity ended before today.
+func (q VendorQuote) expired(now time.Time) bool {
+	if q.ValidUntil == nil {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return q.ValidUntil.Before(today)
+}
+
+type QuoteView struct {
+	VendorQuote
+	VendorName string `json:"vendor_name"`
+	Expired    bool   `json:"expired"`
+	Cheapest   bool   `json:"cheapest"`
+	OverBudget bool   `json:"over_budget"`
+}
+
+// GetCategoryQuotes lists a category's quotes, cheapest first, next to its
+// budget.
+func GetCategoryQuotes(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	category := normalizeCategory(c.Param("category"))
+
+	var quotes []VendorQuote
+	if err := DB.Where("event_id = ? AND category = ?", ev.ID, category).
+		Order("amount_cents asc, id asc").Find(&quotes).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var budget int64
+	DB.Model(&BudgetCategory{}).Where("event_id = ? AND name = ?", ev.ID, category).Pluck("budget_cents", &budget)
+
+	vendorIDs := make([]uint, 0, len(quotes))
+	for _, q := range quotes {
+		vendorIDs = append(vendorIDs, q.VendorID)
+	}
+	var vendors []Vendor
+	if len(vendorIDs) > 0 {
+		DB.Select("id", "name").Where("id IN ?", vendorIDs).Find(&vendors)
+	}
+	names := map[uint]string{}
+	for _, v := range vendors {
+		names[v.ID] = v.Name
+	}
+
+	now := time.Now()
+	views := make([]QuoteView, 0, len(quotes))
+	var accepted *uint
+	cheapest := int64(-1)
+	for _, q := range quotes {
+		v := QuoteView{
+			VendorQuote: q,
+			VendorName:  names[q.VendorID],
+			Expired:     q.expired(now),
+			OverBudget:  budget > 0 && q.AmountCents > budget,
+		}
+		if !v.Expired && q.Status != QuoteRejected && (cheapest < 0 || q.AmountCents == cheapest) {
+			cheapest, v.Cheapest = q.AmountCents, true
+		}
+		if q.Status == QuoteAccepted {
+			accepted = &q.ID
+		}
+		views = append(views, v)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event_id":          ev.ID,
+		"category":          category,
+		"budget_cents":      budget,
+		"accepted_quote_id": accepted,
+		"quotes":            views,
+	})
+}
+
+// CreateCategoryQuote records a vendor's quote for the category.
+func CreateCategoryQuote(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body QuoteRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	q := VendorQuote{
+		EventID:     ev.ID,
+		Category:    normalizeCategory(c.Param("category")),
+		Status:      QuotePending,
+		CreatedByID: userID,
+	}
+	if err := body.apply(&q); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !eventHasVendor(ev.ID, q.VendorID) {
+		jsonError(c, http.StatusBadRequest, "vendor not found for this event")
+		return
+	}
+	if err := DB.Create(&q).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save quote: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, q)
+}
+
+func loadEventQuote(c *gin.Context, ev Event) (VendorQuote, bool) {
+	var q VendorQuote
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("quoteId"), ev.ID).First(&q).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "quote not found")
+		return q, false
+	}
+	return q, true
+}
+
+// UpdateQuote corrects a quote that hasn't been accepted yet.
+func UpdateQuote(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	q, ok := loadEventQuote(c, ev)
+	if !ok {
+		return
+	}
+	if q.Status == QuoteAccepted {
+		jsonError(c, http.StatusConflict, "an accepted quote can't be changed")
+		return
+	}
+
+	var body QuoteRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.apply(&q); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !eventHasVendor(ev.ID, q.VendorID) {
+		jsonError(c, http.StatusBadRequest, "vendor not found for this event")
+		return
+	}
+	if err := DB.Save(&q).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update quote: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+// AcceptQuote picks the quote for its category, rejecting the others, and
+// creates the expense and the payment task.
+func AcceptQuote(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	q, ok := loadEventQuote(c, ev)
+	if !ok {
+		return
+	}
+
+	var (
+		task    Task
+		expense Expense
+		other   VendorQuote
+	)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// lock the category's quotes so two organizers can't accept
+		// different ones at once
+		var quotes []VendorQuote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("event_id = ? AND category = ?", ev.ID, q.Category).Order("id").Find(&quotes).Error; err != nil {
+			return err
+		}
+		found := false
+		for _, cur := range quotes {
+			switch {
+			case cur.ID == q.ID:
+				q, found = cur, true
+			case cur.Status == QuoteAccepted:
+				other = cur
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		if q.Status == QuoteAccepted {
+			return errQuoteAlreadyAccepted
+		}
+		if other.ID != 0 {
+			return errCategoryAccepted
+		}
+		now := time.Now()
+		if q.expired(now) {
+			return errQuoteExpired
+		}
+
+		var vendor Vendor
+		if err := tx.Where("id = ? AND event_id = ?", q.VendorID, ev.ID).First(&vendor).Error; err != nil {
+			return err
+		}
+
+		description := q.Description
+		if description == "" {
+			description = "Accepted quote from " + vendor.Name
+		}
+		expense = Expense{
+			EventID:     ev.ID,
+			VendorID:    &vendor.ID,
+			Category:    q.Category,
+			Description: description,
+			AmountCents: q.AmountCents,
+			IncurredOn:  now,
+			CreatedByID: userID,
+		}
+		if err := tx.Create(&expense).Error; err != nil {
+			return err
+		}
+		task = Task{
+			EventID:     ev.ID,
+			Title:       "Pay " + vendor.Name,
+			Description: description,
+			Status:      TaskTodo,
+			Position:    nextTaskPosition(tx, ev.ID, TaskTodo),
+			VendorID:    &vendor.ID,
+			DueAt:       q.PaymentDueAt,
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityTask, task.ID, ev.ID, ChangeUpsert)
+
+		if err := tx.Model(&VendorQuote{}).
+			Where("event_id = ? AND category = ? AND id <> ? AND status = ?", ev.ID, q.Category, q.ID, QuotePending).
+			Update("status", QuoteRejected).Error; err != nil {
+			return err
+		}
+		q.Status, q.AcceptedAt, q.AcceptedByID = QuoteAccepted, &now, &userID
+		q.ExpenseID, q.TaskID = &expense.ID, &task.ID
+		if err := tx.Save(&q).Error; err != nil {
+			return err
+		}
+		return tx.Model(&vendor).Update("quote_cents", q.AmountCents).Error
+	})
+	switch {
+	case errors.Is(err, errCategoryAccepted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":             errCategoryAccepted.Error() + "; delete it first",
+			"accepted_quote_id": other.ID,
+		})
+		return
+	case errors.Is(err, errQuoteAlreadyAccepted), errors.Is(err, errQuoteExpired):
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		jsonError(c, http.StatusNotFound, "quote not found")
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "could not accept quote: "+err.Error())
+		return
+	}
+
+	broadcastTask("task_created", task)
+	Audit(userID, "quote.accept", "vendor_quote", q.ID, gin.H{"event_id": ev.ID, "category": q.Category, "amount_cents": q.AmountCents})
+	c.JSON(http.StatusOK, gin.H{"quote": q, "expense": expense, "task": taskView(task)})
+}
+
+// DeleteQuote removes a quote. An accepted quote's expense and task stay,
+// since money may already have changed hands.
+func DeleteQuote(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	q, ok := loadEventQuote(c, ev)
+	if !ok {
+		return
+	}
+
+	if err := DB.Delete(&q).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "quote deleted"})
+}
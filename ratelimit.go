@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const rateLimitWindow = time.Minute
+
+// RateBudget is what a caller has left in the current window.
+type RateBudget struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	Enforced  bool      `json:"enforced"`
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter counts requests per key in fixed one-minute windows. Counts
+// live in memory, so each instance limits independently.
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+var RateLimiter = &rateLimiter{windows: map[string]*rateWindow{}}
+
+func (l *rateLimiter) window(key string, now time.Time) *rateWindow {
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= rateLimitWindow {
+		w = &rateWindow{start: now.Truncate(rateLimitWindow)}
+		l.windows[key] = w
+	}
+	return w
+}
+
+// take counts one request and reports the budget left after it, and
+// whether the request went over.
+func (l *rateLimiter) take(key string, limit int) (RateBudget, bool) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := l.window(key, now)
+	w.count++
+	return budgetOf(w, limit), w.count > limit
+}
+
+// peek reports the budget without counting a request.
+func (l *rateLimiter) peek(key string, limit int) RateBudget {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return budgetOf(l.window(key, now), limit)
+}
+
+func budgetOf(w *rateWindow, limit int) RateBudget {
+	return RateBudget{
+		Limit:     limit,
+		Remaining: max(0, limit-w.count),
+		ResetAt:   w.start.Add(rateLimitWindow),
+		Enforced:  AppConfig.RateLimitEnforce,
+	}
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= rateLimitWindow {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// SweepRateLimits forgets windows that have already reset.
+func SweepRateLimits(ctx context.Context) {
+	now := time.Now()
+	RateLimiter.sweep(now)
+	WidgetLimiter.sweep(now)
+}
+
+// rateLimitKey buckets signed-in callers by user, so a user's budget is
+// shared across devices, and everyone else by IP. The token is only read
+// here; AuthMiddleware still decides whether it is acceptable.
+func rateLimitKey(c *gin.Context) (string, int) {
+	if raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		if token, err := jwt.Parse(raw, jwtKeyFunc); err == nil && token.Valid {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				if uid, ok := claims["user_id"].(float64); ok {
+					return userRateKey(uint(uid)), AppConfig.RateLimitPerMinute
+				}
+			}
+		}
+	}
+	return "ip:" + c.ClientIP(), AppConfig.RateLimitAnonPerMinute
+}
+
+func userRateKey(userID uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func setRateLimitHeaders(c *gin.Context, b RateBudget) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(b.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(b.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(b.ResetAt.Unix(), 10))
+}
+
+// RateLimitMiddleware advertises the caller's request budget on every
+// response. Requests over budget are only rejected when enforcement is on.
+func RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, limit := rateLimitKey(c)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		b, over := RateLimiter.take(key, limit)
+		setRateLimitHeaders(c, b)
+		if over && b.Enforced {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(b.ResetAt).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":      "rate limit exceeded",
+				"rate_limit": b,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetMyRateLimits lets integrators check their budgets without waiting for
+// a 429: the per-minute request budget and the invitation quota.
+func GetMyRateLimits(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	resp := gin.H{"invites": inviteQuota(userID)}
+	if AppConfig.RateLimitPerMinute > 0 {
+		resp["requests"] = RateLimiter.peek(userRateKey(userID), AppConfig.RateLimitPerMinute)
+	}
+	c.JSON(http.StatusOK, resp)
+}
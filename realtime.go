@@ -0,0 +1,522 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// ========================
+// HUB
+// ========================
+
+const (
+	wsSendBuffer     = 64
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 4096
+
+	// how often room permissions and the session are re-checked
+	wsRevalidateEvery = 2 * time.Minute
+
+	// application close codes
+	wsCloseSlowConsumer = 4008
+	wsCloseSessionEnded = 4001
+)
+
+// Client is one WebSocket connection. rooms is guarded by the hub's lock.
+type Client struct {
+	userID    uint
+	sessionID uint
+	expiresAt time.Time
+	conn      *websocket.Conn
+	send      chan []byte
+	rooms     map[uint]bool
+	kickOnce  sync.Once
+}
+
+// Hub tracks connections and the per-event rooms they joined.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]bool
+	rooms   map[uint]map[*Client]bool
+}
+
+var RealtimeHub = &Hub{
+	clients: map[*Client]bool{},
+	rooms:   map[uint]map[*Client]bool{},
+}
+
+// WSMessage is the envelope for every frame in both directions.
+type WSMessage struct {
+	Type    string      `json:"type"`
+	EventID uint        `json:"event_id,omitempty"`
+	UserID  uint        `json:"user_id,omitempty"`
+	State   string      `json:"state,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (h *Hub) register(cl *Client) {
+	h.mu.Lock()
+	h.clients[cl] = true
+	h.mu.Unlock()
+}
+
+func (h *Hub) unregister(cl *Client) {
+	h.mu.Lock()
+	rooms := make([]uint, 0, len(cl.rooms))
+	for eventID := range cl.rooms {
+		rooms = append(rooms, eventID)
+		if room, ok := h.rooms[eventID]; ok {
+			delete(room, cl)
+			if len(room) == 0 {
+				delete(h.rooms, eventID)
+			}
+		}
+	}
+	if _, ok := h.clients[cl]; ok {
+		delete(h.clients, cl)
+		close(cl.send)
+	}
+	h.mu.Unlock()
+
+	for _, eventID := range rooms {
+		Presence.leave(eventID, cl)
+	}
+}
+
+func (h *Hub) join(cl *Client, eventID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rooms[eventID] == nil {
+		h.rooms[eventID] = map[*Client]bool{}
+	}
+	h.rooms[eventID][cl] = true
+	cl.rooms[eventID] = true
+}
+
+func (h *Hub) leave(cl *Client, eventID uint) {
+	h.mu.Lock()
+	joined := cl.rooms[eventID]
+	delete(cl.rooms, eventID)
+	if room, ok := h.rooms[eventID]; ok {
+		delete(room, cl)
+		if len(room) == 0 {
+			delete(h.rooms, eventID)
+		}
+	}
+	h.mu.Unlock()
+
+	if joined {
+		Presence.leave(eventID, cl)
+	}
+}
+
+func (h *Hub) inRoom(cl *Client, eventID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return cl.rooms[eventID]
+}
+
+func (h *Hub) roomsOf(cl *Client) []uint {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]uint, 0, len(cl.rooms))
+	for eventID := range cl.rooms {
+		out = append(out, eventID)
+	}
+	return out
+}
+
+// BroadcastToEvent sends msg to every connection in the event's room.
+// A client whose buffer is full is disconnected rather than silently
+// missing updates; it reconnects and refetches.
+func (h *Hub) BroadcastToEvent(eventID uint, msg WSMessage) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for cl := range h.rooms[eventID] {
+		select {
+		case cl.send <- raw:
+		default:
+			cl.kick(wsCloseSlowConsumer, "too slow, reconnect and resync")
+		}
+	}
+}
+
+// BroadcastToEventUsers is BroadcastToEvent limited to the given users'
+// connections, for messages not everyone in the room may see.
+func (h *Hub) BroadcastToEventUsers(eventID uint, userIDs []uint, msg WSMessage) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	allowed := map[uint]bool{}
+	for _, id := range userIDs {
+		allowed[id] = true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for cl := range h.rooms[eventID] {
+		if !allowed[cl.userID] {
+			continue
+		}
+		select {
+		case cl.send <- raw:
+		default:
+			cl.kick(wsCloseSlowConsumer, "too slow, reconnect and resync")
+		}
+	}
+}
+
+func (cl *Client) sendJSON(msg WSMessage) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	// send is closed on unregister; hold the lock so that can't race
+	RealtimeHub.mu.RLock()
+	defer RealtimeHub.mu.RUnlock()
+	if !RealtimeHub.clients[cl] {
+		return
+	}
+	select {
+	case cl.send <- raw:
+	default:
+		cl.kick(wsCloseSlowConsumer, "too slow, reconnect and resync")
+	}
+}
+
+// kick closes the connection with a close frame; readPump then
+// unregisters the client. Safe to call from any goroutine.
+func (cl *Client) kick(code int, reason string) {
+	cl.kickOnce.Do(func() {
+		go func() {
+			cl.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+			cl.conn.Close()
+		}()
+	})
+}
+
+// ========================
+// PRESENCE
+// ========================
+
+const (
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+	PresenceLeft    = "left"
+)
+
+type presenceEntry struct {
+	UserID uint      `json:"user_id"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+}
+
+// PresenceTracker keeps who is looking at or editing each event.
+// A user with several tabs open is counted once, with their latest state.
+type PresenceTracker struct {
+	mu     sync.Mutex
+	events map[uint]map[*Client]presenceEntry
+}
+
+var Presence = &PresenceTracker{events: map[uint]map[*Client]presenceEntry{}}
+
+func (p *PresenceTracker) set(eventID uint, cl *Client, state string) []uint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.events[eventID] == nil {
+		p.events[eventID] = map[*Client]presenceEntry{}
+	}
+	p.events[eventID][cl] = presenceEntry{UserID: cl.userID, State: state, Since: time.Now()}
+
+	// other users already editing, for the concurrent edit warning
+	others := []uint{}
+	seen := map[uint]bool{}
+	for other, e := range p.events[eventID] {
+		if other != cl && e.UserID != cl.userID && e.State == PresenceEditing && !seen[e.UserID] {
+			seen[e.UserID] = true
+			others = append(others, e.UserID)
+		}
+	}
+	return others
+}
+
+func (p *PresenceTracker) leave(eventID uint, cl *Client) {
+	p.mu.Lock()
+	if room, ok := p.events[eventID]; ok {
+		delete(room, cl)
+		if len(room) == 0 {
+			delete(p.events, eventID)
+		}
+	}
+	p.mu.Unlock()
+
+	RealtimeHub.BroadcastToEvent(eventID, WSMessage{Type: "presence", EventID: eventID, UserID: cl.userID, State: PresenceLeft})
+}
+
+// List returns one entry per user, preferring "editing" over "viewing".
+func (p *PresenceTracker) List(eventID uint) []presenceEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	byUser := map[uint]presenceEntry{}
+	for _, e := range p.events[eventID] {
+		cur, ok := byUser[e.UserID]
+		if !ok || (e.State == PresenceEditing && cur.State != PresenceEditing) {
+			byUser[e.UserID] = e
+		}
+	}
+	out := make([]presenceEntry, 0, len(byUser))
+	for _, e := range byUser {
+		out = append(out, e)
+	}
+	return out
+}
+
+// ========================
+// EVENT CHANGES
+// ========================
+
+// broadcastEventUpdated pushes the event as attendees see it.
+func broadcastEventUpdated(ev Event) {
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "event_updated", EventID: ev.ID, Data: eventView(ev, false)})
+}
+
+// broadcastTask pushes a task change: "task_created", "task_updated",
+// "task_completed" or "task_deleted".
+func broadcastTask(kind string, t Task) {
+	RealtimeHub.BroadcastToEvent(t.EventID, WSMessage{Type: kind, EventID: t.EventID, Data: taskView(t)})
+}
+
+// broadcastRSVP pushes a new RSVP status with the updated headcount. A
+// status the attendee keeps hidden only reaches them and the organizers.
+func broadcastRSVP(ev Event, userID uint, status string) {
+	msg := WSMessage{Type: "rsvp", EventID: ev.ID, UserID: userID, State: status, Data: headcountOf(ev)}
+	if hiddenRSVPUsers([]EventAttendee{{UserID: userID}})[userID] {
+		RealtimeHub.BroadcastToEventUsers(ev.ID, append(eventOrganizerIDs(ev), userID), msg)
+		return
+	}
+	RealtimeHub.BroadcastToEvent(ev.ID, msg)
+}
+
+// ========================
+// HANDLERS
+// ========================
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return allowedOrigin(r.Header.Get("Origin"))
+	},
+}
+
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// ServeWS upgrades an authenticated request (JWT in the Authorization
+// header or ?access_token=). Clients then send {"type":"subscribe",
+// "event_id":N} per event; only participants are admitted to a room, and
+// the connection closes when the token expires or the session is revoked.
+// A room receives event_updated, task_*, rsvp, comment, announcement and
+// presence messages as they happen.
+func ServeWS(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("⚠️ websocket upgrade failed: %v", err)
+		return
+	}
+
+	cl := &Client{
+		userID:    userID,
+		sessionID: currentSessionID(c),
+		expiresAt: c.GetTime("token_expires_at"),
+		conn:      conn,
+		send:      make(chan []byte, wsSendBuffer),
+		rooms:     map[uint]bool{},
+	}
+	RealtimeHub.register(cl)
+
+	go cl.writePump()
+	cl.readPump()
+}
+
+func (cl *Client) readPump() {
+	defer func() {
+		RealtimeHub.unregister(cl)
+		cl.conn.Close()
+	}()
+
+	cl.conn.SetReadLimit(wsMaxMessageSize)
+	cl.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg WSMessage
+		if err := cl.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "subscribe":
+			if cl.subscribe(msg.EventID) {
+				cl.sendJSON(WSMessage{Type: "subscribed", EventID: msg.EventID})
+			}
+		case "unsubscribe":
+			RealtimeHub.leave(cl, msg.EventID)
+			cl.sendJSON(WSMessage{Type: "unsubscribed", EventID: msg.EventID})
+		case "presence":
+			cl.handlePresence(msg)
+		default:
+			cl.sendJSON(WSMessage{Type: "error", Data: "unknown message type"})
+		}
+	}
+}
+
+func (cl *Client) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	lastCheck := time.Now()
+
+	for {
+		select {
+		case raw, ok := <-cl.send:
+			cl.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				cl.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := cl.conn.WriteMessage(websocket.TextMessage, raw); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if !cl.expiresAt.IsZero() && time.Now().After(cl.expiresAt) {
+				cl.kick(wsCloseSessionEnded, "token expired")
+				return
+			}
+			if time.Since(lastCheck) >= wsRevalidateEvery {
+				lastCheck = time.Now()
+				if !cl.revalidate() {
+					return
+				}
+			}
+			cl.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := cl.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// subscribe admits the client to an event's room if they participate in it.
+func (cl *Client) subscribe(eventID uint) bool {
+	if eventID == 0 {
+		cl.sendJSON(WSMessage{Type: "error", Data: "event_id required"})
+		return false
+	}
+	if RealtimeHub.inRoom(cl, eventID) {
+		return true
+	}
+	var ev Event
+	if err := DB.First(&ev, eventID).Error; err != nil || !isEventParticipant(ev, cl.userID) {
+		cl.sendJSON(WSMessage{Type: "error", EventID: eventID, Data: "not a participant of this event"})
+		return false
+	}
+	RealtimeHub.join(cl, eventID)
+	return true
+}
+
+// revalidate drops rooms the user lost access to (removed attendee, revoked
+// delegation, deleted event) and ends the connection if its session was
+// revoked. It returns false when the connection was closed.
+func (cl *Client) revalidate() bool {
+	if cl.sessionID != 0 {
+		var n int64
+		DB.Model(&Session{}).Where("id = ? AND revoked_at IS NULL AND expires_at > ?", cl.sessionID, time.Now()).Count(&n)
+		if n == 0 {
+			cl.kick(wsCloseSessionEnded, "session revoked")
+			return false
+		}
+	}
+	if s := activeSuspension(cl.userID); s != nil && s.Mode == SuspensionBanned {
+		cl.kick(wsCloseSessionEnded, "account banned")
+		return false
+	}
+
+	for _, eventID := range RealtimeHub.roomsOf(cl) {
+		var ev Event
+		if err := DB.First(&ev, eventID).Error; err != nil || !isEventParticipant(ev, cl.userID) {
+			RealtimeHub.leave(cl, eventID)
+			cl.sendJSON(WSMessage{Type: "unsubscribed", EventID: eventID, Data: "access removed"})
+		}
+	}
+	return true
+}
+
+func (cl *Client) handlePresence(msg WSMessage) {
+	if msg.EventID == 0 {
+		cl.sendJSON(WSMessage{Type: "error", Data: "event_id required"})
+		return
+	}
+
+	if msg.State == PresenceLeft {
+		Presence.leave(msg.EventID, cl)
+		return
+	}
+	if msg.State != PresenceViewing && msg.State != PresenceEditing {
+		cl.sendJSON(WSMessage{Type: "error", Data: "state must be viewing, editing or left"})
+		return
+	}
+
+	// presence implies a subscription
+	if !cl.subscribe(msg.EventID) {
+		return
+	}
+
+	editors := Presence.set(msg.EventID, cl, msg.State)
+	RealtimeHub.BroadcastToEvent(msg.EventID, WSMessage{Type: "presence", EventID: msg.EventID, UserID: cl.userID, State: msg.State})
+
+	// tell the user before they collide with someone else's save
+	if msg.State == PresenceEditing && len(editors) > 0 {
+		cl.sendJSON(WSMessage{Type: "concurrent_edit_warning", EventID: msg.EventID, Data: gin.H{"editors": editors}})
+	}
+}
+
+func GetEventPresence(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, c.Param("id")).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can view presence")
+		return
+	}
+
+	c.JSON(http.StatusOK, Presence.List(ev.ID))
+}
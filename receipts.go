@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	RegisterJob("receipt_ocr", runReceiptOCR)
+}
+
+const (
+	OCRPending   = "pending"
+	OCRExtracted = "extracted"
+	OCRFailed    = "failed"
+	OCRConfirmed = "confirmed"
+)
+
+var receiptTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// ReceiptExtraction is what an OCR provider could read off a receipt.
+// Any field may be missing.
+type ReceiptExtraction struct {
+	AmountCents *int64     `json:"amount_cents,omitempty"`
+	Date        *time.Time `json:"date,omitempty"`
+	Merchant    string     `json:"merchant,omitempty"`
+	Text        string     `json:"-"`
+}
+
+// OCRProvider reads a receipt image.
+type OCRProvider interface {
+	ExtractReceipt(ctx context.Context, image []byte, contentType string) (ReceiptExtraction, error)
+}
+
+// httpOCR posts the raw image to OCR_ENDPOINT and expects JSON back with
+// any of amount_cents, date (YYYY-MM-DD), merchant and the raw text.
+type httpOCR struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+type httpOCRResponse struct {
+	AmountCents *int64 `json:"amount_cents"`
+	Date        string `json:"date"`
+	Merchant    string `json:"merchant"`
+	Text        string `json:"text"`
+}
+
+func (p *httpOCR) ExtractReceipt(ctx context.Context, image []byte, contentType string) (ReceiptExtraction, error) {
+	var out ReceiptExtraction
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(image))
+	if err != nil {
+		return out, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return out, fmt.Errorf("ocr provider returned %s", resp.Status)
+	}
+
+	var body httpOCRResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return out, err
+	}
+
+	// fill whatever the provider left out from its raw text
+	out = parseReceiptText(body.Text)
+	if body.AmountCents != nil {
+		out.AmountCents = body.AmountCents
+	}
+	if d, err := time.Parse("2006-01-02", body.Date); err == nil {
+		out.Date = &d
+	}
+	if m := strings.TrimSpace(body.Merchant); m != "" {
+		out.Merchant = m
+	}
+	return out, nil
+}
+
+// OCR is nil when no provider is configured; receipts are then stored
+// without extraction.
+var OCR OCRProvider
+
+func InitOCR() {
+	switch strings.ToLower(AppConfig.OCRProvider) {
+	case "":
+		return
+	case "http":
+		if AppConfig.OCREndpoint == "" {
+			log.Fatalf("❌ OCR_PROVIDER=http needs OCR_ENDPOINT")
+		}
+		OCR = &httpOCR{endpoint: AppConfig.OCREndpoint, apiKey: AppConfig.OCRAPIKey, client: newHTTPClient(60 * time.Second)}
+	default:
+		log.Fatalf("❌ unknown OCR_PROVIDER %q", AppConfig.OCRProvider)
+	}
+	log.Printf("🧾 Receipt OCR enabled (%s)", AppConfig.OCRProvider)
+}
+
+var (
+	reReceiptAmount  = regexp.MustCompile(`(\d{1,3}(?:[.,\s]\d{3})*|\d+)[.,](\d{2})\b`)
+	reReceiptISODate = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})\b`)
+	reReceiptDate    = regexp.MustCompile(`\b(\d{1,2} [A-Za-z]{3} \d{4}|[A-Za-z]{3} \d{1,2}, \d{4})\b`)
+)
+
+// parseReceiptText is a best-effort reading of plain OCR text: the amount
+// on the last "total" line (or the largest amount), the first date and
+// the first line as merchant.
+func parseReceiptText(text string) ReceiptExtraction {
+	var out ReceiptExtraction
+	lines := strings.Split(text, "\n")
+
+	var largest, total *int64
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, m := range reReceiptAmount.FindAllStringSubmatch(line, -1) {
+			whole := strings.NewReplacer(",", "", ".", "", " ", "").Replace(m[1])
+			units, err1 := strconv.ParseInt(whole, 10, 64)
+			cents, err2 := strconv.ParseInt(m[2], 10, 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			v := units*100 + cents
+			if largest == nil || v > *largest {
+				largest = &v
+			}
+			if strings.Contains(lower, "total") && !strings.Contains(lower, "subtotal") {
+				total = &v
+			}
+		}
+	}
+	if total != nil {
+		out.AmountCents = total
+	} else {
+		out.AmountCents = largest
+	}
+
+	if m := reReceiptISODate.FindString(text); m != "" {
+		if d, err := time.Parse("2006-01-02", m); err == nil {
+			out.Date = &d
+		}
+	} else if m := reReceiptDate.FindString(text); m != "" {
+		for _, layout := range []string{"2 Jan 2006", "Jan 2, 2006"} {
+			if d, err := time.Parse(layout, m); err == nil {
+				out.Date = &d
+				break
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			out.Merchant = line
+			break
+		}
+	}
+	out.Text = text
+	return out
+}
+
+type receiptOCRJob struct {
+	ExpenseID uint `json:"expense_id"`
+}
+
+func runReceiptOCR(ctx context.Context, payload []byte) error {
+	var job receiptOCRJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	if OCR == nil {
+		return nil
+	}
+
+	var e Expense
+	if err := DB.First(&e, job.ExpenseID).Error; err != nil {
+		return err
+	}
+	if e.ReceiptPath == "" || e.OCRStatus == OCRConfirmed {
+		return nil
+	}
+
+	image, err := os.ReadFile(e.ReceiptPath)
+	if err != nil {
+		return err
+	}
+
+	ex, err := OCR.ExtractReceipt(ctx, image, e.ReceiptContentType)
+	if err != nil {
+		// the job is retried; meanwhile the user can still confirm by hand
+		DB.Model(&e).Updates(map[string]interface{}{"ocr_status": OCRFailed, "ocr_error": err.Error()})
+		return err
+	}
+
+	if err := DB.Model(&e).Updates(map[string]interface{}{
+		"ocr_status":       OCRExtracted,
+		"ocr_amount_cents": ex.AmountCents,
+		"ocr_date":         ex.Date,
+		"ocr_merchant":     ex.Merchant,
+		"ocr_error":        "",
+	}).Error; err != nil {
+		return err
+	}
+
+	if e.ReceiptUploadedBy != nil {
+		NotifyTemplate(*e.ReceiptUploadedBy, "receipt_scanned", nil,
+			gin.H{"event_id": e.EventID, "expense_id": e.ID})
+	}
+	return nil
+}
+
+// removeReceipt deletes the stored receipt file and releases its storage.
+func removeReceipt(e Expense) {
+	if e.ReceiptPath == "" {
+		return
+	}
+	if err := os.Remove(e.ReceiptPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("⚠️ could not remove receipt %s: %v", e.ReceiptPath, err)
+	}
+	if e.ReceiptUploadedBy != nil {
+		reserveStorage(*e.ReceiptUploadedBy, -e.ReceiptSize)
+	}
+}
+
+func receiptView(e Expense) gin.H {
+	return gin.H{
+		"expense_id": e.ID,
+		"ocr_status": e.OCRStatus,
+		"extraction": ReceiptExtraction{AmountCents: e.OCRAmountCents, Date: e.OCRDate, Merchant: e.OCRMerchant},
+		"error":      e.OCRError,
+		"file_url":   fmt.Sprintf("/api/events/%d/expenses/%d/receipt/file", e.EventID, e.ID),
+	}
+}
+
+// loadEventExpense resolves :expenseId within an event the caller organizes.
+func loadEventExpense(c *gin.Context, userID uint) (Expense, bool) {
+	var e Expense
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return e, false
+	}
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("expenseId"), ev.ID).First(&e).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "expense not found")
+		return e, false
+	}
+	return e, true
+}
+
+// UploadReceipt attaches a receipt image to an expense and queues OCR.
+func UploadReceipt(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e, ok := loadEventExpense(c, userID)
+	if !ok {
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "could not read file")
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, allowed := receiptTypes[contentType]
+	if !allowed {
+		jsonError(c, http.StatusUnsupportedMediaType, "receipt must be a JPEG, PNG, WebP or PDF")
+		return
+	}
+
+	size := int64(len(data))
+	if err := reserveStorage(userID, size); err != nil {
+		quotaError(c, "storage", effectiveLimits(userID).StorageQuotaBytes)
+		return
+	}
+
+	name, err := randomToken(16)
+	if err != nil {
+		reserveStorage(userID, -size)
+		jsonError(c, http.StatusInternalServerError, "could not store receipt")
+		return
+	}
+	dir := filepath.Join(AppConfig.DataDir, "receipts")
+	path := filepath.Join(dir, name+ext)
+	if err := os.MkdirAll(dir, 0o750); err == nil {
+		err = os.WriteFile(path, data, 0o640)
+	}
+	if err != nil {
+		reserveStorage(userID, -size)
+		jsonError(c, http.StatusInternalServerError, "could not store receipt: "+err.Error())
+		return
+	}
+
+	previous := e
+	status := ""
+	if OCR != nil {
+		status = OCRPending
+	}
+	if err := DB.Model(&e).Updates(map[string]interface{}{
+		"receipt_path":         path,
+		"receipt_content_type": contentType,
+		"receipt_size":         size,
+		"receipt_uploaded_by":  userID,
+		"ocr_status":           status,
+		"ocr_amount_cents":     nil,
+		"ocr_date":             nil,
+		"ocr_merchant":         "",
+		"ocr_error":            "",
+	}).Error; err != nil {
+		os.Remove(path)
+		reserveStorage(userID, -size)
+		jsonError(c, http.StatusInternalServerError, "could not attach receipt: "+err.Error())
+		return
+	}
+	removeReceipt(previous)
+	DB.First(&e, e.ID)
+
+	if OCR != nil {
+		if err := Enqueue("receipt_ocr", receiptOCRJob{ExpenseID: e.ID}); err != nil {
+			log.Printf("⚠️ could not queue OCR for expense %d: %v", e.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, receiptView(e))
+}
+
+// GetReceipt returns the OCR status and extraction awaiting confirmation.
+func GetReceipt(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e, ok := loadEventExpense(c, userID)
+	if !ok {
+		return
+	}
+	if e.ReceiptPath == "" {
+		jsonError(c, http.StatusNotFound, "no receipt attached")
+		return
+	}
+	c.JSON(http.StatusOK, receiptView(e))
+}
+
+func GetReceiptFile(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e, ok := loadEventExpense(c, userID)
+	if !ok {
+		return
+	}
+	if e.ReceiptPath == "" {
+		jsonError(c, http.StatusNotFound, "no receipt attached")
+		return
+	}
+	c.Header("Content-Type", e.ReceiptContentType)
+	c.Header("Content-Disposition", "inline")
+	c.File(e.ReceiptPath)
+}
+
+type ConfirmReceiptRequest struct {
+	AmountCents *int64  `json:"amount_cents"`
+	IncurredOn  *string `json:"incurred_on"` // YYYY-MM-DD
+	Merchant    *string `json:"merchant"`
+}
+
+// ConfirmReceipt copies the extracted values, with any corrections from
+// the body, onto the expense.
+func ConfirmReceipt(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e, ok := loadEventExpense(c, userID)
+	if !ok {
+		return
+	}
+	if e.ReceiptPath == "" {
+		jsonError(c, http.StatusNotFound, "no receipt attached")
+		return
+	}
+
+	var body ConfirmReceiptRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	amount := e.OCRAmountCents
+	if body.AmountCents != nil {
+		amount = body.AmountCents
+	}
+	if amount != nil {
+		if *amount <= 0 {
+			jsonError(c, http.StatusBadRequest, "amount_cents must be positive")
+			return
+		}
+		e.AmountCents = *amount
+	}
+
+	if body.IncurredOn != nil {
+		d, err := time.Parse("2006-01-02", *body.IncurredOn)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "incurred_on must be YYYY-MM-DD")
+			return
+		}
+		e.IncurredOn = d
+	} else if e.OCRDate != nil {
+		e.IncurredOn = *e.OCRDate
+	}
+
+	if body.Merchant != nil {
+		e.Merchant = strings.TrimSpace(*body.Merchant)
+	} else if e.OCRMerchant != "" {
+		e.Merchant = e.OCRMerchant
+	}
+
+	e.OCRStatus = OCRConfirmed
+	if err := DB.Save(&e).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update expense: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Recurring events are one Event row with an RRULE (see rrule.go). Single
+// occurrences, identified by their original start, can be cancelled
+// (EventOccurrenceException) or changed (EventOccurrenceOverride); editing
+// or deleting the event itself acts on the whole series.
+
+const (
+	defaultOccurrenceWindow = 90 * 24 * time.Hour
+	maxOccurrenceWindow     = 366 * 24 * time.Hour
+	maxOccurrencesListed    = 500
+
+	// occurrences shown per series in the event listings
+	listOccurrenceWindow = 60 * 24 * time.Hour
+	maxListOccurrences   = 50
+)
+
+var errNotRecurring = errors.New("this event doesn't repeat")
+
+// eventEndsExpr is the start of an event's last occurrence, for list
+// filters: the date of a one-off event, the end of a series, or infinity
+// for a series without one.
+const eventEndsExpr = "(CASE WHEN events.recurrence_rule = '' THEN events.date ELSE COALESCE(events.series_ends_at, 'infinity'::timestamptz) END)"
+
+func recurrenceLocation(tz string) *time.Location {
+	if tz == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// eventRule is the event's rule with the start it repeats from, in the
+// series' timezone; the rule is nil for one-off events.
+func eventRule(ev Event) (*RRule, time.Time) {
+	if ev.RecurrenceRule == "" {
+		return nil, ev.Date
+	}
+	r, err := parseRRule(ev.RecurrenceRule)
+	if err != nil {
+		log.Printf("⚠️ event %d has an unreadable recurrence rule %q: %v", ev.ID, ev.RecurrenceRule, err)
+		return nil, ev.Date
+	}
+	return r, ev.Date.In(recurrenceLocation(ev.RecurrenceTimezone))
+}
+
+// seriesEnd is the last occurrence of a series starting at start, nil for
+// one without an end.
+func seriesEnd(r *RRule, start time.Time) *time.Time {
+	last, ok := r.last(start)
+	if !ok {
+		return nil
+	}
+	last = last.UTC()
+	return &last
+}
+
+// eventOver reports whether the event, every occurrence of a series, has
+// started.
+func eventOver(ev Event, now time.Time) bool {
+	if ev.RecurrenceRule == "" {
+		return ev.Date.Before(now)
+	}
+	return ev.SeriesEndsAt != nil && ev.SeriesEndsAt.Before(now)
+}
+
+// findOccurrence returns the occurrence of the series starting at t.
+func findOccurrence(ev Event, t time.Time) (time.Time, bool) {
+	r, start := eventRule(ev)
+	if r == nil {
+		return time.Time{}, false
+	}
+	t = t.Truncate(time.Second)
+	found := r.between(start, t, t.Add(time.Second), 1)
+	if len(found) == 0 {
+		return time.Time{}, false
+	}
+	return found[0], true
+}
+
+func deleteOccurrenceChanges(tx *gorm.DB, eventID uint) error {
+	if err := tx.Where("event_id = ?", eventID).Delete(&EventOccurrenceException{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("event_id = ?", eventID).Delete(&EventOccurrenceOverride{}).Error
+}
+
+// Occurrence is one instance of an event; one-off events have exactly one.
+type Occurrence struct {
+	OccursAt    time.Time `json:"occurs_at"` // original start, identifies the occurrence
+	StartsAt    time.Time `json:"starts_at"`
+	Title       string    `json:"title"`
+	Location    string    `json:"location"`
+	Description *string   `json:"description,omitempty"` // only when changed for this occurrence
+	Overridden  bool      `json:"overridden,omitempty"`
+}
+
+type occurrenceChanges struct {
+	cancelled map[int64]bool // by OccursAt in unix seconds
+	overrides map[int64]EventOccurrenceOverride
+}
+
+// loadOccurrenceChanges loads the cancellations and overrides of several
+// series in two queries.
+func loadOccurrenceChanges(eventIDs []uint) map[uint]*occurrenceChanges {
+	out := map[uint]*occurrenceChanges{}
+	for _, id := range eventIDs {
+		out[id] = &occurrenceChanges{cancelled: map[int64]bool{}, overrides: map[int64]EventOccurrenceOverride{}}
+	}
+	if len(eventIDs) == 0 {
+		return out
+	}
+	var exceptions []EventOccurrenceException
+	DB.Where("event_id IN ?", eventIDs).Find(&exceptions)
+	for _, e := range exceptions {
+		out[e.EventID].cancelled[e.OccursAt.Unix()] = true
+	}
+	var overrides []EventOccurrenceOverride
+	DB.Where("event_id IN ?", eventIDs).Find(&overrides)
+	for _, o := range overrides {
+		out[o.EventID].overrides[o.OccursAt.Unix()] = o
+	}
+	return out
+}
+
+func buildOccurrence(ev Event, t time.Time, ch *occurrenceChanges) Occurrence {
+	o := Occurrence{OccursAt: t.UTC(), StartsAt: t.UTC(), Title: ev.Title, Location: ev.Location}
+	ov, ok := ch.overrides[t.Unix()]
+	if !ok {
+		return o
+	}
+	o.Overridden = true
+	if ov.Title != nil {
+		o.Title = *ov.Title
+	}
+	if ov.Location != nil {
+		o.Location = *ov.Location
+	}
+	if ov.StartsAt != nil {
+		o.StartsAt = ov.StartsAt.UTC()
+	}
+	o.Description = ov.Description
+	return o
+}
+
+// expandOccurrences lists the occurrences of ev starting in [from, to),
+// at most limit, with cancellations and overrides applied.
+func expandOccurrences(ev Event, ch *occurrenceChanges, from, to time.Time, limit int) []Occurrence {
+	out := []Occurrence{}
+	r, start := eventRule(ev)
+	if r == nil {
+		if !ev.Date.Before(from) && ev.Date.Before(to) {
+			out = append(out, buildOccurrence(ev, ev.Date, ch))
+		}
+		return out
+	}
+
+	inWindow := func(o Occurrence) bool { return !o.StartsAt.Before(from) && o.StartsAt.Before(to) }
+	seen := map[int64]bool{}
+	for _, t := range r.between(start, from, to, limit) {
+		seen[t.Unix()] = true
+		if ch.cancelled[t.Unix()] {
+			continue
+		}
+		if o := buildOccurrence(ev, t, ch); inWindow(o) {
+			out = append(out, o)
+		}
+	}
+	// occurrences moved into the window from outside it
+	for at, ov := range ch.overrides {
+		if ov.StartsAt == nil || seen[at] || ch.cancelled[at] {
+			continue
+		}
+		if t, ok := findOccurrence(ev, ov.OccursAt); ok {
+			if o := buildOccurrence(ev, t, ch); inWindow(o) {
+				out = append(out, o)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].StartsAt.Before(out[j].StartsAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// attachOccurrences fills in the upcoming occurrences of the recurring
+// events in a listing, from the list's from parameter (or now).
+func attachOccurrences(c *gin.Context, views []EventView, events []Event) {
+	ids := []uint{}
+	for _, ev := range events {
+		if ev.RecurrenceRule != "" {
+			ids = append(ids, ev.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	from, to := time.Now(), time.Time{}
+	if raw := c.Query("from"); raw != "" {
+		if t, _, err := parseListDate(raw); err == nil {
+			from = t
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if t, dayOnly, err := parseListDate(raw); err == nil {
+			if dayOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			to = t
+		}
+	}
+	if to.IsZero() || to.Sub(from) > maxOccurrenceWindow {
+		to = from.Add(listOccurrenceWindow)
+	}
+
+	changes := loadOccurrenceChanges(ids)
+	for i, ev := range events {
+		if ch := changes[ev.ID]; ch != nil {
+			views[i].Occurrences = expandOccurrences(ev, ch, from, to, maxListOccurrences)
+		}
+	}
+}
+
+// ========================
+// SERIES
+// ========================
+
+type RecurrenceView struct {
+	Rule     string     `json:"rule"`
+	Timezone string     `json:"timezone"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"` // start of the last occurrence
+}
+
+func recurrenceView(ev Event) *RecurrenceView {
+	if ev.RecurrenceRule == "" {
+		return nil
+	}
+	tz := ev.RecurrenceTimezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	return &RecurrenceView{Rule: ev.RecurrenceRule, Timezone: tz, EndsAt: ev.SeriesEndsAt}
+}
+
+type RecurrenceRequest struct {
+	Rule     string `json:"rule"`     // RRULE such as "FREQ=WEEKLY;BYDAY=MO"; empty makes the event one-off
+	Timezone string `json:"timezone"` // IANA zone the event repeats in (keeps 19:00 at 19:00 across DST); default UTC
+}
+
+// SetEventRecurrence makes the event repeat from its date, changes how, or
+// stops it repeating. Changing the rule or timezone drops the changes made
+// to single occurrences, which may no longer exist.
+func SetEventRecurrence(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body RecurrenceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	updates := map[string]interface{}{"recurrence_rule": "", "recurrence_timezone": "", "series_ends_at": nil}
+	if strings.TrimSpace(body.Rule) != "" {
+		tz := strings.TrimSpace(body.Timezone)
+		if tz == "" {
+			tz = "UTC"
+		}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "unknown timezone")
+			return
+		}
+		r, err := parseRRule(body.Rule)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid rule: "+err.Error())
+			return
+		}
+		updates["recurrence_rule"] = r.String()
+		updates["recurrence_timezone"] = tz
+		updates["series_ends_at"] = seriesEnd(r, ev.Date.In(loc))
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if updates["recurrence_rule"] != ev.RecurrenceRule || updates["recurrence_timezone"] != ev.RecurrenceTimezone {
+			if err := deleteOccurrenceChanges(tx, ev.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update recurrence: "+err.Error())
+		return
+	}
+	Audit(userID, "event.recurrence", "event", ev.ID, gin.H{"rule": updates["recurrence_rule"], "timezone": updates["recurrence_timezone"]})
+	DB.First(&ev, ev.ID)
+
+	views := []EventView{eventView(ev, true)}
+	attachOccurrences(c, views, []Event{ev})
+	c.JSON(http.StatusOK, views[0])
+}
+
+// GetEventOccurrences lists an event's occurrences between from (default
+// now) and to (default 90 days later, at most a year).
+func GetEventOccurrences(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	from := time.Now()
+	if raw := c.Query("from"); raw != "" {
+		t, _, err := parseListDate(raw)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid from (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+		from = t
+	}
+	to := from.Add(defaultOccurrenceWindow)
+	if raw := c.Query("to"); raw != "" {
+		t, dayOnly, err := parseListDate(raw)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid to (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+		if dayOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+	if !to.After(from) || to.Sub(from) > maxOccurrenceWindow {
+		jsonError(c, http.StatusBadRequest, "to must be after from and at most a year later")
+		return
+	}
+
+	ch := loadOccurrenceChanges([]uint{ev.ID})[ev.ID]
+	c.JSON(http.StatusOK, gin.H{
+		"recurrence":  recurrenceView(ev),
+		"occurrences": expandOccurrences(ev, ch, from, to, maxOccurrencesListed),
+	})
+}
+
+// ========================
+// SINGLE OCCURRENCES
+// ========================
+
+// loadOccurrence resolves the :occursAt parameter (RFC3339) of an
+// organized recurring event.
+func loadOccurrence(c *gin.Context, userID uint) (Event, time.Time, bool) {
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return ev, time.Time{}, false
+	}
+	if ev.RecurrenceRule == "" {
+		jsonError(c, http.StatusBadRequest, errNotRecurring.Error())
+		return ev, time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, c.Param("occursAt"))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid occurrence (use its occurs_at, RFC3339)")
+		return ev, time.Time{}, false
+	}
+	at, ok := findOccurrence(ev, t)
+	if !ok {
+		jsonError(c, http.StatusNotFound, "the event has no occurrence at this time")
+		return ev, time.Time{}, false
+	}
+	return ev, at, true
+}
+
+type OccurrenceRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Location    *string `json:"location"`
+	Date        *string `json:"date"` // moves this occurrence; same formats as CreateEventRequest.Date
+}
+
+// UpdateOccurrence changes one occurrence of a series; omitted fields keep
+// what the occurrence has.
+func UpdateOccurrence(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, at, ok := loadOccurrence(c, userID)
+	if !ok {
+		return
+	}
+
+	var body OccurrenceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	var ov EventOccurrenceOverride
+	DB.Where("event_id = ? AND occurs_at = ?", ev.ID, at).First(&ov)
+	ov.EventID, ov.OccursAt, ov.UpdatedByID = ev.ID, at, userID
+	if body.Title != nil {
+		title := strings.TrimSpace(*body.Title)
+		if title == "" {
+			jsonError(c, http.StatusBadRequest, "title cannot be empty")
+			return
+		}
+		ov.Title = &title
+	}
+	if body.Description != nil {
+		ov.Description = body.Description
+	}
+	if body.Location != nil {
+		ov.Location = body.Location
+	}
+	if body.Date != nil {
+		date, err := parseEventDate(*body.Date)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		ov.StartsAt = &date
+	}
+
+	var cancelled int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		tx.Model(&EventOccurrenceException{}).Where("event_id = ? AND occurs_at = ?", ev.ID, at).Count(&cancelled)
+		if cancelled > 0 {
+			return nil
+		}
+		if err := tx.Save(&ov).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update occurrence: "+err.Error())
+		return
+	}
+	if cancelled > 0 {
+		jsonError(c, http.StatusConflict, "this occurrence is cancelled; restore it first")
+		return
+	}
+
+	ch := &occurrenceChanges{cancelled: map[int64]bool{}, overrides: map[int64]EventOccurrenceOverride{at.Unix(): ov}}
+	c.JSON(http.StatusOK, buildOccurrence(ev, at, ch))
+}
+
+// CancelOccurrence cancels one occurrence of a series.
+func CancelOccurrence(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, at, ok := loadOccurrence(c, userID)
+	if !ok {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		exc := EventOccurrenceException{EventID: ev.ID, OccursAt: at, CreatedByID: userID}
+		if err := tx.Where("event_id = ? AND occurs_at = ?", ev.ID, at).FirstOrCreate(&exc).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ? AND occurs_at = ?", ev.ID, at).Delete(&EventOccurrenceOverride{}).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not cancel occurrence: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "occurs_at": at.UTC(), "cancelled": true})
+}
+
+// RestoreOccurrence undoes the cancellation of, and any changes to, one
+// occurrence of a series.
+func RestoreOccurrence(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, at, ok := loadOccurrence(c, userID)
+	if !ok {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND occurs_at = ?", ev.ID, at).Delete(&EventOccurrenceException{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ? AND occurs_at = ?", ev.ID, at).Delete(&EventOccurrenceOverride{}).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not restore occurrence: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, buildOccurrence(ev, at, &occurrenceChanges{}))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Signing in starts a session and hands out a short-lived access token
+// with a refresh token. Each refresh token works once: POST /auth/refresh
+// trades it for a new pair and pushes the session's expiry out again. The
+// tokens of a session form a family; if one that was already traded in
+// turns up again, someone kept a copy, and the whole session is revoked.
+// Revoking the session, on logout or from the sessions list, ends both its
+// access tokens and its refresh tokens.
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var errRefreshReused = errors.New("refresh token reused")
+
+// TokenPair is what signing in and refreshing return.
+type TokenPair struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// issueRefreshToken adds a token to the session's family.
+func issueRefreshToken(tx *gorm.DB, s Session) (RefreshToken, string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+	rt := RefreshToken{
+		SessionID: s.ID,
+		UserID:    s.UserID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := tx.Create(&rt).Error; err != nil {
+		return RefreshToken{}, "", err
+	}
+	return rt, raw, nil
+}
+
+// startSessionTokens starts a session for a user who just signed in.
+func startSessionTokens(c *gin.Context, userID uint) (TokenPair, error) {
+	session, err := StartSession(c, userID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	_, refresh, err := issueRefreshToken(DB, *session)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	token, err := GenerateToken(userID, session.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{Token: token, ExpiresAt: time.Now().Add(tokenTTL), RefreshToken: refresh}, nil
+}
+
+// revokeSession ends a session along with its refresh tokens.
+func revokeSession(sessionID uint) {
+	DB.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", time.Now())
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshSession rotates a refresh token: it is used up and a new access
+// token and refresh token are returned.
+func RefreshSession(c *gin.Context) {
+	var req RefreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+
+	var rt RefreshToken
+	if err := DB.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&rt).Error; err != nil {
+		jsonError(c, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	if rt.UsedAt != nil {
+		refreshReused(c, rt)
+		return
+	}
+	now := time.Now()
+	var session Session
+	if err := DB.First(&session, rt.SessionID).Error; err != nil ||
+		session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(rt.ExpiresAt) {
+		jsonError(c, http.StatusUnauthorized, "session expired or revoked")
+		return
+	}
+	if s := activeSuspension(rt.UserID); s != nil && s.Mode == SuspensionBanned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account banned", "reason": s.Reason, "expires_at": s.ExpiresAt})
+		return
+	}
+
+	var refresh string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// claim the token, so two requests racing with it can't both
+		// rotate it
+		res := tx.Model(&RefreshToken{}).Where("id = ? AND used_at IS NULL", rt.ID).Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRefreshReused
+		}
+		next, raw, err := issueRefreshToken(tx, session)
+		if err != nil {
+			return err
+		}
+		refresh = raw
+		if err := tx.Model(&RefreshToken{}).Where("id = ?", rt.ID).Update("replaced_by_id", next.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"expires_at":   next.ExpiresAt,
+			"last_used_at": now,
+		}).Error
+	})
+	if errors.Is(err, errRefreshReused) {
+		refreshReused(c, rt)
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not refresh session: "+err.Error())
+		return
+	}
+
+	token, err := GenerateToken(rt.UserID, session.ID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+	c.JSON(http.StatusOK, TokenPair{Token: token, ExpiresAt: now.Add(tokenTTL), RefreshToken: refresh})
+}
+
+// refreshReused revokes the session of a refresh token presented after it
+// was traded in.
+func refreshReused(c *gin.Context, rt RefreshToken) {
+	revokeSession(rt.SessionID)
+	Audit(rt.UserID, "session.refresh_reuse", "session", rt.SessionID, gin.H{"ip": c.ClientIP(), "user_agent": c.Request.UserAgent()})
+	jsonError(c, http.StatusUnauthorized, "refresh token already used; the session was revoked, sign in again")
+}
+
+// Logout revokes the session of the given refresh token. Unknown tokens
+// are ignored so clients can always clear their state.
+func Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		bindError(c, "invalid request", err)
+		return
+	}
+
+	var rt RefreshToken
+	if err := DB.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&rt).Error; err == nil {
+		revokeSession(rt.SessionID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// PruneRefreshTokens drops expired refresh tokens; their sessions have
+// expired with them or moved on to newer tokens.
+func PruneRefreshTokens(ctx context.Context) {
+	if err := DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&RefreshToken{}).Error; err != nil {
+		log.Printf("⚠️ refresh token cleanup failed: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// A cancellation policy says how much of a ticket is refunded when its
+// holder gives it up: everything until FullRefundDaysBefore days before the
+// start, PartialRefundPercent after that until the start, nothing once the
+// event has begun. Events cancelled by the organizer refund in full.
+//
+// Tickets aren't paid for through the app yet, so the quote is what the
+// attendee is told and what gets audited; a payment provider's refund call
+// would take its Percent of the amount paid. For priced tiers that amount
+// comes from the voided invoice.
+
+const maxFullRefundDays = 365
+
+const (
+	RefundFull           = "full"
+	RefundPartial        = "partial"
+	RefundNone           = "none"
+	RefundEventCancelled = "event_cancelled"
+)
+
+// defaultCancellationPolicy refunds in full until the event starts.
+var defaultCancellationPolicy = CancellationPolicy{FullRefundDaysBefore: 0, PartialRefundPercent: 0}
+
+func cancellationPolicyOf(eventID uint) CancellationPolicy {
+	var p CancellationPolicy
+	if err := DB.First(&p, "event_id = ?", eventID).Error; err != nil {
+		p = defaultCancellationPolicy
+		p.EventID = eventID
+	}
+	return p
+}
+
+type RefundQuote struct {
+	Percent         int       `json:"percent"`
+	Rule            string    `json:"rule"` // full, partial, none or event_cancelled
+	FullRefundUntil time.Time `json:"full_refund_until"`
+
+	AmountCents *int64 `json:"amount_cents,omitempty"` // of the invoice total, when there was one
+	Currency    string `json:"currency,omitempty"`
+}
+
+// quoteRefund applies p to a ticket for ev given up at the given time.
+func quoteRefund(p CancellationPolicy, ev Event, at time.Time) RefundQuote {
+	q := RefundQuote{FullRefundUntil: ev.Date.AddDate(0, 0, -p.FullRefundDaysBefore)}
+	switch {
+	case ev.CancelledAt != nil:
+		q.Percent, q.Rule = 100, RefundEventCancelled
+	case at.Before(q.FullRefundUntil):
+		q.Percent, q.Rule = 100, RefundFull
+	case at.Before(ev.Date) && p.PartialRefundPercent > 0:
+		q.Percent, q.Rule = p.PartialRefundPercent, RefundPartial
+	default:
+		q.Percent, q.Rule = 0, RefundNone
+	}
+	return q
+}
+
+// ticketCancelled tells someone who just gave up their ticket what they
+// get back, and records it for the organizer. inv is the invoice voided
+// with it, if the ticket was paid for.
+func ticketCancelled(ev Event, att EventAttendee, inv *Invoice) RefundQuote {
+	q := quoteRefund(cancellationPolicyOf(ev.ID), ev, time.Now())
+	if inv != nil {
+		amount := inv.TotalCents * int64(q.Percent) / 100
+		q.AmountCents, q.Currency = &amount, inv.Currency
+	}
+	tierName := "ticket"
+	if att.TicketTierID != nil {
+		if tier, err := eventTier(ev.ID, *att.TicketTierID); err == nil {
+			tierName = tier.Name
+		}
+	}
+	vars := map[string]string{"EventTitle": ev.Title, "TierName": tierName, "RefundPercent": strconv.Itoa(q.Percent)}
+	NotifyTemplate(att.UserID, "ticket_cancelled", vars, gin.H{"event_id": ev.ID, "refund": q})
+	EmailTemplate(att.UserID, "ticket_cancelled", vars, ev)
+	audit := gin.H{"tier_id": att.TicketTierID, "refund_percent": q.Percent, "rule": q.Rule}
+	if inv != nil {
+		audit["invoice"], audit["refund_cents"], audit["currency"] = inv.Number, *q.AmountCents, q.Currency
+	}
+	Audit(att.UserID, "ticket.cancel", "event", ev.ID, audit)
+	return q
+}
+
+// ========================
+// ENDPOINTS
+// ========================
+
+// GetCancellationPolicy shows the policy, and what the caller would get
+// back giving up their ticket now.
+func GetCancellationPolicy(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var stored int64
+	DB.Model(&CancellationPolicy{}).Where("event_id = ?", ev.ID).Count(&stored)
+	p := cancellationPolicyOf(ev.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"policy":        p,
+		"default":       stored == 0,
+		"refund_if_now": quoteRefund(p, ev, time.Now()),
+	})
+}
+
+type CancellationPolicyRequest struct {
+	FullRefundDaysBefore int `json:"full_refund_days_before"` // 0 refunds in full until the start
+	PartialRefundPercent int `json:"partial_refund_percent"`  // after that; 0 for none
+}
+
+func PutCancellationPolicy(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body CancellationPolicyRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.FullRefundDaysBefore < 0 || body.FullRefundDaysBefore > maxFullRefundDays {
+		jsonError(c, http.StatusBadRequest, "full_refund_days_before must be between 0 and 365")
+		return
+	}
+	if body.PartialRefundPercent < 0 || body.PartialRefundPercent > 100 {
+		jsonError(c, http.StatusBadRequest, "partial_refund_percent must be between 0 and 100")
+		return
+	}
+
+	p := CancellationPolicy{
+		EventID:              ev.ID,
+		FullRefundDaysBefore: body.FullRefundDaysBefore,
+		PartialRefundPercent: body.PartialRefundPercent,
+		UpdatedByID:          userID,
+	}
+	if err := DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save policy: "+err.Error())
+		return
+	}
+	Audit(userID, "event.cancellation_policy", "event", ev.ID, gin.H{
+		"full_refund_days_before": p.FullRefundDaysBefore, "partial_refund_percent": p.PartialRefundPercent,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"policy": p, "default": false, "refund_if_now": quoteRefund(p, ev, time.Now())})
+}
+
+// DeleteCancellationPolicy goes back to refunding in full until the start.
+func DeleteCancellationPolicy(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := DB.Where("event_id = ?", ev.ID).Delete(&CancellationPolicy{}).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete policy: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "cancellation policy reset"})
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reminders go out by email and in-app (stored notification plus a live
+// websocket message) some minutes before an event's Date. Everyone going,
+// maybe going or organizing follows the event's reminderMinutes unless they
+// set their own. SendDueReminders runs every minute and once at startup,
+// so whatever came due while the server was down still goes out as long
+// as the event hasn't started.
+
+const (
+	ReminderAll   = "all"
+	ReminderEmail = "email"
+	ReminderPush  = "push"
+)
+
+var reminderChannels = []string{ReminderAll, ReminderEmail, ReminderPush}
+
+// remindedStatuses are the RSVPs that get reminders; organizers always do.
+var remindedStatuses = []string{"Going", "Maybe"}
+
+func remindedAttendees(tx *gorm.DB, eventID uint) *gorm.DB {
+	return tx.Model(&EventAttendee{}).Where("event_id = ? AND (status IN ? OR role = ?)", eventID, remindedStatuses, "organizer")
+}
+
+// reminderLead words an offset for the reminder subject ("in 2 hours").
+func reminderLead(minutes int) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "in 1 " + unit
+		}
+		return fmt.Sprintf("in %d %ss", n, unit)
+	}
+	switch {
+	case minutes == 0:
+		return "now"
+	case minutes%(24*60) == 0:
+		return plural(minutes/(24*60), "day")
+	case minutes%60 == 0:
+		return plural(minutes/60, "hour")
+	}
+	return plural(minutes, "minute")
+}
+
+func sendReminder(ev Event, userID uint, offset int, channel string) {
+	vars := map[string]string{
+		"EventTitle": ev.Title,
+		"StartsAt":   startsAtText(ev),
+		"Lead":       reminderLead(offset),
+		"Location":   ev.Location,
+	}
+	if channel != ReminderEmail {
+		NotifyTemplate(userID, "event_reminder", vars, gin.H{"event_id": ev.ID, "offset_minutes": offset})
+		RealtimeHub.BroadcastToEventUsers(ev.ID, []uint{userID}, WSMessage{
+			Type: "reminder", EventID: ev.ID, UserID: userID,
+			Data: gin.H{"offset_minutes": offset, "starts_at": ev.Date},
+		})
+	}
+	if channel != ReminderPush {
+		EmailTemplate(userID, "event_reminder", vars, ev)
+	}
+}
+
+// claimReminder marks one reminder sent, writing its row if the user
+// follows the event's reminders. Only the caller that flips it gets true,
+// so a reminder goes out once however many servers run the scheduler.
+func claimReminder(eventID, userID uint, offset int, channel string, now time.Time) bool {
+	r := Reminder{EventID: eventID, UserID: userID, OffsetMinutes: offset, Channel: channel, SentAt: &now}
+	res := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_id"}, {Name: "user_id"}, {Name: "offset_minutes"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"sent_at": now}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "reminders.sent_at IS NULL"}}},
+	}).Create(&r)
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+type reminderSlot struct {
+	Offset  int
+	Channel string
+}
+
+// SendDueReminders sends the reminders whose time has come for events that
+// haven't started. When several are due at once (after downtime) only the
+// one closest to the start is sent; the rest are claimed silently.
+func SendDueReminders(ctx context.Context) {
+	now := time.Now()
+	var events []Event
+	if err := DB.WithContext(ctx).
+		Where("cancelled_at IS NULL AND date > ? AND date <= ?", now, now.Add(maxReminderMinutes*time.Minute)).
+		Find(&events).Error; err != nil {
+		log.Printf("⚠️ event reminders failed: %v", err)
+		return
+	}
+
+	for _, ev := range events {
+		if ctx.Err() != nil {
+			return
+		}
+		minutesLeft := int(ev.Date.Sub(now) / time.Minute)
+
+		var attendees []EventAttendee
+		remindedAttendees(DB, ev.ID).Find(&attendees)
+		if len(attendees) == 0 {
+			continue
+		}
+
+		// rows due by now: reminders people set themselves, and what was sent
+		var rows []Reminder
+		DB.Where("event_id = ? AND offset_minutes >= ?", ev.ID, minutesLeft).Find(&rows)
+		own := map[uint][]reminderSlot{}
+		sent := map[uint][]int{}
+		for _, r := range rows {
+			if r.SentAt != nil {
+				sent[r.UserID] = append(sent[r.UserID], r.OffsetMinutes)
+			} else {
+				own[r.UserID] = append(own[r.UserID], reminderSlot{r.OffsetMinutes, r.Channel})
+			}
+		}
+
+		defaults := []reminderSlot{}
+		for _, m := range reminderMinutes(ev) {
+			if m >= minutesLeft {
+				defaults = append(defaults, reminderSlot{m, ReminderAll})
+			}
+		}
+
+		for _, a := range attendees {
+			due := own[a.UserID]
+			if !a.CustomReminders {
+				due = slices.DeleteFunc(slices.Clone(defaults), func(s reminderSlot) bool { return slices.Contains(sent[a.UserID], s.Offset) })
+			}
+			if len(due) == 0 {
+				continue
+			}
+			closest := slices.MinFunc(due, func(x, y reminderSlot) int { return x.Offset - y.Offset })
+			if claimReminder(ev.ID, a.UserID, closest.Offset, closest.Channel, now) {
+				sendReminder(ev, a.UserID, closest.Offset, closest.Channel)
+			}
+			for _, s := range due {
+				if s.Offset != closest.Offset {
+					claimReminder(ev.ID, a.UserID, s.Offset, s.Channel, now)
+				}
+			}
+		}
+	}
+}
+
+// rearmReminders lets reminders that were sent go out again relative to a
+// new start date, for those whose time hasn't come yet.
+func rearmReminders(tx *gorm.DB, eventID uint, date time.Time) error {
+	minutesLeft := int(time.Until(date) / time.Minute)
+	return tx.Model(&Reminder{}).Where("event_id = ? AND offset_minutes < ?", eventID, minutesLeft).Update("sent_at", nil).Error
+}
+
+// ========================
+// ENDPOINTS
+// ========================
+
+type reminderView struct {
+	OffsetMinutes int        `json:"offset_minutes"`
+	Channel       string     `json:"channel"`
+	FiresAt       time.Time  `json:"fires_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// userReminders are the reminders att gets for ev, latest first.
+func userReminders(ev Event, att EventAttendee) []reminderView {
+	var rows []Reminder
+	DB.Where("event_id = ? AND user_id = ?", ev.ID, att.UserID).Find(&rows)
+	byOffset := map[int]Reminder{}
+	for _, r := range rows {
+		byOffset[r.OffsetMinutes] = r
+	}
+
+	var slots []reminderSlot
+	if att.CustomReminders {
+		for _, r := range rows {
+			slots = append(slots, reminderSlot{r.OffsetMinutes, r.Channel})
+		}
+	} else {
+		for _, m := range reminderMinutes(ev) {
+			slots = append(slots, reminderSlot{m, ReminderAll})
+		}
+	}
+	slices.SortFunc(slots, func(x, y reminderSlot) int { return y.Offset - x.Offset })
+
+	out := make([]reminderView, 0, len(slots))
+	for _, s := range slots {
+		out = append(out, reminderView{
+			OffsetMinutes: s.Offset,
+			Channel:       s.Channel,
+			FiresAt:       ev.Date.Add(-time.Duration(s.Offset) * time.Minute),
+			SentAt:        byOffset[s.Offset].SentAt,
+		})
+	}
+	return out
+}
+
+// loadMyAttendance is the caller's attendee row on ev, writing a 404 when
+// they have none.
+func loadMyAttendance(c *gin.Context, ev Event, userID uint) (EventAttendee, bool) {
+	var att EventAttendee
+	if err := DB.Where("event_id = ? AND user_id = ?", ev.ID, userID).First(&att).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "you are not on this event")
+		return att, false
+	}
+	return att, true
+}
+
+func GetMyEventReminders(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	att, ok := loadMyAttendance(c, ev, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "default": !att.CustomReminders, "reminders": userReminders(ev, att)})
+}
+
+type MyRemindersRequest struct {
+	MinutesBefore []int  `json:"minutes_before"` // empty list turns reminders off
+	Channel       string `json:"channel"`        // all (default), email or push
+	UseDefault    bool   `json:"use_default"`    // follow the event's reminders
+}
+
+// SetMyEventReminders replaces the caller's reminders for an event.
+// Reminders already sent for an offset aren't sent again.
+func SetMyEventReminders(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	att, ok := loadMyAttendance(c, ev, userID)
+	if !ok {
+		return
+	}
+
+	var body MyRemindersRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.Channel == "" {
+		body.Channel = ReminderAll
+	}
+	if !slices.Contains(reminderChannels, body.Channel) {
+		jsonError(c, http.StatusBadRequest, "channel must be one of: all, email, push")
+		return
+	}
+	var mins []int
+	if !body.UseDefault {
+		var err error
+		if mins, err = normalizeReminderMinutes(body.MinutesBefore); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		// going back to the event's reminders keeps the record of what was
+		// sent; choosing your own keeps only the offsets chosen
+		drop := tx.Where("event_id = ? AND user_id = ?", ev.ID, userID)
+		if body.UseDefault {
+			drop = drop.Where("sent_at IS NULL")
+		} else if len(mins) > 0 {
+			drop = drop.Where("offset_minutes NOT IN ?", mins)
+		}
+		if err := drop.Delete(&Reminder{}).Error; err != nil {
+			return err
+		}
+		for _, m := range mins {
+			r := Reminder{EventID: ev.ID, UserID: userID, OffsetMinutes: m, Channel: body.Channel}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "event_id"}, {Name: "user_id"}, {Name: "offset_minutes"}},
+				DoUpdates: clause.AssignmentColumns([]string{"channel"}),
+			}).Create(&r).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&EventAttendee{}).Where("id = ?", att.ID).Update("custom_reminders", !body.UseDefault).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update reminders: "+err.Error())
+		return
+	}
+	att.CustomReminders = !body.UseDefault
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "default": !att.CustomReminders, "reminders": userReminders(ev, att)})
+}
+
+type upcomingReminders struct {
+	EventID   uint           `json:"event_id"`
+	Title     string         `json:"title"`
+	StartsAt  time.Time      `json:"starts_at"`
+	Default   bool           `json:"default"`
+	Reminders []reminderView `json:"reminders"`
+}
+
+// GetMyReminders lists the caller's reminders for events still to come,
+// soonest event first.
+func GetMyReminders(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q, page, ok := paginate(c, DB.Model(&Event{}).
+		Where("cancelled_at IS NULL AND date > ?", time.Now()).
+		Where("id IN (?)", DB.Model(&EventAttendee{}).Select("event_id").
+			Where("user_id = ? AND (status IN ? OR role = ?)", userID, remindedStatuses, "organizer")).
+		Order("date asc, id asc"))
+	if !ok {
+		return
+	}
+	var events []Event
+	if err := q.Find(&events).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	var atts []EventAttendee
+	if len(ids) > 0 {
+		DB.Where("event_id IN ? AND user_id = ?", ids, userID).Find(&atts)
+	}
+	byEvent := map[uint]EventAttendee{}
+	for _, a := range atts {
+		byEvent[a.EventID] = a
+	}
+
+	out := make([]upcomingReminders, 0, len(events))
+	for _, ev := range events {
+		att := byEvent[ev.ID]
+		out = append(out, upcomingReminders{
+			EventID:   ev.ID,
+			Title:     ev.Title,
+			StartsAt:  ev.Date,
+			Default:   !att.CustomReminders,
+			Reminders: userReminders(ev, att),
+		})
+	}
+	writePage(c, page, out, len(out))
+}
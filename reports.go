@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The comparison report lines up a few of an organizer's events side by
+// side: attendance, costs and how tasks were completed in the run-up. Every
+// section keeps the events in the order they were asked for, and the task
+// timeline is sampled at the same days before each start, so the arrays
+// can be charted against one another directly.
+
+const maxCompareEvents = 10
+
+// compareTimelineDays are the days before the start the task timeline is
+// sampled at.
+var compareTimelineDays = []int{90, 60, 30, 14, 7, 3, 1, 0}
+
+type compareEvent struct {
+	ID     uint      `json:"id"`
+	Title  string    `json:"title"`
+	Date   time.Time `json:"date"`
+	IsPast bool      `json:"is_past"`
+}
+
+type compareAttendance struct {
+	EventID     uint    `json:"event_id"`
+	Invited     int64   `json:"invited"`
+	Going       int64   `json:"going"`
+	Maybe       int64   `json:"maybe"`
+	NotGoing    int64   `json:"not_going"`
+	NoAnswer    int64   `json:"no_answer"`
+	Waitlisted  int64   `json:"waitlisted"`
+	GuestsGoing int64   `json:"guests_going"`
+	CheckedIn   int64   `json:"checked_in"`
+	GoingRate   float64 `json:"going_rate"`    // going / invited
+	CheckInRate float64 `json:"check_in_rate"` // checked in / going
+}
+
+type compareCosts struct {
+	EventID          uint             `json:"event_id"`
+	BudgetCents      int64            `json:"budget_cents"`
+	SpentCents       int64            `json:"spent_cents"`
+	PerAttendeeCents int64            `json:"per_attendee_cents"` // spent per person going
+	ByCategory       map[string]int64 `json:"by_category"`        // every category in the report, 0 when unused
+}
+
+type compareTasks struct {
+	EventID    uint      `json:"event_id"`
+	Total      int       `json:"total"`
+	Done       int       `json:"done"`
+	DoneOnTime int       `json:"done_on_time"` // completed by their due date
+	Timeline   []float64 `json:"timeline"`     // share done at each of timeline_days before the start
+}
+
+// parseEventIDs reads a comma separated list of ids, in order, without repeats.
+func parseEventIDs(raw string) ([]uint, error) {
+	ids := []uint{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid event id %q", part)
+		}
+		if !slices.Contains(ids, uint(id)) {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, nil
+}
+
+// shareOf is n/of to three decimals, 0 when of is.
+func shareOf(n, of int64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(of)*1000) / 1000
+}
+
+func compareAttendanceOf(ids []uint) map[uint]*compareAttendance {
+	out := map[uint]*compareAttendance{}
+	for _, id := range ids {
+		out[id] = &compareAttendance{EventID: id}
+	}
+
+	var rows []struct {
+		EventID   uint
+		Status    string
+		Total     int64
+		CheckedIn int64
+	}
+	ReadDB.Model(&EventAttendee{}).
+		Select("event_id, status, COUNT(*) AS total, COUNT(checked_in_at) AS checked_in").
+		Where("event_id IN ? AND role <> ?", ids, "organizer").
+		Group("event_id, status").Scan(&rows)
+	for _, r := range rows {
+		a := out[r.EventID]
+		a.Invited += r.Total
+		a.CheckedIn += r.CheckedIn
+		switch r.Status {
+		case "Going":
+			a.Going = r.Total
+		case "Maybe":
+			a.Maybe = r.Total
+		case "Not Going":
+			a.NotGoing = r.Total
+		case StatusWaitlisted:
+			a.Waitlisted = r.Total
+		case "":
+			a.NoAnswer = r.Total
+		}
+	}
+
+	var guests []struct {
+		EventID uint
+		Total   int64
+	}
+	ReadDB.Model(&GuestRSVP{}).Select("event_id, COUNT(*) AS total").
+		Where("event_id IN ? AND status = ?", ids, "Going").Group("event_id").Scan(&guests)
+	for _, g := range guests {
+		out[g.EventID].GuestsGoing = g.Total
+	}
+
+	for _, a := range out {
+		a.GoingRate = shareOf(a.Going, a.Invited)
+		a.CheckInRate = shareOf(a.CheckedIn, a.Going)
+	}
+	return out
+}
+
+func compareCostsOf(ids []uint, attendance map[uint]*compareAttendance) (map[uint]*compareCosts, []string) {
+	out := map[uint]*compareCosts{}
+	for _, id := range ids {
+		out[id] = &compareCosts{EventID: id, ByCategory: map[string]int64{}}
+	}
+	seen := map[string]bool{}
+
+	var budgets []struct {
+		EventID uint
+		Total   int64
+	}
+	ReadDB.Model(&BudgetCategory{}).Select("event_id, SUM(budget_cents) AS total").
+		Where("event_id IN ?", ids).Group("event_id").Scan(&budgets)
+	for _, b := range budgets {
+		out[b.EventID].BudgetCents = b.Total
+	}
+
+	var spent []struct {
+		EventID  uint
+		Category string
+		Total    int64
+	}
+	ReadDB.Model(&Expense{}).Select("event_id, category, SUM(amount_cents) AS total").
+		Where("event_id IN ?", ids).Group("event_id, category").Scan(&spent)
+	for _, s := range spent {
+		cat := normalizeCategory(s.Category)
+		seen[cat] = true
+		out[s.EventID].ByCategory[cat] += s.Total
+		out[s.EventID].SpentCents += s.Total
+	}
+
+	categories := make([]string, 0, len(seen))
+	for cat := range seen {
+		categories = append(categories, cat)
+	}
+	sort.Strings(categories)
+	for _, c := range out {
+		for _, cat := range categories {
+			if _, ok := c.ByCategory[cat]; !ok {
+				c.ByCategory[cat] = 0
+			}
+		}
+		if going := attendance[c.EventID].Going + attendance[c.EventID].GuestsGoing; going > 0 {
+			c.PerAttendeeCents = c.SpentCents / going
+		}
+	}
+	return out, categories
+}
+
+func compareTasksOf(events []Event) map[uint]*compareTasks {
+	out := map[uint]*compareTasks{}
+	dates := map[uint]time.Time{}
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		out[ev.ID] = &compareTasks{EventID: ev.ID, Timeline: make([]float64, len(compareTimelineDays))}
+		dates[ev.ID] = ev.Date
+		ids = append(ids, ev.ID)
+	}
+
+	var tasks []Task
+	ReadDB.Select("id", "event_id", "status", "completed_at", "due_at").Where("event_id IN ?", ids).Find(&tasks)
+	done := map[uint][]time.Time{}
+	for _, t := range tasks {
+		ct := out[t.EventID]
+		ct.Total++
+		if t.Status != TaskDone || t.CompletedAt == nil {
+			continue
+		}
+		ct.Done++
+		due := dates[t.EventID]
+		if t.DueAt != nil {
+			due = *t.DueAt
+		}
+		if !t.CompletedAt.After(due) {
+			ct.DoneOnTime++
+		}
+		done[t.EventID] = append(done[t.EventID], *t.CompletedAt)
+	}
+
+	for id, ct := range out {
+		if ct.Total == 0 {
+			continue
+		}
+		for i, days := range compareTimelineDays {
+			at := dates[id].AddDate(0, 0, -days)
+			var n int64
+			for _, completed := range done[id] {
+				if !completed.After(at) {
+					n++
+				}
+			}
+			ct.Timeline[i] = shareOf(n, int64(ct.Total))
+		}
+	}
+	return out
+}
+
+// GetCompareReport compares up to ten events the caller organizes,
+// given as ?event_ids=1,2,3.
+func GetCompareReport(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ids, err := parseEventIDs(c.Query("event_ids"))
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(ids) < 2 || len(ids) > maxCompareEvents {
+		jsonError(c, http.StatusBadRequest, fmt.Sprintf("event_ids must list between 2 and %d events", maxCompareEvents))
+		return
+	}
+
+	var found []Event
+	if err := ReadDB.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	byID := map[uint]Event{}
+	for _, ev := range found {
+		byID[ev.ID] = ev
+	}
+	events := make([]Event, 0, len(ids))
+	for _, id := range ids {
+		ev, ok := byID[id]
+		if !ok || !isEventOrganizer(ev, userID) {
+			jsonError(c, http.StatusNotFound, fmt.Sprintf("event %d not found among the events you organize", id))
+			return
+		}
+		events = append(events, ev)
+	}
+
+	attendance := compareAttendanceOf(ids)
+	costs, categories := compareCostsOf(ids, attendance)
+	tasks := compareTasksOf(events)
+
+	now := time.Now()
+	out := struct {
+		Events       []compareEvent      `json:"events"`
+		Attendance   []compareAttendance `json:"attendance"`
+		Costs        []compareCosts      `json:"costs"`
+		Categories   []string            `json:"categories"`
+		Tasks        []compareTasks      `json:"tasks"`
+		TimelineDays []int               `json:"timeline_days"`
+	}{Categories: categories, TimelineDays: compareTimelineDays}
+	for _, ev := range events {
+		out.Events = append(out.Events, compareEvent{ID: ev.ID, Title: ev.Title, Date: ev.Date, IsPast: ev.Date.Before(now)})
+		out.Attendance = append(out.Attendance, *attendance[ev.ID])
+		out.Costs = append(out.Costs, *costs[ev.ID])
+		out.Tasks = append(out.Tasks, *tasks[ev.ID])
+	}
+	c.JSON(http.StatusOK, out)
+}
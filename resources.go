@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Organizations keep a catalogue of resources their events can book for a
+// time window. A resource is booked at most once at any moment: a booking
+// overlapping another is rejected, checked under a lock on the resource
+// so two organizers can't both take the last slot. Bookings of cancelled
+// events no longer hold the resource.
+
+var resourceKinds = []string{"room", "vehicle", "equipment", "other"}
+
+const (
+	maxBookingLength    = 31 * 24 * time.Hour
+	maxAvailabilitySpan = 92 * 24 * time.Hour
+)
+
+var errResourceBooked = errors.New("the resource is already booked at this time")
+
+type ResourceRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+
+	Accessibility Accessibility `json:"accessibility"`
+}
+
+func (r *ResourceRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 200 {
+		return fmt.Errorf("name must be 1-200 characters")
+	}
+	if r.Kind == "" {
+		r.Kind = "other"
+	}
+	if !slices.Contains(resourceKinds, r.Kind) {
+		return fmt.Errorf("kind must be one of %s", strings.Join(resourceKinds, ", "))
+	}
+	if len(r.Description) > 2000 {
+		return fmt.Errorf("description is too long")
+	}
+	return r.Accessibility.validate()
+}
+
+// activeBookings are the bookings still holding their resource.
+func activeBookings(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&ResourceBooking{}).
+		Joins("JOIN events ON events.id = resource_bookings.event_id AND events.deleted_at IS NULL AND events.cancelled_at IS NULL")
+}
+
+// overlapping narrows bookings to those overlapping [from, to).
+func overlapping(q *gorm.DB, from, to time.Time) *gorm.DB {
+	return q.Where("resource_bookings.starts_at < ? AND resource_bookings.ends_at > ?", to, from)
+}
+
+// parseWindow reads ?from and ?to (RFC3339); ok is false once it has
+// answered with an error. Both missing means no window.
+func parseWindow(c *gin.Context) (from, to time.Time, set, ok bool) {
+	rawFrom, rawTo := c.Query("from"), c.Query("to")
+	if rawFrom == "" && rawTo == "" {
+		return from, to, false, true
+	}
+	var err1, err2 error
+	from, err1 = time.Parse(time.RFC3339, rawFrom)
+	to, err2 = time.Parse(time.RFC3339, rawTo)
+	if err1 != nil || err2 != nil {
+		jsonError(c, http.StatusBadRequest, "from and to must both be RFC3339 times")
+		return from, to, false, false
+	}
+	if !to.After(from) || to.Sub(from) > maxAvailabilitySpan {
+		jsonError(c, http.StatusBadRequest, "to must be after from and at most 92 days later")
+		return from, to, false, false
+	}
+	return from, to, true, true
+}
+
+type resourceView struct {
+	Resource
+	Available *bool             `json:"available,omitempty"` // for ?from and ?to
+	Busy      []ResourceBooking `json:"busy,omitempty"`      // bookings in that window
+}
+
+// GetOrganizationResources lists the organization's resources. Given
+// ?from and ?to, each says whether it is free for the whole window and
+// which bookings are in the way.
+func GetOrganizationResources(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return
+	}
+	from, to, windowed, ok := parseWindow(c)
+	if !ok {
+		return
+	}
+
+	var resources []Resource
+	q := DB.Where("organization_id = ?", orgID)
+	if kind := c.Query("kind"); kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if raw := c.Query("accessibility"); raw != "" {
+		var err error
+		if q, err = withAccessibility(q, "resources", raw); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := q.Order("name asc, id asc").Find(&resources).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	busy := map[uint][]ResourceBooking{}
+	if windowed && len(resources) > 0 {
+		ids := make([]uint, 0, len(resources))
+		for _, r := range resources {
+			ids = append(ids, r.ID)
+		}
+		var bookings []ResourceBooking
+		overlapping(activeBookings(DB), from, to).Where("resource_bookings.resource_id IN ?", ids).
+			Order("resource_bookings.starts_at asc").Find(&bookings)
+		for _, b := range bookings {
+			busy[b.ResourceID] = append(busy[b.ResourceID], b)
+		}
+	}
+
+	out := make([]resourceView, 0, len(resources))
+	for _, r := range resources {
+		v := resourceView{Resource: r}
+		if windowed {
+			free := len(busy[r.ID]) == 0
+			v.Available, v.Busy = &free, busy[r.ID]
+		}
+		out = append(out, v)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// loadOrgResource resolves :resourceId within the organization.
+func loadOrgResource(c *gin.Context, orgID uint) (Resource, bool) {
+	var r Resource
+	if err := DB.Where("id = ? AND organization_id = ?", c.Param("resourceId"), orgID).First(&r).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "resource not found")
+		return r, false
+	}
+	return r, true
+}
+
+// orgResourceAdmin resolves :id for admins, who manage the catalogue.
+func orgResourceAdmin(c *gin.Context, userID uint) (uint, bool) {
+	orgID, ok := orgMemberParam(c, userID)
+	if !ok {
+		return 0, false
+	}
+	if !isOrgAdmin(orgID, userID) {
+		jsonError(c, http.StatusForbidden, "only organization admins can manage resources")
+		return 0, false
+	}
+	return orgID, true
+}
+
+func CreateOrganizationResource(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgResourceAdmin(c, userID)
+	if !ok {
+		return
+	}
+
+	var body ResourceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r := Resource{OrganizationID: orgID, Name: body.Name, Kind: body.Kind, Description: body.Description, Accessibility: body.Accessibility}
+	if err := DB.Create(&r).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create resource: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+func UpdateOrganizationResource(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgResourceAdmin(c, userID)
+	if !ok {
+		return
+	}
+	r, ok := loadOrgResource(c, orgID)
+	if !ok {
+		return
+	}
+
+	var body ResourceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r.Name, r.Kind, r.Description, r.Accessibility = body.Name, body.Kind, body.Description, body.Accessibility
+	if err := DB.Save(&r).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update resource: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// DeleteOrganizationResource removes a resource with its bookings; the
+// organizers holding upcoming ones are told.
+func DeleteOrganizationResource(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgResourceAdmin(c, userID)
+	if !ok {
+		return
+	}
+	r, ok := loadOrgResource(c, orgID)
+	if !ok {
+		return
+	}
+
+	var upcoming []ResourceBooking
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		activeBookings(tx).Where("resource_bookings.resource_id = ? AND resource_bookings.ends_at > ?", r.ID, time.Now()).
+			Find(&upcoming)
+		if err := tx.Where("resource_id = ?", r.ID).Delete(&ResourceBooking{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&r).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete resource: "+err.Error())
+		return
+	}
+
+	Audit(userID, "resource.delete", "resource", r.ID, gin.H{"organization_id": orgID, "name": r.Name, "bookings_dropped": len(upcoming)})
+	notified := map[uint]bool{}
+	for _, b := range upcoming {
+		var ev Event
+		if DB.First(&ev, b.EventID).Error != nil || notified[ev.ID] {
+			continue
+		}
+		notified[ev.ID] = true
+		NotifyTemplate(ev.OrganizerID, "resource_booking_dropped",
+			map[string]string{"EventTitle": ev.Title, "Resource": r.Name},
+			gin.H{"event_id": ev.ID, "resource_id": r.ID})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "resource deleted", "bookings_dropped": len(upcoming)})
+}
+
+type BookResourceRequest struct {
+	ResourceID uint      `json:"resource_id" binding:"required"`
+	StartsAt   time.Time `json:"starts_at" binding:"required"`
+	EndsAt     time.Time `json:"ends_at" binding:"required"`
+	TaskID     *uint     `json:"task_id"`
+	Note       string    `json:"note"`
+}
+
+func (r *BookResourceRequest) validate() error {
+	if !r.EndsAt.After(r.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if r.EndsAt.Sub(r.StartsAt) > maxBookingLength {
+		return fmt.Errorf("a booking can last at most 31 days")
+	}
+	if !r.EndsAt.After(time.Now()) {
+		return fmt.Errorf("ends_at must be in the future")
+	}
+	if len(r.Note) > 500 {
+		return fmt.Errorf("note is too long")
+	}
+	return nil
+}
+
+type bookingView struct {
+	ResourceBooking
+	ResourceName string `json:"resource_name"`
+	ResourceKind string `json:"resource_kind"`
+}
+
+func bookingViews(list []ResourceBooking) []bookingView {
+	ids := make([]uint, 0, len(list))
+	for _, b := range list {
+		ids = append(ids, b.ResourceID)
+	}
+	var resources []Resource
+	if len(ids) > 0 {
+		DB.Where("id IN ?", ids).Find(&resources)
+	}
+	byID := map[uint]Resource{}
+	for _, r := range resources {
+		byID[r.ID] = r
+	}
+	out := make([]bookingView, 0, len(list))
+	for _, b := range list {
+		out = append(out, bookingView{ResourceBooking: b, ResourceName: byID[b.ResourceID].Name, ResourceKind: byID[b.ResourceID].Kind})
+	}
+	return out
+}
+
+// BookResource books one of the organization's resources for the event or
+// one of its tasks. The caller must organize the event and belong to the
+// resource's organization; a clash answers 409 with the bookings in the way.
+func BookResource(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "the event is cancelled")
+		return
+	}
+
+	var body BookResourceRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var r Resource
+	if err := DB.First(&r, body.ResourceID).Error; err != nil || orgRole(r.OrganizationID, userID) == "" {
+		jsonError(c, http.StatusNotFound, "resource not found")
+		return
+	}
+	if body.TaskID != nil {
+		var n int64
+		DB.Model(&Task{}).Where("id = ? AND event_id = ?", *body.TaskID, ev.ID).Count(&n)
+		if n == 0 {
+			jsonError(c, http.StatusNotFound, "task not found")
+			return
+		}
+	}
+
+	b := ResourceBooking{
+		ResourceID: r.ID,
+		EventID:    ev.ID,
+		TaskID:     body.TaskID,
+		BookedByID: userID,
+		StartsAt:   body.StartsAt,
+		EndsAt:     body.EndsAt,
+		Note:       strings.TrimSpace(body.Note),
+	}
+	var clashes []ResourceBooking
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// bookings of one resource are made one at a time
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&Resource{}, r.ID).Error; err != nil {
+			return err
+		}
+		overlapping(activeBookings(tx), b.StartsAt, b.EndsAt).Where("resource_bookings.resource_id = ?", r.ID).
+			Order("resource_bookings.starts_at asc").Find(&clashes)
+		if len(clashes) > 0 {
+			return errResourceBooked
+		}
+		if err := tx.Create(&b).Error; err != nil {
+			return err
+		}
+		// a room tells the event what it hasn't said about access yet
+		if r.Kind == "room" {
+			if filled := ev.Accessibility.filledFrom(r.Accessibility); filled != ev.Accessibility {
+				if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Updates(filled.columns()).Error; err != nil {
+					return err
+				}
+				recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errResourceBooked) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "clashes": bookingViews(clashes)})
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not book resource: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, bookingViews([]ResourceBooking{b})[0])
+}
+
+// GetEventResourceBookings lists what the event has booked, in time order.
+func GetEventResourceBookings(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var list []ResourceBooking
+	if err := DB.Where("event_id = ?", ev.ID).Order("starts_at asc, id asc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, bookingViews(list))
+}
+
+// CancelResourceBooking frees a booking made for the event.
+func CancelResourceBooking(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	bookingID, err := strconv.ParseUint(c.Param("bookingId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid booking id")
+		return
+	}
+
+	res := DB.Where("id = ? AND event_id = ?", bookingID, ev.ID).Delete(&ResourceBooking{})
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "could not cancel booking: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "booking not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "booking cancelled"})
+}
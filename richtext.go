@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.Linkify, extension.Strikethrough, extension.Table))
+
+	// UGCPolicy keeps formatting and links but drops scripts, styles and event handlers
+	htmlPolicy = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy()
+		p.RequireNoFollowOnLinks(true)
+		p.AddTargetBlankToFullyQualifiedLinks(true)
+		return p
+	}()
+)
+
+// RenderRichText turns user Markdown (which may embed HTML) into safe HTML.
+func RenderRichText(src string) string {
+	if src == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return htmlPolicy.Sanitize(src)
+	}
+	return htmlPolicy.Sanitize(buf.String())
+}
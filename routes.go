@@ -1,35 +1,393 @@
 package main
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 func SetupRoutes(r *gin.Engine) {
 
 	// Public Routes
-	r.POST("/signup", Signup)
-	r.POST("/login", Login)
+	r.POST("/signup", RequireCaptcha(), Signup)
+	r.POST("/login", StrictJSON(), Login)
+	r.POST("/auth/refresh", StrictJSON(), RefreshSession)
+	r.POST("/auth/logout", StrictJSON(), Logout)
+	r.POST("/auth/forgot-password", RequireCaptcha(), StrictJSON(), ForgotPassword)
+	r.POST("/auth/reset-password", StrictJSON(), ResetPassword)
+
+	// Inbound email provider webhook (shared secret)
+	r.POST("/inbound/email", InboundEmailWebhook)
+
+	// Organization single sign-on
+	r.POST("/sso/discover", SSODiscover)
+	r.GET("/sso/:orgId/login", SSOLogin)
+	r.GET("/sso/:orgId/oidc/callback", SSOOIDCCallback)
+	r.POST("/sso/:orgId/saml/acs", SSOSAMLACS)
+	r.GET("/sso/:orgId/saml/metadata", SSOSAMLMetadata)
+
+	// SCIM provisioning, authenticated by an organization token
+	scim := r.Group("/scim/v2")
+	scim.Use(ScimAuthMiddleware())
+	{
+		scim.GET("/Users", ScimListUsers)
+		scim.POST("/Users", ScimCreateUser)
+		scim.GET("/Users/:id", ScimGetUser)
+		scim.PUT("/Users/:id", ScimReplaceUser)
+		scim.PATCH("/Users/:id", ScimPatchUser)
+		scim.DELETE("/Users/:id", ScimDeleteUser)
+	}
+
+	// Check-in kiosks at the door, authenticated by an event's kiosk token
+	kiosk := r.Group("/kiosk")
+	kiosk.Use(KioskAuthMiddleware())
+	{
+		kiosk.GET("/event", GetKioskEvent)
+		kiosk.GET("/attendees", SearchKioskAttendees)
+		kiosk.POST("/attendees/:attendeeId/checkin", KioskCheckIn)
+		kiosk.DELETE("/attendees/:attendeeId/checkin", KioskUndoCheckIn)
+	}
+
+	// Public verification of attendance certificates
+	r.GET("/certificates/:code", PageCSP(), VerifyCertificate)
+
+	// Canonical enum values for clients
+	r.GET("/meta/enums", GetEnums)
+
+	// Public event links by vanity slug
+	r.GET("/e/:slug", GetEventBySlug)
+
+	// Embeddable event summary, callable from any origin
+	r.GET("/events/:id/summary-card", GetSummaryCard)
+	r.OPTIONS("/events/:id/summary-card", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	// Embeddable RSVP widget, with per-event origins and its own limits
+	widget := r.Group("/widget/events/:id")
+	widget.Use(WidgetMiddleware())
+	{
+		widget.GET("", WidgetRateLimit("widget-read", AppConfig.WidgetReadPerMinute), GetWidgetEvent)
+		widget.GET("/headcount", WidgetRateLimit("widget-read", AppConfig.WidgetReadPerMinute), GetWidgetHeadcount)
+		widget.POST("/rsvp", WidgetRateLimit("widget-rsvp", AppConfig.WidgetRSVPPerMinute), RequireCaptcha(), SubmitWidgetRSVP)
+		widget.OPTIONS("", func(c *gin.Context) {})
+		widget.OPTIONS("/headcount", func(c *gin.Context) {})
+		widget.OPTIONS("/rsvp", func(c *gin.Context) {})
+	}
+
+	// Time-limited download links
+	r.GET("/exports/:token", DownloadDataExport)
+	r.GET("/files/*key", ServeStoredFile)
+
+	// Feed-token authenticated feeds
+	feeds := r.Group("/me")
+	feeds.Use(FeedTokenMiddleware())
+	{
+		feeds.GET("/upcoming", GetUpcomingFeed)
+		feeds.GET("/calendar.ics", GetCalendarFeed)
+		feeds.GET("/orgs/:id/calendar.ics", GetOrganizationCalendarFeed)
+	}
 
 	// Protected Routes
 	authorized := r.Group("/api")
-	authorized.Use(AuthMiddleware())
+	authorized.Use(AuthMiddleware(), SuspensionMiddleware())
 	{
 		// EVENTS
 		authorized.POST("/events", CreateEvent)
+		authorized.POST("/events/quick", QuickCreateEvent)
 		authorized.GET("/events/organized", GetOrganizedEvents)
 		authorized.GET("/events/invited", GetInvitedEvents)
-		authorized.DELETE("/events/:id", DeleteEvent)
+		authorized.PUT("/events/:id", UpdateEvent)
+		authorized.DELETE("/events/:id", RequireEventRole(EventRoleOwner), DeleteEvent)
+		authorized.POST("/events/bulk-delete", BulkDeleteEvents)
+		authorized.POST("/events/bulk-archive", BulkArchiveEvents)
+		authorized.POST("/events/bulk-unarchive", BulkUnarchiveEvents)
+		authorized.POST("/events/:id/merge/:otherId", MergeEvent)
+		authorized.POST("/events/:id/clone", CloneEvent)
+		authorized.PUT("/events/:id/private", UpdateEventPrivate)
+		authorized.PUT("/events/:id/threshold", SetAttendanceThreshold)
+		authorized.POST("/events/:id/cancel", CancelEvent)
+		authorized.PUT("/events/:id/reminders", SetEventReminders)
+		authorized.GET("/events/:id/reminders/me", GetMyEventReminders)
+		authorized.PUT("/events/:id/reminders/me", SetMyEventReminders)
+		authorized.GET("/me/reminders", GetMyReminders)
+		authorized.PUT("/events/:id/nudges", SetEventNudges)
+		authorized.PUT("/events/:id/visibility", SetEventVisibility)
+		authorized.PUT("/events/:id/summary-card", SetEventPublicCard)
+		authorized.PUT("/events/:id/widget", SetEventWidget)
+		authorized.PUT("/events/:id/slug", SetEventSlug)
+		authorized.GET("/events/:id/widget/rsvps", GetWidgetRSVPs)
+		authorized.GET("/events/:id/ical", GetEventICS)
 
 		// INVITATIONS
-		authorized.POST("/events/:id/invite", InviteUser)
+		authorized.POST("/events/:id/invite", StrictJSON(), RequireEventRole(eventOrganizerRoles...), InviteUser)
+		authorized.POST("/events/:id/invitation/report", ReportInvitation)
+		authorized.GET("/me/invitations", GetMyInvitations)
+		authorized.POST("/invitations/:id/accept", AcceptInvitation)
+		authorized.POST("/invitations/:id/decline", DeclineInvitation)
+		authorized.GET("/me/invite-quota", GetMyInviteQuota)
+		authorized.GET("/me/rate-limits", GetMyRateLimits)
 
 		// ATTENDANCE
-		authorized.POST("/events/:id/respond", SetAttendance)
-		authorized.GET("/events/:id/attendees", GetEventAttendees)
+		authorized.POST("/events/:id/respond", StrictJSON(), SetAttendance)
+		authorized.GET("/events/:id/attendees", RequireEventRole(eventViewerRoles...), GetEventAttendees)
+		authorized.GET("/events/:id/attendees/export.csv", ExportAttendeesCSV)
+		authorized.DELETE("/events/:id/attendees/:userId", RemoveAttendee)
+		authorized.PUT("/events/:id/attendees/:userId/labels", SetAttendeeLabels)
+		authorized.GET("/events/:id/labels", GetEventLabels)
+
+		// Door check-in devices and the badges they scan
+		authorized.GET("/events/:id/badges.pdf", GetEventBadges)
+		authorized.GET("/events/:id/kiosk-tokens", GetKioskTokens)
+		authorized.POST("/events/:id/kiosk-tokens", CreateKioskToken)
+		authorized.DELETE("/events/:id/kiosk-tokens/:tokenId", RevokeKioskToken)
+
+		// Certificates of attendance
+		authorized.GET("/events/:id/certificate-template", GetCertificateTemplate)
+		authorized.PUT("/events/:id/certificate-template", PutCertificateTemplate)
+		authorized.DELETE("/events/:id/certificate-template", DeleteCertificateTemplate)
+		authorized.GET("/events/:id/certificates", GetEventCertificates)
+		authorized.POST("/events/:id/certificates", IssueCertificates)
+		authorized.DELETE("/events/:id/certificates/:certId", RevokeCertificate)
+		authorized.GET("/me/certificates", GetMyCertificates)
+		authorized.GET("/me/certificates/:id/pdf", DownloadMyCertificate)
+
+		// Recurring events; occurrences are addressed by their original start
+		authorized.PUT("/events/:id/recurrence", SetEventRecurrence)
+		authorized.GET("/events/:id/occurrences", GetEventOccurrences)
+		authorized.PUT("/events/:id/occurrences/:occursAt", UpdateOccurrence)
+		authorized.DELETE("/events/:id/occurrences/:occursAt", CancelOccurrence)
+		authorized.POST("/events/:id/occurrences/:occursAt/restore", RestoreOccurrence)
+		authorized.POST("/events/:id/nudge", NudgeInvitees)
+
+		// CAPACITY & WAITLIST
+		authorized.PUT("/events/:id/capacity", SetEventCapacity)
+		authorized.GET("/events/:id/capacity", GetEventCapacity)
+		authorized.GET("/events/:id/stats", GetEventStats)
+		authorized.GET("/events/:id/waitlist", GetWaitlist)
+		authorized.GET("/events/:id/waitlist/me", GetMyWaitlistPosition)
+		authorized.POST("/events/:id/waitlist/:userId/promote", PromoteWaitlisted)
+
+		// TICKET TIERS & SESSIONS
+		authorized.GET("/events/:id/ticket-tiers", GetTicketTiers)
+		authorized.POST("/events/:id/ticket-tiers", RequireFeature("ticketing"), CreateTicketTier)
+		authorized.PUT("/events/:id/ticket-tiers/:tierId", RequireFeature("ticketing"), UpdateTicketTier)
+		authorized.DELETE("/events/:id/ticket-tiers/:tierId", RequireFeature("ticketing"), DeleteTicketTier)
+		authorized.GET("/events/:id/cancellation-policy", GetCancellationPolicy)
+		authorized.PUT("/events/:id/cancellation-policy", RequireFeature("ticketing"), PutCancellationPolicy)
+		authorized.DELETE("/events/:id/cancellation-policy", RequireFeature("ticketing"), DeleteCancellationPolicy)
+		authorized.GET("/events/:id/sessions", GetEventSessions)
+		authorized.POST("/events/:id/sessions", CreateEventSession)
+		authorized.PUT("/events/:id/sessions/:sessionId", UpdateEventSession)
+		authorized.DELETE("/events/:id/sessions/:sessionId", DeleteEventSession)
+		authorized.GET("/events/:id/run-of-show", GetRunOfShow)
+		authorized.PUT("/events/:id/run-of-show", UpdateRunOfShow)
+		authorized.POST("/events/:id/run-of-show/generate", GenerateRunOfShow)
+		authorized.GET("/events/:id/run-of-show/pdf", GetRunOfShowPDF)
+		authorized.GET("/events/:id/sessions/:sessionId/registrations", GetSessionRegistrations)
+		authorized.POST("/events/:id/sessions/:sessionId/registration", RegisterForSession)
+		authorized.DELETE("/events/:id/sessions/:sessionId/registration", UnregisterFromSession)
+
+		// INVOICES
+		authorized.GET("/events/:id/invoices", GetEventInvoices)
+		authorized.GET("/events/:id/invoices/summary", GetEventInvoiceSummary)
+		authorized.GET("/events/:id/invoices/:invoiceId", GetEventInvoice) // :invoiceId or :invoiceId.pdf
+		authorized.GET("/me/invoices", GetMyInvoices)
+		authorized.GET("/me/invoices/:id", GetMyInvoice) // :id or :id.pdf
+		authorized.GET("/me/billing", GetMyBilling)
+		authorized.PUT("/me/billing", PutMyBilling)
+
+		// ATTENDEE FIELDS
+		authorized.GET("/events/:id/fields", GetEventFields)
+		authorized.POST("/events/:id/fields", CreateEventField)
+		authorized.PUT("/events/:id/fields/:fieldId", UpdateEventField)
+		authorized.DELETE("/events/:id/fields/:fieldId", DeleteEventField)
+
+		// ANNOUNCEMENTS
+		authorized.POST("/events/:id/announcements", CreateAnnouncement)
+		authorized.GET("/events/:id/announcements", GetAnnouncements)
+
+		// WEATHER CONTINGENCY
+		authorized.GET("/events/:id/weather-contingency", RequireEventRole(eventOrganizerRoles...), GetWeatherContingency)
+		authorized.PUT("/events/:id/weather-contingency", RequireEventRole(eventOrganizerRoles...), SetWeatherContingency)
+		authorized.DELETE("/events/:id/weather-contingency", RequireEventRole(eventOrganizerRoles...), DeleteWeatherContingency)
+		authorized.POST("/events/:id/weather-contingency/publish", RequireEventRole(eventOrganizerRoles...), PublishWeatherContingency)
+
+		// COMMENTS
+		authorized.GET("/events/:id/comments", GetEventComments)
+		authorized.POST("/events/:id/comments", CreateEventComment)
+		authorized.PUT("/events/:id/comments/:commentId", UpdateEventComment)
+		authorized.DELETE("/events/:id/comments/:commentId", DeleteEventComment)
+		authorized.GET("/events/:id/email-alias", GetEventEmailAlias)
+		authorized.POST("/events/:id/email-alias/rotate", RotateEventEmailAlias)
+
+		// COVER IMAGE
+		authorized.POST("/events/:id/cover", UploadEventCover)
+		authorized.DELETE("/events/:id/cover", DeleteEventCover)
+
+		// ATTACHMENTS
+		authorized.GET("/events/:id/attachments", GetEventAttachments)
+		authorized.POST("/events/:id/attachments", UploadAttachment)
+		authorized.GET("/events/:id/attachments/:attachmentId", GetEventAttachment)
+		authorized.DELETE("/events/:id/attachments/:attachmentId", DeleteEventAttachment)
+
+		// CONTRACTS
+		authorized.GET("/events/:id/contracts", GetContracts)
+		authorized.POST("/events/:id/contracts", CreateContract)
+		authorized.GET("/events/:id/contracts/:contractId", GetContract)
+		authorized.DELETE("/events/:id/contracts/:contractId", DeleteContract)
+		authorized.POST("/events/:id/contracts/:contractId/versions", UploadContractVersion)
+		authorized.POST("/events/:id/contracts/:contractId/approval-requests", RequestContractApproval)
+		authorized.POST("/events/:id/contracts/:contractId/decision", DecideContract)
+
+		// RESOURCE BOOKINGS
+		authorized.GET("/events/:id/resource-bookings", GetEventResourceBookings)
+		authorized.POST("/events/:id/resource-bookings", BookResource)
+		authorized.DELETE("/events/:id/resource-bookings/:bookingId", CancelResourceBooking)
+
+		// BUDGET & VENDORS
+		authorized.GET("/events/:id/vendors", GetVendors)
+		authorized.POST("/events/:id/vendors", CreateVendor)
+		authorized.PUT("/events/:id/vendors/:vendorId", UpdateVendor)
+		authorized.DELETE("/events/:id/vendors/:vendorId", DeleteVendor)
+		authorized.GET("/events/:id/expenses", GetExpenses)
+		authorized.POST("/events/:id/expenses", CreateExpense)
+		authorized.DELETE("/events/:id/expenses/:expenseId", DeleteExpense)
+		authorized.POST("/events/:id/expenses/:expenseId/receipt", UploadReceipt)
+		authorized.GET("/events/:id/expenses/:expenseId/receipt", GetReceipt)
+		authorized.GET("/events/:id/expenses/:expenseId/receipt/file", GetReceiptFile)
+		authorized.POST("/events/:id/expenses/:expenseId/receipt/confirm", ConfirmReceipt)
+		authorized.GET("/events/:id/budget", GetBudgetBreakdown)
+		authorized.GET("/events/:id/health", GetEventHealth)
+		authorized.GET("/events/:id/alerts", GetEventAlerts)
+		authorized.PUT("/events/:id/budget/categories/:category", SetBudgetCategory)
+		authorized.GET("/events/:id/budget/categories/:category/quotes", GetCategoryQuotes)
+		authorized.POST("/events/:id/budget/categories/:category/quotes", CreateCategoryQuote)
+		authorized.PUT("/events/:id/quotes/:quoteId", UpdateQuote)
+		authorized.DELETE("/events/:id/quotes/:quoteId", DeleteQuote)
+		authorized.POST("/events/:id/quotes/:quoteId/accept", AcceptQuote)
 
 		// TASKS
-		authorized.POST("/events/:id/tasks", CreateTask)
-		authorized.GET("/events/:id/tasks", GetTasksByEvent)
+		authorized.POST("/events/:id/tasks", RequireEventRole(EventRoleOwner, EventRoleDelegate), CreateTask)
+		authorized.GET("/events/:id/tasks", RequireEventRole(eventViewerRoles...), GetTasksByEvent)
+		authorized.GET("/events/:id/tasks/board", GetTaskBoard)
+		authorized.PUT("/events/:id/tasks/:taskId/assign", AssignTask)
+		authorized.GET("/me/tasks", GetMyTasks)
+		authorized.GET("/me/organizing/tasks", GetOrganizingTasks)
+		authorized.GET("/me/reports/compare", GetCompareReport)
+		authorized.POST("/tasks/:id/move", MoveTask)
+		authorized.PATCH("/tasks/:id/status", UpdateTaskStatus)
+		authorized.DELETE("/tasks/:id", DeleteTask)
+
+		// UNDO
+		authorized.POST("/undo/:token", Undo)
 
 		// SEARCH
 		authorized.GET("/events/search", SearchHandler)
+		authorized.GET("/search/users", SearchPeople)
+
+		// DELEGATION
+		authorized.GET("/me/delegates", GetMyDelegates)
+		authorized.POST("/me/delegates", GrantDelegation)
+		authorized.DELETE("/me/delegates/:userId", RevokeDelegation)
+		authorized.GET("/me/principals", GetMyPrincipals)
+
+		// SESSIONS
+		authorized.GET("/me/sessions", GetMySessions)
+		authorized.DELETE("/me/sessions/:id", RevokeMySession)
+
+		// PRIVACY
+		authorized.GET("/me/privacy", GetMyPrivacy)
+		authorized.PUT("/me/privacy", UpdateMyPrivacy)
+
+		// NOTIFICATIONS
+		authorized.GET("/me/notifications", GetMyNotifications)
+		authorized.GET("/me/notifications/stream", StreamMyNotifications)
+		authorized.POST("/me/notifications/:id/read", MarkNotificationRead)
+
+		// DATA EXPORT
+		authorized.POST("/me/export", RequestDataExport)
+
+		// ORGANIZATIONS
+		authorized.POST("/orgs", CreateOrganization)
+		authorized.GET("/me/orgs", GetMyOrganizations)
+		authorized.GET("/orgs/:id/members", GetOrganizationMembers)
+		authorized.POST("/orgs/:id/members", AddOrganizationMember)
+		authorized.DELETE("/orgs/:id/members/:userId", RemoveOrganizationMember)
+		authorized.GET("/orgs/:id/events", GetOrganizationEvents)
+		authorized.GET("/orgs/:id/resources", GetOrganizationResources)
+		authorized.POST("/orgs/:id/resources", CreateOrganizationResource)
+		authorized.PUT("/orgs/:id/resources/:resourceId", UpdateOrganizationResource)
+		authorized.DELETE("/orgs/:id/resources/:resourceId", DeleteOrganizationResource)
+		authorized.GET("/orgs/:id/branding", GetOrgBranding)
+		authorized.PUT("/orgs/:id/branding", PutOrgBranding)
+		authorized.GET("/orgs/:id/sso", GetOrgSSOConfig)
+		authorized.PUT("/orgs/:id/sso", PutOrgSSOConfig)
+		authorized.POST("/orgs/:id/sso/domains", AddSSODomain)
+		authorized.POST("/orgs/:id/sso/domains/:domain/verify", VerifySSODomain)
+		authorized.DELETE("/orgs/:id/sso/domains/:domain", DeleteSSODomain)
+		authorized.GET("/orgs/:id/scim/tokens", GetScimTokens)
+		authorized.POST("/orgs/:id/scim/tokens", CreateScimToken)
+		authorized.DELETE("/orgs/:id/scim/tokens/:tokenId", RevokeScimToken)
+
+		// FEATURES
+		authorized.GET("/me/features", GetMyFeatures)
+
+		// REALTIME
+		authorized.GET("/ws", ServeWS)
+		authorized.GET("/sync", GetSync)
+		authorized.POST("/sync/apply", ApplySyncMutations)
+		authorized.GET("/events/:id/presence", GetEventPresence)
+
+		// DASHBOARD
+		authorized.GET("/me/dashboard", GetDashboard)
+		authorized.GET("/me/calendar/heatmap", GetCalendarHeatmap)
+		authorized.PUT("/me/locale", UpdateMyLocale)
+		authorized.GET("/holidays", GetHolidays)
+		authorized.POST("/me/feed-token", RotateFeedToken)
+
+		// PLANS & USAGE
+		authorized.GET("/plans", ListPlans)
+		authorized.GET("/me/usage", GetMyUsage)
+
+		// PROFILES
+		authorized.GET("/me", GetMe)
+		authorized.PUT("/me", UpdateMe)
+		authorized.GET("/users/search", SearchUsers)
+		authorized.GET("/users/:id", GetUserProfile)
+	}
+
+	// Admin Routes
+	admin := authorized.Group("/admin")
+	admin.Use(RequireAdmin())
+	{
+		admin.GET("/audit", GetAuditLog)
+		admin.POST("/users/:id/suspend", SuspendUser)
+		admin.POST("/users/:id/unsuspend", LiftSuspension)
+		admin.GET("/users/:id/suspensions", GetUserSuspensions)
+
+		admin.PUT("/users/:id/plan", SetUserPlan)
+		admin.PUT("/orgs/:id/plan", SetOrganizationPlan)
+		admin.GET("/deprecations", GetDeprecationReport)
+		admin.GET("/maintenance", GetMaintenance)
+		admin.PUT("/maintenance", SetMaintenance)
+		admin.POST("/test/email", SendTestEmail)
+		admin.GET("/jobs", ListJobs)
+		admin.GET("/jobs/:id", GetJob)
+		admin.POST("/jobs/:id/retry", RetryJob)
+		admin.POST("/jobs/requeue", RequeueJobs)
+		admin.GET("/templates", GetTemplates)
+		admin.GET("/templates/:kind", GetTemplate)
+		admin.POST("/templates/:kind/preview", PreviewTemplate)
+		admin.PUT("/templates/:kind/:locale", PutTemplate)
+		admin.DELETE("/templates/:kind/:locale", DeleteTemplate)
+
+		admin.GET("/metering/export", ExportMetering)
+
+		admin.GET("/db/stats", GetDBStats)
+		admin.DELETE("/db/stats", ResetDBStats)
+
+		admin.GET("/features", ListFeatureFlags)
+		admin.PUT("/features/:key", UpsertFeatureFlag)
+		admin.PUT("/features/:key/overrides", SetFeatureOverride)
+		admin.DELETE("/features/:key/overrides", DeleteFeatureOverride)
 	}
 }
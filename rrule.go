@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A subset of RFC 5545 recurrence rules: FREQ (DAILY, WEEKLY, MONTHLY,
+// YEARLY), INTERVAL, COUNT, UNTIL, BYDAY (with ordinals like 2TU or -1FR
+// for MONTHLY and YEARLY), BYMONTHDAY, BYMONTH and WKST. In YEARLY rules
+// BYDAY counts within the months of BYMONTH, which is then required.
+
+const (
+	maxRecurrenceCount    = 1000
+	maxRecurrenceInterval = 1000
+	// periods stepped through before giving up on a rule that matches
+	// rarely or never (FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30)
+	maxRecurrencePeriods = 50000
+)
+
+type rruleDay struct {
+	N       int // 0 for every such weekday, else the nth (negative from the end)
+	Weekday time.Weekday
+}
+
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time // UTC
+	ByDay      []rruleDay
+	ByMonthDay []int
+	ByMonth    []int
+	WeekStart  time.Weekday
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+func rruleWeekdayName(d time.Weekday) string {
+	for k, v := range rruleWeekdays {
+		if v == d {
+			return k
+		}
+	}
+	return ""
+}
+
+func parseRRuleInts(key, val string, min, max int) ([]int, error) {
+	out := []int{}
+	for _, p := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < min || n > max || n == 0 {
+			return nil, fmt.Errorf("invalid %s value %q", key, p)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseRRule parses "FREQ=WEEKLY;BYDAY=MO,WE", with or without an "RRULE:"
+// prefix.
+func parseRRule(s string) (*RRule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s given twice", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			if val != "DAILY" && val != "WEEKLY" && val != "MONTHLY" && val != "YEARLY" {
+				return nil, errors.New("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+			r.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > maxRecurrenceInterval {
+				return nil, errors.New("INTERVAL must be between 1 and 1000")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > maxRecurrenceCount {
+				return nil, errors.New("COUNT must be between 1 and 1000")
+			}
+			r.Count = n
+		case "UNTIL":
+			var t time.Time
+			var err error
+			if len(val) == 8 {
+				// a date: the whole day counts
+				t, err = time.Parse("20060102", val)
+				t = t.Add(24*time.Hour - time.Second)
+			} else {
+				t, err = time.Parse("20060102T150405Z", val)
+			}
+			if err != nil {
+				return nil, errors.New("UNTIL must look like 20261231 or 20261231T235959Z")
+			}
+			r.Until = &t
+		case "BYDAY":
+			for _, p := range strings.Split(val, ",") {
+				if len(p) < 2 {
+					return nil, fmt.Errorf("invalid BYDAY value %q", p)
+				}
+				wd, ok := rruleWeekdays[p[len(p)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY value %q", p)
+				}
+				d := rruleDay{Weekday: wd}
+				if prefix := p[:len(p)-2]; prefix != "" {
+					n, err := strconv.Atoi(prefix)
+					if err != nil || n == 0 || n < -5 || n > 5 {
+						return nil, fmt.Errorf("invalid BYDAY value %q", p)
+					}
+					d.N = n
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		case "BYMONTHDAY":
+			days, err := parseRRuleInts(key, val, -31, 31)
+			if err != nil {
+				return nil, err
+			}
+			r.ByMonthDay = days
+		case "BYMONTH":
+			months, err := parseRRuleInts(key, val, 1, 12)
+			if err != nil {
+				return nil, err
+			}
+			r.ByMonth = months
+		case "WKST":
+			if val != "MO" && val != "SU" {
+				return nil, errors.New("WKST must be MO or SU")
+			}
+			r.WeekStart = rruleWeekdays[val]
+		default:
+			return nil, fmt.Errorf("%s is not supported", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("COUNT and UNTIL can't be combined")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != "MONTHLY" && r.Freq != "YEARLY" {
+			return nil, errors.New("BYDAY ordinals need FREQ=MONTHLY or YEARLY")
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == "WEEKLY" {
+		return nil, errors.New("BYMONTHDAY can't be used with FREQ=WEEKLY")
+	}
+	if r.Freq == "YEARLY" && len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+		return nil, errors.New("BYDAY in a YEARLY rule needs BYMONTH")
+	}
+	return r, nil
+}
+
+// String is the canonical form of the rule, as stored and exported.
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			s := rruleWeekdayName(d.Weekday)
+			if d.N != 0 {
+				s = strconv.Itoa(d.N) + s
+			}
+			days = append(days, s)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	ints := func(key string, ns []int) {
+		if len(ns) == 0 {
+			return
+		}
+		s := make([]string, 0, len(ns))
+		for _, n := range ns {
+			s = append(s, strconv.Itoa(n))
+		}
+		parts = append(parts, key+"="+strings.Join(s, ","))
+	}
+	ints("BYMONTHDAY", r.ByMonthDay)
+	ints("BYMONTH", r.ByMonth)
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+rruleWeekdayName(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// monthDays lists the days of a month the BYDAY and BYMONTHDAY parts pick,
+// or def when the rule has neither.
+func (r *RRule) monthDays(year int, month time.Month, def int) []int {
+	n := daysIn(year, month)
+	var byMonthDay, byDay []int
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = n + d + 1
+		}
+		if d >= 1 && d <= n {
+			byMonthDay = append(byMonthDay, d)
+		}
+	}
+	if len(r.ByDay) > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		for _, bd := range r.ByDay {
+			matches := []int{}
+			for d := 1 + (int(bd.Weekday)-int(first)+7)%7; d <= n; d += 7 {
+				matches = append(matches, d)
+			}
+			switch {
+			case bd.N == 0:
+				byDay = append(byDay, matches...)
+			case bd.N > 0 && bd.N <= len(matches):
+				byDay = append(byDay, matches[bd.N-1])
+			case bd.N < 0 && -bd.N <= len(matches):
+				byDay = append(byDay, matches[len(matches)+bd.N])
+			}
+		}
+	}
+
+	var days []int
+	switch {
+	case len(r.ByDay) > 0 && len(r.ByMonthDay) > 0:
+		for _, d := range byDay {
+			if slices.Contains(byMonthDay, d) {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		days = byDay
+	case len(r.ByMonthDay) > 0:
+		days = byMonthDay
+	case def <= n:
+		days = []int{def}
+	}
+	sort.Ints(days)
+	return slices.Compact(days)
+}
+
+// candidates are the starts the rule produces in the k-th period after
+// the one holding start, in order.
+func (r *RRule) candidates(start time.Time, k int) []time.Time {
+	loc := start.Location()
+	h, mi, s := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, h, mi, s, 0, loc) }
+	monthOK := func(m time.Month) bool { return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, int(m)) }
+
+	var out []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := time.Date(start.Year(), start.Month(), start.Day()+k*r.Interval, 0, 0, 0, 0, time.UTC)
+		if !monthOK(day.Month()) {
+			return nil
+		}
+		if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(d rruleDay) bool { return d.Weekday == day.Weekday() }) {
+			return nil
+		}
+		if len(r.ByMonthDay) > 0 && !slices.Contains(r.monthDays(day.Year(), day.Month(), 0), day.Day()) {
+			return nil
+		}
+		out = append(out, at(day.Year(), day.Month(), day.Day()))
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := time.Date(start.Year(), start.Month(), start.Day()-offset+7*k*r.Interval, 0, 0, 0, 0, time.UTC)
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, d := range r.ByDay {
+				weekdays = append(weekdays, d.Weekday)
+			}
+		}
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if slices.Contains(weekdays, day.Weekday()) && monthOK(day.Month()) {
+				out = append(out, at(day.Year(), day.Month(), day.Day()))
+			}
+		}
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(k*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		if !monthOK(month.Month()) {
+			return nil
+		}
+		for _, d := range r.monthDays(month.Year(), month.Month(), start.Day()) {
+			out = append(out, at(month.Year(), month.Month(), d))
+		}
+	case "YEARLY":
+		year := start.Year() + k*r.Interval
+		months := []int{int(start.Month())}
+		if len(r.ByMonth) > 0 {
+			months = append([]int{}, r.ByMonth...)
+			sort.Ints(months)
+		}
+		for _, m := range months {
+			for _, d := range r.monthDays(year, time.Month(m), start.Day()) {
+				out = append(out, at(year, time.Month(m), d))
+			}
+		}
+	}
+	return out
+}
+
+// each calls fn with every occurrence of the rule starting at start, in
+// order, until fn returns false or the series ends. The start itself is
+// always the first occurrence.
+func (r *RRule) each(start time.Time, fn func(time.Time) bool) {
+	n := 0
+	emit := func(t time.Time) bool {
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		n++
+		if r.Count > 0 && n > r.Count {
+			return false
+		}
+		return fn(t)
+	}
+	if !emit(start) {
+		return
+	}
+	for k := 0; k < maxRecurrencePeriods; k++ {
+		for _, t := range r.candidates(start, k) {
+			if !t.After(start) {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// between lists the occurrences in [from, to), at most limit of them.
+func (r *RRule) between(start, from, to time.Time, limit int) []time.Time {
+	out := []time.Time{}
+	r.each(start, func(t time.Time) bool {
+		if !t.Before(to) || len(out) >= limit {
+			return false
+		}
+		if !t.Before(from) {
+			out = append(out, t)
+		}
+		return true
+	})
+	return out
+}
+
+// last is the final occurrence of a bounded series; ok is false for one
+// that never ends.
+func (r *RRule) last(start time.Time) (time.Time, bool) {
+	if r.Count == 0 && r.Until == nil {
+		return time.Time{}, false
+	}
+	last := start
+	r.each(start, func(t time.Time) bool {
+		last = t
+		return true
+	})
+	return last, true
+}
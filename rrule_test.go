@@ -0,0 +1,178 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"FREQ",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=1001",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=1001",
+		"FREQ=DAILY;UNTIL=2026",
+		"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=DAILY;WKST=TU",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if r, err := parseRRule(s); err == nil {
+			t.Errorf("parseRRule(%q) = %s, want an error", s, r)
+		}
+	}
+}
+
+func TestParseRRuleString(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=monthly;byday=-1fr", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE;WKST=MO", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=WEEKLY;INTERVAL=2;WKST=SU;BYDAY=TU", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;WKST=SU"},
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1;COUNT=3", "FREQ=YEARLY;COUNT=3;BYMONTHDAY=-1;BYMONTH=2"},
+	} {
+		r, err := parseRRule(tc.in)
+		if err != nil {
+			t.Errorf("parseRRule(%q): %v", tc.in, err)
+			continue
+		}
+		if got := r.String(); got != tc.want {
+			t.Errorf("parseRRule(%q).String() = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	for _, tc := range []struct {
+		rule  string
+		start string
+		want  []string
+	}{
+		// months without the 31st are skipped, not clamped
+		{"FREQ=MONTHLY;COUNT=3", "2026-01-31", []string{"2026-01-31", "2026-03-31", "2026-05-31"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=4", "2026-01-31", []string{"2026-01-31", "2026-03-31", "2026-05-31", "2026-07-31"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4", "2024-01-31", []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=30,-1;COUNT=4", "2026-01-30", []string{"2026-01-30", "2026-01-31", "2026-02-28", "2026-03-30"}},
+		// leap days
+		{"FREQ=YEARLY;COUNT=3", "2024-02-29", []string{"2024-02-29", "2028-02-29", "2032-02-29"}},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1;COUNT=3", "2023-02-28", []string{"2023-02-28", "2024-02-29", "2025-02-28"}},
+		// a rule that never matches again ends after the start
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "2026-02-01", []string{"2026-02-01"}},
+		// BYDAY ordinals
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "2026-01-30", []string{"2026-01-30", "2026-02-27", "2026-03-27"}},
+		{"FREQ=MONTHLY;BYDAY=2TU;COUNT=3", "2026-01-13", []string{"2026-01-13", "2026-02-10", "2026-03-10"}},
+		{"FREQ=MONTHLY;BYDAY=5FR;COUNT=3", "2026-01-30", []string{"2026-01-30", "2026-05-29", "2026-07-31"}},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2", "2026-11-26", []string{"2026-11-26", "2027-11-25"}},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3", "2026-02-13", []string{"2026-02-13", "2026-03-13", "2026-11-13"}},
+		// intervals
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=5", "2026-01-05", []string{"2026-01-05", "2026-01-07", "2026-01-19", "2026-01-21", "2026-02-02"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,MO;WKST=SU;COUNT=4", "2026-01-04", []string{"2026-01-04", "2026-01-05", "2026-01-18", "2026-01-19"}},
+		{"FREQ=DAILY;INTERVAL=10;COUNT=3", "2026-01-25", []string{"2026-01-25", "2026-02-04", "2026-02-14"}},
+		{"FREQ=MONTHLY;INTERVAL=5;COUNT=3", "2026-10-15", []string{"2026-10-15", "2027-03-15", "2027-08-15"}},
+		// UNTIL
+		{"FREQ=DAILY;UNTIL=20260103", "2026-01-01", []string{"2026-01-01", "2026-01-02", "2026-01-03"}},
+		{"FREQ=DAILY;UNTIL=20260102T090000Z", "2026-01-01", []string{"2026-01-01"}},
+		{"FREQ=WEEKLY;UNTIL=20260115", "2026-01-01", []string{"2026-01-01", "2026-01-08", "2026-01-15"}},
+	} {
+		r, err := parseRRule(tc.rule)
+		if err != nil {
+			t.Errorf("parseRRule(%q): %v", tc.rule, err)
+			continue
+		}
+		start := rruleTestTime(t, tc.start)
+		var got []string
+		for _, o := range r.between(start, start, start.AddDate(20, 0, 0), 100) {
+			if h, m, _ := o.Clock(); h != 10 || m != 30 {
+				t.Errorf("%s from %s: %s lost the start's time of day", tc.rule, tc.start, o)
+			}
+			got = append(got, o.Format("2006-01-02"))
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s from %s = %v, want %v", tc.rule, tc.start, got, tc.want)
+		}
+	}
+}
+
+func TestRRuleBetween(t *testing.T) {
+	start := rruleTestTime(t, "2026-01-01")
+	day := func(d int) time.Time { return start.AddDate(0, 0, d-1) }
+	for _, tc := range []struct {
+		name     string
+		rule     string
+		from, to time.Time
+		limit    int
+		want     []string
+	}{
+		{"limit caps the list", "FREQ=DAILY", day(1), day(100), 3, []string{"2026-01-01", "2026-01-02", "2026-01-03"}},
+		{"from is inclusive, to exclusive", "FREQ=DAILY", day(5), day(8), 10, []string{"2026-01-05", "2026-01-06", "2026-01-07"}},
+		{"window between occurrences", "FREQ=WEEKLY", day(2), day(8), 10, []string{}},
+		{"window after the series", "FREQ=DAILY;COUNT=3", day(4), day(10), 10, []string{}},
+		{"COUNT includes occurrences before from", "FREQ=DAILY;COUNT=3", day(3), day(10), 10, []string{"2026-01-03"}},
+		{"UNTIL ends a window early", "FREQ=DAILY;UNTIL=20260106", day(4), day(10), 10, []string{"2026-01-04", "2026-01-05", "2026-01-06"}},
+		{"zero limit", "FREQ=DAILY", day(1), day(10), 0, []string{}},
+	} {
+		r, err := parseRRule(tc.rule)
+		if err != nil {
+			t.Fatalf("%s: parseRRule(%q): %v", tc.name, tc.rule, err)
+		}
+		got := []string{}
+		for _, o := range r.between(start, tc.from, tc.to, tc.limit) {
+			got = append(got, o.Format("2006-01-02"))
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRRuleLast(t *testing.T) {
+	start := rruleTestTime(t, "2026-01-31")
+	for _, tc := range []struct {
+		rule string
+		want string // "" for a series that never ends
+	}{
+		{"FREQ=DAILY", ""},
+		{"FREQ=MONTHLY;COUNT=3", "2026-05-31"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20260430", "2026-04-30"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30;COUNT=5", "2026-01-31"},
+	} {
+		r, err := parseRRule(tc.rule)
+		if err != nil {
+			t.Fatalf("parseRRule(%q): %v", tc.rule, err)
+		}
+		last, ok := r.last(start)
+		if ok != (tc.want != "") {
+			t.Errorf("%s: bounded = %v, want %v", tc.rule, ok, tc.want != "")
+			continue
+		}
+		if ok && last.Format("2006-01-02") != tc.want {
+			t.Errorf("%s: last = %s, want %s", tc.rule, last.Format("2006-01-02"), tc.want)
+		}
+	}
+}
+
+// rruleTestTime is 10:30 UTC on the given day.
+func rruleTestTime(t *testing.T, day string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Add(10*time.Hour + 30*time.Minute)
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"gorm.io/gorm"
+)
+
+// The run-of-show is the minute-by-minute plan crews work from on the day:
+// "T-60: sound check". It is generated from the event's sessions and the
+// tasks assigned to someone around the start, then edited by hand.
+// Regenerating refreshes the generated lines but keeps edited and manual
+// ones.
+
+const (
+	RunOfShowSession = "session"
+	RunOfShowTask    = "task"
+	RunOfShowManual  = "manual"
+
+	// assigned tasks due this close to the start are part of the show
+	runOfShowTaskWindow = 24 * time.Hour
+)
+
+// offsetLabel writes a start offset the way crews say it, T-60 or T+15.
+func offsetLabel(minutes int) string {
+	if minutes < 0 {
+		return fmt.Sprintf("T-%d", -minutes)
+	}
+	return fmt.Sprintf("T+%d", minutes)
+}
+
+type RunOfShowView struct {
+	RunOfShowItem
+	Label    string       `json:"label"`
+	At       time.Time    `json:"at"`
+	Assignee *UserSummary `json:"assignee,omitempty"`
+}
+
+func runOfShowViews(ev Event, items []RunOfShowItem) []RunOfShowView {
+	var ids []uint
+	for _, it := range items {
+		if it.AssigneeID != nil {
+			ids = append(ids, *it.AssigneeID)
+		}
+	}
+	users := userSummaries(ids)
+	out := make([]RunOfShowView, 0, len(items))
+	for _, it := range items {
+		v := RunOfShowView{
+			RunOfShowItem: it,
+			Label:         offsetLabel(it.OffsetMinutes),
+			At:            ev.Date.Add(time.Duration(it.OffsetMinutes) * time.Minute),
+		}
+		if it.AssigneeID != nil {
+			if u, ok := users[*it.AssigneeID]; ok {
+				v.Assignee = &u
+			}
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func loadRunOfShow(eventID uint) ([]RunOfShowItem, error) {
+	var items []RunOfShowItem
+	err := DB.Where("event_id = ?", eventID).Order("offset_minutes asc, id asc").Find(&items).Error
+	return items, err
+}
+
+func minutesBetween(from, to time.Time) int {
+	return int(to.Sub(from).Round(time.Minute) / time.Minute)
+}
+
+// generatedRunOfShow builds lines from the event's sessions and from the
+// unfinished tasks assigned to someone and due around the start.
+func generatedRunOfShow(tx *gorm.DB, ev Event) ([]RunOfShowItem, error) {
+	var sessions []EventSession
+	if err := tx.Where("event_id = ?", ev.ID).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	if err := tx.Where("event_id = ? AND assignee_id IS NOT NULL AND status <> ?", ev.ID, TaskDone).
+		Where("due_at IS NULL OR due_at BETWEEN ? AND ?", ev.Date.Add(-runOfShowTaskWindow), ev.Date.Add(runOfShowTaskWindow)).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]RunOfShowItem, 0, len(sessions)+len(tasks))
+	for _, s := range sessions {
+		it := RunOfShowItem{
+			EventID:       ev.ID,
+			OffsetMinutes: minutesBetween(ev.Date, s.StartsAt),
+			Title:         s.Title,
+			Notes:         s.Location,
+			Source:        RunOfShowSession,
+			SourceID:      &s.ID,
+		}
+		if s.EndsAt != nil {
+			d := minutesBetween(s.StartsAt, *s.EndsAt)
+			it.DurationMinutes = &d
+		}
+		items = append(items, it)
+	}
+	for _, t := range tasks {
+		due := ev.Date // tasks without a deadline are due at the start
+		if t.DueAt != nil {
+			due = *t.DueAt
+		}
+		items = append(items, RunOfShowItem{
+			EventID:       ev.ID,
+			OffsetMinutes: minutesBetween(ev.Date, due),
+			Title:         t.Title,
+			Notes:         t.Description,
+			AssigneeID:    t.AssigneeID,
+			Source:        RunOfShowTask,
+			SourceID:      &t.ID,
+		})
+	}
+	return items, nil
+}
+
+// GetRunOfShow lists the event's run-of-show, earliest first. Participants
+// can read it; the crew is usually among them.
+func GetRunOfShow(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can see the run-of-show")
+		return
+	}
+
+	items, err := loadRunOfShow(ev.ID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "starts_at": ev.Date, "items": runOfShowViews(ev, items)})
+}
+
+// GenerateRunOfShow rebuilds the generated lines from the current sessions
+// and tasks. Lines edited by hand, and manual ones, are kept.
+func GenerateRunOfShow(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		var kept []RunOfShowItem
+		if err := tx.Where("event_id = ? AND source <> ? AND edited", ev.ID, RunOfShowManual).Find(&kept).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ? AND source <> ? AND NOT edited", ev.ID, RunOfShowManual).
+			Delete(&RunOfShowItem{}).Error; err != nil {
+			return err
+		}
+		items, err := generatedRunOfShow(tx, ev)
+		if err != nil {
+			return err
+		}
+		// an edited line stands in for the session or task it came from
+		editedFrom := map[string]bool{}
+		for _, it := range kept {
+			if it.SourceID != nil {
+				editedFrom[fmt.Sprintf("%s:%d", it.Source, *it.SourceID)] = true
+			}
+		}
+		fresh := items[:0]
+		for _, it := range items {
+			if !editedFrom[fmt.Sprintf("%s:%d", it.Source, *it.SourceID)] {
+				fresh = append(fresh, it)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return tx.Create(&fresh).Error
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not generate run-of-show: "+err.Error())
+		return
+	}
+
+	items, err := loadRunOfShow(ev.ID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "starts_at": ev.Date, "items": runOfShowViews(ev, items)})
+}
+
+type RunOfShowItemRequest struct {
+	ID              *uint  `json:"id"` // an existing line; omit for a new one
+	OffsetMinutes   int    `json:"offset_minutes"`
+	DurationMinutes *int   `json:"duration_minutes"`
+	Title           string `json:"title" binding:"required"`
+	Notes           string `json:"notes"`
+	AssigneeID      *uint  `json:"assignee_id"`
+}
+
+type RunOfShowRequest struct {
+	Items []RunOfShowItemRequest `json:"items" binding:"dive"`
+}
+
+func (r *RunOfShowRequest) validate() error {
+	if len(r.Items) > 500 {
+		return errors.New("a run-of-show has at most 500 lines")
+	}
+	for i := range r.Items {
+		it := &r.Items[i]
+		it.Title = strings.TrimSpace(it.Title)
+		if it.Title == "" || len(it.Title) > 200 {
+			return fmt.Errorf("items[%d]: title must be 1-200 characters", i)
+		}
+		if it.DurationMinutes != nil && *it.DurationMinutes <= 0 {
+			return fmt.Errorf("items[%d]: duration_minutes must be positive", i)
+		}
+	}
+	return nil
+}
+
+// UpdateRunOfShow replaces the run-of-show with the lines in the body.
+// Lines sent with their id keep where they came from but are marked
+// edited; lines left out are removed.
+func UpdateRunOfShow(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body RunOfShowRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if err := body.validate(); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, it := range body.Items {
+		if it.AssigneeID != nil && !isEventParticipant(ev, *it.AssigneeID) {
+			jsonError(c, http.StatusBadRequest, "assignees must be participants of the event")
+			return
+		}
+	}
+
+	errUnknownItem := errors.New("unknown run-of-show line")
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var existing []RunOfShowItem
+		if err := tx.Where("event_id = ?", ev.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		byID := map[uint]RunOfShowItem{}
+		for _, it := range existing {
+			byID[it.ID] = it
+		}
+
+		keep := []uint{}
+		for _, req := range body.Items {
+			it := RunOfShowItem{EventID: ev.ID, Source: RunOfShowManual}
+			if req.ID != nil {
+				old, ok := byID[*req.ID]
+				if !ok {
+					return errUnknownItem
+				}
+				it = old
+				it.Edited = it.Edited || old.OffsetMinutes != req.OffsetMinutes || old.Title != req.Title ||
+					old.Notes != req.Notes || !sameUint(old.AssigneeID, req.AssigneeID) || !sameInt(old.DurationMinutes, req.DurationMinutes)
+			}
+			it.OffsetMinutes, it.DurationMinutes = req.OffsetMinutes, req.DurationMinutes
+			it.Title, it.Notes, it.AssigneeID = req.Title, req.Notes, req.AssigneeID
+			if err := tx.Save(&it).Error; err != nil {
+				return err
+			}
+			keep = append(keep, it.ID)
+		}
+		q := tx.Where("event_id = ?", ev.ID)
+		if len(keep) > 0 {
+			q = q.Where("id NOT IN ?", keep)
+		}
+		return q.Delete(&RunOfShowItem{}).Error
+	})
+	switch {
+	case errors.Is(err, errUnknownItem):
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "could not save run-of-show: "+err.Error())
+		return
+	}
+
+	items, err := loadRunOfShow(ev.ID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "starts_at": ev.Date, "items": runOfShowViews(ev, items)})
+}
+
+func sameUint(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameInt(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// GetRunOfShowPDF renders the run-of-show for printing.
+func GetRunOfShowPDF(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadViewableEvent(c, userID)
+	if !ok {
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can see the run-of-show")
+		return
+	}
+
+	items, err := loadRunOfShow(ev.ID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	out, err := renderRunOfShow(ev, runOfShowViews(ev, items))
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not render run-of-show: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="run-of-show-%d.pdf"`, ev.ID))
+	c.Data(http.StatusOK, "application/pdf", out)
+}
+
+// renderRunOfShow lays the run-of-show out as a table on portrait A4
+// pages, with times in UTC.
+func renderRunOfShow(ev Event, items []RunOfShowView) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle("Run-of-show: "+ev.Title, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+	pdf.AddPage()
+	w, _ := pdf.GetPageSize()
+	content := w - 30
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.MultiCell(content, 9, tr(ev.Title), "", "L", false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(content, 6, tr("Run-of-show - starts "+ev.Date.UTC().Format("Mon 2 January 2006, 15:04 UTC")), "", 1, "L", false, 0, "")
+	if ev.Location != "" {
+		pdf.CellFormat(content, 6, fitText(pdf, tr(ev.Location), content), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	names := map[uint]string{}
+	var ids []uint
+	for _, it := range items {
+		if it.AssigneeID != nil {
+			ids = append(ids, *it.AssigneeID)
+		}
+	}
+	for id, email := range userEmails(ids) {
+		names[id] = email
+	}
+	for _, it := range items {
+		if it.Assignee != nil && it.Assignee.Name != "" {
+			names[it.Assignee.ID] = it.Assignee.Name
+		}
+	}
+
+	cols := []float64{22, 18, 18, content - 98, 40}
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetFillColor(235, 235, 235)
+		for i, head := range []string{"Cue", "Time", "Length", "What", "Who"} {
+			pdf.CellFormat(cols[i], 8, head, "B", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 10)
+	}
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() > 1 {
+			header()
+		}
+	})
+	header()
+
+	for _, it := range items {
+		length := ""
+		if it.DurationMinutes != nil {
+			length = fmt.Sprintf("%d min", *it.DurationMinutes)
+		}
+		who := ""
+		if it.AssigneeID != nil {
+			who = names[*it.AssigneeID]
+		}
+		pdf.CellFormat(cols[0], 7, it.Label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(cols[1], 7, it.At.UTC().Format("15:04"), "", 0, "L", false, 0, "")
+		pdf.CellFormat(cols[2], 7, length, "", 0, "L", false, 0, "")
+		pdf.CellFormat(cols[3], 7, fitText(pdf, tr(it.Title), cols[3]-2), "", 0, "L", false, 0, "")
+		pdf.CellFormat(cols[4], 7, fitText(pdf, tr(who), cols[4]), "", 1, "L", false, 0, "")
+		if it.Notes != "" {
+			pdf.SetFont("Helvetica", "I", 9)
+			pdf.SetTextColor(90, 90, 90)
+			pdf.SetX(15 + cols[0] + cols[1] + cols[2])
+			pdf.MultiCell(cols[3]+cols[4], 5, tr(it.Notes), "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.SetFont("Helvetica", "", 10)
+		}
+	}
+	if len(items) == 0 {
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(content, 8, "Nothing planned yet.", "", 1, "L", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
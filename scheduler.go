@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// background tracks the worker goroutines so shutdown can let a run in
+// progress finish.
+var background sync.WaitGroup
+
+// StartPeriodic runs fn every interval until ctx is cancelled.
+// A panic in fn is logged and does not stop later runs.
+func StartPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context)) {
+	startPeriodic(ctx, name, interval, fn, false)
+}
+
+// StartPeriodicNow is StartPeriodic with a first run right away, for work
+// that has to catch up on what came due while the server was down.
+func StartPeriodicNow(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context)) {
+	startPeriodic(ctx, name, interval, fn, true)
+}
+
+func startPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context), now bool) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		if now && !currentMaintenance().Enabled {
+			runPeriodic(ctx, name, fn)
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// periodic tasks write; skip them while read-only
+				if currentMaintenance().Enabled {
+					continue
+				}
+				runPeriodic(ctx, name, fn)
+			}
+		}
+	}()
+}
+
+func runPeriodic(ctx context.Context, name string, fn func(ctx context.Context)) {
+	ctx, span := startSpan(ctx, "periodic "+name)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ periodic task %s panicked: %v", name, r)
+			endSpan(span, fmt.Errorf("panic: %v", r))
+			return
+		}
+		span.End()
+	}()
+	fn(ctx)
+}
+
+// WaitForBackground waits up to timeout for the job worker and periodic
+// tasks to stop after their context is cancelled. It reports whether they
+// all did.
+func WaitForBackground(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
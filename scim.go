@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimMaxPageSize = 200
+)
+
+var (
+	errScimExists = errors.New("user is already provisioned")
+	errScimDomain = errors.New("new accounts can only be created for the organization's verified domains")
+)
+
+// ========================
+// PROVISIONING TOKENS
+// ========================
+
+// GetScimTokens lists an organization's provisioning tokens (never the secret).
+func GetScimTokens(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var tokens []ScimToken
+	if err := DB.Where("organization_id = ? AND revoked_at IS NULL", orgID).Order("created_at asc").Find(&tokens).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateScimToken issues a bearer token for the IdP's SCIM client. The
+// token is only shown in this response.
+func CreateScimToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token")
+		return
+	}
+	t := ScimToken{OrganizationID: orgID, Name: strings.TrimSpace(body.Name), TokenHash: hashToken(token), CreatedByID: userID}
+	if err := DB.Create(&t).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token: "+err.Error())
+		return
+	}
+	Audit(userID, "scim.token_created", "organization", orgID, gin.H{"token_id": t.ID})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":    t,
+		"secret":   token,
+		"base_url": AppConfig.APIBaseURL + "/scim/v2",
+	})
+}
+
+func RevokeScimToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	res := DB.Model(&ScimToken{}).
+		Where("id = ? AND organization_id = ? AND revoked_at IS NULL", c.Param("tokenId"), orgID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "token not found")
+		return
+	}
+	Audit(userID, "scim.token_revoked", "organization", orgID, gin.H{"token_id": c.Param("tokenId")})
+
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
+
+// ScimAuthMiddleware authenticates the IdP by its provisioning token and
+// scopes the request to that token's organization.
+func ScimAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.GetHeader("Authorization")
+		if !strings.HasPrefix(h, "Bearer ") {
+			scimError(c, http.StatusUnauthorized, "missing bearer token")
+			c.Abort()
+			return
+		}
+
+		var t ScimToken
+		if err := DB.Where("token_hash = ? AND revoked_at IS NULL", hashToken(strings.TrimPrefix(h, "Bearer "))).First(&t).Error; err != nil {
+			scimError(c, http.StatusUnauthorized, "invalid token")
+			c.Abort()
+			return
+		}
+		DB.Model(&t).Update("last_used_at", time.Now())
+
+		c.Set("scim_org_id", t.OrganizationID)
+		c.Next()
+	}
+}
+
+// ========================
+// SCIM RESOURCES
+// ========================
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type scimUserResource struct {
+	Schemas    []string    `json:"schemas"`
+	ID         string      `json:"id"`
+	ExternalID string      `json:"externalId,omitempty"`
+	UserName   string      `json:"userName"`
+	Active     bool        `json:"active"`
+	Emails     []scimEmail `json:"emails"`
+	Meta       scimMeta    `json:"meta"`
+}
+
+// scimUserRequest is the subset of the core User schema we act on; the
+// rest (name, title, ...) is accepted and ignored.
+type scimUserRequest struct {
+	UserName   string      `json:"userName"`
+	ExternalID string      `json:"externalId"`
+	Active     *bool       `json:"active"`
+	Emails     []scimEmail `json:"emails"`
+}
+
+// email prefers userName, then the primary email.
+func (r scimUserRequest) email() string {
+	if strings.Contains(r.UserName, "@") {
+		return strings.ToLower(strings.TrimSpace(r.UserName))
+	}
+	for _, e := range r.Emails {
+		if e.Primary {
+			return strings.ToLower(strings.TrimSpace(e.Value))
+		}
+	}
+	if len(r.Emails) > 0 {
+		return strings.ToLower(strings.TrimSpace(r.Emails[0].Value))
+	}
+	return ""
+}
+
+func scimJSON(c *gin.Context, code int, obj interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(code, obj)
+}
+
+func scimError(c *gin.Context, code int, detail string) {
+	scimJSON(c, code, gin.H{"schemas": []string{scimErrorSchema}, "status": strconv.Itoa(code), "detail": detail})
+}
+
+func scimOrgID(c *gin.Context) uint {
+	return c.GetUint("scim_org_id")
+}
+
+func scimResource(link ScimUser, email string) scimUserResource {
+	return scimUserResource{
+		Schemas:    []string{scimUserSchema},
+		ID:         strconv.FormatUint(uint64(link.UserID), 10),
+		ExternalID: link.ExternalID,
+		UserName:   email,
+		Active:     link.Active,
+		Emails:     []scimEmail{{Value: email, Primary: true}},
+		Meta: scimMeta{
+			ResourceType: "User",
+			Created:      link.CreatedAt,
+			LastModified: link.UpdatedAt,
+			Location:     fmt.Sprintf("%s/scim/v2/Users/%d", AppConfig.APIBaseURL, link.UserID),
+		},
+	}
+}
+
+func loadScimUser(c *gin.Context) (ScimUser, User, bool) {
+	var link ScimUser
+	var user User
+	if err := DB.Where("organization_id = ? AND user_id = ?", scimOrgID(c), c.Param("id")).First(&link).Error; err != nil {
+		scimError(c, http.StatusNotFound, "user not found")
+		return link, user, false
+	}
+	if err := DB.Select("id", "email").First(&user, link.UserID).Error; err != nil {
+		scimError(c, http.StatusNotFound, "user not found")
+		return link, user, false
+	}
+	return link, user, true
+}
+
+// scimSuspensionReason marks bans created by an organization's deprovisioning
+// so reactivation only lifts those.
+func scimSuspensionReason(orgID uint) string {
+	return fmt.Sprintf("deprovisioned by organization %d", orgID)
+}
+
+// setScimActive applies an IdP (de)activation. Deactivating removes the
+// org membership; when the org owns the email domain the account is also
+// banned and signed out everywhere.
+func setScimActive(tx *gorm.DB, link *ScimUser, user User, active bool) error {
+	if link.Active == active {
+		return nil
+	}
+	orgID := link.OrganizationID
+	owned := false
+	if owner, ok := domainOwner(user.Email); ok && owner == orgID {
+		owned = true
+	}
+
+	if !active {
+		if orgRole(orgID, user.ID) == OrgRoleOwner {
+			return errors.New("the organization owner cannot be deprovisioned")
+		}
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, user.ID).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if owned {
+			if err := tx.Create(&UserSuspension{UserID: user.ID, Mode: SuspensionBanned, Reason: scimSuspensionReason(orgID)}).Error; err != nil {
+				return err
+			}
+			tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", time.Now())
+		}
+	} else {
+		m := OrganizationMember{OrganizationID: orgID, UserID: user.ID}
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, user.ID).
+			FirstOrCreate(&m, OrganizationMember{Role: OrgRoleMember}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&UserSuspension{}).
+			Where("user_id = ? AND reason = ? AND lifted_at IS NULL", user.ID, scimSuspensionReason(orgID)).
+			Update("lifted_at", time.Now()).Error; err != nil {
+			return err
+		}
+	}
+
+	link.Active = active
+	return tx.Model(link).Update("active", active).Error
+}
+
+// changeScimEmail renames the account; only allowed for domains the org owns.
+func changeScimEmail(tx *gorm.DB, orgID uint, user *User, email string) error {
+	if email == "" || email == strings.ToLower(user.Email) {
+		return nil
+	}
+	for _, e := range []string{user.Email, email} {
+		if owner, ok := domainOwner(e); !ok || owner != orgID {
+			return errors.New("userName can only change within the organization's verified domains")
+		}
+	}
+	if err := tx.Model(user).Update("email", email).Error; err != nil {
+		return errors.New("userName already in use")
+	}
+	user.Email = email
+	return nil
+}
+
+var scimFilterRe = regexp.MustCompile(`^(?i)(userName|externalId)\s+eq\s+"([^"]*)"$`)
+
+// ScimListUsers supports the `userName eq "..."` and `externalId eq "..."`
+// filters IdPs use to look up existing users, with startIndex/count paging.
+func ScimListUsers(c *gin.Context) {
+	orgID := scimOrgID(c)
+	q := DB.Model(&ScimUser{}).Where("scim_users.organization_id = ?", orgID)
+
+	if f := strings.TrimSpace(c.Query("filter")); f != "" {
+		m := scimFilterRe.FindStringSubmatch(f)
+		if m == nil {
+			scimError(c, http.StatusBadRequest, "unsupported filter")
+			return
+		}
+		if strings.EqualFold(m[1], "userName") {
+			q = q.Joins("JOIN users ON users.id = scim_users.user_id").Where("LOWER(users.email) = ?", strings.ToLower(m[2]))
+		} else {
+			q = q.Where("scim_users.external_id = ?", m[2])
+		}
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		scimError(c, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	start, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+	if count > scimMaxPageSize {
+		count = scimMaxPageSize
+	}
+
+	var links []ScimUser
+	if err := q.Order("scim_users.id asc").Offset(start - 1).Limit(count).Find(&links).Error; err != nil {
+		scimError(c, http.StatusInternalServerError, "db error")
+		return
+	}
+	ids := make([]uint, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.UserID)
+	}
+	emails := userEmails(ids)
+
+	resources := make([]scimUserResource, 0, len(links))
+	for _, l := range links {
+		resources = append(resources, scimResource(l, emails[l.UserID]))
+	}
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   start,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func ScimGetUser(c *gin.Context) {
+	link, user, ok := loadScimUser(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, scimResource(link, user.Email))
+}
+
+// ScimCreateUser provisions a user into the organization. Existing accounts
+// are linked; new accounts are only created for the org's verified domains.
+func ScimCreateUser(c *gin.Context) {
+	orgID := scimOrgID(c)
+
+	var body scimUserRequest
+	if err := bindJSON(c, &body); err != nil {
+		scimError(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	email := body.email()
+	if email == "" {
+		scimError(c, http.StatusBadRequest, "userName must be an email address")
+		return
+	}
+
+	var user User
+	var link ScimUser
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Select("id", "email").Where("LOWER(email) = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if owner, ok := domainOwner(email); !ok || owner != orgID {
+				return errScimDomain
+			}
+			// random password: provisioned users sign in through SSO
+			password, _ := randomToken(32)
+			user = User{Email: email, Password: password}
+			err = tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var n int64
+		tx.Model(&ScimUser{}).Where("organization_id = ? AND user_id = ?", orgID, user.ID).Count(&n)
+		if n > 0 {
+			return errScimExists
+		}
+		link = ScimUser{OrganizationID: orgID, UserID: user.ID, ExternalID: body.ExternalID, Active: false}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		return setScimActive(tx, &link, user, body.Active == nil || *body.Active)
+	})
+	switch {
+	case errors.Is(err, errScimExists):
+		scimJSON(c, http.StatusConflict, gin.H{"schemas": []string{scimErrorSchema}, "status": "409", "scimType": "uniqueness", "detail": err.Error()})
+		return
+	case errors.Is(err, errScimDomain):
+		scimError(c, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	Audit(0, "scim.user_provisioned", "organization", orgID, gin.H{"user_id": user.ID})
+	scimJSON(c, http.StatusCreated, scimResource(link, user.Email))
+}
+
+// ScimReplaceUser handles PUT: the IdP sends the full resource.
+func ScimReplaceUser(c *gin.Context) {
+	link, user, ok := loadScimUser(c)
+	if !ok {
+		return
+	}
+
+	var body scimUserRequest
+	if err := bindJSON(c, &body); err != nil {
+		scimError(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := changeScimEmail(tx, link.OrganizationID, &user, body.email()); err != nil {
+			return err
+		}
+		if err := tx.Model(&link).Update("external_id", body.ExternalID).Error; err != nil {
+			return err
+		}
+		return setScimActive(tx, &link, user, body.Active == nil || *body.Active)
+	})
+	if err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	Audit(0, "scim.user_updated", "organization", link.OrganizationID, gin.H{"user_id": user.ID, "active": link.Active})
+	scimJSON(c, http.StatusOK, scimResource(link, user.Email))
+}
+
+type scimPatchRequest struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// ScimPatchUser handles PATCH with replace/add operations on active,
+// userName and externalId, in both the path and the value-object forms.
+func ScimPatchUser(c *gin.Context) {
+	link, user, ok := loadScimUser(c)
+	if !ok {
+		return
+	}
+
+	var body scimPatchRequest
+	if err := bindJSON(c, &body); err != nil {
+		scimError(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, op := range body.Operations {
+			switch strings.ToLower(op.Op) {
+			case "replace", "add":
+			default:
+				return fmt.Errorf("unsupported op %q", op.Op)
+			}
+
+			attrs := map[string]json.RawMessage{}
+			if op.Path != "" {
+				attrs[op.Path] = op.Value
+			} else if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return errors.New("value must be an object when path is omitted")
+			}
+
+			for path, raw := range attrs {
+				switch strings.ToLower(path) {
+				case "active":
+					var active bool
+					if err := json.Unmarshal(raw, &active); err != nil {
+						// some IdPs send "False"/"True" as strings
+						var s string
+						if json.Unmarshal(raw, &s) != nil {
+							return errors.New("active must be a boolean")
+						}
+						active = strings.EqualFold(s, "true")
+					}
+					if err := setScimActive(tx, &link, user, active); err != nil {
+						return err
+					}
+				case "username":
+					var email string
+					if err := json.Unmarshal(raw, &email); err != nil {
+						return errors.New("userName must be a string")
+					}
+					if err := changeScimEmail(tx, link.OrganizationID, &user, strings.ToLower(strings.TrimSpace(email))); err != nil {
+						return err
+					}
+				case "externalid":
+					var ext string
+					if err := json.Unmarshal(raw, &ext); err != nil {
+						return errors.New("externalId must be a string")
+					}
+					if err := tx.Model(&link).Update("external_id", ext).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	Audit(0, "scim.user_updated", "organization", link.OrganizationID, gin.H{"user_id": user.ID, "active": link.Active})
+	scimJSON(c, http.StatusOK, scimResource(link, user.Email))
+}
+
+// ScimDeleteUser deprovisions the user and forgets the IdP link. The
+// account itself is kept so their event history stays intact.
+func ScimDeleteUser(c *gin.Context) {
+	link, user, ok := loadScimUser(c)
+	if !ok {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := setScimActive(tx, &link, user, false); err != nil {
+			return err
+		}
+		return tx.Delete(&link).Error
+	})
+	if err != nil {
+		scimError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	Audit(0, "scim.user_deprovisioned", "organization", link.OrganizationID, gin.H{"user_id": user.ID})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersMiddleware sets conservative response headers on every request.
+// Values come from AppConfig so deployments can loosen them without a rebuild.
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+
+		h.Set("X-Content-Type-Options", "nosniff")
+		if AppConfig.FrameOptions != "" {
+			h.Set("X-Frame-Options", AppConfig.FrameOptions)
+		}
+		if AppConfig.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", AppConfig.ReferrerPolicy)
+		}
+		if AppConfig.APIContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", AppConfig.APIContentSecurityPolicy)
+		}
+
+		// HSTS only makes sense when the client actually reached us over TLS
+		if AppConfig.HSTSMaxAge > 0 && isTLSRequest(c) {
+			h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", AppConfig.HSTSMaxAge))
+		}
+
+		c.Next()
+	}
+}
+
+// PageCSP swaps the API policy for the one used by HTML pages (share/preview).
+func PageCSP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if AppConfig.PageContentSecurityPolicy != "" {
+			c.Header("Content-Security-Policy", AppConfig.PageContentSecurityPolicy)
+		}
+		c.Next()
+	}
+}
+
+func isTLSRequest(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
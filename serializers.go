@@ -0,0 +1,381 @@
+package main
+
+import "time"
+
+// Responses never return GORM models verbatim: each handler projects what
+// it loaded through one of the views below, depending on the caller's role.
+
+// ========================
+// EVENTS
+// ========================
+
+// EventPrivate holds the organizer-only fields of an event.
+type EventPrivate struct {
+	PrivateNotes   string `json:"private_notes"`
+	VendorContacts string `json:"vendor_contacts"`
+	BudgetDetails  string `json:"budget_details"`
+}
+
+// EventView is an event as seen by a particular caller. Timestamps and
+// Private are only set for organizers.
+type EventView struct {
+	ID              uint          `json:"id"`
+	Title           string        `json:"title"`
+	Description     string        `json:"description"`
+	DescriptionHTML string        `json:"description_html"`
+	Location        string        `json:"location"`
+	Date            time.Time     `json:"date"`
+	OrganizerID     uint          `json:"organizer_id"`
+	MaxAttendees    *int          `json:"max_attendees,omitempty"`
+	Accessibility   Accessibility `json:"accessibility"`
+	LinkPreviews    []LinkPreview `json:"link_previews,omitempty"`
+	IsOrganizer     bool          `json:"is_organizer"`
+
+	MinAttendees     *int       `json:"min_attendees,omitempty"`
+	DecisionDeadline *time.Time `json:"decision_deadline,omitempty"`
+	AutoCancel       bool       `json:"auto_cancel"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason     string     `json:"cancel_reason,omitempty"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
+	Reminders        []int      `json:"reminders"`
+	NudgeDaysBefore  int        `json:"nudge_days_before"`
+	OrganizationID   *uint      `json:"organization_id,omitempty"`
+	Visibility       string     `json:"visibility"`
+	PublicCard       bool       `json:"public_card"`
+	Slug             *string    `json:"slug,omitempty"`
+	Tasks            []TaskView `json:"tasks,omitempty"`
+
+	// Set for recurring events; listings add the occurrences in their window
+	Recurrence  *RecurrenceView `json:"recurrence,omitempty"`
+	Occurrences []Occurrence    `json:"occurrences,omitempty"`
+
+	CreatedAt *time.Time    `json:"created_at,omitempty"`
+	UpdatedAt *time.Time    `json:"updated_at,omitempty"`
+	Private   *EventPrivate `json:"private,omitempty"`
+
+	// Signed URLs of the cover image at each size; see cover.go
+	Cover *CoverView `json:"cover,omitempty"`
+
+	// Readiness score, on organizer listings; see health.go
+	Health *EventHealth `json:"health,omitempty"`
+
+	// Non-blocking notes for the caller, e.g. the date is a public holiday
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func eventView(ev Event, isOrganizer bool) EventView {
+	v := EventView{
+		ID:              ev.ID,
+		Title:           ev.Title,
+		Description:     ev.Description,
+		DescriptionHTML: ev.DescriptionHTML,
+		Location:        ev.Location,
+		Date:            ev.Date,
+		OrganizerID:     ev.OrganizerID,
+		MaxAttendees:    ev.MaxAttendees,
+		Accessibility:   ev.Accessibility,
+		LinkPreviews:    ev.LinkPreviews,
+		IsOrganizer:     isOrganizer,
+
+		MinAttendees:     ev.MinAttendees,
+		DecisionDeadline: ev.DecisionDeadline,
+		AutoCancel:       ev.AutoCancel,
+		CancelledAt:      ev.CancelledAt,
+		CancelReason:     ev.CancelReason,
+		ArchivedAt:       ev.ArchivedAt,
+		Reminders:        reminderMinutes(ev),
+		NudgeDaysBefore:  nudgeDaysBefore(ev),
+		OrganizationID:   ev.OrganizationID,
+		Visibility:       ev.Visibility,
+		PublicCard:       ev.PublicCard,
+		Slug:             ev.Slug,
+		Recurrence:       recurrenceView(ev),
+		Cover:            coverView(ev),
+	}
+	if len(ev.Tasks) > 0 {
+		v.Tasks = taskViews(ev.Tasks)
+	}
+	if v.Visibility == "" {
+		v.Visibility = VisibilityInvited
+	}
+	if isOrganizer {
+		createdAt, updatedAt := ev.CreatedAt, ev.UpdatedAt
+		v.CreatedAt = &createdAt
+		v.UpdatedAt = &updatedAt
+		v.Private = &EventPrivate{
+			PrivateNotes:   ev.PrivateNotes,
+			VendorContacts: ev.VendorContacts,
+			BudgetDetails:  ev.BudgetDetails,
+		}
+	}
+	return v
+}
+
+// organizedEventSet returns which of the given events the user organizes.
+func organizedEventSet(userID uint, ids []uint) map[uint]bool {
+	set := map[uint]bool{}
+	if len(ids) == 0 {
+		return set
+	}
+	var owned []uint
+	DB.Model(&Event{}).Where("id IN ? AND (organizer_id = ? OR organizer_id IN (?))", ids, userID, principalsQuery(userID)).Pluck("id", &owned)
+	var coOrganized []uint
+	DB.Model(&EventAttendee{}).Where("event_id IN ? AND user_id = ? AND role = ?", ids, userID, "organizer").
+		Where("invitation IN ?", attendingInvitation).Pluck("event_id", &coOrganized)
+	for _, id := range append(owned, coOrganized...) {
+		set[id] = true
+	}
+	return set
+}
+
+// eventViews projects a list of events for the caller with one role lookup.
+func eventViews(events []Event, userID uint) []EventView {
+	ids := make([]uint, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	organizes := organizedEventSet(userID, ids)
+	out := make([]EventView, 0, len(events))
+	for _, e := range events {
+		out = append(out, eventView(e, organizes[e.ID]))
+	}
+	return out
+}
+
+// ========================
+// TASKS
+// ========================
+
+type TaskView struct {
+	ID          uint      `json:"id"`
+	EventID     uint      `json:"event_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	Position    int       `json:"position"`
+	VendorID    *uint     `json:"vendor_id,omitempty"`
+	AssigneeID  *uint     `json:"assignee_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CompletedByID *uint      `json:"completed_by_id,omitempty"`
+	DueAt         *time.Time `json:"due_at,omitempty"`
+}
+
+func taskView(t Task) TaskView {
+	return TaskView{
+		ID:          t.ID,
+		EventID:     t.EventID,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      t.Status,
+		Position:    t.Position,
+		VendorID:    t.VendorID,
+		AssigneeID:  t.AssigneeID,
+		CreatedAt:   t.CreatedAt,
+
+		CompletedAt:   t.CompletedAt,
+		CompletedByID: t.CompletedByID,
+		DueAt:         t.DueAt,
+	}
+}
+
+func taskViews(tasks []Task) []TaskView {
+	out := make([]TaskView, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, taskView(t))
+	}
+	return out
+}
+
+// ========================
+// ATTENDEES
+// ========================
+
+// AttendeeView is one participant of an event. Email is only filled in
+// for organizers and for the caller's own row.
+type AttendeeView struct {
+	ID          uint        `json:"id"`
+	EventID     uint        `json:"event_id"`
+	UserID      uint        `json:"user_id"`
+	User        UserSummary `json:"user"`
+	Email       string      `json:"email,omitempty"`
+	Role        string      `json:"role"`
+	Status      string      `json:"status"`
+	InvitedByID *uint       `json:"invited_by_id,omitempty"`
+
+	Labels      []string   `json:"labels,omitempty"`        // organizers only
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"` // organizers only
+
+	TicketTierID *uint  `json:"ticket_tier_id,omitempty"` // organizers and the attendee
+	Invitation   string `json:"invitation,omitempty"`     // organizers and the attendee
+
+	Refund    *RefundQuote `json:"refund,omitempty"`     // right after giving up a ticket
+	InvoiceID *uint        `json:"invoice_id,omitempty"` // right after buying one
+}
+
+// attendeeViews projects attendee rows for viewerID; isOrganizer widens
+// the projection to emails and who invited whom.
+func attendeeViews(attendees []EventAttendee, viewerID uint, isOrganizer bool) []AttendeeView {
+	ids := make([]uint, 0, len(attendees))
+	all := make([]uint, 0, len(attendees))
+	for _, a := range attendees {
+		if isOrganizer || a.UserID == viewerID {
+			ids = append(ids, a.UserID)
+		}
+		all = append(all, a.UserID)
+	}
+	emails := userEmails(ids)
+	users := userSummaries(all)
+
+	out := make([]AttendeeView, 0, len(attendees))
+	for _, a := range attendees {
+		v := AttendeeView{
+			ID:      a.ID,
+			EventID: a.EventID,
+			UserID:  a.UserID,
+			User:    users[a.UserID],
+			Email:   emails[a.UserID],
+			Role:    a.Role,
+			Status:  a.Status,
+		}
+		if isOrganizer || a.UserID == viewerID {
+			v.TicketTierID = a.TicketTierID
+			v.Invitation = a.Invitation
+		}
+		if isOrganizer {
+			v.InvitedByID = a.InvitedByID
+			v.Labels = attendeeLabels(a)
+			v.CheckedInAt = a.CheckedInAt
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func attendeeView(a EventAttendee, viewerID uint, isOrganizer bool) AttendeeView {
+	return attendeeViews([]EventAttendee{a}, viewerID, isOrganizer)[0]
+}
+
+// ========================
+// ORGANIZATIONS
+// ========================
+
+// MemberView is one organization member. Only org admins see emails
+// other than their own.
+type MemberView struct {
+	UserID   uint      `json:"user_id"`
+	Email    string    `json:"email,omitempty"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func memberViews(members []OrganizationMember, viewerID uint, isAdmin bool) []MemberView {
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		if isAdmin || m.UserID == viewerID {
+			ids = append(ids, m.UserID)
+		}
+	}
+	emails := userEmails(ids)
+
+	out := make([]MemberView, 0, len(members))
+	for _, m := range members {
+		out = append(out, MemberView{
+			UserID:   m.UserID,
+			Email:    emails[m.UserID],
+			Role:     m.Role,
+			JoinedAt: m.CreatedAt,
+		})
+	}
+	return out
+}
+
+// ========================
+// ANNOUNCEMENTS
+// ========================
+
+type AnnouncementView struct {
+	ID        uint      `json:"id"`
+	EventID   uint      `json:"event_id"`
+	AuthorID  uint      `json:"author_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	BodyHTML  string    `json:"body_html"`
+	Audience  string    `json:"audience"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func announcementView(a Announcement) AnnouncementView {
+	return AnnouncementView{
+		ID:        a.ID,
+		EventID:   a.EventID,
+		AuthorID:  a.AuthorID,
+		Title:     a.Title,
+		Body:      a.Body,
+		BodyHTML:  a.BodyHTML,
+		Audience:  a.Audience,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+type CommentView struct {
+	ID          uint      `json:"id"`
+	EventID     uint      `json:"event_id"`
+	AuthorID    uint      `json:"author_id"`
+	AuthorEmail string    `json:"author_email,omitempty"`
+	Body        string    `json:"body"`
+	BodyHTML    string    `json:"body_html"`
+	Source      string    `json:"source"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	EditedAt     *time.Time    `json:"edited_at,omitempty"`
+	LinkPreviews []LinkPreview `json:"link_previews,omitempty"`
+}
+
+// commentViews hides author emails except to organizers and the author,
+// like attendeeViews.
+func commentViews(list []EventComment, viewerID uint, isOrganizer bool) []CommentView {
+	ids := make([]uint, 0, len(list))
+	texts := make([]string, 0, len(list))
+	for _, cm := range list {
+		if isOrganizer || cm.AuthorID == viewerID {
+			ids = append(ids, cm.AuthorID)
+		}
+		texts = append(texts, cm.Body)
+	}
+	emails := userEmails(ids)
+	previews := loadPreviewCache(texts...)
+
+	out := make([]CommentView, 0, len(list))
+	for _, cm := range list {
+		out = append(out, CommentView{
+			ID:          cm.ID,
+			EventID:     cm.EventID,
+			AuthorID:    cm.AuthorID,
+			AuthorEmail: emails[cm.AuthorID],
+			Body:        cm.Body,
+			BodyHTML:    cm.BodyHTML,
+			Source:      cm.Source,
+			CreatedAt:   cm.CreatedAt,
+
+			EditedAt:     cm.EditedAt,
+			LinkPreviews: previewsFor(cm.Body, previews),
+		})
+	}
+	return out
+}
+
+// userEmails loads the emails of the given users in one query.
+func userEmails(ids []uint) map[uint]string {
+	out := map[uint]string{}
+	if len(ids) == 0 {
+		return out
+	}
+	var users []User
+	DB.Select("id", "email").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		out[u.ID] = u.Email
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// last_used_at is only written once per interval to keep auth cheap
+const sessionTouchInterval = time.Minute
+
+var errSessionInvalid = errors.New("session invalid")
+
+func StartSession(c *gin.Context, userID uint) (*Session, error) {
+	now := time.Now()
+	s := Session{
+		UserID:     userID,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		ExpiresAt:  now.Add(refreshTokenTTL),
+		LastUsedAt: now,
+	}
+	if err := DB.Create(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// TouchSession checks the session is still live and bumps last_used_at.
+func TouchSession(sessionID, userID uint) error {
+	var s Session
+	if err := DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&s).Error; err != nil {
+		return errSessionInvalid
+	}
+	now := time.Now()
+	if s.RevokedAt != nil || now.After(s.ExpiresAt) {
+		return errSessionInvalid
+	}
+	if now.Sub(s.LastUsedAt) > sessionTouchInterval {
+		DB.Model(&Session{}).Where("id = ?", s.ID).Update("last_used_at", now)
+	}
+	return nil
+}
+
+func currentSessionID(c *gin.Context) uint {
+	if v, ok := c.Get("session_id"); ok {
+		if id, ok := v.(uint); ok {
+			return id
+		}
+	}
+	return 0
+}
+
+func GetMySessions(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var sessions []Session
+	if err := DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at desc").
+		Find(&sessions).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	current := currentSessionID(c)
+	out := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, gin.H{
+			"id":           s.ID,
+			"user_agent":   s.UserAgent,
+			"ip":           s.IP,
+			"created_at":   s.CreatedAt,
+			"last_used_at": s.LastUsedAt,
+			"expires_at":   s.ExpiresAt,
+			"current":      s.ID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+func RevokeMySession(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	res := DB.Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "session not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
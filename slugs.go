@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const (
+	minSlugLength = 3
+	maxSlugLength = 64
+)
+
+// reservedSlugs would read as app pages or official accounts.
+var reservedSlugs = map[string]bool{
+	"admin": true, "api": true, "app": true, "auth": true, "login": true, "logout": true,
+	"signup": true, "register": true, "settings": true, "account": true, "me": true,
+	"events": true, "event": true, "new": true, "edit": true, "create": true, "search": true,
+	"help": true, "support": true, "about": true, "terms": true, "privacy": true,
+	"static": true, "assets": true, "widget": true, "sso": true, "scim": true,
+	"eventplanner": true, "official": true, "null": true, "undefined": true,
+}
+
+var (
+	errSlugInvalid  = errors.New("slug must be 3-64 lowercase letters, digits or single dashes")
+	errSlugReserved = errors.New("this slug is reserved")
+	errSlugTaken    = errors.New("this slug is already taken")
+)
+
+func validateSlug(slug string) error {
+	if len(slug) < minSlugLength || len(slug) > maxSlugLength || !slugPattern.MatchString(slug) {
+		return errSlugInvalid
+	}
+	if reservedSlugs[slug] {
+		return errSlugReserved
+	}
+	return nil
+}
+
+// eventURL is the link to an event, by slug when it has one that resolves.
+func eventURL(ev Event) string {
+	if ev.Slug != nil && ev.PublicCard {
+		return AppConfig.PublicBaseURL + "/e/" + *ev.Slug
+	}
+	return fmt.Sprintf("%s/events/%d", AppConfig.PublicBaseURL, ev.ID)
+}
+
+type SlugRequest struct {
+	Slug *string `json:"slug"` // null clears it
+}
+
+// SetEventSlug sets or clears the event's vanity slug. The previous slug
+// keeps redirecting to the event and can't be taken by anyone else.
+func SetEventSlug(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body SlugRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	var slug *string
+	if body.Slug != nil {
+		s := strings.ToLower(strings.TrimSpace(*body.Slug))
+		if err := validateSlug(s); err != nil {
+			jsonError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		slug = &s
+	}
+	if slug != nil && ev.Slug != nil && *slug == *ev.Slug {
+		c.JSON(http.StatusOK, eventView(ev, true))
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if slug != nil {
+			var n int64
+			tx.Model(&Event{}).Unscoped().Where("slug = ? AND id <> ?", *slug, ev.ID).Count(&n)
+			if n > 0 {
+				return errSlugTaken
+			}
+			tx.Model(&EventSlugHistory{}).Where("slug = ? AND event_id <> ?", *slug, ev.ID).Count(&n)
+			if n > 0 {
+				return errSlugTaken
+			}
+			// taking back one of our own old slugs
+			if err := tx.Where("slug = ?", *slug).Delete(&EventSlugHistory{}).Error; err != nil {
+				return err
+			}
+		}
+		if ev.Slug != nil {
+			if err := tx.Create(&EventSlugHistory{Slug: *ev.Slug, EventID: ev.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Event{}).Where("id = ?", ev.ID).Update("slug", slug).Error
+	})
+	if errors.Is(err, errSlugTaken) {
+		jsonError(c, http.StatusConflict, errSlugTaken.Error())
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update slug: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	DB.First(&ev, ev.ID)
+
+	c.JSON(http.StatusOK, eventView(ev, true))
+}
+
+// GetEventBySlug resolves /e/:slug to the event's summary card. Old slugs
+// answer with a 308 to the current one. Only events with a published
+// summary card resolve; the rest look like unknown slugs.
+func GetEventBySlug(c *gin.Context) {
+	slug := strings.ToLower(c.Param("slug"))
+
+	var ev Event
+	if err := DB.Where("slug = ?", slug).First(&ev).Error; err != nil {
+		var old EventSlugHistory
+		if DB.Where("slug = ?", slug).First(&old).Error != nil || DB.First(&ev, old.EventID).Error != nil || !ev.PublicCard {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		location := fmt.Sprintf("/events/%d/summary-card", ev.ID)
+		if ev.Slug != nil {
+			location = "/e/" + *ev.Slug
+		}
+		c.Header("Location", location)
+		c.JSON(http.StatusPermanentRedirect, gin.H{"error": "slug was changed", "slug": ev.Slug, "event_id": ev.ID})
+		return
+	}
+	if !ev.PublicCard {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+
+	card, ok := publicSummaryCard(c, ev)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, card)
+}
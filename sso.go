@@ -0,0 +1,927 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	SSOProtocolOIDC = "oidc"
+	SSOProtocolSAML = "saml"
+
+	ssoStateTTL = 10 * time.Minute
+)
+
+var ssoHTTPClient = newHTTPClient(15 * time.Second)
+
+var errSSORequired = errors.New("single sign-on required for this email domain")
+
+// ========================
+// CONFIGURATION
+// ========================
+
+type SSOConfigRequest struct {
+	Protocol         string            `json:"protocol" binding:"required"` // oidc or saml
+	Enabled          bool              `json:"enabled"`
+	OIDCIssuer       string            `json:"oidc_issuer"`
+	OIDCClientID     string            `json:"oidc_client_id"`
+	OIDCClientSecret string            `json:"oidc_client_secret"` // kept when empty
+	SAMLMetadataURL  string            `json:"saml_metadata_url"`
+	SAMLMetadataXML  string            `json:"saml_metadata_xml"`
+	RoleClaim        string            `json:"role_claim"`
+	RoleMapping      map[string]string `json:"role_mapping"` // IdP group -> member/admin
+	DefaultRole      string            `json:"default_role"`
+}
+
+type ssoConfigResponse struct {
+	OrganizationID  uint              `json:"organization_id"`
+	Protocol        string            `json:"protocol"`
+	Enabled         bool              `json:"enabled"`
+	OIDCIssuer      string            `json:"oidc_issuer,omitempty"`
+	OIDCClientID    string            `json:"oidc_client_id,omitempty"`
+	SAMLMetadataURL string            `json:"saml_metadata_url,omitempty"`
+	RoleClaim       string            `json:"role_claim,omitempty"`
+	RoleMapping     map[string]string `json:"role_mapping"`
+	DefaultRole     string            `json:"default_role"`
+	Domains         []SSODomain       `json:"domains"`
+	LoginURL        string            `json:"login_url"`
+	RedirectURI     string            `json:"redirect_uri,omitempty"` // register at the IdP
+	SAMLMetadata    string            `json:"saml_sp_metadata_url,omitempty"`
+}
+
+func roleMapping(cfg OrgSSOConfig) map[string]string {
+	m := map[string]string{}
+	if cfg.RoleMapping != "" {
+		json.Unmarshal([]byte(cfg.RoleMapping), &m)
+	}
+	return m
+}
+
+func ssoURL(orgID uint, suffix string) string {
+	return fmt.Sprintf("%s/sso/%d/%s", AppConfig.APIBaseURL, orgID, suffix)
+}
+
+func ssoConfigView(cfg OrgSSOConfig) ssoConfigResponse {
+	var domains []SSODomain
+	DB.Where("organization_id = ?", cfg.OrganizationID).Order("domain asc").Find(&domains)
+	out := ssoConfigResponse{
+		OrganizationID:  cfg.OrganizationID,
+		Protocol:        cfg.Protocol,
+		Enabled:         cfg.Enabled,
+		OIDCIssuer:      cfg.OIDCIssuer,
+		OIDCClientID:    cfg.OIDCClientID,
+		SAMLMetadataURL: cfg.SAMLMetadataURL,
+		RoleClaim:       cfg.RoleClaim,
+		RoleMapping:     roleMapping(cfg),
+		DefaultRole:     cfg.DefaultRole,
+		Domains:         domains,
+		LoginURL:        ssoURL(cfg.OrganizationID, "login"),
+	}
+	if cfg.Protocol == SSOProtocolOIDC {
+		out.RedirectURI = ssoURL(cfg.OrganizationID, "oidc/callback")
+	} else {
+		out.SAMLMetadata = ssoURL(cfg.OrganizationID, "saml/metadata")
+	}
+	return out
+}
+
+// orgAdminParam parses :id and checks the caller administers the organization.
+func orgAdminParam(c *gin.Context, userID uint) (uint, bool) {
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid organization id")
+		return 0, false
+	}
+	if !isOrgAdmin(uint(orgID), userID) {
+		jsonError(c, http.StatusForbidden, "only organization admins can manage SSO")
+		return 0, false
+	}
+	return uint(orgID), true
+}
+
+func GetOrgSSOConfig(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var cfg OrgSSOConfig
+	if err := DB.Where("organization_id = ?", orgID).First(&cfg).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "SSO is not configured")
+		return
+	}
+	c.JSON(http.StatusOK, ssoConfigView(cfg))
+}
+
+// PutOrgSSOConfig creates or replaces the organization's IdP settings.
+// OIDC issuers are checked by fetching their discovery document; SAML
+// metadata is fetched once from the URL and stored.
+func PutOrgSSOConfig(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var body SSOConfigRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	cfg := OrgSSOConfig{OrganizationID: orgID}
+	DB.Where("organization_id = ?", orgID).First(&cfg)
+
+	cfg.Protocol = strings.ToLower(body.Protocol)
+	cfg.Enabled = body.Enabled
+	cfg.RoleClaim = strings.TrimSpace(body.RoleClaim)
+	cfg.DefaultRole = body.DefaultRole
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = OrgRoleMember
+	}
+	if cfg.DefaultRole != OrgRoleMember && cfg.DefaultRole != OrgRoleAdmin {
+		jsonError(c, http.StatusBadRequest, "default_role must be member or admin")
+		return
+	}
+	for group, role := range body.RoleMapping {
+		if role != OrgRoleMember && role != OrgRoleAdmin {
+			jsonError(c, http.StatusBadRequest, "role_mapping for "+group+" must be member or admin")
+			return
+		}
+	}
+	mapping, _ := json.Marshal(body.RoleMapping)
+	cfg.RoleMapping = string(mapping)
+
+	switch cfg.Protocol {
+	case SSOProtocolOIDC:
+		cfg.OIDCIssuer = strings.TrimRight(strings.TrimSpace(body.OIDCIssuer), "/")
+		cfg.OIDCClientID = strings.TrimSpace(body.OIDCClientID)
+		if body.OIDCClientSecret != "" {
+			cfg.OIDCClientSecret = body.OIDCClientSecret
+		}
+		if cfg.OIDCIssuer == "" || cfg.OIDCClientID == "" || cfg.OIDCClientSecret == "" {
+			jsonError(c, http.StatusBadRequest, "oidc_issuer, oidc_client_id and oidc_client_secret are required")
+			return
+		}
+		if _, err := discoverOIDC(c.Request.Context(), cfg.OIDCIssuer); err != nil {
+			jsonError(c, http.StatusBadRequest, "OIDC discovery failed: "+err.Error())
+			return
+		}
+	case SSOProtocolSAML:
+		cfg.SAMLMetadataURL = strings.TrimSpace(body.SAMLMetadataURL)
+		raw := body.SAMLMetadataXML
+		if raw == "" && cfg.SAMLMetadataURL != "" {
+			fetched, err := fetchSAMLMetadata(c.Request.Context(), cfg.SAMLMetadataURL)
+			if err != nil {
+				jsonError(c, http.StatusBadRequest, "could not fetch SAML metadata: "+err.Error())
+				return
+			}
+			raw = fetched
+		}
+		if raw == "" {
+			raw = cfg.SAMLMetadataXML
+		}
+		var md saml.EntityDescriptor
+		if err := xml.Unmarshal([]byte(raw), &md); err != nil || md.EntityID == "" {
+			jsonError(c, http.StatusBadRequest, "saml_metadata_xml or saml_metadata_url with valid IdP metadata is required")
+			return
+		}
+		cfg.SAMLMetadataXML = raw
+	default:
+		jsonError(c, http.StatusBadRequest, "protocol must be oidc or saml")
+		return
+	}
+
+	if err := DB.Save(&cfg).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save SSO config: "+err.Error())
+		return
+	}
+	Audit(userID, "sso.configure", "organization", orgID, gin.H{"protocol": cfg.Protocol, "enabled": cfg.Enabled})
+
+	c.JSON(http.StatusOK, ssoConfigView(cfg))
+}
+
+// ========================
+// DOMAINS
+// ========================
+
+// AddSSODomain claims an email domain for the organization. It only routes
+// logins once the DNS TXT record returned here is published and verified.
+func AddSSODomain(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Domain string `json:"domain" binding:"required"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(body.Domain))
+	if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@/ ") {
+		jsonError(c, http.StatusBadRequest, "invalid domain")
+		return
+	}
+
+	token, err := randomToken(16)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not create token")
+		return
+	}
+	d := SSODomain{OrganizationID: orgID, Domain: domain, VerificationToken: token}
+	if err := DB.Create(&d).Error; err != nil {
+		jsonError(c, http.StatusConflict, "domain already claimed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"domain":     d,
+		"txt_record": gin.H{"name": "_eventplanner-verify." + domain, "value": "eventplanner-verify=" + token},
+	})
+}
+
+func VerifySSODomain(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	var d SSODomain
+	if err := DB.Where("organization_id = ? AND domain = ?", orgID, strings.ToLower(c.Param("domain"))).First(&d).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "domain not found")
+		return
+	}
+
+	records, err := net.DefaultResolver.LookupTXT(c.Request.Context(), "_eventplanner-verify."+d.Domain)
+	if err != nil {
+		jsonError(c, http.StatusUnprocessableEntity, "TXT record not found")
+		return
+	}
+	for _, r := range records {
+		if strings.TrimSpace(r) == "eventplanner-verify="+d.VerificationToken {
+			now := time.Now()
+			d.VerifiedAt = &now
+			DB.Save(&d)
+			c.JSON(http.StatusOK, d)
+			return
+		}
+	}
+	jsonError(c, http.StatusUnprocessableEntity, "TXT record does not match")
+}
+
+func DeleteSSODomain(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	orgID, ok := orgAdminParam(c, userID)
+	if !ok {
+		return
+	}
+
+	res := DB.Where("organization_id = ? AND domain = ?", orgID, strings.ToLower(c.Param("domain"))).Delete(&SSODomain{})
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "domain not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "domain removed"})
+}
+
+// domainOwner returns the organization that verified the email's domain.
+func domainOwner(email string) (uint, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return 0, false
+	}
+	domain := strings.ToLower(strings.TrimSpace(email[at+1:]))
+
+	var d SSODomain
+	if err := DB.Where("domain = ? AND verified_at IS NOT NULL", domain).First(&d).Error; err != nil {
+		return 0, false
+	}
+	return d.OrganizationID, true
+}
+
+// ssoConfigForEmail finds the enabled SSO config owning the email's domain.
+func ssoConfigForEmail(email string) (OrgSSOConfig, bool) {
+	var cfg OrgSSOConfig
+	orgID, ok := domainOwner(email)
+	if !ok {
+		return cfg, false
+	}
+	if err := DB.Where("organization_id = ? AND enabled = ?", orgID, true).First(&cfg).Error; err != nil {
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// ssoRequiredError answers password logins/signups for SSO domains.
+func ssoRequiredError(c *gin.Context, cfg OrgSSOConfig) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":         errSSORequired.Error(),
+		"sso_login_url": ssoURL(cfg.OrganizationID, "login"),
+	})
+}
+
+// SSODiscover tells the login form whether an email must use SSO.
+func SSODiscover(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	cfg, ok := ssoConfigForEmail(body.Email)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"sso": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sso": true, "login_url": ssoURL(cfg.OrganizationID, "login")})
+}
+
+// ========================
+// LOGIN FLOW
+// ========================
+
+func loadSSOConfig(c *gin.Context) (OrgSSOConfig, bool) {
+	var cfg OrgSSOConfig
+	if err := DB.Where("organization_id = ? AND enabled = ?", c.Param("orgId"), true).First(&cfg).Error; err != nil {
+		ssoFail(c, "SSO is not enabled for this organization")
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// ssoFail sends the browser back to the frontend login page with an error.
+func ssoFail(c *gin.Context, msg string) {
+	c.Redirect(http.StatusFound, AppConfig.PublicBaseURL+"/login#sso_error="+url.QueryEscape(msg))
+}
+
+func newSSOState(orgID uint, nonce string) (string, error) {
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	s := SSOLoginState{State: state, OrganizationID: orgID, Nonce: nonce, ExpiresAt: time.Now().Add(ssoStateTTL)}
+	return state, DB.Create(&s).Error
+}
+
+// consumeSSOState returns the stored nonce/request id; each state is single use.
+func consumeSSOState(orgID uint, state string) (string, bool) {
+	var s SSOLoginState
+	if state == "" || DB.Where("state = ? AND organization_id = ?", state, orgID).First(&s).Error != nil {
+		return "", false
+	}
+	DB.Delete(&s)
+	return s.Nonce, time.Now().Before(s.ExpiresAt)
+}
+
+// PurgeSSOStates drops login states of round trips that were never finished.
+func PurgeSSOStates(ctx context.Context) {
+	if err := DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&SSOLoginState{}).Error; err != nil {
+		log.Printf("⚠️ SSO state cleanup failed: %v", err)
+	}
+}
+
+// SSOLogin starts the IdP round trip.
+func SSOLogin(c *gin.Context) {
+	cfg, ok := loadSSOConfig(c)
+	if !ok {
+		return
+	}
+
+	switch cfg.Protocol {
+	case SSOProtocolOIDC:
+		disc, err := discoverOIDC(c.Request.Context(), cfg.OIDCIssuer)
+		if err != nil {
+			ssoFail(c, "identity provider unavailable")
+			return
+		}
+		nonce, _ := randomToken(16)
+		state, err := newSSOState(cfg.OrganizationID, nonce)
+		if err != nil {
+			ssoFail(c, "could not start login")
+			return
+		}
+		q := url.Values{}
+		q.Set("response_type", "code")
+		q.Set("client_id", cfg.OIDCClientID)
+		q.Set("redirect_uri", ssoURL(cfg.OrganizationID, "oidc/callback"))
+		q.Set("scope", "openid email profile")
+		q.Set("state", state)
+		q.Set("nonce", nonce)
+		if hint := c.Query("login_hint"); hint != "" {
+			q.Set("login_hint", hint)
+		}
+		c.Redirect(http.StatusFound, disc.AuthorizationEndpoint+"?"+q.Encode())
+
+	case SSOProtocolSAML:
+		sp, err := samlServiceProvider(cfg)
+		if err != nil {
+			ssoFail(c, "invalid SAML configuration")
+			return
+		}
+		req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+		if err != nil {
+			ssoFail(c, "could not start login")
+			return
+		}
+		state, err := newSSOState(cfg.OrganizationID, req.ID)
+		if err != nil {
+			ssoFail(c, "could not start login")
+			return
+		}
+		redirect, err := req.Redirect(state, sp)
+		if err != nil {
+			ssoFail(c, "could not start login")
+			return
+		}
+		c.Redirect(http.StatusFound, redirect.String())
+	}
+}
+
+// finishSSOLogin provisions the user just in time, maps their org role and
+// hands a session token to the frontend.
+func finishSSOLogin(c *gin.Context, cfg OrgSSOConfig, email string, groups []string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if owner, ok := ssoConfigForEmail(email); !ok || owner.OrganizationID != cfg.OrganizationID {
+		ssoFail(c, "this identity provider cannot sign in "+email)
+		return
+	}
+
+	var user User
+	err := DB.Where("LOWER(email) = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// random password: SSO users never log in with one
+		password, _ := randomToken(32)
+		user = User{Email: email, Password: password}
+		err = DB.Create(&user).Error
+	}
+	if err != nil {
+		ssoFail(c, "could not provision account")
+		return
+	}
+	if s := activeSuspension(user.ID); s != nil && s.Mode == SuspensionBanned {
+		ssoFail(c, "account banned")
+		return
+	}
+
+	role := cfg.DefaultRole
+	mapping := roleMapping(cfg)
+	for _, g := range groups {
+		if mapping[g] == OrgRoleAdmin {
+			role = OrgRoleAdmin
+			break
+		} else if r, ok := mapping[g]; ok {
+			role = r
+		}
+	}
+	m := OrganizationMember{OrganizationID: cfg.OrganizationID, UserID: user.ID}
+	if err := DB.Where("organization_id = ? AND user_id = ?", cfg.OrganizationID, user.ID).FirstOrCreate(&m, OrganizationMember{Role: role}).Error; err == nil {
+		if m.Role != role && m.Role != OrgRoleOwner {
+			DB.Model(&m).Update("role", role)
+		}
+	}
+
+	tokens, err := startSessionTokens(c, user.ID)
+	if err != nil {
+		ssoFail(c, "could not start session")
+		return
+	}
+
+	// fragment so the tokens never reach server logs
+	c.Redirect(http.StatusFound, AppConfig.PublicBaseURL+"/sso/complete#token="+url.QueryEscape(tokens.Token)+
+		"&refresh_token="+url.QueryEscape(tokens.RefreshToken))
+}
+
+// ========================
+// OIDC
+// ========================
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+var oidcCache = struct {
+	sync.Mutex
+	docs map[string]oidcDiscovery
+	at   map[string]time.Time
+}{docs: map[string]oidcDiscovery{}, at: map[string]time.Time{}}
+
+func ssoGetJSON(ctx context.Context, u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ssoHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// discoverOIDC fetches (and caches for an hour) the issuer's discovery document.
+func discoverOIDC(ctx context.Context, issuer string) (oidcDiscovery, error) {
+	oidcCache.Lock()
+	doc, ok := oidcCache.docs[issuer]
+	fresh := ok && time.Since(oidcCache.at[issuer]) < time.Hour
+	oidcCache.Unlock()
+	if fresh {
+		return doc, nil
+	}
+
+	if err := ssoGetJSON(ctx, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return doc, err
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return doc, errors.New("incomplete discovery document")
+	}
+	oidcCache.Lock()
+	oidcCache.docs[issuer] = doc
+	oidcCache.at[issuer] = time.Now()
+	oidcCache.Unlock()
+	return doc, nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// fetchJWKS returns the provider's RSA and EC signing keys by kid.
+func fetchJWKS(ctx context.Context, uri string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := ssoGetJSON(ctx, uri, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := b64Int(k.N)
+			e, err2 := b64Int(k.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := b64Int(k.X)
+			y, err2 := b64Int(k.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	return keys, nil
+}
+
+// exchangeOIDCCode trades the authorization code for an ID token.
+func exchangeOIDCCode(ctx context.Context, cfg OrgSSOConfig, disc oidcDiscovery, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", ssoURL(cfg.OrganizationID, "oidc/callback"))
+	form.Set("client_id", cfg.OIDCClientID)
+	form.Set("client_secret", cfg.OIDCClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ssoHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var out struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.IDToken == "" {
+		return "", errors.New("no id_token in response")
+	}
+	return out.IDToken, nil
+}
+
+func claimStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := []string{}
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func SSOOIDCCallback(c *gin.Context) {
+	cfg, ok := loadSSOConfig(c)
+	if !ok {
+		return
+	}
+	if cfg.Protocol != SSOProtocolOIDC {
+		ssoFail(c, "SSO protocol mismatch")
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		ssoFail(c, "identity provider error: "+e)
+		return
+	}
+	nonce, ok := consumeSSOState(cfg.OrganizationID, c.Query("state"))
+	if !ok {
+		ssoFail(c, "login expired, please try again")
+		return
+	}
+
+	ctx := c.Request.Context()
+	disc, err := discoverOIDC(ctx, cfg.OIDCIssuer)
+	if err != nil {
+		ssoFail(c, "identity provider unavailable")
+		return
+	}
+	rawIDToken, err := exchangeOIDCCode(ctx, cfg, disc, c.Query("code"))
+	if err != nil {
+		log.Printf("⚠️ OIDC code exchange for org %d failed: %v", cfg.OrganizationID, err)
+		ssoFail(c, "could not complete login")
+		return
+	}
+	keys, err := fetchJWKS(ctx, disc.JWKSURI)
+	if err != nil {
+		ssoFail(c, "identity provider unavailable")
+		return
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(disc.Issuer),
+		jwt.WithAudience(cfg.OIDCClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		log.Printf("⚠️ OIDC id_token for org %d rejected: %v", cfg.OrganizationID, err)
+		ssoFail(c, "invalid identity token")
+		return
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		ssoFail(c, "invalid identity token")
+		return
+	}
+	if verified, present := claims["email_verified"].(bool); present && !verified {
+		ssoFail(c, "email address is not verified")
+		return
+	}
+	email, _ := claims["email"].(string)
+	if email == "" {
+		ssoFail(c, "identity provider did not share an email")
+		return
+	}
+
+	var groups []string
+	if cfg.RoleClaim != "" {
+		groups = claimStrings(claims[cfg.RoleClaim])
+	}
+	finishSSOLogin(c, cfg, email, groups)
+}
+
+// ========================
+// SAML
+// ========================
+
+func fetchSAMLMetadata(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ssoHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata URL returned %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return string(b), err
+}
+
+func samlServiceProvider(cfg OrgSSOConfig) (*saml.ServiceProvider, error) {
+	var md saml.EntityDescriptor
+	if err := xml.Unmarshal([]byte(cfg.SAMLMetadataXML), &md); err != nil {
+		return nil, err
+	}
+	metadataURL, err := url.Parse(ssoURL(cfg.OrganizationID, "saml/metadata"))
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(ssoURL(cfg.OrganizationID, "saml/acs"))
+	if err != nil {
+		return nil, err
+	}
+	return &saml.ServiceProvider{
+		EntityID:    metadataURL.String(),
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: &md,
+	}, nil
+}
+
+// SSOSAMLMetadata is the service provider metadata to register at the IdP.
+func SSOSAMLMetadata(c *gin.Context) {
+	cfg, ok := loadSSOConfig(c)
+	if !ok {
+		return
+	}
+	sp, err := samlServiceProvider(cfg)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "invalid SAML configuration")
+		return
+	}
+	out, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not render metadata")
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", out)
+}
+
+var samlEmailAttributes = []string{
+	"email", "mail", "emailAddress",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	"urn:oid:0.9.2342.19200300.100.1.3",
+}
+
+func samlAttribute(a *saml.Assertion, names ...string) []string {
+	out := []string{}
+	for _, stmt := range a.AttributeStatements {
+		for _, attr := range stmt.Attributes {
+			for _, name := range names {
+				if attr.Name == name || attr.FriendlyName == name {
+					for _, v := range attr.Values {
+						out = append(out, v.Value)
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// SSOSAMLACS receives the IdP's POSTed response. Only SP-initiated logins
+// are accepted: RelayState must match a state we issued.
+func SSOSAMLACS(c *gin.Context) {
+	cfg, ok := loadSSOConfig(c)
+	if !ok {
+		return
+	}
+	if cfg.Protocol != SSOProtocolSAML {
+		ssoFail(c, "SSO protocol mismatch")
+		return
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		ssoFail(c, "invalid SAML response")
+		return
+	}
+	requestID, ok := consumeSSOState(cfg.OrganizationID, c.Request.PostForm.Get("RelayState"))
+	if !ok {
+		ssoFail(c, "login expired, please try again")
+		return
+	}
+
+	sp, err := samlServiceProvider(cfg)
+	if err != nil {
+		ssoFail(c, "invalid SAML configuration")
+		return
+	}
+	assertion, err := sp.ParseResponse(c.Request, []string{requestID})
+	if err != nil {
+		var ire *saml.InvalidResponseError
+		if errors.As(err, &ire) {
+			log.Printf("⚠️ SAML response for org %d rejected: %v", cfg.OrganizationID, ire.PrivateErr)
+		}
+		ssoFail(c, "invalid SAML response")
+		return
+	}
+
+	email := ""
+	if emails := samlAttribute(assertion, samlEmailAttributes...); len(emails) > 0 {
+		email = emails[0]
+	} else if assertion.Subject != nil && assertion.Subject.NameID != nil && strings.Contains(assertion.Subject.NameID.Value, "@") {
+		email = assertion.Subject.NameID.Value
+	}
+	if email == "" {
+		ssoFail(c, "identity provider did not share an email")
+		return
+	}
+
+	var groups []string
+	if cfg.RoleClaim != "" {
+		groups = samlAttribute(assertion, cfg.RoleClaim)
+	}
+	finishSSOLogin(c, cfg, email, groups)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Uploaded files live in a FileStore under keys like "attachments/ab12.pdf".
+// Clients never get a permanent link: they ask the API, which checks they
+// may see the file, and are handed a URL that stops working after
+// STORAGE_URL_TTL_SECONDS. On local disk that URL points back at /files,
+// signed with the active JWT key; on S3 it is a presigned bucket URL.
+
+// FileStore keeps uploaded files.
+type FileStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the file; a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where the file can be downloaded from until expires.
+	URL(key string, dl Download, expires time.Time) (string, error)
+}
+
+// Download describes how a signed URL serves the file.
+type Download struct {
+	FileName    string
+	ContentType string
+	Inline      bool // shown in the browser rather than saved
+}
+
+func (d Download) disposition() string {
+	kind := "attachment"
+	if d.Inline {
+		kind = "inline"
+	}
+	if d.FileName == "" {
+		return kind
+	}
+	return mime.FormatMediaType(kind, map[string]string{"filename": d.FileName})
+}
+
+var Storage FileStore
+
+func InitStorage() {
+	switch strings.ToLower(AppConfig.StorageDriver) {
+	case "", "local":
+		Storage = &localStore{dir: AppConfig.DataDir}
+	case "s3":
+		if AppConfig.S3Endpoint == "" || AppConfig.S3Bucket == "" || AppConfig.S3AccessKeyID == "" || AppConfig.S3SecretAccessKey == "" {
+			log.Fatalf("❌ STORAGE_DRIVER=s3 needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		endpoint, err := url.Parse(AppConfig.S3Endpoint)
+		if err != nil || endpoint.Host == "" {
+			log.Fatalf("❌ invalid S3_ENDPOINT %q", AppConfig.S3Endpoint)
+		}
+		Storage = &s3Store{
+			endpoint:  endpoint,
+			region:    AppConfig.S3Region,
+			bucket:    AppConfig.S3Bucket,
+			accessKey: AppConfig.S3AccessKeyID,
+			secretKey: AppConfig.S3SecretAccessKey,
+			client:    newHTTPClient(60 * time.Second),
+		}
+		log.Printf("🗄️ File storage in bucket %s at %s", AppConfig.S3Bucket, endpoint.Host)
+	default:
+		log.Fatalf("❌ unknown STORAGE_DRIVER %q", AppConfig.StorageDriver)
+	}
+}
+
+// signedURLExpiry is when a download link handed out now stops working.
+func signedURLExpiry() time.Time {
+	return time.Now().Add(time.Duration(AppConfig.StorageURLTTLSeconds) * time.Second)
+}
+
+// newStorageKey is a fresh random key under prefix, keeping ext.
+func newStorageKey(prefix, ext string) (string, error) {
+	name, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "/" + name + ext, nil
+}
+
+// ========================
+// LOCAL DISK
+// ========================
+
+type localStore struct {
+	dir string
+}
+
+// path maps a key into the store's directory, refusing keys that would
+// leave it.
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o640)
+}
+
+func (s *localStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// localSignature covers everything the /files link serves, so none of it
+// can be changed without the secret.
+func localSignature(secret, key string, dl Download, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%d\n%s\n%s\n%t", key, expires, dl.FileName, dl.ContentType, dl.Inline)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *localStore) URL(key string, dl Download, expires time.Time) (string, error) {
+	signKey, ok := JWTKeys.Active()
+	if !ok {
+		return "", errors.New("no active signing key")
+	}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("kid", signKey.KID)
+	q.Set("name", dl.FileName)
+	q.Set("type", dl.ContentType)
+	if dl.Inline {
+		q.Set("inline", "1")
+	}
+	q.Set("sig", localSignature(signKey.Secret, key, dl, expires.Unix()))
+	return AppConfig.APIBaseURL + "/files/" + key + "?" + q.Encode(), nil
+}
+
+// ServeStoredFile serves a file from local storage to the holder of a
+// link from localStore.URL.
+func ServeStoredFile(c *gin.Context) {
+	store, ok := Storage.(*localStore)
+	if !ok {
+		jsonError(c, http.StatusNotFound, "not found")
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		jsonError(c, http.StatusForbidden, "link has expired")
+		return
+	}
+	signKey, ok := JWTKeys.Lookup(c.Query("kid"))
+	dl := Download{FileName: c.Query("name"), ContentType: c.Query("type"), Inline: c.Query("inline") == "1"}
+	if !ok || !hmac.Equal([]byte(c.Query("sig")), []byte(localSignature(signKey.Secret, key, dl, expires))) {
+		jsonError(c, http.StatusForbidden, "invalid link")
+		return
+	}
+	p, err := store.path(key)
+	if err != nil {
+		jsonError(c, http.StatusNotFound, "not found")
+		return
+	}
+	if _, err := os.Stat(p); err != nil {
+		jsonError(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	if dl.ContentType != "" {
+		c.Header("Content-Type", dl.ContentType)
+	}
+	c.Header("Content-Disposition", dl.disposition())
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(max(0, expires-time.Now().Unix()), 10))
+	c.File(p)
+}
+
+// ========================
+// S3-COMPATIBLE STORAGE
+// ========================
+
+// s3Store talks to an S3-compatible bucket with path-style URLs and SigV4
+// presigned requests, which MinIO, R2 and the like all accept.
+type s3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// awsEscape percent-encodes everything but unreserved characters, as
+// SigV4 canonical requests require.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', keepSlash && ch == '/':
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// presign returns a URL allowing method on key until now+ttl, with any
+// extra query parameters (response-content-type, ...) signed in.
+func (s *s3Store) presign(method, key string, ttl time.Duration, extra map[string]string) string {
+	now := time.Now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+
+	params := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.accessKey + "/" + scope,
+		"X-Amz-Date":          stamp,
+		"X-Amz-Expires":       strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, k := range names {
+		pairs = append(pairs, awsEscape(k, false)+"="+awsEscape(params[k], false))
+	}
+	query := strings.Join(pairs, "&")
+
+	uri := strings.TrimRight(s.endpoint.Path, "/") + "/" + awsEscape(s.bucket, false) + "/" + awsEscape(key, true)
+	canonical := strings.Join([]string{method, uri, query, "host:" + s.endpoint.Host, "", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	signing := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		signing = hmacSHA256(signing, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(signing, toSign))
+	return s.endpoint.Scheme + "://" + s.endpoint.Host + uri + "?" + query + "&X-Amz-Signature=" + sig
+}
+
+func (s *s3Store) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.presign(method, key, time.Minute, nil), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.client.Do(req)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage returned %s for %s", resp.Status, key)
+	}
+	return nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("storage returned %s deleting %s", resp.Status, key)
+	}
+	return nil
+}
+
+func (s *s3Store) URL(key string, dl Download, expires time.Time) (string, error) {
+	extra := map[string]string{"response-content-disposition": dl.disposition()}
+	if dl.ContentType != "" {
+		extra["response-content-type"] = dl.ContentType
+	}
+	return s.presign(http.MethodGet, key, time.Until(expires).Round(time.Second), extra), nil
+}
+
+// removeStoredFile deletes a file once nothing refers to it, logging
+// rather than failing: a leftover file only costs space.
+func removeStoredFile(key string) {
+	if key == "" {
+		return
+	}
+	if err := Storage.Delete(context.Background(), key); err != nil {
+		log.Printf("⚠️ could not remove stored file %s: %v", key, err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryCardVersion is bumped whenever a field of SummaryCard changes
+// meaning or goes away; new fields may be added without a bump.
+const summaryCardVersion = 1
+
+// SummaryCard is the public, embeddable view of an event. It is a stable
+// contract with third-party sites: only add fields, and never anything
+// that needs a login to see.
+type SummaryCard struct {
+	Version   int       `json:"version"`
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	StartsAt  time.Time `json:"starts_at"`
+	Location  string    `json:"location"`
+	Status    string    `json:"status"` // "scheduled", "cancelled" or "past"
+	URL       string    `json:"url"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Branding  *Branding `json:"branding,omitempty"` // without reply_to
+
+	Tickets []PublicTicket `json:"tickets,omitempty"` // see currency.go
+
+	Accessibility *Accessibility `json:"accessibility,omitempty"` // once anything is stated
+}
+
+func summaryCard(ev Event) SummaryCard {
+	status := "scheduled"
+	if ev.CancelledAt != nil {
+		status = "cancelled"
+	} else if eventOver(ev, time.Now()) {
+		status = "past"
+	}
+	brand := eventBranding(ev)
+	if brand != nil {
+		brand.ReplyTo = ""
+	}
+	card := SummaryCard{
+		Branding:  brand,
+		Version:   summaryCardVersion,
+		ID:        ev.ID,
+		Title:     ev.Title,
+		StartsAt:  ev.Date.UTC(),
+		Location:  ev.Location,
+		Status:    status,
+		URL:       eventURL(ev),
+		UpdatedAt: ev.UpdatedAt.UTC(),
+	}
+	if ev.Accessibility.stated() {
+		card.Accessibility = &ev.Accessibility
+	}
+	return card
+}
+
+// GetSummaryCard serves the summary card of an event whose organizer
+// published it. Unpublished and unknown events look the same.
+func GetSummaryCard(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, eventID).Error; err != nil {
+		eventNotFound(c, uint(eventID))
+		return
+	}
+	if !ev.PublicCard {
+		jsonError(c, http.StatusNotFound, "event not found")
+		return
+	}
+
+	card, ok := publicSummaryCard(c, ev)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, card)
+}
+
+type PublicCardRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetEventPublicCard publishes or withdraws the event's summary card.
+func SetEventPublicCard(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body PublicCardRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Update("public_card", body.Enabled).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update summary card: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	DB.First(&ev, ev.ID)
+
+	c.JSON(http.StatusOK, eventView(ev, true))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	SuspensionReadOnly = "read_only"
+	SuspensionBanned   = "banned"
+)
+
+// activeSuspension returns the user's current suspension, if any.
+// Bans win over read-only when both are active.
+func activeSuspension(userID uint) *UserSuspension {
+	var list []UserSuspension
+	DB.Where("user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Find(&list)
+	if len(list) == 0 {
+		return nil
+	}
+	for i := range list {
+		if list[i].Mode == SuspensionBanned {
+			return &list[i]
+		}
+	}
+	return &list[0]
+}
+
+// suspendedUsersQuery selects ids of users under any active suspension.
+func suspendedUsersQuery() *gorm.DB {
+	return DB.Model(&UserSuspension{}).
+		Select("user_id").
+		Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+}
+
+// invitationFrozen is true while the inviter of a pending invitation is suspended.
+func invitationFrozen(att EventAttendee) bool {
+	return att.Invitation == InvitationPending && att.InvitedByID != nil && activeSuspension(*att.InvitedByID) != nil
+}
+
+// SuspensionMiddleware blocks banned users and limits read-only users to safe methods.
+// It must run after AuthMiddleware.
+func SuspensionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		s := activeSuspension(userID)
+		if s == nil {
+			c.Next()
+			return
+		}
+
+		if s.Mode == SuspensionBanned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account banned", "reason": s.Reason, "expires_at": s.ExpiresAt})
+			c.Abort()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is read-only", "reason": s.Reason, "expires_at": s.ExpiresAt})
+			c.Abort()
+		}
+	}
+}
+
+type SuspendUserRequest struct {
+	Mode      string     `json:"mode" binding:"required"`
+	Reason    string     `json:"reason" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func SuspendUser(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var body SuspendUserRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.Mode != SuspensionReadOnly && body.Mode != SuspensionBanned {
+		jsonError(c, http.StatusBadRequest, "mode must be read_only or banned")
+		return
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		jsonError(c, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	if uint(targetID) == adminID {
+		jsonError(c, http.StatusBadRequest, "cannot suspend yourself")
+		return
+	}
+
+	var target User
+	if err := DB.First(&target, targetID).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "user not found")
+		return
+	}
+
+	s := UserSuspension{
+		UserID:      target.ID,
+		Mode:        body.Mode,
+		Reason:      body.Reason,
+		ExpiresAt:   body.ExpiresAt,
+		CreatedByID: adminID,
+	}
+	if err := DB.Create(&s).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not suspend user: "+err.Error())
+		return
+	}
+
+	// a ban also ends every open session
+	if s.Mode == SuspensionBanned {
+		DB.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", target.ID).Update("revoked_at", time.Now())
+	}
+
+	Audit(adminID, "user.suspend", "user", target.ID, gin.H{
+		"suspension_id": s.ID,
+		"mode":          s.Mode,
+		"reason":        s.Reason,
+		"expires_at":    s.ExpiresAt,
+	})
+
+	c.JSON(http.StatusCreated, s)
+}
+
+func LiftSuspension(c *gin.Context) {
+	adminID, _ := getUserIDFromContext(c)
+
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	now := time.Now()
+	res := DB.Model(&UserSuspension{}).
+		Where("user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", targetID, now).
+		Updates(map[string]interface{}{"lifted_at": now, "lifted_by_id": adminID})
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "user has no active suspension")
+		return
+	}
+
+	Audit(adminID, "user.unsuspend", "user", uint(targetID), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "suspension lifted"})
+}
+
+func GetUserSuspensions(c *gin.Context) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var list []UserSuspension
+	if err := DB.Where("user_id = ?", targetID).Order("created_at desc").Find(&list).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	EntityEvent        = "event"
+	EntityTask         = "task"
+	EntityAttendee     = "attendee"
+	EntityAnnouncement = "announcement"
+	EntityComment      = "comment"
+
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+
+	syncPageSize  = 500
+	syncRetention = 30 * 24 * time.Hour
+)
+
+// recordChange appends to the change feed read by GET /api/sync, visible
+// to everyone participating in eventID. Like Audit it never fails the
+// caller; pass the transaction so the record commits with the change.
+// The returned cursor doubles as the entity's version for sync writes.
+func recordChange(tx *gorm.DB, entity string, entityID, eventID uint, op string) uint {
+	rec := ChangeRecord{EventID: eventID, Entity: entity, EntityID: entityID, Op: op}
+	if err := tx.Create(&rec).Error; err != nil {
+		log.Printf("⚠️ could not record %s %s %d: %v", op, entity, entityID, err)
+	}
+	return rec.ID
+}
+
+// recordUserChange is a change only one user sees, such as the tombstone
+// for an event they lost access to.
+func recordUserChange(tx *gorm.DB, userID uint, entity string, entityID, eventID uint, op string) {
+	rec := ChangeRecord{UserID: userID, EventID: eventID, Entity: entity, EntityID: entityID, Op: op}
+	if err := tx.Create(&rec).Error; err != nil {
+		log.Printf("⚠️ could not record %s %s %d for user %d: %v", op, entity, entityID, userID, err)
+	}
+}
+
+// recordEventRemoved tombstones an event for each of the given users.
+func recordEventRemoved(tx *gorm.DB, eventID uint, userIDs []uint) {
+	for _, uid := range userIDs {
+		recordUserChange(tx, uid, EntityEvent, eventID, eventID, ChangeDelete)
+	}
+}
+
+// syncEventIDs is every event whose changes the user receives: the ones
+// they participate in plus those of principals they manage.
+func syncEventIDs(userID uint) []uint {
+	ids := participatingEventIDs(userID)
+	var delegated []uint
+	DB.Model(&Event{}).Where("organizer_id IN (?)", principalsQuery(userID)).Pluck("id", &delegated)
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range delegated {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+type syncChange struct {
+	Cursor   uint        `json:"cursor"`
+	Entity   string      `json:"entity"`
+	EntityID uint        `json:"id"`
+	EventID  uint        `json:"event_id"`
+	Op       string      `json:"op"`
+	Payload  interface{} `json:"payload,omitempty"`
+}
+
+type syncKey struct {
+	entity string
+	id     uint
+}
+
+// syncPayloads loads the current state of the referenced entities,
+// serialized for this viewer. Missing entities are left out.
+func syncPayloads(userID uint, refs map[string][]uint, eventIDs []uint) map[syncKey]interface{} {
+	out := map[syncKey]interface{}{}
+	if len(eventIDs) == 0 {
+		return out
+	}
+	organizes := organizedEventSet(userID, eventIDs)
+
+	if ids := refs[EntityEvent]; len(ids) > 0 {
+		var events []Event
+		DB.Where("id IN ?", ids).Find(&events)
+		attachLinkPreviews(events)
+		for _, ev := range events {
+			out[syncKey{EntityEvent, ev.ID}] = eventView(ev, organizes[ev.ID])
+		}
+	}
+	if ids := refs[EntityTask]; len(ids) > 0 {
+		var tasks []Task
+		DB.Where("id IN ? AND event_id IN ?", ids, eventIDs).Find(&tasks)
+		for _, t := range tasks {
+			out[syncKey{EntityTask, t.ID}] = taskView(t)
+		}
+	}
+	if ids := refs[EntityAttendee]; len(ids) > 0 {
+		var attendees []EventAttendee
+		DB.Where("id IN ? AND event_id IN ?", ids, eventIDs).Find(&attendees)
+		maskable := []EventAttendee{}
+		for _, a := range attendees {
+			if organizes[a.EventID] {
+				out[syncKey{EntityAttendee, a.ID}] = attendeeView(a, userID, true)
+			} else {
+				maskable = append(maskable, a)
+			}
+		}
+		maskHiddenRSVPs(maskable, userID)
+		for _, v := range attendeeViews(maskable, userID, false) {
+			out[syncKey{EntityAttendee, v.ID}] = v
+		}
+	}
+	if ids := refs[EntityAnnouncement]; len(ids) > 0 {
+		var list []Announcement
+		DB.Where("id IN ? AND event_id IN ?", ids, eventIDs).Find(&list)
+		audiences := map[uint][]string{}
+		for _, a := range list {
+			if !organizes[a.EventID] && a.Audience != AudienceAll {
+				if _, ok := audiences[a.EventID]; !ok {
+					audiences[a.EventID] = viewerAudiences(a.EventID, userID)
+				}
+				if !slices.Contains(audiences[a.EventID], a.Audience) {
+					continue
+				}
+			}
+			out[syncKey{EntityAnnouncement, a.ID}] = announcementView(a)
+		}
+	}
+	if ids := refs[EntityComment]; len(ids) > 0 {
+		var list []EventComment
+		DB.Where("id IN ? AND event_id IN ?", ids, eventIDs).Find(&list)
+		for _, cm := range list {
+			out[syncKey{EntityComment, cm.ID}] = commentViews([]EventComment{cm}, userID, organizes[cm.EventID])[0]
+		}
+	}
+	return out
+}
+
+// GetSync is the offline change feed. Without ?since it returns a full
+// snapshot as upserts; with ?since=<cursor> only what changed after it,
+// one record per entity with its current state, deletions as tombstones.
+// Follow "cursor" while has_more is true. A 410 means the cursor is older
+// than the feed's retention and the client must start over.
+func GetSync(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventIDs := syncEventIDs(userID)
+	if c.Query("since") == "" {
+		syncSnapshot(c, userID, eventIDs)
+		return
+	}
+
+	since, err := strconv.ParseUint(c.Query("since"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	var oldest ChangeRecord
+	if DB.Order("id asc").First(&oldest).Error == nil && since > 0 && uint(since)+1 < oldest.ID {
+		jsonError(c, http.StatusGone, "cursor expired, fetch a new snapshot")
+		return
+	}
+
+	q := DB.Where("id > ?", since)
+	if len(eventIDs) > 0 {
+		q = q.Where("(user_id = 0 AND event_id IN ?) OR user_id = ?", eventIDs, userID)
+	} else {
+		q = q.Where("user_id = ?", userID)
+	}
+	var records []ChangeRecord
+	if err := q.Order("id asc").Limit(syncPageSize + 1).Find(&records).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	hasMore := len(records) > syncPageSize
+	if hasMore {
+		records = records[:syncPageSize]
+	}
+	cursor := uint(since)
+	if len(records) > 0 {
+		cursor = records[len(records)-1].ID
+	}
+
+	// keep only the latest record per entity
+	latest := map[syncKey]int{}
+	for i, r := range records {
+		latest[syncKey{r.Entity, r.EntityID}] = i
+	}
+	refs := map[string][]uint{}
+	for k, i := range latest {
+		if records[i].Op == ChangeUpsert {
+			refs[k.entity] = append(refs[k.entity], k.id)
+		}
+	}
+	payloads := syncPayloads(userID, refs, eventIDs)
+
+	changes := []syncChange{}
+	for i, r := range records {
+		k := syncKey{r.Entity, r.EntityID}
+		if latest[k] != i {
+			continue
+		}
+		ch := syncChange{Cursor: r.ID, Entity: r.Entity, EntityID: r.EntityID, EventID: r.EventID, Op: r.Op}
+		if r.Op == ChangeUpsert {
+			p, ok := payloads[k]
+			if !ok {
+				// gone or no longer visible
+				ch.Op = ChangeDelete
+			}
+			ch.Payload = p
+		}
+		changes = append(changes, ch)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes, "cursor": cursor, "has_more": hasMore})
+}
+
+func syncSnapshot(c *gin.Context, userID uint, eventIDs []uint) {
+	// take the cursor first so nothing written meanwhile is missed
+	var head ChangeRecord
+	DB.Order("id desc").First(&head)
+
+	refs := map[string][]uint{EntityEvent: eventIDs}
+	if len(eventIDs) > 0 {
+		var ids []uint
+		DB.Model(&Task{}).Where("event_id IN ?", eventIDs).Pluck("id", &ids)
+		refs[EntityTask] = ids
+		ids = nil
+		DB.Model(&EventAttendee{}).Where("event_id IN ?", eventIDs).Pluck("id", &ids)
+		refs[EntityAttendee] = ids
+		ids = nil
+		DB.Model(&Announcement{}).Where("event_id IN ?", eventIDs).Pluck("id", &ids)
+		refs[EntityAnnouncement] = ids
+		ids = nil
+		DB.Model(&EventComment{}).Where("event_id IN ?", eventIDs).Pluck("id", &ids)
+		refs[EntityComment] = ids
+	}
+	payloads := syncPayloads(userID, refs, eventIDs)
+
+	changes := []syncChange{}
+	for _, entity := range []string{EntityEvent, EntityTask, EntityAttendee, EntityAnnouncement, EntityComment} {
+		for _, id := range refs[entity] {
+			p, ok := payloads[syncKey{entity, id}]
+			if !ok {
+				continue
+			}
+			changes = append(changes, syncChange{Cursor: head.ID, Entity: entity, EntityID: id, Op: ChangeUpsert, Payload: p})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes, "cursor": head.ID, "has_more": false, "snapshot": true})
+}
+
+// PruneChangeFeed drops change records past the retention window.
+func PruneChangeFeed(ctx context.Context) {
+	res := DB.WithContext(ctx).Where("created_at < ?", time.Now().Add(-syncRetention)).Delete(&ChangeRecord{})
+	if res.Error != nil {
+		log.Printf("⚠️ change feed prune failed: %v", res.Error)
+	}
+	if err := pruneSyncMutations(DB.WithContext(ctx)); err != nil {
+		log.Printf("⚠️ sync mutation prune failed: %v", err)
+	}
+}
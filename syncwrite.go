@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MutationApplied  = "applied"
+	MutationConflict = "conflict"
+	MutationRejected = "rejected"
+
+	maxSyncMutations = 100
+)
+
+// SyncMutation is one change made offline. BaseVersion is the cursor the
+// client last saw for the entity (from GET /api/sync); if the entity changed
+// since, the mutation conflicts instead of overwriting.
+type SyncMutation struct {
+	ClientID    string          `json:"client_id" binding:"required,max=64"`
+	Entity      string          `json:"entity" binding:"required"`
+	Op          string          `json:"op" binding:"required"` // "create" or "update"
+	ID          uint            `json:"id"`
+	BaseVersion uint            `json:"base_version"`
+	Data        json.RawMessage `json:"data"`
+}
+
+type SyncApplyRequest struct {
+	Mutations []SyncMutation `json:"mutations" binding:"required,dive"`
+	Atomic    bool           `json:"atomic"` // all or nothing
+}
+
+type mutationResult struct {
+	ClientID string      `json:"client_id"`
+	Status   string      `json:"status"`
+	ID       uint        `json:"id,omitempty"`
+	Version  uint        `json:"version,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Current  interface{} `json:"current,omitempty"` // server state on conflict
+}
+
+type mutationError struct {
+	status  string
+	message string
+	current interface{}
+}
+
+func (e *mutationError) Error() string { return e.message }
+
+func rejected(msg string) error { return &mutationError{status: MutationRejected, message: msg} }
+
+var errAtomicAbort = errors.New("atomic batch aborted")
+
+// entityVersion is the cursor of the entity's latest change.
+func entityVersion(tx *gorm.DB, entity string, id uint) uint {
+	var v *uint
+	tx.Model(&ChangeRecord{}).Where("entity = ? AND entity_id = ?", entity, id).Select("MAX(id)").Scan(&v)
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// checkBase fails with a conflict carrying the current state when the
+// entity changed after the client's base version.
+func checkBase(tx *gorm.DB, userID uint, m SyncMutation, eventID uint) error {
+	if entityVersion(tx, m.Entity, m.ID) <= m.BaseVersion {
+		return nil
+	}
+	current := syncPayloads(userID, map[string][]uint{m.Entity: {m.ID}}, []uint{eventID})[syncKey{m.Entity, m.ID}]
+	return &mutationError{status: MutationConflict, message: "changed since base_version", current: current}
+}
+
+// ApplySyncMutations applies offline changes in order, each in its own
+// savepoint so one conflict doesn't lose the rest (unless atomic is set).
+// Retried client_ids return their original result.
+func ApplySyncMutations(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body SyncApplyRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if len(body.Mutations) > maxSyncMutations {
+		jsonError(c, http.StatusBadRequest, "at most 100 mutations per request")
+		return
+	}
+
+	results := make([]mutationResult, len(body.Mutations))
+	replayed := make([]bool, len(body.Mutations))
+	var after []func()
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for i, m := range body.Mutations {
+			var prior SyncMutationLog
+			if tx.Where("user_id = ? AND client_id = ?", userID, m.ClientID).First(&prior).Error == nil {
+				json.Unmarshal([]byte(prior.Result), &results[i])
+				replayed[i] = true
+				continue
+			}
+
+			var res mutationResult
+			var announce func()
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				var err error
+				res, announce, err = applyMutation(sp, userID, m)
+				return err
+			})
+			res.ClientID = m.ClientID
+			var me *mutationError
+			switch {
+			case err == nil:
+				if announce != nil {
+					after = append(after, announce)
+				}
+			case errors.As(err, &me):
+				res.Status, res.Error, res.Current = me.status, me.message, me.current
+			default:
+				// a database failure: report it but don't remember it, so
+				// a retry gets another chance
+				res.Status, res.Error = MutationRejected, "could not apply: "+err.Error()
+			}
+			results[i] = res
+			if err != nil && body.Atomic {
+				return errAtomicAbort
+			}
+
+			// conflicts aren't remembered either: the client rebases and
+			// retries with the same client_id
+			if err == nil || (me != nil && me.status == MutationRejected) {
+				raw, _ := json.Marshal(res)
+				if err := tx.Create(&SyncMutationLog{UserID: userID, ClientID: m.ClientID, Result: string(raw)}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errAtomicAbort) {
+		for i, m := range body.Mutations {
+			if replayed[i] {
+				continue
+			}
+			if results[i].Status == "" || results[i].Status == MutationApplied {
+				results[i] = mutationResult{ClientID: m.ClientID, Status: MutationRejected, Error: "rolled back: atomic batch failed"}
+			}
+		}
+		c.JSON(http.StatusConflict, gin.H{"results": results})
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not apply mutations: "+err.Error())
+		return
+	}
+
+	for _, fn := range after {
+		fn()
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func applyMutation(tx *gorm.DB, userID uint, m SyncMutation) (mutationResult, func(), error) {
+	switch m.Entity + "." + m.Op {
+	case EntityEvent + ".update":
+		return applyEventUpdate(tx, userID, m)
+	case EntityTask + ".create":
+		return applyTaskCreate(tx, userID, m)
+	case EntityTask + ".update":
+		return applyTaskUpdate(tx, userID, m)
+	case EntityComment + ".create":
+		return applyCommentCreate(tx, userID, m)
+	}
+	return mutationResult{}, nil, rejected("unsupported mutation " + m.Entity + "." + m.Op)
+}
+
+func decodeMutation(m SyncMutation, out interface{}) error {
+	if len(m.Data) == 0 {
+		return rejected("data is required")
+	}
+	if err := json.Unmarshal(m.Data, out); err != nil {
+		return rejected("invalid data: " + err.Error())
+	}
+	return nil
+}
+
+// lockEvent loads and row-locks an event the user participates in.
+func lockEvent(tx *gorm.DB, userID, eventID uint) (Event, error) {
+	var ev Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ev, eventID).Error; err != nil {
+		return ev, rejected("event not found")
+	}
+	if !isEventParticipant(ev, userID) {
+		return ev, rejected("not a participant of this event")
+	}
+	return ev, nil
+}
+
+func applyEventUpdate(tx *gorm.DB, userID uint, m SyncMutation) (mutationResult, func(), error) {
+	var data struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Location    *string `json:"location"`
+	}
+	if err := decodeMutation(m, &data); err != nil {
+		return mutationResult{}, nil, err
+	}
+	ev, err := lockEvent(tx, userID, m.ID)
+	if err != nil {
+		return mutationResult{}, nil, err
+	}
+	if !isEventOrganizer(ev, userID) {
+		return mutationResult{}, nil, rejected("only organizers can edit the event")
+	}
+	if err := checkBase(tx, userID, m, ev.ID); err != nil {
+		return mutationResult{}, nil, err
+	}
+
+	if data.Title != nil {
+		if strings.TrimSpace(*data.Title) == "" {
+			return mutationResult{}, nil, rejected("title cannot be empty")
+		}
+		ev.Title = strings.TrimSpace(*data.Title)
+	}
+	if data.Description != nil {
+		ev.Description = *data.Description
+	}
+	if data.Location != nil {
+		ev.Location = *data.Location
+	}
+	if err := tx.Save(&ev).Error; err != nil {
+		return mutationResult{}, nil, err
+	}
+	version := recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	return mutationResult{Status: MutationApplied, ID: ev.ID, Version: version}, func() { broadcastEventUpdated(ev) }, nil
+}
+
+type syncTaskData struct {
+	EventID     uint    `json:"event_id"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Status      *string `json:"status"`
+
+	DueAt *time.Time `json:"due_at"`
+}
+
+func applyTaskCreate(tx *gorm.DB, userID uint, m SyncMutation) (mutationResult, func(), error) {
+	var data syncTaskData
+	if err := decodeMutation(m, &data); err != nil {
+		return mutationResult{}, nil, err
+	}
+	ev, err := lockEvent(tx, userID, data.EventID)
+	if err != nil {
+		return mutationResult{}, nil, err
+	}
+	if ev.OrganizerID != userID && !isDelegateOf(ev.OrganizerID, userID) {
+		return mutationResult{}, nil, rejected("only organizer can create tasks")
+	}
+	if data.Title == nil || strings.TrimSpace(*data.Title) == "" {
+		return mutationResult{}, nil, rejected("title is required")
+	}
+
+	task := Task{EventID: ev.ID, Title: strings.TrimSpace(*data.Title), Status: TaskTodo, DueAt: data.DueAt}
+	if data.Description != nil {
+		task.Description = *data.Description
+	}
+	if data.Status != nil {
+		if !validTaskStatus(*data.Status) {
+			return mutationResult{}, nil, rejected("invalid status")
+		}
+		task.setStatus(*data.Status, userID)
+	}
+	task.Position = nextTaskPosition(tx, ev.ID, task.Status)
+	if err := tx.Create(&task).Error; err != nil {
+		return mutationResult{}, nil, err
+	}
+	version := recordChange(tx, EntityTask, task.ID, ev.ID, ChangeUpsert)
+	return mutationResult{Status: MutationApplied, ID: task.ID, Version: version}, func() { broadcastTask("task_created", task) }, nil
+}
+
+func applyTaskUpdate(tx *gorm.DB, userID uint, m SyncMutation) (mutationResult, func(), error) {
+	var data syncTaskData
+	if err := decodeMutation(m, &data); err != nil {
+		return mutationResult{}, nil, err
+	}
+	var task Task
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, m.ID).Error; err != nil {
+		return mutationResult{}, nil, rejected("task not found")
+	}
+	var ev Event
+	if err := tx.First(&ev, task.EventID).Error; err != nil {
+		return mutationResult{}, nil, err
+	}
+	if !isEventOrganizer(ev, userID) {
+		return mutationResult{}, nil, rejected("only organizers can edit tasks")
+	}
+	if err := checkBase(tx, userID, m, ev.ID); err != nil {
+		return mutationResult{}, nil, err
+	}
+
+	if data.Title != nil {
+		if strings.TrimSpace(*data.Title) == "" {
+			return mutationResult{}, nil, rejected("title cannot be empty")
+		}
+		task.Title = strings.TrimSpace(*data.Title)
+	}
+	if data.Description != nil {
+		task.Description = *data.Description
+	}
+	if data.DueAt != nil {
+		task.DueAt = data.DueAt
+	}
+	from := task.Status
+	if data.Status != nil && *data.Status != task.Status {
+		if !validTaskStatus(*data.Status) {
+			return mutationResult{}, nil, rejected("invalid status")
+		}
+		if !taskTransitionAllowed(task.Status, *data.Status) {
+			return mutationResult{}, nil, rejected("cannot move a task from " + task.Status + " to " + *data.Status)
+		}
+		task.setStatus(*data.Status, userID)
+		task.Position = nextTaskPosition(tx, ev.ID, task.Status)
+	}
+	if err := tx.Save(&task).Error; err != nil {
+		return mutationResult{}, nil, err
+	}
+	version := recordChange(tx, EntityTask, task.ID, ev.ID, ChangeUpsert)
+	return mutationResult{Status: MutationApplied, ID: task.ID, Version: version}, func() { broadcastTask(taskChangeKind(from, task.Status), task) }, nil
+}
+
+func applyCommentCreate(tx *gorm.DB, userID uint, m SyncMutation) (mutationResult, func(), error) {
+	var data struct {
+		EventID uint   `json:"event_id"`
+		Body    string `json:"body"`
+	}
+	if err := decodeMutation(m, &data); err != nil {
+		return mutationResult{}, nil, err
+	}
+	var ev Event
+	if err := tx.First(&ev, data.EventID).Error; err != nil {
+		return mutationResult{}, nil, rejected("event not found")
+	}
+	if !isEventParticipant(ev, userID) {
+		return mutationResult{}, nil, rejected("only participants can comment")
+	}
+
+	cm, err := createComment(tx, ev, userID, data.Body, CommentSourceApp)
+	if errors.Is(err, errCommentEmpty) || errors.Is(err, errCommentTooLong) {
+		return mutationResult{}, nil, rejected(err.Error())
+	}
+	if err != nil {
+		return mutationResult{}, nil, err
+	}
+	version := entityVersion(tx, EntityComment, cm.ID)
+	return mutationResult{Status: MutationApplied, ID: cm.ID, Version: version}, func() { announceComment(ev, cm) }, nil
+}
+
+// pruneSyncMutations forgets client_ids once their versions have aged
+// out of the change feed.
+func pruneSyncMutations(tx *gorm.DB) error {
+	return tx.Where("created_at < ?", time.Now().Add(-syncRetention)).Delete(&SyncMutationLog{}).Error
+}
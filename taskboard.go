@@ -0,0 +1,595 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TaskTodo       = "todo"
+	TaskInProgress = "in_progress"
+	TaskDone       = "done"
+)
+
+// taskColumns is the left-to-right order of the board.
+var taskColumns = []string{TaskTodo, TaskInProgress, TaskDone}
+
+func validTaskStatus(s string) bool {
+	for _, col := range taskColumns {
+		if col == s {
+			return true
+		}
+	}
+	return false
+}
+
+// taskTransitions are the status changes allowed outside the board, where
+// organizers drag freely. A done task is reopened as in progress.
+var taskTransitions = map[string][]string{
+	TaskTodo:       {TaskInProgress, TaskDone},
+	TaskInProgress: {TaskTodo, TaskDone},
+	TaskDone:       {TaskInProgress},
+}
+
+func taskTransitionAllowed(from, to string) bool {
+	return from == to || slices.Contains(taskTransitions[from], to)
+}
+
+// setStatus moves t to status, stamping or clearing who completed it.
+func (t *Task) setStatus(status string, by uint) {
+	if status == TaskDone && t.Status != TaskDone {
+		now := time.Now()
+		t.CompletedAt, t.CompletedByID = &now, &by
+	} else if status != TaskDone {
+		t.CompletedAt, t.CompletedByID = nil, nil
+	}
+	t.Status = status
+}
+
+// taskChangeKind names a status change for realtime clients.
+func taskChangeKind(from, to string) string {
+	if to == TaskDone && from != TaskDone {
+		return "task_completed"
+	}
+	return "task_updated"
+}
+
+// nextTaskPosition is the position that appends a task to the bottom of a column.
+func nextTaskPosition(tx *gorm.DB, eventID uint, status string) int {
+	var max *int
+	tx.Model(&Task{}).Where("event_id = ? AND status = ?", eventID, status).Select("MAX(position)").Scan(&max)
+	if max == nil {
+		return 0
+	}
+	return *max + 1
+}
+
+type boardColumn struct {
+	Status string     `json:"status"`
+	Count  int        `json:"count"`
+	Tasks  []TaskView `json:"tasks"`
+}
+
+func GetTaskBoard(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var ev Event
+	if err := DB.First(&ev, eventID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "event not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !isEventParticipant(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only participants can view the task board")
+		return
+	}
+
+	var tasks []Task
+	if err := DB.Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&tasks).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	byStatus := map[string][]TaskView{}
+	for _, t := range tasks {
+		byStatus[t.Status] = append(byStatus[t.Status], taskView(t))
+	}
+	columns := make([]boardColumn, 0, len(taskColumns))
+	for _, status := range taskColumns {
+		list := byStatus[status]
+		if list == nil {
+			list = []TaskView{}
+		}
+		columns = append(columns, boardColumn{Status: status, Count: len(list), Tasks: list})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "total": len(tasks), "columns": columns})
+}
+
+type MoveTaskRequest struct {
+	Status   string `json:"status" binding:"required"`
+	Position int    `json:"position"` // 0-based index in the target column
+}
+
+// MoveTask moves a task to a column and index. Both columns are renumbered
+// in one transaction so positions stay contiguous under concurrent drags.
+func MoveTask(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	var body MoveTaskRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if !validTaskStatus(body.Status) {
+		jsonError(c, http.StatusBadRequest, "status must be one of: todo, in_progress, done")
+		return
+	}
+	if body.Position < 0 {
+		jsonError(c, http.StatusBadRequest, "position must not be negative")
+		return
+	}
+
+	var task Task
+	if err := DB.First(&task, taskID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "task not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var ev Event
+	if err := DB.First(&ev, task.EventID).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only organizers can move tasks")
+		return
+	}
+
+	var from string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var tasks []Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("event_id = ?", ev.ID).Order("position asc, id asc").Find(&tasks).Error; err != nil {
+			return err
+		}
+
+		columns := map[string][]Task{}
+		for _, t := range tasks {
+			if t.ID == task.ID {
+				task = t
+				continue
+			}
+			columns[t.Status] = append(columns[t.Status], t)
+		}
+
+		from = task.Status
+		target := columns[body.Status]
+		pos := body.Position
+		if pos > len(target) {
+			pos = len(target)
+		}
+		if task.Status != body.Status {
+			task.setStatus(body.Status, userID)
+			if err := tx.Model(&Task{}).Where("id = ?", task.ID).
+				Updates(map[string]interface{}{"completed_at": task.CompletedAt, "completed_by_id": task.CompletedByID}).Error; err != nil {
+				return err
+			}
+		}
+		target = append(target[:pos], append([]Task{task}, target[pos:]...)...)
+		columns[body.Status] = target
+
+		for _, list := range columns {
+			for i, t := range list {
+				if t.Position == i && t.ID != task.ID {
+					continue
+				}
+				if err := tx.Model(&Task{}).Where("id = ?", t.ID).
+					Updates(map[string]interface{}{"status": t.Status, "position": i}).Error; err != nil {
+					return err
+				}
+				recordChange(tx, EntityTask, t.ID, ev.ID, ChangeUpsert)
+				if t.ID == task.ID {
+					task.Position = i
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not move task: "+err.Error())
+		return
+	}
+	broadcastTask(taskChangeKind(from, task.Status), task)
+
+	c.JSON(http.StatusOK, taskView(task))
+}
+
+// DeleteTask removes a task and closes the gap in its column. Expenses
+// linked to it are kept and unlinked. The response carries an undo token
+// for the undo window.
+func DeleteTask(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	var task Task
+	if err := DB.First(&task, taskID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "task not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var ev Event
+	if err := DB.First(&ev, task.EventID).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	if !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only organizers can delete tasks")
+		return
+	}
+
+	var undo UndoAction
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, task.ID).Error; err != nil {
+			return err
+		}
+		snap := taskSnapshot{Task: task}
+		tx.Model(&Expense{}).Where("task_id = ?", task.ID).Pluck("id", &snap.ExpenseIDs)
+		tx.Model(&Attachment{}).Where("task_id = ?", task.ID).Pluck("id", &snap.AttachmentIDs)
+		tx.Model(&ResourceBooking{}).Where("task_id = ?", task.ID).Pluck("id", &snap.BookingIDs)
+		tx.Model(&VendorQuote{}).Where("task_id = ?", task.ID).Pluck("id", &snap.QuoteIDs)
+
+		// expenses, attachments, bookings and quotes stay on the event
+		if err := tx.Model(&Expense{}).Where("task_id = ?", task.ID).Update("task_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Attachment{}).Where("task_id = ?", task.ID).Update("task_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ResourceBooking{}).Where("task_id = ?", task.ID).Update("task_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&VendorQuote{}).Where("task_id = ?", task.ID).Update("task_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&Task{}, task.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Task{}).Where("event_id = ? AND status = ? AND position > ?", ev.ID, task.Status, task.Position).
+			UpdateColumn("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityTask, task.ID, ev.ID, ChangeDelete)
+
+		var err error
+		undo, err = recordUndo(tx, userID, UndoTaskDelete, ev.ID, task.ID, snap)
+		return err
+	})
+	if err == gorm.ErrRecordNotFound {
+		jsonError(c, http.StatusNotFound, "task not found")
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete task: "+err.Error())
+		return
+	}
+
+	Audit(userID, UndoTaskDelete, "task", task.ID, gin.H{"event_id": ev.ID, "title": task.Title})
+	broadcastTask("task_deleted", task)
+
+	resp := undoResponse(undo)
+	resp["message"] = "task deleted"
+	c.JSON(http.StatusOK, resp)
+}
+
+type TaskStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// UpdateTaskStatus moves a task along todo -> in_progress -> done for its
+// assignee or an organizer. It goes to the bottom of its new column.
+func UpdateTaskStatus(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var body TaskStatusRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if !validTaskStatus(body.Status) {
+		jsonError(c, http.StatusBadRequest, "status must be one of: todo, in_progress, done")
+		return
+	}
+
+	var task Task
+	if err := DB.First(&task, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			jsonError(c, http.StatusNotFound, "task not found")
+			return
+		}
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	var ev Event
+	if err := DB.First(&ev, task.EventID).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	isAssignee := task.AssigneeID != nil && *task.AssigneeID == userID
+	if !isAssignee && !isEventOrganizer(ev, userID) {
+		jsonError(c, http.StatusForbidden, "only the assignee or an organizer can change the status")
+		return
+	}
+
+	var from string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, task.ID).Error; err != nil {
+			return err
+		}
+		from = task.Status
+		if from == body.Status {
+			return nil
+		}
+		if !taskTransitionAllowed(from, body.Status) {
+			return errBadTransition
+		}
+		if err := tx.Model(&Task{}).Where("event_id = ? AND status = ? AND position > ?", ev.ID, from, task.Position).
+			UpdateColumn("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		task.setStatus(body.Status, userID)
+		task.Position = nextTaskPosition(tx, ev.ID, task.Status)
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityTask, task.ID, ev.ID, ChangeUpsert)
+		return nil
+	})
+	if err == errBadTransition {
+		jsonError(c, http.StatusConflict, "cannot move a task from "+from+" to "+body.Status)
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update task: "+err.Error())
+		return
+	}
+
+	if from != task.Status {
+		broadcastTask(taskChangeKind(from, task.Status), task)
+	}
+	if from != task.Status && task.Status == TaskDone && ev.OrganizerID != userID {
+		NotifyTemplate(ev.OrganizerID, "task_completed", map[string]string{"EventTitle": ev.Title, "TaskTitle": task.Title},
+			gin.H{"event_id": ev.ID, "task_id": task.ID})
+	}
+
+	c.JSON(http.StatusOK, taskView(task))
+}
+
+var errBadTransition = errors.New("task status transition not allowed")
+
+// ========================
+// ASSIGNMENT
+// ========================
+
+type AssignTaskRequest struct {
+	AssigneeID *uint `json:"assignee_id"` // null unassigns
+}
+
+// AssignTask gives a task to one of the event's participants.
+func AssignTask(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var task Task
+	if err := DB.Where("id = ? AND event_id = ?", c.Param("taskId"), ev.ID).First(&task).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "task not found")
+		return
+	}
+
+	var body AssignTaskRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.AssigneeID != nil && !isEventParticipant(ev, *body.AssigneeID) {
+		jsonError(c, http.StatusBadRequest, "assignee must be a participant of the event")
+		return
+	}
+
+	previous := task.AssigneeID
+	if err := DB.Model(&task).Update("assignee_id", body.AssigneeID).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not assign task: "+err.Error())
+		return
+	}
+	task.AssigneeID = body.AssigneeID
+	recordChange(DB, EntityTask, task.ID, ev.ID, ChangeUpsert)
+	Audit(userID, "task.assign", "task", task.ID, gin.H{"event_id": ev.ID, "assignee_id": body.AssigneeID})
+	broadcastTask("task_updated", task)
+
+	if a := body.AssigneeID; a != nil && *a != userID && (previous == nil || *previous != *a) {
+		NotifyTemplate(*a, "task_assigned", map[string]string{"EventTitle": ev.Title, "TaskTitle": task.Title},
+			gin.H{"event_id": ev.ID, "task_id": task.ID})
+	}
+
+	c.JSON(http.StatusOK, taskView(task))
+}
+
+// GetMyTasks lists the tasks assigned to the caller on every event, open
+// ones first, then by event date.
+func GetMyTasks(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := DB.Model(&Task{}).Joins("JOIN events ON events.id = tasks.event_id").
+		Where("tasks.assignee_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		if !validTaskStatus(status) {
+			jsonError(c, http.StatusBadRequest, "status must be one of: todo, in_progress, done")
+			return
+		}
+		q = q.Where("tasks.status = ?", status)
+	}
+	// Order ignores bare expressions, so this takes a whole clause
+	q, page, ok := paginate(c, q.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "tasks.status = ? ASC, events.date ASC, tasks.position ASC, tasks.id ASC",
+		Vars: []interface{}{TaskDone},
+	}}))
+	if !ok {
+		return
+	}
+	var tasks []Task
+	if err := q.Find(&tasks).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	writePage(c, page, taskViews(tasks), len(tasks))
+}
+
+// taskDueExpr is when a task is due: its own deadline, else the start of
+// its event, joined as task_events.
+const taskDueExpr = "COALESCE(tasks.due_at, task_events.date)"
+
+type eventTaskRollup struct {
+	EventID    uint       `json:"event_id"`
+	EventTitle string     `json:"event_title"`
+	EventDate  time.Time  `json:"event_date"`
+	Open       int        `json:"open"`
+	Overdue    int        `json:"overdue"`
+	Tasks      []TaskView `json:"tasks"`
+}
+
+// GetOrganizingTasks rolls up the open tasks of every live event the
+// caller organizes (or manages as a delegate), grouped by event in date
+// order, for one triage view. ?due_before only keeps tasks due before then.
+// Pages are of events.
+func GetOrganizingTasks(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	open := DB.Model(&Task{}).Select("tasks.id").
+		Joins("JOIN events task_events ON task_events.id = tasks.event_id").
+		Where("tasks.status <> ?", TaskDone)
+	if raw := c.Query("due_before"); raw != "" {
+		before, _, err := parseListDate(raw)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid due_before (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+		open = open.Where(taskDueExpr+" < ?", before)
+	}
+	open = open.Session(&gorm.Session{}) // reused for the events and their tasks
+
+	organized := DB.Model(&EventAttendee{}).Select("event_id").Where("user_id = ? AND role = ?", userID, "organizer").
+		Where("invitation IN ?", attendingInvitation)
+	q := ReadDB.Model(&Event{}).
+		Where("events.organizer_id = ? OR events.organizer_id IN (?) OR events.id IN (?)", userID, principalsQuery(userID), organized).
+		Where("events.cancelled_at IS NULL AND events.archived_at IS NULL").
+		Where("EXISTS (?)", open.Where("tasks.event_id = events.id")).
+		Order("events.date asc, events.id asc")
+	q, page, ok := paginate(c, q)
+	if !ok {
+		return
+	}
+	var events []Event
+	if err := q.Find(&events).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	ids := make([]uint, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	var tasks []Task
+	if len(ids) > 0 {
+		if err := ReadDB.Where("id IN (?)", open.Where("tasks.event_id IN ?", ids)).
+			Order("event_id asc, position asc, id asc").Find(&tasks).Error; err != nil {
+			jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+	}
+
+	now := time.Now()
+	byEvent := map[uint]*eventTaskRollup{}
+	out := make([]*eventTaskRollup, 0, len(events))
+	for _, ev := range events {
+		r := &eventTaskRollup{EventID: ev.ID, EventTitle: ev.Title, EventDate: ev.Date, Tasks: []TaskView{}}
+		byEvent[ev.ID] = r
+		out = append(out, r)
+	}
+	for _, t := range tasks {
+		r := byEvent[t.EventID]
+		due := r.EventDate
+		if t.DueAt != nil {
+			due = *t.DueAt
+		}
+		r.Open++
+		if due.Before(now) {
+			r.Overdue++
+		}
+		r.Tasks = append(r.Tasks, taskView(t))
+	}
+	writePage(c, page, out, len(out))
+}
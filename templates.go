@@ -0,0 +1,499 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// Notification wording lives in templates rather than in the handlers, so
+// hosts can change it without a rebuild. Every kind has built-in English
+// text; admins override it per locale through NotificationTemplate rows.
+// Templates use text/template syntax ({{.EventTitle}}) over the variables
+// listed for the kind.
+
+const defaultLocale = "en"
+
+// messageTemplate is the wording of one notification kind. Summary is only
+// used by bundled kinds and is the title once several items fold into one
+// notification; {{.Count}} is the number of items.
+type messageTemplate struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type templateKind struct {
+	// variable name -> sample value used by previews and validation
+	Vars    map[string]string
+	Bundled bool
+	Default messageTemplate
+}
+
+var templateKinds = map[string]templateKind{
+	"announcement": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Title": "Bus leaves at 8", "Body": "Meet at the main entrance."},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: {{.Title}}", Body: "{{.Body}}"},
+	},
+	"comment_mention": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Name": "sam@example.com", "Body": "@alex can you book the bus?"},
+		Default: messageTemplate{Subject: "{{.Name}} mentioned you on {{.EventTitle}}", Body: "{{.Body}}"},
+	},
+	"comment": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Body": "Can I bring a friend?"},
+		Bundled: true,
+		Default: messageTemplate{Subject: "New comment on {{.EventTitle}}", Body: "{{.Body}}", Summary: "{{.Count}} new comments on {{.EventTitle}}"},
+	},
+	"rsvp": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Name": "sam@example.com", "Status": "Going"},
+		Bundled: true,
+		Default: messageTemplate{Subject: "{{.EventTitle}}: {{.Name}} replied {{.Status}}", Body: "{{.Name}}: {{.Status}}", Summary: "{{.Count}} new RSVPs for {{.EventTitle}}"},
+	},
+	"invitation_answered": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Name": "sam@example.com", "Answer": "accepted"},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: {{.Name}} {{.Answer}} your invitation", Body: "{{.Name}} {{.Answer}} your invitation to {{.EventTitle}}."},
+	},
+	"attendance_nudge": {
+		Vars: map[string]string{"EventTitle": "Team offsite", "StartsAt": "Fri Jun 5, 09:00 UTC"},
+		Default: messageTemplate{
+			Subject: "Are you coming to {{.EventTitle}}?",
+			Body:    "We haven't heard from you yet. {{.EventTitle}} starts {{.StartsAt}}; let the organizer know if you're going.",
+		},
+	},
+	"threshold_not_met": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Going": "4", "Required": "10"},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: not enough attendees", Body: "{{.Going}} of the required {{.Required}} attendees confirmed. Consider cancelling or rescheduling."},
+	},
+	"password_reset": {
+		Vars:    map[string]string{"ExpiresIn": "1 hour"},
+		Default: messageTemplate{Subject: "Reset your password", Body: "Someone asked to reset the password of your account. The link below lets you choose a new one; it works once, within {{.ExpiresIn}}.\n\nIf it wasn't you, ignore this email and your password stays the same."},
+	},
+	"password_changed": {
+		Vars:    map[string]string{"IP": "203.0.113.7"},
+		Default: messageTemplate{Subject: "Your password was changed", Body: "Your password was reset from {{.IP}} and you were signed out everywhere. If it wasn't you, reset it again right away."},
+	},
+	"weather_alert": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Forecast": "80% chance of rain", "Next": "Your contingency announcement was sent to attendees."},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: bad weather forecast", Body: "{{.Forecast}} during {{.EventTitle}}. {{.Next}}"},
+	},
+	"event_anomaly": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Summary": "6 of 20 invitees declined in the last hour"},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: something looks off", Body: "{{.Summary}}."},
+	},
+	"resource_booking_dropped": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Resource": "Minibus"},
+		Default: messageTemplate{Subject: "{{.Resource}} is no longer booked for {{.EventTitle}}", Body: "{{.Resource}} was removed from your organization's resources, so its bookings for {{.EventTitle}} were dropped."},
+	},
+	"contract_approval_requested": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Name": "sam@example.com", "Contract": "Venue hire agreement", "Message": "Can you check the cancellation terms?"},
+		Default: messageTemplate{Subject: "{{.Name}} asks you to approve {{.Contract}}", Body: "{{.Name}} asks you to approve {{.Contract}} for {{.EventTitle}}. {{.Message}}"},
+	},
+	"contract_approval_withdrawn": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Contract": "Venue hire agreement"},
+		Default: messageTemplate{Subject: "{{.Contract}} has a new version", Body: "A new version of {{.Contract}} for {{.EventTitle}} was uploaded, so the approval you were asked for is withdrawn."},
+	},
+	"contract_decided": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Name": "alex@example.com", "Contract": "Venue hire agreement", "Decision": "approved", "Comment": "Looks good."},
+		Default: messageTemplate{Subject: "{{.Contract}} was {{.Decision}}", Body: "{{.Name}} {{.Decision}} {{.Contract}} for {{.EventTitle}}. {{.Comment}}"},
+	},
+	"event_cancelled": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Reason": "The venue flooded."},
+		Default: messageTemplate{Subject: "{{.EventTitle}} was cancelled", Body: "{{.Reason}}"},
+	},
+	"event_reinstated": {
+		Vars:    map[string]string{"EventTitle": "Team offsite"},
+		Default: messageTemplate{Subject: "{{.EventTitle}} is back on", Body: "The cancellation was withdrawn."},
+	},
+	"invitation": {
+		Vars: map[string]string{"EventTitle": "Team offsite", "InviterEmail": "alex@example.com", "StartsAt": "Fri Jun 5, 09:00 UTC", "Location": "Lisbon"},
+		Default: messageTemplate{
+			Subject: "You're invited: {{.EventTitle}}",
+			Body:    "{{.InviterEmail}} invited you to {{.EventTitle}} on {{.StartsAt}}{{if .Location}} at {{.Location}}{{end}}. Let them know whether you can make it.",
+		},
+	},
+	"rsvp_confirmation": {
+		Vars: map[string]string{"EventTitle": "Team offsite", "Status": "Going", "StartsAt": "Fri Jun 5, 09:00 UTC"},
+		Default: messageTemplate{
+			Subject: "{{.EventTitle}}: you replied {{.Status}}",
+			Body:    "Your answer for {{.EventTitle}} on {{.StartsAt}} is \"{{.Status}}\". You can change it any time before the event.",
+		},
+	},
+	"event_reminder": {
+		Vars: map[string]string{"EventTitle": "Team offsite", "StartsAt": "Fri Jun 5, 09:00 UTC", "Lead": "in 1 hour", "Location": "Main office"},
+		Default: messageTemplate{
+			Subject: "Reminder: {{.EventTitle}} starts {{.Lead}}",
+			Body:    "{{.EventTitle}} starts {{.StartsAt}}{{if .Location}} at {{.Location}}{{end}}.",
+		},
+	},
+	"ticket_cancelled": {
+		Vars: map[string]string{"EventTitle": "Team offsite", "TierName": "Early bird", "RefundPercent": "50"},
+		Default: messageTemplate{
+			Subject: "{{.EventTitle}}: your {{.TierName}} ticket was cancelled",
+			Body:    "You gave up your {{.TierName}} ticket for {{.EventTitle}}. Under the event's cancellation policy you are due a {{.RefundPercent}}% refund.",
+		},
+	},
+	"invoice_issued": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "Number": "2026-000042", "Total": "119.00 EUR"},
+		Default: messageTemplate{Subject: "Invoice {{.Number}} for {{.EventTitle}}", Body: "Your invoice for {{.Total}} is ready to download."},
+	},
+	"task_assigned": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "TaskTitle": "Book the bus"},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: you were assigned a task", Body: "{{.TaskTitle}}"},
+	},
+	"task_completed": {
+		Vars:    map[string]string{"EventTitle": "Team offsite", "TaskTitle": "Book the bus"},
+		Default: messageTemplate{Subject: "{{.EventTitle}}: task done", Body: "{{.TaskTitle}}"},
+	},
+	"certificate_issued": {
+		Vars:    map[string]string{"EventTitle": "Team offsite"},
+		Default: messageTemplate{Subject: "Your certificate for {{.EventTitle}}", Body: "Your certificate of attendance is ready to download."},
+	},
+	"waitlist_promoted": {
+		Vars:    map[string]string{"EventTitle": "Team offsite"},
+		Default: messageTemplate{Subject: "You're in: {{.EventTitle}}", Body: "A spot opened up and you are now going."},
+	},
+	"delegation_granted": {
+		Vars:    map[string]string{"PrincipalEmail": "alex@example.com"},
+		Default: messageTemplate{Subject: "You can now manage events for {{.PrincipalEmail}}", Body: "You can create, edit and invite people to their events until they revoke access."},
+	},
+	"data_export_ready": {
+		Vars:    map[string]string{"ExpiresAt": "Mon, 02 Jan 2006 15:04:05 UTC"},
+		Default: messageTemplate{Subject: "Your data export is ready", Body: "Download it before {{.ExpiresAt}}."},
+	},
+	"receipt_scanned": {
+		Vars:    map[string]string{},
+		Default: messageTemplate{Subject: "Receipt scanned", Body: "Check the amount and date we read before confirming."},
+	},
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// localeChain is the order locales are tried in: "pt-BR" falls back to
+// "pt", then to English.
+func localeChain(locale string) []string {
+	chain := []string{}
+	if locale != "" {
+		chain = append(chain, locale)
+		if lang, _, ok := strings.Cut(locale, "-"); ok {
+			chain = append(chain, lang)
+		}
+	}
+	if locale != defaultLocale {
+		chain = append(chain, defaultLocale)
+	}
+	return chain
+}
+
+// lookupTemplate finds the wording for kind in the closest locale, an
+// admin override winning over the built-in text of the same locale.
+func lookupTemplate(kind, locale string) messageTemplate {
+	def := templateKinds[kind].Default
+	chain := localeChain(locale)
+	var overrides []NotificationTemplate
+	DB.Where("kind = ? AND locale IN ?", kind, chain).Find(&overrides)
+	for _, l := range chain {
+		for _, o := range overrides {
+			if o.Locale == l {
+				return messageTemplate{Subject: o.Subject, Body: o.Body, Summary: o.Summary}
+			}
+		}
+		if l == defaultLocale {
+			return def
+		}
+	}
+	return def
+}
+
+func executeTemplate(text string, vars map[string]string) (string, error) {
+	t, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderTemplate fills in tpl. The summary keeps a %d where {{.Count}} was,
+// as NotifyBundled expects.
+func renderTemplate(tpl messageTemplate, vars map[string]string) (messageTemplate, error) {
+	var out messageTemplate
+	var err error
+	if out.Subject, err = executeTemplate(tpl.Subject, vars); err != nil {
+		return out, err
+	}
+	if out.Body, err = executeTemplate(tpl.Body, vars); err != nil {
+		return out, err
+	}
+	if tpl.Summary != "" {
+		escaped := map[string]string{"Count": "%d"}
+		for k, v := range vars {
+			escaped[k] = strings.ReplaceAll(v, "%", "%%")
+		}
+		if out.Summary, err = executeTemplate(tpl.Summary, escaped); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// renderNotification renders kind for the user's language. A broken
+// override falls back to the built-in text rather than losing the
+// notification.
+func renderNotification(userID uint, kind string, vars map[string]string) messageTemplate {
+	var u User
+	DB.Select("id", "language").First(&u, userID)
+	msg, err := renderTemplate(lookupTemplate(kind, u.Language), vars)
+	if err != nil {
+		log.Printf("⚠️ template %s (%s) failed, using the default: %v", kind, u.Language, err)
+		msg, _ = renderTemplate(templateKinds[kind].Default, vars)
+	}
+	return msg
+}
+
+// NotifyTemplate is Notify with the wording taken from kind's template.
+func NotifyTemplate(userID uint, kind string, vars map[string]string, data gin.H) {
+	msg := renderNotification(userID, kind, vars)
+	Notify(userID, kind, msg.Subject, msg.Body, data)
+}
+
+// NotifyBundledTemplate is NotifyBundled with the wording taken from
+// kind's template.
+func NotifyBundledTemplate(userID uint, kind, bundleKey string, vars map[string]string, data gin.H) {
+	msg := renderNotification(userID, kind, vars)
+	NotifyBundled(userID, kind, bundleKey, msg.Subject, msg.Body, msg.Summary, data)
+}
+
+// ========================
+// ADMIN
+// ========================
+
+type templateInfo struct {
+	Kind      string             `json:"kind"`
+	Variables []string           `json:"variables"`
+	Bundled   bool               `json:"bundled"`
+	Default   messageTemplate    `json:"default"`
+	Overrides []TemplateOverride `json:"overrides"`
+}
+
+// TemplateOverride is an admin's wording for one locale.
+type TemplateOverride struct {
+	Locale string `json:"locale"`
+	messageTemplate
+}
+
+func describeTemplate(kind string, overrides []NotificationTemplate) templateInfo {
+	k := templateKinds[kind]
+	info := templateInfo{Kind: kind, Variables: []string{}, Bundled: k.Bundled, Default: k.Default, Overrides: []TemplateOverride{}}
+	for name := range k.Vars {
+		info.Variables = append(info.Variables, name)
+	}
+	if k.Bundled {
+		info.Variables = append(info.Variables, "Count")
+	}
+	sort.Strings(info.Variables)
+	for _, o := range overrides {
+		info.Overrides = append(info.Overrides, TemplateOverride{Locale: o.Locale, messageTemplate: messageTemplate{Subject: o.Subject, Body: o.Body, Summary: o.Summary}})
+	}
+	return info
+}
+
+func templateKindNames() []string {
+	kinds := make([]string, 0, len(templateKinds))
+	for kind := range templateKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// GetTemplates lists every notification kind with its variables, built-in
+// wording and overrides.
+func GetTemplates(c *gin.Context) {
+	var all []NotificationTemplate
+	if err := DB.Order("kind asc, locale asc").Find(&all).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	byKind := map[string][]NotificationTemplate{}
+	for _, t := range all {
+		byKind[t.Kind] = append(byKind[t.Kind], t)
+	}
+
+	kinds := templateKindNames()
+	out := make([]templateInfo, 0, len(kinds))
+	for _, kind := range kinds {
+		out = append(out, describeTemplate(kind, byKind[kind]))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func templateKindParam(c *gin.Context) (string, bool) {
+	kind := c.Param("kind")
+	if _, ok := templateKinds[kind]; !ok {
+		jsonError(c, http.StatusNotFound, "unknown template kind")
+		return "", false
+	}
+	return kind, true
+}
+
+func GetTemplate(c *gin.Context) {
+	kind, ok := templateKindParam(c)
+	if !ok {
+		return
+	}
+	var overrides []NotificationTemplate
+	DB.Where("kind = ?", kind).Order("locale asc").Find(&overrides)
+	c.JSON(http.StatusOK, describeTemplate(kind, overrides))
+}
+
+type TemplateRequest struct {
+	Subject string `json:"subject" binding:"required,max=255"`
+	Body    string `json:"body" binding:"max=5000"`
+	Summary string `json:"summary" binding:"max=255"`
+}
+
+var errUnknownVariable = errors.New("unknown variable")
+
+// checkTemplate parses tpl and renders it with the kind's sample values,
+// so a typo fails here and not when a notification goes out.
+func checkTemplate(kind string, tpl messageTemplate) error {
+	k := templateKinds[kind]
+	vars := map[string]string{}
+	for name, sample := range k.Vars {
+		vars[name] = sample
+	}
+	for _, text := range []string{tpl.Subject, tpl.Body, tpl.Summary} {
+		for _, action := range templateActionPattern.FindAllString(text, -1) {
+			for _, m := range templateVarPattern.FindAllStringSubmatch(action, -1) {
+				if _, ok := vars[m[1]]; !ok && !(k.Bundled && m[1] == "Count") {
+					return fmt.Errorf("%w: %s", errUnknownVariable, m[1])
+				}
+			}
+		}
+	}
+	_, err := renderTemplate(tpl, vars)
+	return err
+}
+
+var (
+	templateActionPattern = regexp.MustCompile(`\{\{.*?\}\}`)
+	templateVarPattern    = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// PutTemplate stores the override of a kind for one locale.
+func PutTemplate(c *gin.Context) {
+	userID, _ := getUserIDFromContext(c)
+	kind, ok := templateKindParam(c)
+	if !ok {
+		return
+	}
+	locale := c.Param("locale")
+	if !localePattern.MatchString(locale) {
+		jsonError(c, http.StatusBadRequest, "locale must look like en or pt-BR")
+		return
+	}
+
+	var body TemplateRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	tpl := messageTemplate{Subject: body.Subject, Body: body.Body, Summary: body.Summary}
+	if templateKinds[kind].Bundled && tpl.Summary == "" {
+		jsonError(c, http.StatusBadRequest, "summary is required for bundled notifications")
+		return
+	}
+	if err := checkTemplate(kind, tpl); err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid template: "+err.Error())
+		return
+	}
+
+	row := NotificationTemplate{Kind: kind, Locale: locale, Subject: tpl.Subject, Body: tpl.Body, Summary: tpl.Summary, UpdatedByID: userID}
+	if err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "body", "summary", "updated_by_id", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save template: "+err.Error())
+		return
+	}
+	Audit(userID, "template.update", "template", 0, gin.H{"kind": kind, "locale": locale})
+
+	c.JSON(http.StatusOK, TemplateOverride{Locale: locale, messageTemplate: tpl})
+}
+
+// DeleteTemplate drops an override; the locale falls back to the next one
+// in its chain.
+func DeleteTemplate(c *gin.Context) {
+	userID, _ := getUserIDFromContext(c)
+	kind, ok := templateKindParam(c)
+	if !ok {
+		return
+	}
+	res := DB.Where("kind = ? AND locale = ?", kind, c.Param("locale")).Delete(&NotificationTemplate{})
+	if res.Error != nil {
+		jsonError(c, http.StatusInternalServerError, "could not delete template: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		jsonError(c, http.StatusNotFound, "no override for this locale")
+		return
+	}
+	Audit(userID, "template.delete", "template", 0, gin.H{"kind": kind, "locale": c.Param("locale")})
+	c.JSON(http.StatusOK, gin.H{"message": "override removed"})
+}
+
+type TemplatePreviewRequest struct {
+	Locale   string            `json:"locale"`
+	Template *TemplateRequest  `json:"template"` // a draft; the stored wording when omitted
+	Vars     map[string]string `json:"vars"`     // defaults to the sample values
+}
+
+// PreviewTemplate renders a kind, or a draft of it, the way a user with
+// the given locale would see it.
+func PreviewTemplate(c *gin.Context) {
+	kind, ok := templateKindParam(c)
+	if !ok {
+		return
+	}
+	var body TemplatePreviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := bindJSON(c, &body); err != nil {
+			bindError(c, "invalid body", err)
+			return
+		}
+	}
+
+	tpl := lookupTemplate(kind, body.Locale)
+	if body.Template != nil {
+		tpl = messageTemplate{Subject: body.Template.Subject, Body: body.Template.Body, Summary: body.Template.Summary}
+	}
+	vars := map[string]string{}
+	for name, sample := range templateKinds[kind].Vars {
+		vars[name] = sample
+	}
+	for name, v := range body.Vars {
+		vars[name] = v
+	}
+
+	msg, err := renderTemplate(tpl, vars)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid template: "+err.Error())
+		return
+	}
+	resp := gin.H{"subject": msg.Subject, "body": msg.Body}
+	if msg.Summary != "" {
+		resp["summary"] = fmt.Sprintf(msg.Summary, 3)
+	}
+	c.JSON(http.StatusOK, resp)
+}
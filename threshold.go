@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ThresholdRequest struct {
+	MinAttendees     *int   `json:"min_attendees"`
+	DecisionDeadline string `json:"decision_deadline"` // RFC3339; required with min_attendees
+	AutoCancel       bool   `json:"auto_cancel"`
+}
+
+// SetAttendanceThreshold sets or clears (min_attendees null) the minimum
+// headcount of an event.
+func SetAttendanceThreshold(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
+
+	var body ThresholdRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+
+	updates := map[string]interface{}{
+		"min_attendees":        nil,
+		"decision_deadline":    nil,
+		"auto_cancel":          false,
+		"threshold_checked_at": nil,
+	}
+	if body.MinAttendees != nil {
+		if *body.MinAttendees < 1 {
+			jsonError(c, http.StatusBadRequest, "min_attendees must be at least 1")
+			return
+		}
+		if ev.MaxAttendees != nil && *body.MinAttendees > *ev.MaxAttendees {
+			jsonError(c, http.StatusBadRequest, "min_attendees cannot exceed max_attendees")
+			return
+		}
+		deadline, err := time.Parse(time.RFC3339, body.DecisionDeadline)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "decision_deadline must be RFC3339")
+			return
+		}
+		if !deadline.After(time.Now()) || deadline.After(ev.Date) {
+			jsonError(c, http.StatusBadRequest, "decision_deadline must be in the future and before the event")
+			return
+		}
+		updates["min_attendees"] = *body.MinAttendees
+		updates["decision_deadline"] = deadline
+		updates["auto_cancel"] = body.AutoCancel
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Updates(updates).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update threshold: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	DB.First(&ev, ev.ID)
+
+	c.JSON(http.StatusOK, eventView(ev, true))
+}
+
+// CheckAttendanceThresholds evaluates every event whose decision deadline
+// has passed. Each event is claimed with a conditional update so it is only
+// handled once even with several instances running.
+func CheckAttendanceThresholds(ctx context.Context) {
+	var due []Event
+	if err := DB.Where("min_attendees IS NOT NULL AND decision_deadline <= ? AND threshold_checked_at IS NULL AND cancelled_at IS NULL", time.Now()).
+		Find(&due).Error; err != nil {
+		log.Printf("⚠️ attendance threshold check failed: %v", err)
+		return
+	}
+
+	for _, ev := range due {
+		now := time.Now()
+		res := DB.Model(&Event{}).Where("id = ? AND threshold_checked_at IS NULL", ev.ID).Update("threshold_checked_at", now)
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		going := goingCount(DB, ev.ID)
+		if going >= int64(*ev.MinAttendees) {
+			continue
+		}
+
+		if !ev.AutoCancel {
+			NotifyTemplate(ev.OrganizerID, "threshold_not_met",
+				map[string]string{"EventTitle": ev.Title, "Going": strconv.FormatInt(going, 10), "Required": strconv.Itoa(*ev.MinAttendees)},
+				gin.H{"event_id": ev.ID})
+			continue
+		}
+		summary := fmt.Sprintf("%d of the required %d attendees confirmed", going, *ev.MinAttendees)
+
+		if err := cancelEvent(ev, "minimum attendance not reached ("+summary+")"); err != nil {
+			log.Printf("⚠️ auto-cancel of event %d failed: %v", ev.ID, err)
+		}
+	}
+}
+
+// cancelEvent marks the event cancelled and tells everyone who hadn't declined.
+func cancelEvent(ev Event, reason string) error {
+	now := time.Now()
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Updates(map[string]interface{}{
+		"cancelled_at":  now,
+		"cancel_reason": reason,
+	}).Error; err != nil {
+		return err
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	announceCancelled(ev, reason)
+	return nil
+}
+
+func announceCancelled(ev Event, reason string) {
+	for _, uid := range cancellationAudience(ev) {
+		vars := map[string]string{"EventTitle": ev.Title, "Reason": reason}
+		NotifyTemplate(uid, "event_cancelled", vars, gin.H{"event_id": ev.ID})
+		EmailTemplate(uid, "event_cancelled", vars, ev)
+	}
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "event_cancelled", EventID: ev.ID, Data: gin.H{"reason": reason}})
+}
+
+// announceReinstated tells the same people the cancellation was undone.
+func announceReinstated(ev Event) {
+	for _, uid := range cancellationAudience(ev) {
+		NotifyTemplate(uid, "event_reinstated", map[string]string{"EventTitle": ev.Title}, gin.H{"event_id": ev.ID})
+	}
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "event_reinstated", EventID: ev.ID})
+}
+
+// cancellationAudience is the organizer and everyone who hadn't declined.
+func cancellationAudience(ev Event) []uint {
+	var userIDs []uint
+	DB.Model(&EventAttendee{}).Where("event_id = ? AND status <> ?", ev.ID, "Not Going").Pluck("user_id", &userIDs)
+	seen := map[uint]bool{}
+	out := []uint{}
+	for _, uid := range append(userIDs, ev.OrganizerID) {
+		if !seen[uid] {
+			seen[uid] = true
+			out = append(out, uid)
+		}
+	}
+	return out
+}
+
+type CancelEventRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// CancelEvent cancels an event by hand. The response carries an undo
+// token for the undo window.
+func CancelEvent(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has already been cancelled")
+		return
+	}
+
+	var body CancelEventRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.Reason == "" {
+		body.Reason = "cancelled by the organizer"
+	}
+
+	var undo UndoAction
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Event{}).Where("id = ? AND cancelled_at IS NULL", ev.ID).Updates(map[string]interface{}{
+			"cancelled_at":  time.Now(),
+			"cancel_reason": body.Reason,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errUndoConflict
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+
+		var err error
+		undo, err = recordUndo(tx, userID, UndoEventCancel, ev.ID, ev.ID, cancelSnapshot{Reason: body.Reason})
+		return err
+	})
+	if err == errUndoConflict {
+		jsonError(c, http.StatusConflict, "this event has already been cancelled")
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not cancel event: "+err.Error())
+		return
+	}
+	announceCancelled(ev, body.Reason)
+	Audit(userID, UndoEventCancel, "event", ev.ID, gin.H{"reason": body.Reason})
+
+	DB.First(&ev, ev.ID)
+	resp := undoResponse(undo)
+	resp["event"] = eventView(ev, true)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
+)
+
+var tracer = otel.Tracer("eventplanner-backend")
+
+// InitTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set; the exporter reads the rest of the standard OTEL_* variables
+// itself. Without an endpoint spans are dropped, but incoming trace
+// context is still passed on to outbound calls.
+func InitTracing(ctx context.Context) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if AppConfig.OTLPEndpoint == "" {
+		return
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Fatalf("❌ could not create OTLP exporter: %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", AppConfig.ServiceName)))
+	if err != nil {
+		log.Fatalf("❌ invalid tracing resource: %v", err)
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	))
+	log.Printf("🔭 Tracing enabled, exporting to %s", AppConfig.OTLPEndpoint)
+}
+
+// TracingMiddleware starts a server span per request, continuing any trace
+// in the incoming traceparent header.
+func TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(AppConfig.ServiceName)
+}
+
+// useDBTracing records a span per query. Bound values are left out since
+// they include passwords, which are stored as given, and tokens.
+func useDBTracing(db *gorm.DB) {
+	if err := db.Use(gormtracing.NewPlugin(gormtracing.WithoutQueryVariables(), gormtracing.WithoutMetrics())); err != nil {
+		log.Fatalf("❌ could not enable query tracing: %v", err)
+	}
+}
+
+// tracedTransport propagates trace context on outbound requests and records
+// a client span for each. Requests must carry the caller's context.
+func tracedTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// newHTTPClient is the client for calls to third-party APIs.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: tracedTransport(nil)}
+}
+
+// dbFor scopes queries to the request so their spans join its trace.
+// Coverage is partial: only the handlers that use it (RequireEventRole,
+// InviteUser, jobs admin, the deprecation report and the test email) have
+// their queries in the request's trace.
+// Queries on the global DB still get spans, but as roots of their own.
+func dbFor(c *gin.Context) *gorm.DB {
+	return DB.WithContext(c.Request.Context())
+}
+
+// startSpan opens a child span for one step of a handler or job.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan closes a span, marking it failed when err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	UndoTaskDelete     = "task.delete"
+	UndoAttendeeRemove = "attendee.remove"
+	UndoEventCancel    = "event.cancel"
+)
+
+var (
+	errUndoConflict = errors.New("this can no longer be undone")
+	errUndoGone     = errors.New("the event no longer exists")
+)
+
+// taskSnapshot is what a task deletion needs to put back.
+type taskSnapshot struct {
+	Task          Task   `json:"task"`
+	ExpenseIDs    []uint `json:"expense_ids"`
+	AttachmentIDs []uint `json:"attachment_ids"`
+	BookingIDs    []uint `json:"booking_ids"`
+	QuoteIDs      []uint `json:"quote_ids"`
+}
+
+type attendeeSnapshot struct {
+	Attendee   EventAttendee    `json:"attendee"`
+	Answers    []AttendeeAnswer `json:"answers"`
+	Waitlisted *WaitlistEntry   `json:"waitlisted,omitempty"`
+}
+
+type cancelSnapshot struct {
+	Reason string `json:"reason"`
+}
+
+// recordUndo stores what a destructive action removed and hands back a
+// token that reverses it until the undo window closes. It runs in the
+// action's transaction so there is never a token for something that
+// didn't happen.
+func recordUndo(tx *gorm.DB, userID uint, action string, eventID, targetID uint, snapshot interface{}) (UndoAction, error) {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return UndoAction{}, err
+	}
+	token, err := randomToken(24)
+	if err != nil {
+		return UndoAction{}, err
+	}
+	u := UndoAction{
+		Token:     token,
+		UserID:    userID,
+		Action:    action,
+		EventID:   eventID,
+		TargetID:  targetID,
+		Snapshot:  string(raw),
+		ExpiresAt: time.Now().Add(time.Duration(AppConfig.UndoWindowMinutes) * time.Minute),
+	}
+	return u, tx.Create(&u).Error
+}
+
+// undoResponse is merged into the response of an undoable action.
+func undoResponse(u UndoAction) gin.H {
+	return gin.H{"undo_token": u.Token, "undo_expires_at": u.ExpiresAt}
+}
+
+// Undo reverses a recent destructive action by the caller. Each token
+// works once.
+func Undo(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var u UndoAction
+	var announce func()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UndoAction{}).
+			Where("token = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", c.Param("token"), userID, time.Now()).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("token = ?", c.Param("token")).First(&u).Error; err != nil {
+			return err
+		}
+
+		var ev Event
+		if err := tx.First(&ev, u.EventID).Error; err != nil {
+			return errUndoGone
+		}
+
+		var err error
+		switch u.Action {
+		case UndoTaskDelete:
+			err = undoTaskDelete(tx, ev, u)
+		case UndoAttendeeRemove:
+			err = undoAttendeeRemove(tx, ev, u)
+		case UndoEventCancel:
+			announce, err = undoEventCancel(tx, ev)
+		default:
+			err = errUndoConflict
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		jsonError(c, http.StatusNotFound, "undo token not found or expired")
+		return
+	case errors.Is(err, errUndoConflict), errors.Is(err, errUndoGone):
+		jsonError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		jsonError(c, http.StatusInternalServerError, "undo failed: "+err.Error())
+		return
+	}
+	if announce != nil {
+		announce()
+	}
+
+	Audit(userID, u.Action+".undo", "event", u.EventID, gin.H{"target_id": u.TargetID})
+	c.JSON(http.StatusOK, gin.H{"message": "undone", "action": u.Action, "event_id": u.EventID, "target_id": u.TargetID})
+}
+
+func undoTaskDelete(tx *gorm.DB, ev Event, u UndoAction) error {
+	var snap taskSnapshot
+	if err := json.Unmarshal([]byte(u.Snapshot), &snap); err != nil {
+		return err
+	}
+	task := snap.Task
+	task.EventID = ev.ID
+	if err := tx.Model(&Task{}).Where("event_id = ? AND status = ? AND position >= ?", ev.ID, task.Status, task.Position).
+		UpdateColumn("position", gorm.Expr("position + 1")).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&task).Error; err != nil {
+		return err
+	}
+	if len(snap.ExpenseIDs) > 0 {
+		if err := tx.Model(&Expense{}).Where("id IN ? AND event_id = ? AND task_id IS NULL", snap.ExpenseIDs, ev.ID).
+			Update("task_id", task.ID).Error; err != nil {
+			return err
+		}
+	}
+	if len(snap.AttachmentIDs) > 0 {
+		if err := tx.Model(&Attachment{}).Where("id IN ? AND event_id = ? AND task_id IS NULL", snap.AttachmentIDs, ev.ID).
+			Update("task_id", task.ID).Error; err != nil {
+			return err
+		}
+	}
+	if len(snap.BookingIDs) > 0 {
+		if err := tx.Model(&ResourceBooking{}).Where("id IN ? AND event_id = ? AND task_id IS NULL", snap.BookingIDs, ev.ID).
+			Update("task_id", task.ID).Error; err != nil {
+			return err
+		}
+	}
+	if len(snap.QuoteIDs) > 0 {
+		if err := tx.Model(&VendorQuote{}).Where("id IN ? AND event_id = ? AND task_id IS NULL", snap.QuoteIDs, ev.ID).
+			Update("task_id", task.ID).Error; err != nil {
+			return err
+		}
+	}
+	recordChange(tx, EntityTask, task.ID, ev.ID, ChangeUpsert)
+	return nil
+}
+
+func undoAttendeeRemove(tx *gorm.DB, ev Event, u UndoAction) error {
+	var snap attendeeSnapshot
+	if err := json.Unmarshal([]byte(u.Snapshot), &snap); err != nil {
+		return err
+	}
+	// invited again in the meantime
+	var existing int64
+	tx.Model(&EventAttendee{}).Where("event_id = ? AND user_id = ?", ev.ID, snap.Attendee.UserID).Count(&existing)
+	if existing > 0 {
+		return errUndoConflict
+	}
+
+	att := snap.Attendee
+	// the seat may have gone to someone from the waitlist since
+	if att.Status == "Going" {
+		limits, err := lockCapacity(tx, capacityScope{ScopeEvent, ev.ID})
+		if err != nil {
+			return err
+		}
+		if firstFull(limits) != nil {
+			att.Status = StatusWaitlisted
+			snap.Waitlisted = &WaitlistEntry{EventID: ev.ID, UserID: att.UserID}
+		}
+	}
+	if err := tx.Create(&att).Error; err != nil {
+		return err
+	}
+	for _, a := range snap.Answers {
+		// skip answers to fields deleted since
+		var n int64
+		tx.Model(&EventField{}).Where("id = ? AND event_id = ?", a.FieldID, ev.ID).Count(&n)
+		if n == 0 {
+			continue
+		}
+		if err := tx.Create(&a).Error; err != nil {
+			return err
+		}
+	}
+	if w := snap.Waitlisted; w != nil {
+		if err := tx.Where("event_id = ? AND user_id = ?", ev.ID, w.UserID).FirstOrCreate(w).Error; err != nil {
+			return err
+		}
+	}
+	recordChange(tx, EntityAttendee, att.ID, ev.ID, ChangeUpsert)
+	recordUserChange(tx, att.UserID, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	return nil
+}
+
+func undoEventCancel(tx *gorm.DB, ev Event) (func(), error) {
+	if ev.CancelledAt == nil {
+		return nil, errUndoConflict
+	}
+	if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Updates(map[string]interface{}{
+		"cancelled_at":  nil,
+		"cancel_reason": "",
+	}).Error; err != nil {
+		return nil, err
+	}
+	recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	return func() { announceReinstated(ev) }, nil
+}
+
+// PruneUndoActions forgets tokens whose window has closed.
+func PruneUndoActions(ctx context.Context) {
+	if err := DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&UndoAction{}).Error; err != nil {
+		log.Printf("⚠️ undo prune failed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Events have no venue of their own beyond their location, so two events
+// book the same venue when their locations match once case, punctuation
+// and spacing are ignored. Events are taken to last defaultEventDuration,
+// as in the calendar feeds. Only the organizer's own events and those of
+// organizations they belong to are checked: other people's events are
+// none of their business.
+
+// VenueClash is an event already booked at the same venue and time.
+type VenueClash struct {
+	EventID  uint      `json:"event_id"`
+	Title    string    `json:"title"`
+	Location string    `json:"location"`
+	Date     time.Time `json:"date"`
+}
+
+// venueKey normalises a location for comparison; "" means none.
+func venueKey(location string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(location), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// venueClashes finds the organizer's and their organizations' events at
+// location whose time overlaps an event starting at start.
+func venueClashes(organizerID uint, location string, start time.Time) []VenueClash {
+	key := venueKey(location)
+	if key == "" {
+		return nil
+	}
+	scope := DB.Where("organizer_id = ?", organizerID)
+	if orgs := userOrgIDs(organizerID); len(orgs) > 0 {
+		scope = scope.Or("organization_id IN ?", orgs)
+	}
+	var candidates []Event
+	DB.Select("id", "title", "location", "date").
+		Where("cancelled_at IS NULL AND date > ? AND date < ?", start.Add(-defaultEventDuration), start.Add(defaultEventDuration)).
+		Where(scope).Order("date asc").Find(&candidates)
+
+	var out []VenueClash
+	for _, ev := range candidates {
+		if venueKey(ev.Location) == key {
+			out = append(out, VenueClash{EventID: ev.ID, Title: ev.Title, Location: ev.Location, Date: ev.Date})
+		}
+	}
+	return out
+}
+
+func venueClashConflict(c *gin.Context, clashes []VenueClash) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "the venue is already booked at this time; set allow_double_booking to create the event anyway",
+		"clashes": clashes,
+	})
+}
+
+// venueClashWarnings are the notes attached to an event created despite
+// its clashes.
+func venueClashWarnings(clashes []VenueClash) []string {
+	out := make([]string, 0, len(clashes))
+	for _, cl := range clashes {
+		out = append(out, fmt.Sprintf("%s is also booked for %q at %s", cl.Location, cl.Title, cl.Date.Format(time.RFC3339)))
+	}
+	return out
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const StatusWaitlisted = "Waitlisted"
+
+func goingCount(tx *gorm.DB, eventID uint) int64 {
+	var n int64
+	tx.Model(&EventAttendee{}).Where("event_id = ? AND status = ?", eventID, "Going").Count(&n)
+	var guests int64
+	tx.Model(&GuestRSVP{}).Where("event_id = ? AND status = ?", eventID, "Going").Count(&guests)
+	return n + guests
+}
+
+// waitlistPosition is 1-based; 0 means the user isn't waitlisted.
+func waitlistPosition(eventID, userID uint) int64 {
+	var entry WaitlistEntry
+	if err := DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&entry).Error; err != nil {
+		return 0
+	}
+	var ahead int64
+	DB.Model(&WaitlistEntry{}).
+		Where("event_id = ? AND (created_at < ? OR (created_at = ? AND id < ?))", eventID, entry.CreatedAt, entry.CreatedAt, entry.ID).
+		Count(&ahead)
+	return ahead + 1
+}
+
+func joinWaitlist(tx *gorm.DB, eventID, userID uint) error {
+	entry := WaitlistEntry{EventID: eventID, UserID: userID}
+	return tx.Where("event_id = ? AND user_id = ?", eventID, userID).FirstOrCreate(&entry).Error
+}
+
+func leaveWaitlist(tx *gorm.DB, eventID, userID uint) error {
+	return tx.Where("event_id = ? AND user_id = ?", eventID, userID).Delete(&WaitlistEntry{}).Error
+}
+
+// promoteFromWaitlist moves a waitlisted user to "Going", invoicing them
+// against their saved billing details if their tier is priced.
+func promoteFromWaitlist(tx *gorm.DB, eventID, userID uint) error {
+	if err := leaveWaitlist(tx, eventID, userID); err != nil {
+		return err
+	}
+	var att EventAttendee
+	if err := tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&att).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&att).Update("status", "Going").Error; err != nil {
+		return err
+	}
+	recordChange(tx, EntityAttendee, att.ID, eventID, ChangeUpsert)
+
+	if att.TicketTierID == nil {
+		return nil
+	}
+	var tier TicketTier
+	if tx.First(&tier, *att.TicketTierID).Error != nil || tier.PriceCents == 0 {
+		return nil
+	}
+	bill, ok := billingProfileOf(tx, userID)
+	if !ok {
+		return nil
+	}
+	var ev Event
+	if err := tx.First(&ev, eventID).Error; err != nil {
+		return err
+	}
+	_, err := issueInvoice(tx, ev, att, tier, bill)
+	return err
+}
+
+// promoteWaitlisted fills the event's free seats from the front of the
+// waitlist, passing over anyone whose ticket tier has sold out since, and
+// returns who moved up. It runs in the transaction that freed the seats;
+// the caller tells them once it commits.
+func promoteWaitlisted(tx *gorm.DB, eventID uint) ([]uint, error) {
+	limits, err := lockCapacity(tx, capacityScope{ScopeEvent, eventID})
+	if err != nil {
+		return nil, err
+	}
+	free := limits[0].Remaining
+	if free != nil && *free == 0 {
+		return nil, nil
+	}
+
+	var entries []WaitlistEntry
+	if err := tx.Where("event_id = ?", eventID).Order("created_at asc, id asc").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	promoted := []uint{}
+	for _, e := range entries {
+		if free != nil && int64(len(promoted)) >= *free {
+			break
+		}
+		var att EventAttendee
+		if tx.Select("id", "ticket_tier_id").Where("event_id = ? AND user_id = ?", eventID, e.UserID).First(&att).Error != nil {
+			continue
+		}
+		if att.TicketTierID != nil {
+			tierLimits, err := lockCapacity(tx, capacityScope{ScopeTicketTier, *att.TicketTierID})
+			if err != nil {
+				return nil, err
+			}
+			if tierLimits[0].full() {
+				continue
+			}
+		}
+		if err := promoteFromWaitlist(tx, eventID, e.UserID); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, e.UserID)
+	}
+	return promoted, nil
+}
+
+func notifyPromoted(ev Event, userIDs []uint) {
+	for _, id := range userIDs {
+		NotifyTemplate(id, "waitlist_promoted", map[string]string{"EventTitle": ev.Title}, gin.H{"event_id": ev.ID})
+		broadcastRSVP(ev, id, "Going")
+	}
+}
+
+type waitlistItem struct {
+	Position int       `json:"position"`
+	UserID   uint      `json:"user_id"`
+	Email    string    `json:"email"`
+	Since    time.Time `json:"since"`
+}
+
+func GetWaitlist(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var entries []WaitlistEntry
+	if err := DB.Where("event_id = ?", ev.ID).Order("created_at asc, id asc").Find(&entries).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.UserID)
+	}
+	emails := map[uint]string{}
+	var users []User
+	DB.Select("id", "email").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+
+	items := make([]waitlistItem, 0, len(entries))
+	for i, e := range entries {
+		items = append(items, waitlistItem{Position: i + 1, UserID: e.UserID, Email: emails[e.UserID], Since: e.CreatedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"max_attendees": ev.MaxAttendees,
+		"going":         goingCount(DB, ev.ID),
+		"waitlist":      items,
+	})
+}
+
+// PromoteWaitlisted lets an organizer pick someone off the queue, even past capacity.
+func PromoteWaitlisted(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if waitlistPosition(ev.ID, uint(targetID)) == 0 {
+		jsonError(c, http.StatusNotFound, "user is not on the waitlist")
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return promoteFromWaitlist(tx, ev.ID, uint(targetID))
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not promote: "+err.Error())
+		return
+	}
+
+	notifyPromoted(ev, []uint{uint(targetID)})
+
+	c.JSON(http.StatusOK, gin.H{"message": "user promoted", "user_id": targetID})
+}
+
+func GetMyWaitlistPosition(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	pos := waitlistPosition(uint(eventID), userID)
+	if pos == 0 {
+		jsonError(c, http.StatusNotFound, "you are not on the waitlist")
+		return
+	}
+
+	var total int64
+	DB.Model(&WaitlistEntry{}).Where("event_id = ?", eventID).Count(&total)
+
+	c.JSON(http.StatusOK, gin.H{"position": pos, "total": total})
+}
+
+type CapacityRequest struct {
+	MaxAttendees *int `json:"max_attendees"`
+}
+
+func SetEventCapacity(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body CapacityRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if body.MaxAttendees != nil && *body.MaxAttendees < 1 {
+		jsonError(c, http.StatusBadRequest, "max_attendees must be at least 1")
+		return
+	}
+
+	// seats a higher limit opens go to the waitlist straight away
+	var promoted []uint
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Event{}).Where("id = ?", ev.ID).Update("max_attendees", body.MaxAttendees).Error; err != nil {
+			return err
+		}
+		recordChange(tx, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+		var err error
+		promoted, err = promoteWaitlisted(tx, ev.ID)
+		return err
+	}); err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update capacity: "+err.Error())
+		return
+	}
+	notifyPromoted(ev, promoted)
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "max_attendees": body.MaxAttendees, "promoted": promoted})
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Outdoor events can keep a plan B for bad weather: thresholds on the
+// chance of rain and the temperature, and an announcement written ahead of
+// time ("we're moving into the hall"). From LeadHours before the start the
+// forecast for the event's hours is checked every hour; the first time a
+// threshold is met the organizers are told, and the announcement goes out
+// to everyone when the plan is set to publish it on its own. Otherwise an
+// organizer publishes it with one call once they've had a look.
+
+const (
+	weatherDefaultLead = 48
+	weatherMaxLead     = 7 * 24 // forecasts further out aren't worth acting on
+)
+
+var (
+	errWeatherPlaceNotFound = errors.New("place not found")
+	errNoForecast           = errors.New("no forecast for that time yet")
+)
+
+// WeatherForecast sums up the hourly forecast over a span of time.
+type WeatherForecast struct {
+	RainProbability int     `json:"rain_probability"` // highest of the hours, percent
+	MinTempC        float64 `json:"min_temp_c"`
+	MaxTempC        float64 `json:"max_temp_c"`
+}
+
+// WeatherProvider looks places up and forecasts their weather.
+type WeatherProvider interface {
+	Geocode(ctx context.Context, place string) (lat, lon float64, name string, err error)
+	Forecast(ctx context.Context, lat, lon float64, from, to time.Time) (WeatherForecast, error)
+}
+
+// ========================
+// OPEN-METEO PROVIDER
+// ========================
+
+// openMeteoProvider uses the free Open-Meteo forecast and geocoding APIs,
+// which need no key.
+type openMeteoProvider struct {
+	forecastURL  string
+	geocodingURL string
+	client       *http.Client
+}
+
+func (p *openMeteoProvider) get(ctx context.Context, u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("weather provider returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *openMeteoProvider) Geocode(ctx context.Context, place string) (float64, float64, string, error) {
+	var doc struct {
+		Results []struct {
+			Name      string  `json:"name"`
+			Country   string  `json:"country"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	u := p.geocodingURL + "?count=1&name=" + url.QueryEscape(place)
+	if err := p.get(ctx, u, &doc); err != nil {
+		return 0, 0, "", err
+	}
+	if len(doc.Results) == 0 {
+		return 0, 0, "", errWeatherPlaceNotFound
+	}
+	r := doc.Results[0]
+	name := r.Name
+	if r.Country != "" {
+		name += ", " + r.Country
+	}
+	return r.Latitude, r.Longitude, name, nil
+}
+
+func (p *openMeteoProvider) Forecast(ctx context.Context, lat, lon float64, from, to time.Time) (WeatherForecast, error) {
+	from, to = from.UTC(), to.UTC()
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("hourly", "temperature_2m,precipitation_probability")
+	q.Set("timezone", "UTC")
+	q.Set("start_date", from.Format("2006-01-02"))
+	q.Set("end_date", to.Format("2006-01-02"))
+
+	var doc struct {
+		Hourly struct {
+			Time        []string   `json:"time"`
+			Temperature []*float64 `json:"temperature_2m"`
+			Rain        []*int     `json:"precipitation_probability"`
+		} `json:"hourly"`
+	}
+	if err := p.get(ctx, p.forecastURL+"?"+q.Encode(), &doc); err != nil {
+		return WeatherForecast{}, err
+	}
+
+	// every hour the event overlaps counts
+	start := from.Truncate(time.Hour)
+	out := WeatherForecast{MinTempC: math.Inf(1), MaxTempC: math.Inf(-1)}
+	hours := 0
+	for i, raw := range doc.Hourly.Time {
+		at, err := time.Parse("2006-01-02T15:04", raw)
+		if err != nil || at.Before(start) || !at.Before(to) {
+			continue
+		}
+		if i < len(doc.Hourly.Temperature) && doc.Hourly.Temperature[i] != nil {
+			out.MinTempC = math.Min(out.MinTempC, *doc.Hourly.Temperature[i])
+			out.MaxTempC = math.Max(out.MaxTempC, *doc.Hourly.Temperature[i])
+			hours++
+		}
+		if i < len(doc.Hourly.Rain) && doc.Hourly.Rain[i] != nil {
+			out.RainProbability = max(out.RainProbability, *doc.Hourly.Rain[i])
+		}
+	}
+	if hours == 0 {
+		return WeatherForecast{}, errNoForecast
+	}
+	return out, nil
+}
+
+// Weather is the configured provider; nil disables contingency plans.
+var Weather WeatherProvider
+
+func InitWeather() {
+	switch strings.ToLower(AppConfig.WeatherProvider) {
+	case "", "none":
+		Weather = nil
+	case "open-meteo":
+		Weather = &openMeteoProvider{
+			forecastURL:  "https://api.open-meteo.com/v1/forecast",
+			geocodingURL: "https://geocoding-api.open-meteo.com/v1/search",
+			client:       newHTTPClient(10 * time.Second),
+		}
+	default:
+		log.Fatalf("❌ unknown WEATHER_PROVIDER %q", AppConfig.WeatherProvider)
+	}
+}
+
+// ========================
+// PLANS
+// ========================
+
+type WeatherContingencyRequest struct {
+	// Where to forecast; both or neither, the latter geocoding the
+	// event's location
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+
+	RainProbability *int     `json:"rain_probability"` // percent
+	MinTempC        *float64 `json:"min_temp_c"`
+	MaxTempC        *float64 `json:"max_temp_c"`
+	LeadHours       int      `json:"lead_hours"` // default 48, at most a week
+
+	AnnouncementTitle string `json:"announcement_title"`
+	AnnouncementBody  string `json:"announcement_body"`
+	AutoPublish       bool   `json:"auto_publish"`
+}
+
+func (r *WeatherContingencyRequest) apply(p *WeatherContingency) error {
+	if r.RainProbability == nil && r.MinTempC == nil && r.MaxTempC == nil {
+		return errors.New("set at least one of rain_probability, min_temp_c and max_temp_c")
+	}
+	if r.RainProbability != nil && (*r.RainProbability < 1 || *r.RainProbability > 100) {
+		return errors.New("rain_probability must be between 1 and 100")
+	}
+	if r.MinTempC != nil && r.MaxTempC != nil && *r.MinTempC >= *r.MaxTempC {
+		return errors.New("min_temp_c must be below max_temp_c")
+	}
+	if (r.Latitude == nil) != (r.Longitude == nil) {
+		return errors.New("set both latitude and longitude, or neither")
+	}
+	if r.Latitude != nil && (math.Abs(*r.Latitude) > 90 || math.Abs(*r.Longitude) > 180) {
+		return errors.New("latitude or longitude out of range")
+	}
+	if r.LeadHours == 0 {
+		r.LeadHours = weatherDefaultLead
+	}
+	if r.LeadHours < 1 || r.LeadHours > weatherMaxLead {
+		return fmt.Errorf("lead_hours must be between 1 and %d", weatherMaxLead)
+	}
+	title := strings.TrimSpace(r.AnnouncementTitle)
+	if r.AutoPublish && title == "" {
+		return errors.New("auto_publish needs an announcement_title")
+	}
+
+	p.RainProbability, p.MinTempC, p.MaxTempC = r.RainProbability, r.MinTempC, r.MaxTempC
+	p.LeadHours = r.LeadHours
+	p.AnnouncementTitle, p.AnnouncementBody, p.AutoPublish = title, r.AnnouncementBody, r.AutoPublish
+
+	// saving re-arms the plan
+	p.CheckedAt, p.ForecastRainProbability, p.ForecastMinTempC, p.ForecastMaxTempC = nil, nil, nil, nil
+	p.TriggeredAt, p.TriggerReason, p.AnnouncementID = nil, "", nil
+	return nil
+}
+
+// breaches describes each threshold the forecast meets.
+func (p WeatherContingency) breaches(f WeatherForecast) []string {
+	var out []string
+	if p.RainProbability != nil && f.RainProbability >= *p.RainProbability {
+		out = append(out, fmt.Sprintf("%d%% chance of rain", f.RainProbability))
+	}
+	if p.MinTempC != nil && f.MinTempC < *p.MinTempC {
+		out = append(out, fmt.Sprintf("a low of %.0f°C", f.MinTempC))
+	}
+	if p.MaxTempC != nil && f.MaxTempC > *p.MaxTempC {
+		out = append(out, fmt.Sprintf("a high of %.0f°C", f.MaxTempC))
+	}
+	return out
+}
+
+func loadWeatherContingency(c *gin.Context, ev Event) (WeatherContingency, bool) {
+	var p WeatherContingency
+	if err := DB.Where("event_id = ?", ev.ID).First(&p).Error; err != nil {
+		jsonError(c, http.StatusNotFound, "this event has no weather contingency plan")
+		return p, false
+	}
+	return p, true
+}
+
+// GetWeatherContingency shows the event's plan and the latest forecast
+// checked against it.
+func GetWeatherContingency(c *gin.Context) {
+	ev, _ := eventFromContext(c)
+	p, ok := loadWeatherContingency(c, ev)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SetWeatherContingency creates or replaces the event's plan.
+func SetWeatherContingency(c *gin.Context) {
+	userID, _ := getUserIDFromContext(c)
+	ev, _ := eventFromContext(c)
+	if Weather == nil {
+		jsonError(c, http.StatusServiceUnavailable, "weather forecasts are not configured")
+		return
+	}
+	if ev.CancelledAt != nil {
+		jsonError(c, http.StatusConflict, "this event has been cancelled")
+		return
+	}
+
+	var body WeatherContingencyRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	var p WeatherContingency
+	if DB.Where("event_id = ?", ev.ID).First(&p).Error != nil {
+		p = WeatherContingency{EventID: ev.ID, CreatedByID: userID}
+	}
+	if err := body.apply(&p); err != nil {
+		jsonError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.Latitude != nil {
+		p.Latitude, p.Longitude, p.PlaceName = *body.Latitude, *body.Longitude, ""
+	} else {
+		if strings.TrimSpace(ev.Location) == "" {
+			jsonError(c, http.StatusBadRequest, "the event has no location; set latitude and longitude")
+			return
+		}
+		lat, lon, name, err := Weather.Geocode(c.Request.Context(), ev.Location)
+		if errors.Is(err, errWeatherPlaceNotFound) {
+			jsonError(c, http.StatusBadRequest, "could not find the event's location; set latitude and longitude")
+			return
+		}
+		if err != nil {
+			jsonError(c, http.StatusBadGateway, "could not look up the event's location: "+err.Error())
+			return
+		}
+		p.Latitude, p.Longitude, p.PlaceName = lat, lon, name
+	}
+
+	if err := DB.Save(&p).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save weather plan: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func DeleteWeatherContingency(c *gin.Context) {
+	ev, _ := eventFromContext(c)
+	if err := DB.Where("event_id = ?", ev.ID).Delete(&WeatherContingency{}).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "delete failed: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "weather plan deleted"})
+}
+
+// PublishWeatherContingency sends the plan's announcement now, whether or
+// not the forecast set the plan off.
+func PublishWeatherContingency(c *gin.Context) {
+	userID, _ := getUserIDFromContext(c)
+	ev, _ := eventFromContext(c)
+	p, ok := loadWeatherContingency(c, ev)
+	if !ok {
+		return
+	}
+	if p.AnnouncementTitle == "" {
+		jsonError(c, http.StatusBadRequest, "the plan has no announcement to publish")
+		return
+	}
+	if p.AnnouncementID != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "the announcement was already published",
+			"announcement_id": *p.AnnouncementID,
+		})
+		return
+	}
+
+	a, err := publishContingency(ev, p, userID)
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not publish announcement: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, announcementView(a))
+}
+
+// publishContingency posts the plan's announcement to everyone and
+// records it on the plan.
+func publishContingency(ev Event, p WeatherContingency, authorID uint) (Announcement, error) {
+	a := Announcement{
+		EventID:  ev.ID,
+		AuthorID: authorID,
+		Title:    p.AnnouncementTitle,
+		Body:     p.AnnouncementBody,
+		Audience: AudienceAll,
+	}
+	if err := publishAnnouncement(ev, &a); err != nil {
+		return a, err
+	}
+	DB.Model(&WeatherContingency{}).Where("id = ?", p.ID).Update("announcement_id", a.ID)
+	return a, nil
+}
+
+// ========================
+// CHECKS
+// ========================
+
+// CheckWeatherContingencies fetches the forecast for every armed plan
+// whose event starts within its lead time, and sets off those the
+// forecast breaches.
+func CheckWeatherContingencies(ctx context.Context) {
+	if Weather == nil {
+		return
+	}
+	now := time.Now()
+	var plans []WeatherContingency
+	if err := DB.Joins("JOIN events ON events.id = weather_contingencies.event_id").
+		Where("weather_contingencies.triggered_at IS NULL AND events.cancelled_at IS NULL AND events.deleted_at IS NULL").
+		Where("events.date > ? AND events.date <= ?", now, now.Add(weatherMaxLead*time.Hour)).
+		Find(&plans).Error; err != nil {
+		log.Printf("⚠️ weather contingency check failed: %v", err)
+		return
+	}
+
+	for _, p := range plans {
+		var ev Event
+		if err := DB.First(&ev, p.EventID).Error; err != nil || ev.Date.Sub(now) > time.Duration(p.LeadHours)*time.Hour {
+			continue
+		}
+		f, err := Weather.Forecast(ctx, p.Latitude, p.Longitude, ev.Date, ev.Date.Add(defaultEventDuration))
+		if errors.Is(err, errNoForecast) {
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️ weather forecast for event %d failed: %v", ev.ID, err)
+			continue
+		}
+		DB.Model(&WeatherContingency{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"checked_at":                now,
+			"forecast_rain_probability": f.RainProbability,
+			"forecast_min_temp_c":       f.MinTempC,
+			"forecast_max_temp_c":       f.MaxTempC,
+		})
+
+		if reasons := p.breaches(f); len(reasons) > 0 {
+			triggerWeatherContingency(ev, p, strings.Join(reasons, " and "))
+		}
+	}
+}
+
+// triggerWeatherContingency tells the organizers and, for plans set to,
+// publishes the announcement. The plan is claimed with a conditional
+// update so it only fires once with several instances running.
+func triggerWeatherContingency(ev Event, p WeatherContingency, reason string) {
+	res := DB.Model(&WeatherContingency{}).Where("id = ? AND triggered_at IS NULL", p.ID).
+		Updates(map[string]interface{}{"triggered_at": time.Now(), "trigger_reason": reason})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+
+	next := ""
+	data := gin.H{"event_id": ev.ID, "weather_contingency_id": p.ID}
+	switch {
+	case p.AutoPublish && p.AnnouncementID == nil:
+		a, err := publishContingency(ev, p, p.CreatedByID)
+		if err != nil {
+			log.Printf("⚠️ weather announcement for event %d failed: %v", ev.ID, err)
+			next = "Your contingency announcement could not be sent; publish it yourself."
+			break
+		}
+		next = "Your contingency announcement was sent to attendees."
+		data["announcement_id"] = a.ID
+	case p.AnnouncementTitle != "" && p.AnnouncementID == nil:
+		next = "Your contingency announcement is ready to publish."
+	}
+
+	forecast := strings.ToUpper(reason[:1]) + reason[1:]
+	for _, uid := range eventOrganizerIDs(ev) {
+		NotifyTemplate(uid, "weather_alert", map[string]string{"EventTitle": ev.Title, "Forecast": forecast, "Next": next}, data)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The RSVP widget is embedded on sites the organizer lists for the event.
+// Its routes live under /widget with their own CORS policy: the Origin
+// must be one of the event's widget origins (or the app itself), and
+// requests are rate limited per IP regardless of RATE_LIMIT_ENFORCE.
+
+const maxWidgetOrigins = 10
+
+// WidgetLimiter is separate from RateLimiter so widget traffic neither
+// eats into nor is loosened by the main app's budgets.
+var WidgetLimiter = &rateLimiter{windows: map[string]*rateWindow{}}
+
+// widgetOrigins parses the event's comma separated widget origins.
+func widgetOrigins(ev Event) []string {
+	if ev.WidgetOrigins == "" {
+		return nil
+	}
+	return strings.Split(ev.WidgetOrigins, ",")
+}
+
+func widgetOriginAllowed(ev Event, origin string) bool {
+	if allowedOrigin(origin) {
+		return true
+	}
+	for _, o := range widgetOrigins(ev) {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOrigin reduces a URL to scheme://host[:port], the form browsers
+// send in the Origin header.
+func normalizeOrigin(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", false
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), true
+}
+
+// WidgetRateLimit allows limit requests per minute per IP for a bucket of
+// widget routes, and always enforces it.
+func WidgetRateLimit(bucket string, limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		b, over := WidgetLimiter.take(bucket+":"+c.ClientIP(), limit)
+		b.Enforced = true
+		setRateLimitHeaders(c, b)
+		if over {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(b.ResetAt).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded", "rate_limit": b})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// WidgetMiddleware loads the event behind a widget route and applies its
+// CORS policy. Events without widget origins have no widget. Requests
+// without an Origin (the widget's own iframe page) are let through.
+func WidgetMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		eventID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			jsonError(c, http.StatusBadRequest, "invalid event id")
+			c.Abort()
+			return
+		}
+		var ev Event
+		if err := DB.First(&ev, eventID).Error; err != nil || ev.WidgetOrigins == "" {
+			jsonError(c, http.StatusNotFound, "event not found")
+			c.Abort()
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		if origin != "" {
+			if !widgetOriginAllowed(ev, origin) {
+				jsonError(c, http.StatusForbidden, "origin not allowed")
+				c.Abort()
+				return
+			}
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Headers", "Content-Type, X-Captcha-Token")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Vary", "Origin")
+		}
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Set("widgetEvent", ev)
+		c.Next()
+	}
+}
+
+func widgetEvent(c *gin.Context) Event {
+	return c.MustGet("widgetEvent").(Event)
+}
+
+type widgetHeadcount struct {
+	Going        int64 `json:"going"`
+	MaxAttendees *int  `json:"max_attendees,omitempty"`
+	SpotsLeft    *int  `json:"spots_left,omitempty"`
+	Open         bool  `json:"open"` // whether RSVPs are accepted
+}
+
+func headcountOf(ev Event) widgetHeadcount {
+	h := widgetHeadcount{
+		Going:        goingCount(DB, ev.ID),
+		MaxAttendees: ev.MaxAttendees,
+		Open:         ev.CancelledAt == nil && !eventOver(ev, time.Now()),
+	}
+	if ev.MaxAttendees != nil {
+		left := max(0, *ev.MaxAttendees-int(h.Going))
+		h.SpotsLeft = &left
+	}
+	return h
+}
+
+// GetWidgetEvent is everything the widget renders: the summary card and
+// the current headcount.
+func GetWidgetEvent(c *gin.Context) {
+	ev := widgetEvent(c)
+	card, ok := publicSummaryCard(c, ev)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(http.StatusOK, gin.H{"event": card, "headcount": headcountOf(ev)})
+}
+
+// GetWidgetHeadcount is polled by the widget to keep the count live.
+func GetWidgetHeadcount(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=5")
+	c.JSON(http.StatusOK, headcountOf(widgetEvent(c)))
+}
+
+type WidgetRSVPRequest struct {
+	Name   string `json:"name" binding:"required,max=100"`
+	Email  string `json:"email" binding:"required,email,max=255"`
+	Status string `json:"status" binding:"required"`
+}
+
+// SubmitWidgetRSVP records a guest's answer from the widget. Guests have
+// no account; answering again with the same email updates the answer.
+func SubmitWidgetRSVP(c *gin.Context) {
+	ev := widgetEvent(c)
+
+	var body WidgetRSVPRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	status, ok := normalizeRSVPStatus(body.Status)
+	if !ok {
+		jsonError(c, http.StatusBadRequest, "status must be one of: "+strings.Join(rsvpStatuses, ", "))
+		return
+	}
+	if !headcountOf(ev).Open {
+		jsonError(c, http.StatusConflict, "this event no longer accepts RSVPs")
+		return
+	}
+
+	guest := GuestRSVP{
+		EventID: ev.ID,
+		Name:    strings.TrimSpace(body.Name),
+		Email:   strings.ToLower(strings.TrimSpace(body.Email)),
+		Origin:  c.GetHeader("Origin"),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// lock the event so concurrent RSVPs can't overshoot capacity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ev, ev.ID).Error; err != nil {
+			return err
+		}
+		var existing GuestRSVP
+		found := tx.Where("event_id = ? AND email = ?", ev.ID, guest.Email).First(&existing).Error == nil
+		if status == "Going" && (!found || existing.Status != "Going") {
+			if err := reserveCapacity(tx, capacityScope{ScopeEvent, ev.ID}); err != nil {
+				return err
+			}
+		}
+		if found {
+			guest.ID, guest.CreatedAt = existing.ID, existing.CreatedAt
+		}
+		guest.Status = status
+		return tx.Save(&guest).Error
+	})
+	var full *CapacityError
+	if errors.As(err, &full) {
+		capacityConflict(c, full)
+		return
+	}
+	if err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not save RSVP: "+err.Error())
+		return
+	}
+
+	NotifyBundledTemplate(ev.OrganizerID, "rsvp", fmt.Sprintf("rsvp:%d", ev.ID),
+		map[string]string{"EventTitle": ev.Title, "Name": guest.Name + " (guest)", "Status": guest.Status}, gin.H{"event_id": ev.ID})
+	h := headcountOf(ev)
+	RealtimeHub.BroadcastToEvent(ev.ID, WSMessage{Type: "headcount", EventID: ev.ID, Data: h})
+
+	c.JSON(http.StatusOK, gin.H{"status": guest.Status, "headcount": h})
+}
+
+// ========================
+// ORGANIZER SETTINGS
+// ========================
+
+type WidgetSettingsRequest struct {
+	Origins []string `json:"origins"` // empty disables the widget
+}
+
+// SetEventWidget sets the sites allowed to embed the RSVP widget.
+func SetEventWidget(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var body WidgetSettingsRequest
+	if err := bindJSON(c, &body); err != nil {
+		bindError(c, "invalid body", err)
+		return
+	}
+	if len(body.Origins) > maxWidgetOrigins {
+		jsonError(c, http.StatusBadRequest, "at most 10 origins")
+		return
+	}
+	origins := []string{}
+	seen := map[string]bool{}
+	for _, raw := range body.Origins {
+		o, ok := normalizeOrigin(raw)
+		if !ok {
+			jsonError(c, http.StatusBadRequest, "invalid origin: "+raw)
+			return
+		}
+		if !seen[o] {
+			seen[o] = true
+			origins = append(origins, o)
+		}
+	}
+
+	if err := DB.Model(&Event{}).Where("id = ?", ev.ID).Update("widget_origins", strings.Join(origins, ",")).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "could not update widget: "+err.Error())
+		return
+	}
+	recordChange(DB, EntityEvent, ev.ID, ev.ID, ChangeUpsert)
+	Audit(userID, "event.widget", "event", ev.ID, gin.H{"origins": origins})
+
+	c.JSON(http.StatusOK, gin.H{"event_id": ev.ID, "origins": origins, "enabled": len(origins) > 0})
+}
+
+// GetWidgetRSVPs lists the guests who answered through the widget.
+func GetWidgetRSVPs(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		jsonError(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ev, ok := loadOrganizedEvent(c, userID)
+	if !ok {
+		return
+	}
+
+	var guests []GuestRSVP
+	if err := DB.Where("event_id = ?", ev.ID).Order("created_at asc").Find(&guests).Error; err != nil {
+		jsonError(c, http.StatusInternalServerError, "db error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"origins": widgetOrigins(ev), "guests": guests})
+}